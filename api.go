@@ -0,0 +1,807 @@
+package sketchy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aldernero/sketchy/internal/sketchdb"
+	"github.com/aldernero/sketchy/internal/ws"
+)
+
+// apiCallTimeout bounds how long a request waits for Update to run its
+// work before answering 503.
+const apiCallTimeout = 10 * time.Second
+
+// apiEventBuffer bounds the event messages queued for one WebSocket
+// client; a client that falls this far behind is disconnected.
+const apiEventBuffer = 64
+
+// apiMaxFrameScale bounds the scale of /api/frame.png and of recordings
+// started through the API.
+const apiMaxFrameScale = 8
+
+// apiMaxSaveScale bounds the raster scale of /api/save; larger exports go
+// through the Save Image dialog.
+const apiMaxSaveScale = 16
+
+// apiRecordingFormats are the names the API accepts for RecordingFormat.
+var apiRecordingFormats = map[string]RecordingFormat{
+	"webm": RecordWebM,
+	"mp4":  RecordMP4,
+	"webp": RecordWebP,
+	"ffv1": RecordFFV1,
+}
+
+// apiServer is the HTTP API of Config.HTTPAddr. Handlers run on net/http's
+// goroutines, so anything touching the sketch goes through do, which hands
+// it to Update (updateAPI) like OSC messages. Until Update first runs —
+// httptest servers over APIHandler, headless sketches — do runs the work
+// directly instead, one request at a time.
+type apiServer struct {
+	srv   *http.Server // nil unless started by Init
+	calls chan func()
+	live  atomic.Bool // Update is draining calls
+	// inline serializes work run directly, and guards the switch to live.
+	inline sync.Mutex
+
+	mu   sync.Mutex // guards subs
+	subs map[chan []byte]struct{}
+	// sent is the last value streamed for each control. It belongs to
+	// Update.
+	sent map[string]any
+}
+
+func newAPIServer() *apiServer {
+	return &apiServer{
+		calls: make(chan func()),
+		subs:  make(map[chan []byte]struct{}),
+	}
+}
+
+// do runs fn on the Update goroutine and waits for it to finish.
+func (a *apiServer) do(ctx context.Context, fn func()) error {
+	a.inline.Lock()
+	if !a.live.Load() {
+		defer a.inline.Unlock()
+		fn()
+		return nil
+	}
+	a.inline.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, apiCallTimeout)
+	defer cancel()
+	done := make(chan struct{})
+	select {
+	case a.calls <- func() { fn(); close(done) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// APIHandler returns the handler of the HTTP API that Config.HTTPAddr
+// serves, for mounting in another server or testing with httptest.
+func (s *Sketch) APIHandler() http.Handler {
+	if s.api == nil {
+		s.api = newAPIServer()
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/controls", s.apiListControls)
+	mux.HandleFunc("PATCH /api/controls", s.apiPatchControls)
+	mux.HandleFunc("GET /api/controls/{key...}", s.apiGetControl)
+	mux.HandleFunc("PUT /api/controls/{key...}", s.apiPutControl)
+	mux.HandleFunc("GET /api/state", s.apiGetState)
+	mux.HandleFunc("PUT /api/state", s.apiPutState)
+	mux.HandleFunc("POST /api/save", s.apiSave)
+	mux.HandleFunc("POST /api/snapshot", s.apiSnapshot)
+	mux.HandleFunc("GET /api/recording", s.apiRecordingStatus)
+	mux.HandleFunc("POST /api/recording/start", s.apiStartRecording)
+	mux.HandleFunc("POST /api/recording/stop", s.apiStopRecording)
+	mux.HandleFunc("GET /api/frame.png", s.apiFrame)
+	mux.HandleFunc("GET /api/events", s.apiEvents)
+	return apiGuard(s.HTTPAddr, mux)
+}
+
+// apiGuard keeps web pages from driving the API: the server has no auth,
+// so a request a browser sends from another origin is refused, and a body
+// must be declared JSON, which a cross-site form or simple fetch cannot do
+// without a CORS preflight the server never answers. The Host must name
+// this machine (see apiHostAllowed): a page on a domain rebound to
+// 127.0.0.1 is same-origin with itself, but still sends its own name.
+func apiGuard(addr string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !apiHostAllowed(r.Host, addr) {
+			apiError(w, http.StatusForbidden, fmt.Errorf("host %s may not use this API", r.Host))
+			return
+		}
+		if o := r.Header.Get("Origin"); o != "" {
+			if u, err := url.Parse(o); err != nil || u.Host != r.Host {
+				apiError(w, http.StatusForbidden, fmt.Errorf("origin %s may not use this API", o))
+				return
+			}
+		}
+		if r.ContentLength != 0 && r.Method != http.MethodGet {
+			if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+				apiError(w, http.StatusUnsupportedMediaType, errors.New("request body must be application/json"))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// apiHostAllowed reports whether host, a request's Host header, is a
+// loopback name or address, or the host of addr (HTTPAddr) itself.
+func apiHostAllowed(host, addr string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	h, _, err := net.SplitHostPort(addr)
+	return err == nil && h != "" && strings.EqualFold(h, host)
+}
+
+// startAPI serves the HTTP API on HTTPAddr.
+func (s *Sketch) startAPI() error {
+	ln, err := net.Listen("tcp", s.HTTPAddr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.APIHandler(), ReadHeaderTimeout: 10 * time.Second}
+	s.api.srv = srv
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("sketchy: http: %v", err)
+		}
+	}()
+	log.Printf("sketchy: HTTP API on http://%s/api/", ln.Addr())
+	return nil
+}
+
+// closeAPI stops the server and disconnects every event stream.
+func (s *Sketch) closeAPI() {
+	a := s.api
+	if a == nil {
+		return
+	}
+	if a.srv != nil {
+		a.srv.Close()
+		a.srv = nil
+	}
+	a.mu.Lock()
+	for ch := range a.subs {
+		delete(a.subs, ch)
+		close(ch)
+	}
+	a.mu.Unlock()
+}
+
+// updateAPI runs the work requests have queued since the last tick.
+func (s *Sketch) updateAPI() {
+	a := s.api
+	if a == nil {
+		return
+	}
+	if !a.live.Load() {
+		a.inline.Lock()
+		a.live.Store(true)
+		a.inline.Unlock()
+	}
+	for {
+		select {
+		case fn := <-a.calls:
+			fn()
+		default:
+			return
+		}
+	}
+}
+
+// apiControl describes one control in /api/controls.
+type apiControl struct {
+	Key     string   `json:"key"`
+	Folder  string   `json:"folder,omitempty"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Value   any      `json:"value,omitempty"`
+	Min     any      `json:"min,omitempty"`
+	Max     any      `json:"max,omitempty"`
+	Step    any      `json:"step,omitempty"`
+	Options []string `json:"options,omitempty"`
+	// Modulated is the value a modulated slider reads this tick.
+	Modulated any `json:"modulated,omitempty"`
+}
+
+// apiControls lists the panel's controls in panel order.
+func (s *Sketch) apiControls() []apiControl {
+	out := make([]apiControl, 0, len(s.uiPlan))
+	for _, e := range s.uiPlan {
+		var c apiControl
+		switch e.Kind {
+		case entryFloatSlider:
+			sl := &s.FloatSliders[e.Index]
+			c = apiControl{Folder: sl.Folder, Name: sl.Name, Type: "float", Min: sl.MinVal, Max: sl.MaxVal, Step: sl.Incr}
+		case entryIntSlider:
+			sl := &s.IntSliders[e.Index]
+			c = apiControl{Folder: sl.Folder, Name: sl.Name, Type: "int", Min: sl.MinVal, Max: sl.MaxVal, Step: sl.Incr}
+		case entryToggle:
+			t := &s.Toggles[e.Index]
+			c = apiControl{Folder: t.Folder, Name: t.Name, Type: "checkbox"}
+			if t.IsButton {
+				c.Type = "button"
+			}
+		case entryColor:
+			cp := &s.ColorPickers[e.Index]
+			c = apiControl{Folder: cp.Folder, Name: cp.Name, Type: "color"}
+		case entryDropdown:
+			d := &s.Dropdowns[e.Index]
+			c = apiControl{Folder: d.Folder, Name: d.Name, Type: "dropdown", Options: d.Options}
+		case entryTextBox:
+			t := &s.TextBoxes[e.Index]
+			c = apiControl{Folder: t.Folder, Name: t.Name, Type: "text"}
+		case entryLabel:
+			l := &s.Labels[e.Index]
+			c = apiControl{Folder: l.Folder, Name: l.Name, Type: "label"}
+			if l.Value != nil {
+				c.Value = l.Value()
+			}
+		}
+		c.Key = controlMapKey(c.Folder, c.Name)
+		if v, ok := s.apiValue(c.Key); ok {
+			c.Value = v
+		}
+		if m := s.modulators[c.Key]; m != nil {
+			if c.Type == "float" {
+				c.Modulated = s.GetFloat(c.Folder, c.Name)
+			} else {
+				c.Modulated = s.GetInt(c.Folder, c.Name)
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// apiValue is control k's value as the API reports it: a number for
+// sliders, a bool for checkboxes, "#RRGGBB" for colors, the option index
+// for dropdowns and the text of text boxes. Buttons have none.
+func (s *Sketch) apiValue(k string) (any, bool) {
+	if i, ok := s.floatSliderControlMap[k]; ok {
+		return s.FloatSliders[i].Val, true
+	}
+	if i, ok := s.intSliderControlMap[k]; ok {
+		return s.IntSliders[i].Val, true
+	}
+	if i, ok := s.toggleControlMap[k]; ok && !s.Toggles[i].IsButton {
+		return s.Toggles[i].Checked, true
+	}
+	if i, ok := s.colorPickerControlMap[k]; ok {
+		return s.ColorPickers[i].GetHex(), true
+	}
+	if i, ok := s.dropdownControlMap[k]; ok {
+		return s.Dropdowns[i].Index, true
+	}
+	if i, ok := s.textBoxControlMap[k]; ok {
+		return s.TextBoxes[i].Val, true
+	}
+	return nil, false
+}
+
+// setAPIValue sets control k from a decoded JSON value; an array is the
+// argument list (r, g, b for colors).
+func (s *Sketch) setAPIValue(k string, v any) error {
+	if v == nil {
+		return errors.New("missing value")
+	}
+	args, ok := v.([]any)
+	if !ok {
+		args = []any{v}
+	} else if len(args) == 0 {
+		return errors.New("empty value")
+	}
+	return s.setControl(k, args)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("sketchy: http: %v", err)
+	}
+}
+
+func apiError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// update runs fn through Update, answering 503 if it does not get to run.
+func (s *Sketch) update(w http.ResponseWriter, r *http.Request, fn func()) bool {
+	if err := s.api.do(r.Context(), fn); err != nil {
+		apiError(w, http.StatusServiceUnavailable, fmt.Errorf("sketch did not respond: %w", err))
+		return false
+	}
+	return true
+}
+
+// decodeBody reads a JSON request body into v. An empty body leaves v as
+// it is.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		apiError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func (s *Sketch) apiListControls(w http.ResponseWriter, r *http.Request) {
+	var list []apiControl
+	if s.update(w, r, func() { list = s.apiControls() }) {
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+func (s *Sketch) apiGetControl(w http.ResponseWriter, r *http.Request) {
+	k := r.PathValue("key")
+	var c *apiControl
+	if !s.update(w, r, func() {
+		for _, e := range s.apiControls() {
+			if e.Key == k {
+				c = &e
+				return
+			}
+		}
+	}) {
+		return
+	}
+	if c == nil {
+		apiError(w, http.StatusNotFound, fmt.Errorf("no control %q", k))
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Sketch) apiPutControl(w http.ResponseWriter, r *http.Request) {
+	k := r.PathValue("key")
+	var body struct {
+		Value any `json:"value"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	var err error
+	var v any
+	found := true
+	if !s.update(w, r, func() {
+		if found = s.hasControl(splitControlKey(k)); found {
+			if err = s.setAPIValue(k, body.Value); err == nil {
+				v, _ = s.apiValue(k)
+			}
+		}
+	}) {
+		return
+	}
+	switch {
+	case !found:
+		apiError(w, http.StatusNotFound, fmt.Errorf("no control %q", k))
+	case err != nil:
+		apiError(w, http.StatusBadRequest, err)
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"key": k, "value": v})
+	}
+}
+
+// apiPatchControls sets several controls in the same tick, from a map of
+// key to value. Each is set as by PUT; failures are reported together.
+func (s *Sketch) apiPatchControls(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	var errs []error
+	values := make(map[string]any)
+	if !s.update(w, r, func() {
+		for k, v := range body {
+			if err := s.setAPIValue(k, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", k, err))
+			} else if v, ok := s.apiValue(k); ok {
+				values[k] = v
+			}
+		}
+	}) {
+		return
+	}
+	if len(errs) > 0 {
+		apiError(w, http.StatusBadRequest, errors.Join(errs...))
+		return
+	}
+	writeJSON(w, http.StatusOK, values)
+}
+
+// apiState is the body of /api/state: the JSON a snapshot stores.
+type apiState struct {
+	Controls json.RawMessage `json:"controls"`
+	Builtins json.RawMessage `json:"builtins,omitempty"`
+}
+
+func (s *Sketch) apiGetState(w http.ResponseWriter, r *http.Request) {
+	var st apiState
+	var err error
+	if !s.update(w, r, func() {
+		if st.Controls, err = s.serializeControlState(); err == nil {
+			st.Builtins, err = s.serializeBuiltinState()
+		}
+	}) {
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Sketch) apiPutState(w http.ResponseWriter, r *http.Request) {
+	var st apiState
+	if !decodeBody(w, r, &st) {
+		return
+	}
+	if len(st.Controls) == 0 {
+		apiError(w, http.StatusBadRequest, errors.New("missing controls"))
+		return
+	}
+	var missing []string
+	var err error
+	if !s.update(w, r, func() {
+		if missing, err = s.applyControlStateJSON(st.Controls); err == nil && len(st.Builtins) > 0 {
+			err = s.applyBuiltinStateJSON(st.Builtins)
+		}
+	}) {
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"missing": missing})
+}
+
+func (s *Sketch) apiSave(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name    string   `json:"name"`
+		Formats []string `json:"formats"`
+		Scale   float64  `json:"scale"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	if body.Scale < 0 || body.Scale > apiMaxSaveScale {
+		apiError(w, http.StatusBadRequest, fmt.Errorf("scale must be in (0, %d]", apiMaxSaveScale))
+		return
+	}
+	if len(body.Formats) == 0 {
+		body.Formats = []string{"png"}
+	}
+	var paths []string
+	var err error
+	if !s.update(w, r, func() {
+		scale := body.Scale
+		if scale <= 0 {
+			scale = s.RasterDPI / DefaultDPI
+		}
+		paths, err = s.saveDesign(body.Name, body.Formats, scale)
+	}) {
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"queued": paths})
+}
+
+func (s *Sketch) apiSnapshot(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		PNG         bool     `json:"png"`
+		SVG         bool     `json:"svg"`
+		PDF         bool     `json:"pdf"`
+		Tags        []string `json:"tags"`
+		Rating      int      `json:"rating"`
+		Favorite    bool     `json:"favorite"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	if err := checkSaveName(strings.TrimSpace(body.Name)); err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Rating < 0 || body.Rating > sketchdb.MaxRating {
+		apiError(w, http.StatusBadRequest, fmt.Errorf("rating %d is outside 0-%d", body.Rating, sketchdb.MaxRating))
+		return
+	}
+	var name string
+	var err error
+	if !s.update(w, r, func() {
+		name, err = s.takeSnapshot(body.Name, body.Description, body.PNG, body.SVG, body.PDF)
+		if err == nil {
+			err = s.labelSnapshot(name, body.Tags, body.Rating, body.Favorite)
+		}
+	}) {
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"name": name})
+}
+
+// apiRecording is the body of /api/recording.
+type apiRecording struct {
+	Recording bool   `json:"recording"`
+	Armed     bool   `json:"armed"`
+	Frames    int64  `json:"frames"`
+	Path      string `json:"path,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+func (s *Sketch) recordingStatus() apiRecording {
+	st := apiRecording{Recording: s.IsRecording(), Frames: s.RecordingFrameCount(), Status: s.recStatus}
+	if s.vrec != nil {
+		st.Armed = s.vrec.state == recArmed
+		st.Path = s.vrec.outPath
+	}
+	return st
+}
+
+func (s *Sketch) apiRecordingStatus(w http.ResponseWriter, r *http.Request) {
+	var st apiRecording
+	if s.update(w, r, func() { st = s.recordingStatus() }) {
+		writeJSON(w, http.StatusOK, st)
+	}
+}
+
+// apiStartRecording starts a recording with the Builtins Recording
+// settings, overridden by the fields given: format (webm, mp4, webp or
+// ffv1), fps, scale, frames, or loop to arm a perfect loop of that many
+// ticks.
+func (s *Sketch) apiStartRecording(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Format string  `json:"format"`
+		FPS    int     `json:"fps"`
+		Scale  float64 `json:"scale"`
+		Frames int64   `json:"frames"`
+		Loop   int64   `json:"loop"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	if body.Scale < 0 || body.Scale > apiMaxFrameScale {
+		apiError(w, http.StatusBadRequest, fmt.Errorf("scale must be in (0, %d]", apiMaxFrameScale))
+		return
+	}
+	var st apiRecording
+	var err error
+	status := http.StatusBadRequest
+	if !s.update(w, r, func() {
+		opts := s.recordingOptionsFromPanel()
+		if body.Format != "" {
+			f, ok := apiRecordingFormats[body.Format]
+			if !ok {
+				err = fmt.Errorf("unknown recording format %q", body.Format)
+				return
+			}
+			opts.Format = f
+		}
+		if body.FPS > 0 {
+			opts.FPS = body.FPS
+		}
+		if body.Scale > 0 {
+			opts.Scale = body.Scale
+		}
+		opts.NumFrames = body.Frames
+		if body.Loop > 0 {
+			opts.StartModulus, opts.NumFrames = body.Loop, body.Loop
+		}
+		if s.vrec != nil {
+			err, status = errors.New("a recording is already in progress"), http.StatusConflict
+			return
+		}
+		if err = s.StartRecording(opts); err == nil {
+			st = s.recordingStatus()
+		}
+	}) {
+		return
+	}
+	if err != nil {
+		apiError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Sketch) apiStopRecording(w http.ResponseWriter, r *http.Request) {
+	var st apiRecording
+	if s.update(w, r, func() {
+		s.StopRecording()
+		st = s.recordingStatus()
+	}) {
+		writeJSON(w, http.StatusOK, st)
+	}
+}
+
+// apiFrame answers with the current frame as PNG, at ?scale= times the
+// sketch size (default 1).
+func (s *Sketch) apiFrame(w http.ResponseWriter, r *http.Request) {
+	scale := 1.0
+	if v := r.URL.Query().Get("scale"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > apiMaxFrameScale {
+			apiError(w, http.StatusBadRequest, fmt.Errorf("scale must be in (0, %d]", apiMaxFrameScale))
+			return
+		}
+		scale = f
+	}
+	var img image.Image
+	if !s.update(w, r, func() {
+		// GPU readback must happen on the ebiten thread; a vector frame is
+		// replayed from its recording here too, so it is the current one.
+		if s.usesGPUCanvas() {
+			img = s.CaptureGPUImage()
+		} else {
+			img = s.rasterizeRecording(DefaultDPI * scale)
+		}
+	}) {
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, img); err != nil {
+		log.Printf("sketchy: http frame: %v", err)
+	}
+}
+
+// apiEvent is a message of the /api/events stream: the controls whose
+// value changed on a tick, keyed like /api/controls. The first message
+// carries every control.
+type apiEvent struct {
+	Tick     int64          `json:"tick"`
+	Controls map[string]any `json:"controls"`
+}
+
+// apiEvents streams control changes over a WebSocket.
+func (s *Sketch) apiEvents(w http.ResponseWriter, r *http.Request) {
+	ch := make(chan []byte, apiEventBuffer)
+	if !s.update(w, r, func() { s.subscribeAPI(ch) }) {
+		return
+	}
+	a := s.api
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		a.unsubscribe(ch)
+		return
+	}
+	defer conn.Close()
+	go func() {
+		// Incoming messages are ignored; reading answers pings and notices
+		// the client leaving.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				a.unsubscribe(ch)
+				return
+			}
+		}
+	}()
+	for msg := range ch {
+		if err := conn.WriteText(msg); err != nil {
+			a.unsubscribe(ch)
+			return
+		}
+	}
+}
+
+// subscribeAPI adds an event stream, starting it with every control's
+// value. Runs on the Update goroutine.
+func (s *Sketch) subscribeAPI(ch chan []byte) {
+	a := s.api
+	state := make(map[string]any)
+	for _, k := range s.controlKeys() {
+		if v, ok := s.apiValue(k); ok {
+			state[k] = v
+		}
+	}
+	b, err := json.Marshal(apiEvent{Tick: s.Tick, Controls: state})
+	if err != nil {
+		log.Printf("sketchy: http events: %v", err)
+		return
+	}
+	ch <- b
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Streams already open keep their baseline, so a change this tick still
+	// reaches them.
+	if len(a.subs) == 0 {
+		a.sent = state
+	}
+	a.subs[ch] = struct{}{}
+}
+
+// unsubscribe ends an event stream, if it has not ended already.
+func (a *apiServer) unsubscribe(ch chan []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.subs[ch]; ok {
+		delete(a.subs, ch)
+		close(ch)
+	}
+}
+
+// publishAPI streams the controls whose value changed since they were last
+// streamed.
+func (s *Sketch) publishAPI() {
+	a := s.api
+	if a == nil || !s.DidControlsChange {
+		return
+	}
+	a.mu.Lock()
+	n := len(a.subs)
+	a.mu.Unlock()
+	if n == 0 {
+		return
+	}
+	changed := make(map[string]any)
+	for _, k := range s.controlKeys() {
+		if v, ok := s.apiValue(k); ok {
+			if prev, seen := a.sent[k]; !seen || prev != v {
+				a.sent[k] = v
+				changed[k] = v
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	b, err := json.Marshal(apiEvent{Tick: s.Tick, Controls: changed})
+	if err != nil {
+		log.Printf("sketchy: http events: %v", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for ch := range a.subs {
+		select {
+		case ch <- b:
+		default:
+			log.Printf("sketchy: http events: dropped a client that fell behind")
+			delete(a.subs, ch)
+			close(ch)
+		}
+	}
+}
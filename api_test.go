@@ -0,0 +1,331 @@
+package sketchy
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/sketchdb"
+	"github.com/aldernero/sketchy/internal/ws"
+)
+
+func newAPISketch(t *testing.T) (*Sketch, *httptest.Server) {
+	t.Helper()
+	s := New(Config{SketchWidth: 100, SketchHeight: 80})
+	s.BuildUI = func(_ *Sketch, ui *UI) {
+		ui.Folder("Noise", func() {
+			ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+		})
+		ui.IntSlider("Count", 0, 10, 5, 1)
+		ui.Checkbox("Fill", false)
+		ui.ColorPicker("Tint", "#000000")
+		ui.Dropdown("Mode", []string{"lines", "dots"}, 0)
+	}
+	s.rebuildControls()
+	s.recorder = render.NewRecorder(s.SketchWidth, s.SketchHeight)
+	srv := httptest.NewServer(s.APIHandler())
+	t.Cleanup(func() {
+		s.closeAPI()
+		srv.Close()
+	})
+	return s, srv
+}
+
+// call sends a request with a JSON body and decodes the JSON answer into
+// out, returning the status.
+func call(t *testing.T, method, url string, body, out any) int {
+	t.Helper()
+	var b bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&b).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIControls(t *testing.T) {
+	s, srv := newAPISketch(t)
+	var list []apiControl
+	if code := call(t, "GET", srv.URL+"/api/controls", nil, &list); code != 200 || len(list) != 5 {
+		t.Fatalf("list: %d, %+v", code, list)
+	}
+	if c := list[0]; c.Key != "Noise/Scale" || c.Type != "float" || c.Min != 0.0 || c.Max != 10.0 || c.Value != 1.0 {
+		t.Errorf("float slider %+v", c)
+	}
+	if c := list[4]; c.Type != "dropdown" || len(c.Options) != 2 {
+		t.Errorf("dropdown %+v", c)
+	}
+
+	var got map[string]any
+	if code := call(t, "PUT", srv.URL+"/api/controls/Noise/Scale", map[string]any{"value": 3.5}, &got); code != 200 || got["value"] != 3.5 {
+		t.Fatalf("put scale: %d, %v", code, got)
+	}
+	if s.FloatSliders[0].Val != 3.5 {
+		t.Errorf("scale %v", s.FloatSliders[0].Val)
+	}
+	patch := map[string]any{"Count": 42, "Fill": true, "Tint": []float64{1, 0.5, 0}, "Mode": "dots"}
+	if code := call(t, "PATCH", srv.URL+"/api/controls", patch, &got); code != 200 {
+		t.Fatalf("patch: %d, %v", code, got)
+	}
+	if s.IntSliders[0].Val != 10 || !s.Toggles[0].Checked || s.ColorPickers[0].GetHex() != "#FF8000" || s.Dropdowns[0].Index != 1 {
+		t.Errorf("patched %v %v %s %d", s.IntSliders[0].Val, s.Toggles[0].Checked, s.ColorPickers[0].GetHex(), s.Dropdowns[0].Index)
+	}
+
+	var c apiControl
+	if code := call(t, "GET", srv.URL+"/api/controls/Count", nil, &c); code != 200 || c.Value != 10.0 {
+		t.Errorf("get count: %d, %+v", code, c)
+	}
+	if code := call(t, "PUT", srv.URL+"/api/controls/Nope", map[string]any{"value": 1}, &got); code != 404 {
+		t.Errorf("unknown control: %d", code)
+	}
+	if code := call(t, "PUT", srv.URL+"/api/controls/Mode", map[string]any{"value": "spirals"}, &got); code != 400 {
+		t.Errorf("bad option: %d", code)
+	}
+}
+
+func TestAPIState(t *testing.T) {
+	s, srv := newAPISketch(t)
+	var st apiState
+	if code := call(t, "GET", srv.URL+"/api/state", nil, &st); code != 200 {
+		t.Fatalf("get state: %d", code)
+	}
+	s.FloatSliders[0].Val = 9
+	var got map[string]any
+	if code := call(t, "PUT", srv.URL+"/api/state", st, &got); code != 200 {
+		t.Fatalf("put state: %d, %v", code, got)
+	}
+	if s.FloatSliders[0].Val != 1 {
+		t.Errorf("restored scale %v", s.FloatSliders[0].Val)
+	}
+}
+
+func TestAPIRejectsBadRequests(t *testing.T) {
+	_, srv := newAPISketch(t)
+	var got map[string]any
+	if code := call(t, "POST", srv.URL+"/api/save", map[string]any{"formats": []string{"bmp"}}, &got); code != 400 {
+		t.Errorf("unknown save format: %d, %v", code, got)
+	}
+	if code := call(t, "POST", srv.URL+"/api/recording/start", map[string]any{"format": "avi"}, &got); code != 400 {
+		t.Errorf("unknown recording format: %d, %v", code, got)
+	}
+	if code := call(t, "GET", srv.URL+"/api/frame.png?scale=100", nil, &got); code != 400 {
+		t.Errorf("frame scale: %d", code)
+	}
+	if code := call(t, "POST", srv.URL+"/api/save", map[string]any{"scale": 1000}, &got); code != 400 {
+		t.Errorf("save scale: %d, %v", code, got)
+	}
+	if code := call(t, "POST", srv.URL+"/api/recording/start", map[string]any{"scale": 1000}, &got); code != 400 {
+		t.Errorf("recording scale: %d, %v", code, got)
+	}
+	for _, name := range []string{"../../../../tmp/x", `..\x`, ".."} {
+		if code := call(t, "POST", srv.URL+"/api/save", map[string]any{"name": name}, &got); code != 400 {
+			t.Errorf("save name %q: %d, %v", name, code, got)
+		}
+		if code := call(t, "POST", srv.URL+"/api/snapshot", map[string]any{"name": name}, &got); code != 400 {
+			t.Errorf("snapshot name %q: %d, %v", name, code, got)
+		}
+	}
+	var rec apiRecording
+	if code := call(t, "GET", srv.URL+"/api/recording", nil, &rec); code != 200 || rec.Recording {
+		t.Errorf("recording status: %d, %+v", code, rec)
+	}
+}
+
+// TestAPIRefusesBrowsers checks that a web page cannot drive the API:
+// another origin is refused everywhere, and a body must be JSON.
+func TestAPIRefusesBrowsers(t *testing.T) {
+	_, srv := newAPISketch(t)
+	send := func(method, path, contentType, origin, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	const value = `{"value": 2}`
+	if code := send("PUT", "/api/controls/Count", "text/plain", "", value); code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain body: %d", code)
+	}
+	if code := send("PUT", "/api/controls/Count", "application/json", "http://evil.example", value); code != http.StatusForbidden {
+		t.Errorf("foreign origin: %d", code)
+	}
+	if code := send("GET", "/api/events", "", "http://evil.example", ""); code != http.StatusForbidden {
+		t.Errorf("foreign origin on events: %d", code)
+	}
+	if code := send("PUT", "/api/controls/Count", "application/json; charset=utf-8", srv.URL, value); code != http.StatusOK {
+		t.Errorf("same origin: %d", code)
+	}
+	if code := send("POST", "/api/recording/stop", "", "", ""); code != http.StatusOK {
+		t.Errorf("empty body: %d", code)
+	}
+
+	// A page on a domain rebound to 127.0.0.1 is same-origin with itself.
+	req, err := http.NewRequest("PUT", srv.URL+"/api/controls/Count", strings.NewReader(value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "evil.example"
+	req.Header.Set("Origin", "http://evil.example")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("rebound host: %d", resp.StatusCode)
+	}
+}
+
+func TestAPIHostAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		host, addr string
+		want       bool
+	}{
+		{"localhost:8080", ":8080", true},
+		{"127.0.0.1:8080", ":8080", true},
+		{"[::1]:8080", ":8080", true},
+		{"sketch.localhost", ":8080", true},
+		{"evil.example:8080", ":8080", false},
+		{"192.168.1.5:8080", ":8080", false},
+		{"192.168.1.5:8080", "192.168.1.5:8080", true},
+		{"studio.lan", "studio.lan:8080", true},
+	} {
+		if got := apiHostAllowed(tc.host, tc.addr); got != tc.want {
+			t.Errorf("apiHostAllowed(%q, %q) = %v", tc.host, tc.addr, got)
+		}
+	}
+}
+
+func TestAPIFrame(t *testing.T) {
+	_, srv := newAPISketch(t)
+	resp, err := http.Get(srv.URL + "/api/frame.png?scale=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("content type %q", ct)
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 160 {
+		t.Fatalf("frame is %v", b)
+	}
+}
+
+func TestAPIEvents(t *testing.T) {
+	s, srv := newAPISketch(t)
+	c, err := ws.Dial("ws" + strings.TrimPrefix(srv.URL, "http") + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	next := func() apiEvent {
+		t.Helper()
+		_, b, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ev apiEvent
+		if err := json.Unmarshal(b, &ev); err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+	if ev := next(); ev.Controls["Noise/Scale"] != 1.0 || ev.Controls["Count"] != 5.0 {
+		t.Fatalf("initial state %v", ev.Controls)
+	}
+
+	s.FloatSliders[0].Val = 7
+	s.Tick = 3
+	s.DidControlsChange = true
+	s.publishAPI()
+	if ev := next(); ev.Tick != 3 || len(ev.Controls) != 1 || ev.Controls["Noise/Scale"] != 7.0 {
+		t.Fatalf("change %+v", ev)
+	}
+}
+
+func TestAPIRunsOnUpdate(t *testing.T) {
+	s, srv := newAPISketch(t)
+	stop := make(chan struct{})
+	defer close(stop)
+	s.updateAPI() // from now on requests wait for Update
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				s.updateAPI()
+			}
+		}
+	}()
+	var got map[string]any
+	if code := call(t, "PUT", srv.URL+"/api/controls/Count", map[string]any{"value": 7}, &got); code != 200 || got["value"] != 7.0 {
+		t.Fatalf("put count: %d, %v", code, got)
+	}
+}
+
+func TestAPISnapshotLabels(t *testing.T) {
+	s, srv := newAPISketch(t)
+	s.workDir = t.TempDir()
+	db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s.db = db
+
+	body := map[string]any{"name": "blue grid", "tags": []string{"blue", "grid"}, "rating": 4, "favorite": true}
+	var got map[string]string
+	if code := call(t, "POST", srv.URL+"/api/snapshot", body, &got); code != http.StatusCreated || got["name"] != "blue grid" {
+		t.Fatalf("snapshot: %d, %v", code, got)
+	}
+	list := s.dbListSnapshots(sketchdb.SnapshotFilter{Tag: "grid", MinRating: 4, Favorites: true})
+	if len(list) != 1 || list[0].Name != "blue grid" {
+		t.Fatalf("listed %+v", list)
+	}
+	if code := call(t, "POST", srv.URL+"/api/snapshot", map[string]any{"rating": 6}, nil); code != http.StatusBadRequest {
+		t.Fatalf("rating 6: %d", code)
+	}
+}
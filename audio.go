@@ -0,0 +1,247 @@
+package sketchy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/aldernero/sketchy/internal/audio"
+	ebitenaudio "github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+// AudioBandCount is the number of frequency bands in AudioFrame.Bands.
+const AudioBandCount = audio.BandCount
+
+// audioDriftLimit is how far, in seconds, the speakers may drift from the
+// tick clock before the player is moved back onto it.
+const audioDriftLimit = 0.1
+
+// AudioFrame is the loaded audio at the current tick (see Sketch.Audio).
+// All of it comes from analysing the file up front, so a tick always sees
+// the same values whatever the frame rate or playback timing.
+type AudioFrame struct {
+	// Time is the playhead in seconds.
+	Time float64
+	// Bands is the energy in each band, from bass (40 Hz) to treble
+	// (16 kHz) in even pitch steps: 1 at the band's loudest in the file, 0
+	// at 60 dB below that.
+	Bands [AudioBandCount]float64
+	// RMS is the root mean square amplitude, 1 for a full-scale square
+	// wave. Level is RMS on the same scale as Bands.
+	RMS   float64
+	Level float64
+	// Onset reports a beat or note starting at this tick.
+	Onset bool
+	// Pulse is 1 at an onset and halves every 0.1 s after it: a smooth
+	// envelope for beat-driven motion.
+	Pulse float64
+	// Playing reports whether the playhead is moving.
+	Playing bool
+}
+
+// audioTrack is the loaded audio file, its analysis and its playhead. The
+// playhead advances one frame per tick while playing: 1/60 s live, or one
+// frame at the recording's FPS while recording, so videos stay in step with
+// the file. The speakers follow the playhead, not the other way round.
+type audioTrack struct {
+	path string
+	clip *audio.Clip
+	// frames holds the analysis by frame rate: the tick rate, plus the FPS
+	// of any recording made.
+	frames  map[int][]audio.Frame
+	pos     float64 // playhead, in seconds
+	cur     AudioFrame
+	playing bool
+	// player plays the file live; made on first play, and never again if
+	// that fails (e.g. no audio device).
+	player   *ebitenaudio.Player
+	noPlayer bool
+}
+
+// analysis is the per-frame analysis at fps, computed on first use.
+func (a *audioTrack) analysis(fps int) []audio.Frame {
+	f, ok := a.frames[fps]
+	if !ok {
+		f = audio.Analyze(a.clip, float64(fps))
+		a.frames[fps] = f
+	}
+	return f
+}
+
+// frameAt is the analysis at the playhead, at fps frames per second.
+func (a *audioTrack) frameAt(fps int) AudioFrame {
+	af := AudioFrame{Time: a.pos, Playing: a.playing}
+	frames := a.analysis(fps)
+	if i := int(math.Round(a.pos * float64(fps))); i < len(frames) {
+		f := frames[i]
+		af.Bands, af.RMS, af.Level, af.Onset, af.Pulse = f.Bands, f.RMS, f.Level, f.Onset, f.Pulse
+	}
+	return af
+}
+
+// LoadAudio decodes a WAV, FLAC or MP3 file (relative paths resolve
+// against the working directory), analyses it and starts playing it from
+// the start on the next tick. It replaces any audio already loaded.
+func (s *Sketch) LoadAudio(path string) error {
+	full := path
+	if !filepath.IsAbs(full) && s.workDir != "" {
+		full = filepath.Join(s.workDir, full)
+	}
+	clip, err := audio.Load(full)
+	if err != nil {
+		return err
+	}
+	s.closeAudio()
+	a := &audioTrack{path: path, clip: clip, frames: make(map[int][]audio.Frame), playing: true}
+	a.cur = a.frameAt(s.audioFPS())
+	s.audio = a
+	s.dirty = true
+	return nil
+}
+
+// closeAudio stops and drops the loaded audio, if any.
+func (s *Sketch) closeAudio() {
+	if s.audio == nil {
+		return
+	}
+	if p := s.audio.player; p != nil {
+		if err := p.Close(); err != nil {
+			log.Printf("sketchy: audio: %v", err)
+		}
+	}
+	s.audio = nil
+}
+
+// Audio is the loaded audio's analysis at the current tick; the zero
+// AudioFrame without audio. Shader sketches get the same values as the
+// AudioBands, AudioLevel, AudioPulse and AudioTime builtin uniforms.
+func (s *Sketch) Audio() AudioFrame {
+	if s.audio == nil {
+		return AudioFrame{}
+	}
+	return s.audio.cur
+}
+
+// AudioDuration is the loaded audio's length in seconds, 0 without audio.
+func (s *Sketch) AudioDuration() float64 {
+	if s.audio == nil {
+		return 0
+	}
+	return s.audio.clip.Duration()
+}
+
+// PlayAudio starts or pauses playback from the current playhead. Playing
+// from the end restarts the file.
+func (s *Sketch) PlayAudio(play bool) {
+	a := s.audio
+	if a == nil {
+		return
+	}
+	if play && a.pos >= a.clip.Duration() {
+		a.pos = 0
+	}
+	a.playing = play
+}
+
+// SeekAudio moves the playhead to seconds into the file.
+func (s *Sketch) SeekAudio(seconds float64) {
+	a := s.audio
+	if a == nil {
+		return
+	}
+	a.pos = clampFloat(seconds, 0, a.clip.Duration())
+	a.cur = a.frameAt(s.audioFPS())
+	s.dirty = true
+}
+
+// audioFPS is the rate the audio playhead advances at: one frame per tick,
+// at the recording's FPS while one is running, else the nominal tick rate.
+func (s *Sketch) audioFPS() int {
+	if r := s.vrec; r != nil && r.state != recFinalizing {
+		return r.opts.FPS
+	}
+	return int(shaderTimeTPS)
+}
+
+// updateAudio runs once per tick before the timeline and Updater: it
+// publishes the analysis at the playhead for this tick, then advances the
+// playhead. live keeps the speakers in step; headless renders pass false.
+func (s *Sketch) updateAudio(live bool) {
+	a := s.audio
+	if a == nil {
+		return
+	}
+	fps := s.audioFPS()
+	a.cur = a.frameAt(fps)
+	if live {
+		// Recording ticks run as fast as the encoder allows, not in real
+		// time, so the speakers stay quiet until it stops.
+		a.syncPlayer(s.vrec != nil)
+	}
+	if !a.playing {
+		return
+	}
+	s.dirty = true
+	a.pos += 1 / float64(fps)
+	if d := a.clip.Duration(); a.pos >= d {
+		a.pos, a.playing = d, false
+	}
+}
+
+// syncPlayer plays, pauses or moves the player to match the playhead.
+func (a *audioTrack) syncPlayer(mute bool) {
+	if !a.playing || mute {
+		if a.player != nil && a.player.IsPlaying() {
+			a.player.Pause()
+		}
+		return
+	}
+	if a.player == nil {
+		if a.noPlayer {
+			return
+		}
+		p, err := a.newPlayer()
+		if err != nil {
+			log.Printf("sketchy: audio playback: %v", err)
+			a.noPlayer = true
+			return
+		}
+		a.player = p
+	}
+	at := time.Duration(a.pos * float64(time.Second))
+	if !a.player.IsPlaying() || math.Abs((a.player.Position()-at).Seconds()) > audioDriftLimit {
+		if err := a.player.SetPosition(at); err != nil {
+			log.Printf("sketchy: audio playback: %v", err)
+		}
+	}
+	if !a.player.IsPlaying() {
+		a.player.Play()
+	}
+}
+
+// newPlayer makes an ebiten player for the clip, resampled to the audio
+// context's rate. The context is created at the clip's rate if the sketch
+// has none yet; ebiten allows only one.
+func (a *audioTrack) newPlayer() (*ebitenaudio.Player, error) {
+	ctx := ebitenaudio.CurrentContext()
+	if ctx == nil {
+		ctx = ebitenaudio.NewContext(a.clip.SampleRate)
+	}
+	// ebiten plays interleaved stereo float32.
+	c := a.clip
+	pcm := make([]byte, c.Frames()*8)
+	for i := range c.Frames() {
+		l := c.Samples[i*c.Channels]
+		r := l
+		if c.Channels > 1 {
+			r = c.Samples[i*c.Channels+1]
+		}
+		binary.LittleEndian.PutUint32(pcm[i*8:], math.Float32bits(l))
+		binary.LittleEndian.PutUint32(pcm[i*8+4:], math.Float32bits(r))
+	}
+	src := ebitenaudio.ResampleReaderF32(bytes.NewReader(pcm), int64(len(pcm)), c.SampleRate, ctx.SampleRate())
+	return ctx.NewPlayerF32(src)
+}
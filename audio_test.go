@@ -0,0 +1,174 @@
+package sketchy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClickWAV writes a mono 16-bit WAV of seconds of silence with a short
+// noise burst at each of clicks (in seconds), returning its path.
+func writeClickWAV(t *testing.T, seconds float64, clicks ...float64) string {
+	t.Helper()
+	const rate = 44100
+	pcm := make([]int16, int(seconds*rate))
+	noise := uint32(1)
+	for _, c := range clicks {
+		for i := int(c * rate); i < min(int((c+0.02)*rate), len(pcm)); i++ {
+			noise = noise*1664525 + 1013904223
+			pcm[i] = int16(noise >> 16)
+		}
+	}
+	var b bytes.Buffer
+	le := func(v any) { binary.Write(&b, binary.LittleEndian, v) }
+	b.WriteString("RIFF")
+	le(uint32(36 + 2*len(pcm)))
+	b.WriteString("WAVEfmt ")
+	le([]uint32{16})
+	le([]uint16{1, 1})
+	le([]uint32{rate, 2 * rate})
+	le([]uint16{2, 16})
+	b.WriteString("data")
+	le(uint32(2 * len(pcm)))
+	le(pcm)
+	path := filepath.Join(t.TempDir(), "clicks.wav")
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// audioTicks runs n ticks of the audio clock and returns what Audio
+// reported on each.
+func audioTicks(s *Sketch, n int) []AudioFrame {
+	var out []AudioFrame
+	for range n {
+		s.updateAudio(false)
+		out = append(out, s.Audio())
+	}
+	return out
+}
+
+// onsetTicks lists the ticks with an onset.
+func onsetTicks(frames []AudioFrame) []int {
+	var out []int
+	for i, f := range frames {
+		if f.Onset {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func TestAudioPlayback(t *testing.T) {
+	s := New(Config{SketchWidth: 100, SketchHeight: 100})
+	if s.Audio() != (AudioFrame{}) {
+		t.Fatal("audio frame without audio")
+	}
+	if err := s.LoadAudio(writeClickWAV(t, 1, 0.25, 0.75)); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.AudioDuration(); d != 1 {
+		t.Fatalf("duration %v", d)
+	}
+
+	frames := audioTicks(s, 70)
+	for i, f := range frames[:60] {
+		if math.Abs(f.Time-float64(i)/60) > 1e-9 || !f.Playing {
+			t.Fatalf("tick %d: time %v, playing %v", i, f.Time, f.Playing)
+		}
+	}
+	// Playback stops at the end of the file.
+	if f := frames[69]; f.Playing || f.Time != 1 {
+		t.Fatalf("after the end: time %v, playing %v", f.Time, f.Playing)
+	}
+	got := onsetTicks(frames)
+	if len(got) != 2 || math.Abs(float64(got[0]-15)) > 1 || math.Abs(float64(got[1]-45)) > 1 {
+		t.Fatalf("onsets at ticks %v, want about 15 and 45", got)
+	}
+	if f := frames[got[0]]; f.Pulse != 1 || f.Level < 0.9 {
+		t.Fatalf("onset pulse %v, level %v", f.Pulse, f.Level)
+	}
+
+	// Paused, the playhead holds; a seek lands on the frame it names.
+	s.SeekAudio(0.5)
+	s.PlayAudio(false)
+	for _, f := range audioTicks(s, 3) {
+		if f.Time != 0.5 || f.Playing {
+			t.Fatalf("paused at %v, playing %v", f.Time, f.Playing)
+		}
+	}
+	s.PlayAudio(true)
+	if f := audioTicks(s, 2)[1]; math.Abs(f.Time-(0.5+1.0/60)) > 1e-9 {
+		t.Fatalf("resumed at %v", f.Time)
+	}
+}
+
+func TestAudioFollowsRecordingFPS(t *testing.T) {
+	s := New(Config{SketchWidth: 100, SketchHeight: 100})
+	if err := s.LoadAudio(writeClickWAV(t, 1, 0.25, 0.75)); err != nil {
+		t.Fatal(err)
+	}
+	startMemRecording(t, s, RecordingOptions{FPS: 30})
+	frames := audioTicks(s, 30)
+	if err := s.FinishRecording(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// One tick is one video frame, so the file advances 1/30 s per tick
+	// and the clicks land on the frames that show them.
+	if f := frames[29]; math.Abs(f.Time-29.0/30) > 1e-9 {
+		t.Fatalf("tick 29 at %v", f.Time)
+	}
+	got := onsetTicks(frames)
+	if len(got) != 2 || math.Abs(float64(got[0])-7.5) > 1 || math.Abs(float64(got[1])-22.5) > 1 {
+		t.Fatalf("onsets at frames %v, want about 7.5 and 22.5", got)
+	}
+	if f := audioTicks(s, 1)[0]; math.Abs(f.Time-1) > 1e-9 {
+		t.Fatalf("after recording at %v", f.Time)
+	}
+}
+
+func TestAudioShaderUniforms(t *testing.T) {
+	us, err := parseShaderUniforms([]byte(`package main
+
+var AudioBands [8]float
+var AudioLevel float
+var AudioPulse float
+var Other [4]float
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range us {
+		if isBuiltinUniform(u) != (u.Name != "Other") {
+			t.Fatalf("%s [%d]%s builtin: %v", u.Name, u.Len, u.Kind, isBuiltinUniform(u))
+		}
+	}
+
+	s := New(Config{SketchWidth: 100, SketchHeight: 100})
+	s.shaderUniforms = us
+	s.recomputeShaderTraits()
+	if !s.shaderAnimates {
+		t.Fatal("audio uniforms do not animate the shader")
+	}
+	if err := s.LoadAudio(writeClickWAV(t, 0.5, 0.1)); err != nil {
+		t.Fatal(err)
+	}
+	audioTicks(s, 7) // to the click at tick 6
+	m := s.buildUniforms(100, 100)
+	bands, ok := m["AudioBands"].([]float32)
+	if !ok || len(bands) != AudioBandCount {
+		t.Fatalf("AudioBands = %#v", m["AudioBands"])
+	}
+	a := s.Audio()
+	if m["AudioLevel"] != a.Level || m["AudioPulse"] != a.Pulse || a.Level == 0 {
+		t.Fatalf("uniforms %v for %+v", m, a)
+	}
+	if _, ok := m["Other"]; ok {
+		t.Fatal("non-builtin array uniform set")
+	}
+}
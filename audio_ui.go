@@ -0,0 +1,101 @@
+package sketchy
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+
+	"github.com/aldernero/debugui"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// audioLevelColor draws the loudness envelope in the audio scrubber.
+var audioLevelColor = color.RGBA{0x4a, 0x90, 0xd9, 0xff}
+
+// drawBuiltinAudioRows is the Audio section, shown once audio is loaded: a
+// scrubber over the file's loudness, play and restart buttons, and the
+// playhead time.
+func (s *Sketch) drawBuiltinAudioRows(ctx *debugui.Context) {
+	a := s.audio
+	if a == nil {
+		return
+	}
+	ctx.IDScope("audio", func() {
+		ctx.Header("Audio", false, func() {
+			ctx.SetGridLayout([]int{-1}, []int{timelineScrubberHeight})
+			ctx.IDScope("scrubber", func() {
+				ctx.DragArea(
+					func(screen *ebiten.Image, bounds image.Rectangle) {
+						s.drawAudioScrubber(screen, bounds, ctx.Scale())
+					},
+					func(bounds image.Rectangle, pos image.Point) bool {
+						dx := bounds.Dx()
+						if dx <= 1 {
+							return false
+						}
+						t := float64(pos.X-bounds.Min.X) / float64(dx-1) * a.clip.Duration()
+						if t == a.pos {
+							return false
+						}
+						s.SeekAudio(t)
+						return true
+					},
+				)
+			})
+
+			ctx.SetGridLayout([]int{-1, -1}, nil)
+			label := "Play"
+			if a.playing {
+				label = "Pause"
+			}
+			ctx.IDScope("play", func() {
+				ctx.Button(label).On(func() { s.PlayAudio(!a.playing) })
+			})
+			ctx.IDScope("restart", func() {
+				ctx.Button("Restart").On(func() { s.SeekAudio(0) })
+			})
+			ctx.SetGridLayout([]int{-1}, nil)
+			ctx.Text(fmt.Sprintf("%s  %s / %s", filepath.Base(a.path), formatAudioTime(a.pos), formatAudioTime(a.clip.Duration())))
+		})
+	})
+}
+
+// drawAudioScrubber paints the loudness envelope and playhead in bounds
+// (panel units; scale converts to screen pixels).
+func (s *Sketch) drawAudioScrubber(screen *ebiten.Image, bounds image.Rectangle, scale int) {
+	a := s.audio
+	dx, dy := bounds.Dx(), bounds.Dy()
+	if dx <= 1 || dy <= 0 {
+		return
+	}
+	sc := float32(scale)
+	rect := func(x, y, w, h float32, c color.Color) {
+		vector.FillRect(screen, x*sc, y*sc, w*sc, h*sc, c, false)
+	}
+	x0, y0 := float32(bounds.Min.X), float32(bounds.Min.Y)
+	rect(x0, y0, float32(dx), float32(dy), scrubberBG)
+	// One column per panel pixel, at the loudest frame it covers.
+	frames := a.analysis(int(shaderTimeTPS))
+	for x := range dx {
+		lo, hi := x*len(frames)/dx, (x+1)*len(frames)/dx
+		var level float64
+		for _, f := range frames[lo:max(hi, lo+1)] {
+			level = max(level, f.Level)
+		}
+		h := float32(level) * float32(dy)
+		rect(x0+float32(x), y0+(float32(dy)-h)/2, 1, h, audioLevelColor)
+	}
+	d := a.clip.Duration()
+	if d > 0 {
+		rect(x0+float32(a.pos/d)*float32(dx-1), y0, 1, float32(dy), scrubberPlayhead)
+	}
+}
+
+// formatAudioTime renders seconds as m:ss.s.
+func formatAudioTime(sec float64) string {
+	m := math.Floor(sec / 60)
+	return fmt.Sprintf("%d:%04.1f", int(m), sec-60*m)
+}
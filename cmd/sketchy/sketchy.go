@@ -3,12 +3,15 @@ package main
 import (
 	"embed"
 	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"strconv"
 	"syscall"
 )
 
@@ -27,7 +30,7 @@ func main() {
 			fmt.Printf("Sketchy %s\n", version)
 			os.Exit(0)
 		}
-		fmt.Println("expected 'init' or 'run' subcommands")
+		fmt.Println("expected 'init', 'run', 'render' or 'snapshots' subcommands")
 		usage()
 		os.Exit(1)
 	}
@@ -106,11 +109,61 @@ func main() {
 		if err != nil {
 			log.Fatal("error while changing directory:", err)
 		}
+	case "render":
+		renderSketch(dirPath, prefix, os.Args[3:])
+	case "snapshots":
+		snapshotsCommand(prefix, os.Args[3:])
 	default:
 		usage()
 	}
 }
 
+// renderSketch runs the sketch in dirPath with the headless render request
+// in its environment; the sketch's main hands it to Sketch.RenderHeadless
+// (see sketchy.HeadlessFromEnv) and exits without opening a window. A
+// sketch that doesn't handle the request says so and exits from Init.
+func renderSketch(dirPath, name string, args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	var seed string
+	flags.Func("seed", "random seed (default the sketch's own)", func(v string) error {
+		_, err := strconv.ParseInt(v, 10, 64)
+		seed = v
+		return err
+	})
+	ticks := flags.Int("ticks", 1, "update/draw cycles to run before saving")
+	scale := flags.Float64("scale", 0, "PNG export scale (0 = the sketch's RasterDPI)")
+	out := flags.String("out", path.Base(name)+".png", "output file (.png, .tif, .exr, .svg, .pdf, .gcode or .hpgl)")
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+	// The sketch runs in its own directory; resolve the output against
+	// the directory the command was started from.
+	outPath, err := filepath.Abs(*out)
+	if err != nil {
+		log.Fatal("error while resolving output path: ", err)
+	}
+	err = os.Chdir(dirPath)
+	if err != nil {
+		log.Fatal("error while changing directory:", err)
+	}
+	bin, binErr := exec.LookPath("go")
+	if binErr != nil {
+		log.Fatal(binErr)
+	}
+	env := append(os.Environ(),
+		"SKETCHY_RENDER_OUT="+outPath,
+		"SKETCHY_RENDER_TICKS="+strconv.Itoa(*ticks),
+		"SKETCHY_RENDER_SCALE="+strconv.FormatFloat(*scale, 'g', -1, 64),
+	)
+	if seed != "" {
+		env = append(env, "SKETCHY_RENDER_SEED="+seed)
+	}
+	execErr := syscall.Exec(bin, []string{"go", "run", "."}, env)
+	if execErr != nil {
+		log.Fatal("error while rendering sketch: ", execErr)
+	}
+}
+
 func usage() {
 	fmt.Println("Usage: sketchy command args")
 	fmt.Println("Commands:")
@@ -118,6 +171,13 @@ func usage() {
 	fmt.Println("\t         'sketch' draws on a CPU canvas;")
 	fmt.Println("\t         'shader' renders a Kage fragment shader (fragment.kage)")
 	fmt.Println("\trun <name> - run the project in directory 'name'")
+	fmt.Println("\trender <name> [--seed N] [--ticks N] [--scale N] [--out file]")
+	fmt.Println("\t         - render a CPU sketch to PNG/SVG without opening a window")
+	fmt.Println("\tsnapshots <dir> list [--tag T] [--search TEXT] [--min-rating N] [--favorites] [--sort newest|oldest|rating|name]")
+	fmt.Println("\tsnapshots <dir> show <name>... - print a snapshot's labels, saves, and control and builtin JSON")
+	fmt.Println("\tsnapshots <dir> export [--out dir] [name...] - write a snapshot bundle and copy the linked saves")
+	fmt.Println("\tsnapshots <dir> delete <name>... | rename <old> <new>")
+	fmt.Println("\t         - manage the snapshots in a sketch's sketch.db")
 	fmt.Println("\tversion  - print Sketchy version")
 }
 
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+var snapshotSorts = map[string]sketchdb.SnapshotSort{
+	"newest": sketchdb.SortNewest,
+	"oldest": sketchdb.SortOldest,
+	"rating": sketchdb.SortRating,
+	"name":   sketchdb.SortName,
+}
+
+// snapshotsCommand runs `sketchy snapshots <dir> <action> …` against the
+// sketch.db in dir, the same database the Take/Load Snapshot dialogs use.
+func snapshotsCommand(dir string, args []string) {
+	if len(args) == 0 {
+		fmt.Println("expected an action: sketchy snapshots <dir> list|show|export|delete|rename")
+		os.Exit(1)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		log.Fatal("error while resolving sketch directory: ", err)
+	}
+	dbPath := filepath.Join(dir, "sketch.db")
+	// sketchdb.Open creates a missing database; don't leave an empty one
+	// behind for a mistyped directory.
+	exists, err := regularFileExists(dbPath)
+	if err != nil {
+		log.Fatal("error while looking for sketch.db: ", err)
+	}
+	if !exists {
+		log.Fatalf("%s doesn't exist; run the sketch and take a snapshot first", dbPath)
+	}
+	db, err := sketchdb.Open(dbPath)
+	if err != nil {
+		log.Fatal("error while opening sketch.db: ", err)
+	}
+	defer db.Close()
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "list":
+		err = listSnapshots(db, args)
+	case "show":
+		err = showSnapshots(db, args)
+	case "export":
+		err = exportSnapshots(db, dir, args)
+	case "delete":
+		err = deleteSnapshots(db, args)
+	case "rename":
+		if len(args) != 2 {
+			err = fmt.Errorf("expected: sketchy snapshots <dir> rename <old> <new>")
+			break
+		}
+		if err = db.RenameSnapshot(args[0], args[1]); err == nil {
+			fmt.Printf("renamed %s to %s\n", args[0], strings.TrimSpace(args[1]))
+		}
+	default:
+		err = fmt.Errorf("unknown snapshots action %q", action)
+	}
+	if err != nil {
+		db.Close()
+		log.Fatal(err)
+	}
+}
+
+func listSnapshots(db *sketchdb.DB, args []string) error {
+	flags := flag.NewFlagSet("snapshots list", flag.ExitOnError)
+	var f sketchdb.SnapshotFilter
+	flags.StringVar(&f.Tag, "tag", "", "only snapshots with this tag")
+	flags.StringVar(&f.Text, "search", "", "only snapshots whose name, description or tags contain every word")
+	flags.IntVar(&f.MinRating, "min-rating", 0, "only snapshots rated at least this many stars")
+	flags.BoolVar(&f.Favorites, "favorites", false, "only favorites")
+	sort := flags.String("sort", "newest", "order: newest, oldest, rating or name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var ok bool
+	if f.Sort, ok = snapshotSorts[*sort]; !ok {
+		return fmt.Errorf("unknown sort %q; use newest, oldest, rating or name", *sort)
+	}
+	list, err := db.ListSnapshots(f)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tRATING\tFAVORITE\tTAGS")
+	for _, info := range list {
+		fav := ""
+		if info.Favorite {
+			fav = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", info.Name, formatCreated(info.CreatedAt),
+			strings.Repeat("*", info.Rating), fav, strings.Join(info.Tags, ", "))
+	}
+	return w.Flush()
+}
+
+// formatCreated shows a snapshot's UTC creation time in local time.
+func formatCreated(s string) string {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return s
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// showSnapshots prints each named snapshot: its labels, linked saves, and
+// control and builtin state as indented JSON.
+func showSnapshots(db *sketchdb.DB, names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("expected: sketchy snapshots <dir> show <name>...")
+	}
+	for i, n := range names {
+		r, err := db.GetSnapshotByName(n)
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			return fmt.Errorf("no snapshot %q", n)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println("Name:", r.Name)
+		fmt.Println("Created:", formatCreated(r.CreatedAt))
+		if d := strings.TrimSpace(r.Description); d != "" {
+			fmt.Println("Description:", d)
+		}
+		if len(r.Tags) > 0 {
+			fmt.Println("Tags:", strings.Join(r.Tags, ", "))
+		}
+		if r.Rating > 0 {
+			fmt.Println("Rating:", strings.Repeat("*", r.Rating))
+		}
+		if r.Favorite {
+			fmt.Println("Favorite: yes")
+		}
+		for _, s := range []struct{ label, rel string }{{"PNG", r.PNGPath}, {"SVG", r.SVGPath}, {"PDF", r.PDFPath}} {
+			if s.rel != "" {
+				fmt.Printf("%s: %s\n", s.label, s.rel)
+			}
+		}
+		if len(r.SVGLayers) > 0 {
+			fmt.Println("SVG layers:", strings.Join(r.SVGLayers, ", "))
+		}
+		for _, s := range []struct{ label, data string }{{"Controls", r.ControlJSON}, {"Builtins", r.BuiltinJSON}} {
+			fmt.Println(s.label + ":")
+			if strings.TrimSpace(s.data) == "" {
+				fmt.Println("(none)")
+				continue
+			}
+			out, err := json.MarshalIndent(json.RawMessage(s.data), "", "  ")
+			if err != nil {
+				return fmt.Errorf("snapshot %q: %s: %w", r.Name, strings.ToLower(s.label), err)
+			}
+			fmt.Println(string(out))
+		}
+	}
+	return nil
+}
+
+// exportSnapshots writes the named snapshots, or all of them, to a bundle
+// in the output directory that Load Snapshot… can import, with their linked
+// PNG, SVG and PDF saves embedded. The saves, and the per-layer files of a
+// split SVG, are also copied there under their saves/<format>/ paths, for
+// use as they are.
+func exportSnapshots(db *sketchdb.DB, dir string, args []string) error {
+	flags := flag.NewFlagSet("snapshots export", flag.ExitOnError)
+	out := flags.String("out", filepath.Base(dir)+"_snapshots", "output directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	b, err := db.ExportBundle(dir, flags.Args(), true)
+	if err != nil {
+		return err
+	}
+	bundlePath := filepath.Join(*out, filepath.Base(dir)+"_snapshots"+sketchdb.BundleExt)
+	if err := sketchdb.WriteBundle(bundlePath, b); err != nil {
+		return err
+	}
+	copied := 0
+	for _, bs := range b.Snapshots {
+		r, err := db.GetSnapshotByName(bs.Name)
+		if err != nil {
+			return err
+		}
+		layerFiles, err := db.SVGLayerFiles(r.SVGPath, r.SVGLayers)
+		if err != nil {
+			return err
+		}
+		for _, rel := range append([]string{r.PNGPath, r.SVGPath, r.PDFPath}, layerFiles...) {
+			if rel == "" {
+				continue
+			}
+			if !filepath.IsLocal(filepath.FromSlash(rel)) {
+				log.Printf("snapshot %s: %s is outside the sketch directory; not copied", bs.Name, rel)
+				continue
+			}
+			src := filepath.Join(dir, filepath.FromSlash(rel))
+			if err := copyFile(src, filepath.Join(*out, filepath.FromSlash(rel))); err != nil {
+				log.Printf("snapshot %s: %v", bs.Name, err)
+				continue
+			}
+			copied++
+		}
+	}
+	fmt.Printf("exported %d snapshots to %s and copied %d saves\n", len(b.Snapshots), bundlePath, copied)
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// deleteSnapshots removes snapshots from sketch.db. Their saved images are
+// left on disk.
+func deleteSnapshots(db *sketchdb.DB, names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("expected: sketchy snapshots <dir> delete <name>...")
+	}
+	for _, n := range names {
+		if err := db.DeleteSnapshot(n); err != nil {
+			return err
+		}
+		fmt.Println("deleted", n)
+	}
+	return nil
+}
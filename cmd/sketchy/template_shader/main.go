@@ -25,10 +25,16 @@ func main() {
 	var prefix string
 	var randomSeed int64
 	var paletteDBPath string
+	var oscAddr string
+	var httpAddr string
+	var audioPath string
 	var cpuprofile = flag.String("pprof", "", "Collect CPU profile")
 	flag.StringVar(&prefix, "p", "", "Output file prefix")
 	flag.Int64Var(&randomSeed, "s", 0, "Random number generator seed (0 = auto)")
 	flag.StringVar(&paletteDBPath, "palettedb", "", "Path to palettedb database (default ~/.config/palettedb/palettedb.db)")
+	flag.StringVar(&oscAddr, "osc", "", "UDP address to listen on for OSC control, e.g. :9000")
+	flag.StringVar(&httpAddr, "http", "", "address to serve the HTTP control API on, e.g. 127.0.0.1:8080")
+	flag.StringVar(&audioPath, "audio", "", "WAV, FLAC or MP3 file to play and analyse for audio-reactive visuals")
 	flag.Parse()
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
@@ -64,6 +70,9 @@ func main() {
 	}
 	s.RandomSeed = randomSeed
 	s.PaletteDBPath = paletteDBPath
+	s.OSCAddr = oscAddr
+	s.HTTPAddr = httpAddr
+	s.AudioPath = audioPath
 	s.Updater = update
 	// s.ExtraUniforms = func(s *sketchy.Sketch) map[string]any {
 	// 	return map[string]any{"MyVec2": []float32{1, 2}} // computed uniforms
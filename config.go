@@ -1,6 +1,9 @@
 package sketchy
 
-import "image/color"
+import (
+	"image/color"
+	"log"
+)
 
 // Config holds sketch options set from code (no JSON).
 type Config struct {
@@ -66,6 +69,33 @@ type Config struct {
 	// PreviewMode rasterizes at half detail and scales up on screen for
 	// ~4x faster frames while iterating.
 	PreviewMode bool
+	// Plot configures the pen-plotter stages of SVG saves; see PlotOptions.
+	Plot PlotOptions
+	// PDFPage is the paper preset of PDF saves ("A4", "Letter", …); empty
+	// prints the sketch at its own size (its Page, if set). PDFMargin is in
+	// millimetres.
+	PDFPage   string
+	PDFMargin float64
+	// Page optionally describes the physical sheet: paper preset or custom
+	// size, orientation, margins, and pixels per inch. When set, it decides
+	// SketchWidth and SketchHeight, and saves carry real units. See Page.
+	Page Page
+	// OSCAddr, when set (e.g. ":9000"), listens for OSC messages over UDP
+	// that set controls, addressed /sketchy/<folder>/<name>. Control changes
+	// are published back to every client heard from and to OSCSendAddr
+	// ("host:port"), if set. See docs/builtin-goodies.md.
+	OSCAddr     string
+	OSCSendAddr string
+	// HTTPAddr, when set (e.g. "127.0.0.1:8080"), serves the HTTP API:
+	// controls, saves, snapshots, recordings and the current frame under
+	// /api/, with control changes streamed over a WebSocket. See
+	// docs/builtin-goodies.md.
+	HTTPAddr string
+	// AudioPath loads a WAV, FLAC or MP3 file at Init and plays it in step
+	// with the tick clock; Sketch.Audio and the Audio* shader builtins
+	// report its band energies, level and onsets each tick. See
+	// docs/builtin-goodies.md.
+	AudioPath string
 }
 
 // New returns an uninitialized sketch. Set BuildUI, Updater, and Drawer, then call Init().
@@ -98,6 +128,23 @@ func New(cfg Config) *Sketch {
 		ShaderSrc:                 append([]byte(nil), cfg.ShaderSrc...),
 		StatePath:                 cfg.StatePath,
 		GPUDrawer:                 cfg.GPUDrawer,
+		Plot:                      cfg.Plot,
+		PDFPage:                   cfg.PDFPage,
+		PDFMargin:                 cfg.PDFMargin,
+		Page:                      cfg.Page,
+		OSCAddr:                   cfg.OSCAddr,
+		OSCSendAddr:               cfg.OSCSendAddr,
+		HTTPAddr:                  cfg.HTTPAddr,
+		AudioPath:                 cfg.AudioPath,
+	}
+	if s.Plot.DrawSpeed <= 0 {
+		s.Plot.DrawSpeed = defaultDrawSpeed
+	}
+	if s.Plot.TravelSpeed <= 0 {
+		s.Plot.TravelSpeed = defaultTravelSpeed
+	}
+	if err := s.applyPage(); err != nil {
+		log.Fatalf("sketchy: %v", err)
 	}
 	if s.SketchWidth <= 0 {
 		s.SketchWidth = 1080
@@ -12,6 +12,7 @@ import (
 
 	"github.com/aldernero/debugui"
 	"github.com/aldernero/gaul"
+	"github.com/aldernero/sketchy/internal/sketchdb"
 )
 
 // formatSnapshotCreatedLocal parses snapshot created_at (UTC RFC3339 from sketch.db) for display in local time.
@@ -48,6 +49,9 @@ func (s *Sketch) controlWindow(ctx *debugui.Context) {
 	s.dialogSaveImage(ctx)
 	s.dialogSnapshot(ctx)
 	s.dialogLoadSnapshot(ctx)
+	s.dialogDiff(ctx)
+	s.dialogSeedSweep(ctx)
+	s.dialogParamSweep(ctx)
 }
 
 func (s *Sketch) builtinsPanel(ctx *debugui.Context) {
@@ -70,7 +74,10 @@ func (s *Sketch) builtinsPanel(ctx *debugui.Context) {
 		}
 		s.drawBuiltinPaletteRows(ctx)
 		s.drawBuiltinRecordingRows(ctx)
+		s.drawBuiltinTimelineRows(ctx)
+		s.drawBuiltinAudioRows(ctx)
 		s.drawBuiltinShaderRows(ctx)
+		s.drawBuiltinPlotEstimateRows(ctx)
 
 		ctx.SetGridLayout([]int{-1}, nil)
 		ctx.Button("Save Image…").On(func() {
@@ -78,26 +85,35 @@ func (s *Sketch) builtinsPanel(ctx *debugui.Context) {
 			s.dlgSaveImagePrefix = s.Prefix + "_" + gaul.GetTimestampString()
 			s.dlgSavePNG = true
 			s.dlgSaveSVG = true
+			s.dlgSavePDF = false
+			s.dlgPNGScale = s.RasterDPI / DefaultDPI
 		})
+		if st := s.PlotStatus(); st != "" {
+			ctx.Text(st)
+		}
+		if st := s.TileStatus(); st != "" {
+			ctx.Text(st)
+		}
 		ctx.Button("Take Snapshot…").On(func() {
 			s.dlgSnapshotOpen = true
 			s.dlgSnapshotName = s.Prefix + "_snap_" + gaul.GetTimestampString()
 			s.dlgSnapshotDescription = ""
 			s.dlgSnapshotPNG = false
 			s.dlgSnapshotSVG = false
+			s.dlgSnapshotPDF = false
+			s.dlgSnapshotTags = ""
+			s.dlgSnapshotRating = 0
+			s.dlgSnapshotFavorite = false
 		})
-		ctx.Button("Load Snapshot…").On(func() {
-			s.dlgLoadOpen = true
-			s.dlgLoadNames = s.dbListSnapshots()
-			if len(s.dlgLoadNames) > 0 {
-				s.dlgLoadSelected = s.dlgLoadNames[0]
-				s.refreshLoadPreview()
-			} else {
-				s.dlgLoadSelected = ""
-				s.dlgLoadPreviewRow = nil
-				s.dlgLoadMissing = nil
-			}
-		})
+		ctx.Button("Seed Sweep…").On(func() { s.openSeedSweepDialog() })
+		if len(s.FloatSliders)+len(s.IntSliders) > 0 {
+			ctx.Button("Param Sweep…").On(func() { s.openParamSweepDialog() })
+		}
+		if s.sweepStatus != "" {
+			ctx.Text(s.sweepStatus)
+		}
+		ctx.Button("Load Snapshot…").On(func() { s.openLoadSnapshotDialog() })
+		ctx.Button("Compare Snapshots…").On(func() { s.openDiffDialog() })
 
 		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
 		ctx.Text("UI theme")
@@ -225,6 +241,35 @@ func (s *Sketch) drawBuiltinExportScaleRow(ctx *debugui.Context) {
 	})
 }
 
+// drawRasterFormatRows are the Save Image dialog's raster format and, for
+// CPU sketches, scale: any multiple of the sketch size, independent of the
+// live Export scale. 8-bit PNGs past tiledPNGPixels are rendered in bands
+// (tiled.go); the deep formats are supersampled (deep.go).
+func (s *Sketch) drawRasterFormatRows(ctx *debugui.Context) {
+	ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+	ctx.Text("Format")
+	ctx.IDScope("rasterFormat", func() {
+		ctx.Dropdown(&s.dlgRasterFormatIdx, rasterFormatLabels)
+	})
+	if s.usesGPUCanvas() { // GPU captures follow the Export scale
+		return
+	}
+	ctx.Text("Scale")
+	ctx.IDScope("pngScale", func() {
+		ctx.NumberFieldF(&s.dlgPNGScale, 0.5, 2).On(func() {
+			s.dlgPNGScale = max(s.dlgPNGScale, 0.1)
+		})
+	})
+	ctx.SetGridLayout([]int{-1}, nil)
+	w := int(s.SketchWidth*s.dlgPNGScale + 0.5)
+	h := int(s.SketchHeight*s.dlgPNGScale + 0.5)
+	size := fmt.Sprintf("%d×%d px at %.0f dpi", w, h, s.dlgPNGScale*s.pxPerInch())
+	if rasterFormats[s.dlgRasterFormatIdx] == "png" && w*h > tiledPNGPixels {
+		size += ", tiled"
+	}
+	ctx.Text(size)
+}
+
 // drawBuiltinPreviewModeRow shows the Preview mode checkbox. Not called in
 // shader mode, whose live display is always native 1:1.
 func (s *Sketch) drawBuiltinPreviewModeRow(ctx *debugui.Context) {
@@ -281,11 +326,20 @@ func (s *Sketch) drawBuiltinPaletteRows(ctx *debugui.Context) {
 	})
 }
 
+// sliderLabel is a slider row's name, marked with a "~" while a modulator
+// drives it; the row itself shows the base value.
+func (s *Sketch) sliderLabel(folder, name string) string {
+	if s.modulators[controlMapKey(folder, name)] != nil {
+		return name + " ~"
+	}
+	return name
+}
+
 func (s *Sketch) drawFloatSliderRow(ctx *debugui.Context, idx int) {
 	sl := &s.FloatSliders[idx]
 	sl.maybeSyncTextBufFromVal()
 	ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1, 96}, nil)
-	ctx.Text(sl.Name)
+	ctx.Text(s.sliderLabel(sl.Folder, sl.Name))
 	ctx.GridCell(func(bounds image.Rectangle) {
 		ctx.IDScope(fmt.Sprintf("fsld%d", idx), func() {
 			disp := clampFloat(sl.Val, sl.MinVal, sl.MaxVal)
@@ -314,7 +368,7 @@ func (s *Sketch) drawIntSliderRow(ctx *debugui.Context, idx int) {
 	sl := &s.IntSliders[idx]
 	sl.maybeSyncTextBufFromVal()
 	ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1, 96}, nil)
-	ctx.Text(sl.Name)
+	ctx.Text(s.sliderLabel(sl.Folder, sl.Name))
 	ctx.GridCell(func(bounds image.Rectangle) {
 		ctx.IDScope(fmt.Sprintf("isld%d", idx), func() {
 			ctx.SliderNoValue(&sl.Val, sl.MinVal, sl.MaxVal, sl.Incr).On(func() {
@@ -399,34 +453,45 @@ func (s *Sketch) dialogSaveImage(ctx *debugui.Context) {
 	if !s.dlgSaveImageOpen {
 		return
 	}
-	ctx.Window("Save Image", image.Rect(200, 120, 520, 300), func(layout debugui.ContainerLayout) {
+	ctx.Window("Save Image", image.Rect(200, 100, 520, 480), func(layout debugui.ContainerLayout) {
 		ctx.BringRootContainerToFront()
 		ctx.SetGridLayout([]int{-1}, nil)
 		ctx.Text("Filename prefix (no extension)")
 		prefix := &s.dlgSaveImagePrefix
 		ctx.TextField(prefix).On(func() {})
-		ctx.Checkbox(&s.dlgSavePNG, "PNG")
+		ctx.Checkbox(&s.dlgSavePNG, "Raster image")
+		if s.dlgSavePNG {
+			s.drawRasterFormatRows(ctx)
+		}
 		if !s.usesGPUCanvas() { // GPU output has no vector representation
 			ctx.Checkbox(&s.dlgSaveSVG, "SVG")
+			ctx.Checkbox(&s.dlgSavePDF, "PDF")
+			ctx.Checkbox(&s.dlgSaveGCode, "G-code")
+			ctx.Checkbox(&s.dlgSaveHPGL, "HPGL")
+			if s.dlgSavePDF {
+				s.drawPDFPageRow(ctx)
+			}
+			if s.dlgSaveSVG || s.dlgSaveGCode || s.dlgSaveHPGL {
+				s.drawSVGLayerRows(ctx)
+			}
 		}
 		modalActionRow(ctx, "OK", func() { s.dlgSaveImageOpen = false }, func() {
-			base := strings.TrimSpace(*prefix)
-			if base == "" {
-				base = s.Prefix + "_" + gaul.GetTimestampString()
-			}
+			var formats []string
 			if s.dlgSavePNG {
-				rel := filepath.ToSlash(filepath.Join("saves", "png", base+".png"))
-				if s.usesGPUCanvas() {
-					// GPU readback must happen here on the ebiten thread;
-					// the worker only encodes.
-					s.EnqueueSavePixels(rel, s.CaptureGPUImage(), true)
-				} else {
-					s.EnqueueSave(rel, "png", s.RasterDPI, true)
+				formats = append(formats, rasterFormats[s.dlgRasterFormatIdx])
+			}
+			if !s.usesGPUCanvas() {
+				for _, v := range []struct {
+					on     bool
+					format string
+				}{{s.dlgSaveSVG, "svg"}, {s.dlgSavePDF, formatPDF}, {s.dlgSaveGCode, formatGCode}, {s.dlgSaveHPGL, formatHPGL}} {
+					if v.on {
+						formats = append(formats, v.format)
+					}
 				}
 			}
-			if s.dlgSaveSVG && !s.usesGPUCanvas() {
-				rel := filepath.ToSlash(filepath.Join("saves", "svg", base+".svg"))
-				s.EnqueueSave(rel, "svg", 0, true)
+			if _, err := s.saveDesign(*prefix, formats, s.dlgPNGScale); err != nil {
+				fmt.Println("save:", err)
 			}
 			s.dlgSaveImageOpen = false
 		})
@@ -437,7 +502,7 @@ func (s *Sketch) dialogSnapshot(ctx *debugui.Context) {
 	if !s.dlgSnapshotOpen {
 		return
 	}
-	ctx.Window("Take Snapshot", image.Rect(200, 80, 560, 460), func(layout debugui.ContainerLayout) {
+	ctx.Window("Take Snapshot", image.Rect(200, 60, 560, 560), func(layout debugui.ContainerLayout) {
 		ctx.BringRootContainerToFront()
 		ctx.SetGridLayout([]int{-1}, nil)
 		ctx.Text("Snapshot name")
@@ -451,66 +516,26 @@ func (s *Sketch) dialogSnapshot(ctx *debugui.Context) {
 				ctx.TextField(desc).On(func() {})
 			})
 		})
+		s.drawSnapshotLabelInputs(ctx)
 		ctx.SetGridLayout([]int{-1}, nil)
 		ctx.Text("Save images")
 		ctx.Checkbox(&s.dlgSnapshotPNG, "PNG")
 		if !s.IsShaderSketch() {
 			ctx.Checkbox(&s.dlgSnapshotSVG, "SVG")
-		}
-		modalActionRow(ctx, "OK", func() { s.dlgSnapshotOpen = false }, func() {
-			n := strings.TrimSpace(*name)
-			if n == "" {
-				n = s.Prefix + "_snap_" + gaul.GetTimestampString()
+			if s.dlgSnapshotSVG {
+				s.drawSVGLayerRows(ctx)
 			}
-			data, err := s.serializeControlState()
-			if err != nil {
-				fmt.Println("snapshot serialize:", err)
-				s.dlgSnapshotOpen = false
-				return
+			ctx.Checkbox(&s.dlgSnapshotPDF, "PDF")
+			if s.dlgSnapshotPDF {
+				s.drawPDFPageRow(ctx)
 			}
-			bdata, err := s.serializeBuiltinState()
+		}
+		modalActionRow(ctx, "OK", func() { s.dlgSnapshotOpen = false }, func() {
+			n, err := s.takeSnapshot(*name, *desc, s.dlgSnapshotPNG, s.dlgSnapshotSVG, s.dlgSnapshotPDF)
 			if err != nil {
-				fmt.Println("snapshot builtin serialize:", err)
-				s.dlgSnapshotOpen = false
-				return
-			}
-			var pngID, svgID *int64
-			var pngVal, svgVal int64
-			base := n
-			if s.dlgSnapshotPNG {
-				rel := filepath.ToSlash(filepath.Join("saves", "png", base+".png"))
-				full := filepath.Join(s.workDir, filepath.FromSlash(rel))
-				if err := s.writeSnapshotPNG(full); err != nil {
-					fmt.Println("snapshot png:", err)
-				} else if s.db != nil {
-					id, ierr := s.db.InsertSave(rel, "png")
-					if ierr != nil {
-						fmt.Println("snapshot db png:", ierr)
-					} else {
-						pngVal = id
-						pngID = &pngVal
-					}
-				}
-			}
-			if s.dlgSnapshotSVG && !s.usesGPUCanvas() {
-				rel := filepath.ToSlash(filepath.Join("saves", "svg", base+".svg"))
-				full := filepath.Join(s.workDir, filepath.FromSlash(rel))
-				if err := writeSVG(full, s); err != nil {
-					fmt.Println("snapshot svg:", err)
-				} else if s.db != nil {
-					id, ierr := s.db.InsertSave(rel, "svg")
-					if ierr != nil {
-						fmt.Println("snapshot db svg:", ierr)
-					} else {
-						svgVal = id
-						svgID = &svgVal
-					}
-				}
-			}
-			if err := s.dbInsertSnapshot(n, strings.TrimSpace(*desc), string(data), string(bdata), pngID, svgID); err != nil {
-				fmt.Println("snapshot db:", err)
-			} else {
-				log.Printf("sketchy: saved snapshot %q", n)
+				fmt.Println("snapshot:", err)
+			} else if err := s.labelSnapshot(n, sketchdb.ParseTags(s.dlgSnapshotTags), s.dlgSnapshotRating, s.dlgSnapshotFavorite); err != nil {
+				fmt.Println("label snapshot:", err)
 			}
 			s.dlgSnapshotOpen = false
 		})
@@ -521,29 +546,29 @@ func (s *Sketch) dialogLoadSnapshot(ctx *debugui.Context) {
 	if !s.dlgLoadOpen {
 		return
 	}
-	ctx.Window("Load Snapshot", image.Rect(180, 80, 560, 420), func(layout debugui.ContainerLayout) {
+	ctx.Window("Load Snapshot", image.Rect(160, 40, 680, 680), func(layout debugui.ContainerLayout) {
 		ctx.BringRootContainerToFront()
 		ctx.SetGridLayout([]int{-1}, nil)
-		if len(s.dlgLoadNames) == 0 {
+		if len(s.dlgLoadNames) == 0 && !s.loadFiltered() {
 			ctx.Text("No snapshots in sketch.db")
+			s.drawLoadImageRows(ctx)
+			s.drawLoadBundleRows(ctx)
 			ctx.Button("Close").On(func() { s.dlgLoadOpen = false })
 			return
 		}
-		idx := 0
-		for i, n := range s.dlgLoadNames {
-			if n == s.dlgLoadSelected {
-				idx = i
-				break
-			}
+		s.drawLoadFilterRows(ctx)
+		ctx.SetGridLayout([]int{-1}, nil)
+		if len(s.dlgLoadNames) == 0 {
+			ctx.Text("No snapshots match")
+			ctx.Text("")
+			s.drawLoadImageRows(ctx)
+			s.drawLoadBundleRows(ctx)
+			ctx.Button("Close").On(func() { s.dlgLoadOpen = false })
+			return
 		}
-		ctx.Dropdown(&idx, s.dlgLoadNames).On(func() {
-			if idx >= 0 && idx < len(s.dlgLoadNames) {
-				s.dlgLoadSelected = s.dlgLoadNames[idx]
-				s.refreshLoadPreview()
-			}
-		})
+		s.drawLoadPreview(ctx)
 		ctx.SetGridLayout([]int{-1}, nil)
-		ctx.Text("")
+		ctx.Text(s.dlgLoadSelected)
 		if s.dlgLoadPreviewRow != nil {
 			ctx.Text("Taken: " + formatSnapshotCreatedLocal(s.dlgLoadPreviewRow.CreatedAt))
 			if d := strings.TrimSpace(s.dlgLoadPreviewRow.Description); d != "" {
@@ -555,9 +580,17 @@ func (s *Sketch) dialogLoadSnapshot(ctx *debugui.Context) {
 			if s.dlgLoadPreviewRow.PNGPath != "" {
 				ctx.Text("PNG: " + filepath.Base(s.dlgLoadPreviewRow.PNGPath))
 			}
+			if s.dlgLoadPreviewRow.PDFPath != "" {
+				ctx.Text("PDF: " + filepath.Base(s.dlgLoadPreviewRow.PDFPath))
+			}
 			if s.dlgLoadPreviewRow.SVGPath != "" {
 				ctx.Text("SVG: " + filepath.Base(s.dlgLoadPreviewRow.SVGPath))
 			}
+			if layers := s.dlgLoadPreviewRow.SVGLayers; len(layers) > 0 {
+				ctx.Text("SVG layers: " + strings.Join(layers, ", "))
+			}
+			ctx.Text("")
+			s.drawSnapshotLabelRows(ctx)
 		}
 		if len(s.dlgLoadMissing) > 0 {
 			ctx.Text("Warning: unknown keys in snapshot:")
@@ -565,10 +598,13 @@ func (s *Sketch) dialogLoadSnapshot(ctx *debugui.Context) {
 				ctx.Text("  • " + k)
 			}
 		}
-		if s.dlgLoadPreviewRow != nil || len(s.dlgLoadMissing) > 0 {
-			ctx.SetGridLayout([]int{-1}, nil)
-			ctx.Text("")
-		}
+		ctx.SetGridLayout([]int{-1}, nil)
+		ctx.Text("")
+		s.drawLoadGallery(ctx)
+		ctx.SetGridLayout([]int{-1}, nil)
+		ctx.Text("")
+		s.drawLoadImageRows(ctx)
+		s.drawLoadBundleRows(ctx)
 		modalActionRow(ctx, "OK", func() { s.dlgLoadOpen = false }, func() {
 			row := s.dbGetSnapshot(s.dlgLoadSelected)
 			if row == nil {
@@ -585,11 +621,48 @@ func (s *Sketch) dialogLoadSnapshot(ctx *debugui.Context) {
 	})
 }
 
+// drawLoadImageRows lets the Load Snapshot dialog restore the state
+// embedded in a saved PNG or SVG (metadata.go) instead of a sketch.db row.
+func (s *Sketch) drawLoadImageRows(ctx *debugui.Context) {
+	ctx.SetGridLayout([]int{-1}, nil)
+	ctx.Text("Or restore from a saved PNG or SVG:")
+	ctx.SetGridLayout([]int{-1, 80}, nil)
+	ctx.IDScope("loadImage", func() {
+		ctx.TextField(&s.dlgLoadImagePath).On(func() {})
+		ctx.Button("Restore").On(func() {
+			p := strings.Trim(strings.TrimSpace(s.dlgLoadImagePath), `"'`)
+			if p == "" {
+				s.dlgLoadImageErr = "Enter the path of an image"
+				return
+			}
+			missing, err := s.ApplyImageState(p)
+			if err != nil {
+				s.dlgLoadImageErr = err.Error()
+				return
+			}
+			if len(missing) > 0 {
+				fmt.Println("unknown keys in image state:", strings.Join(missing, ", "))
+			}
+			log.Printf("sketchy: restored state from %s", p)
+			s.dlgLoadImageErr = ""
+			s.dlgLoadOpen = false
+		})
+	})
+	if s.dlgLoadImageErr != "" {
+		ctx.SetGridLayout([]int{-1}, nil)
+		ctx.Text(s.dlgLoadImageErr)
+	}
+	ctx.SetGridLayout([]int{-1}, nil)
+	ctx.Text("")
+}
+
 func (s *Sketch) refreshLoadPreview() {
 	row := s.dbGetSnapshot(s.dlgLoadSelected)
 	s.dlgLoadPreviewRow = row
 	s.dlgLoadMissing = nil
+	s.dlgLoadEditTags = ""
 	if row != nil {
+		s.dlgLoadEditTags = strings.Join(row.Tags, ", ")
 		miss, err := s.snapshotKeysPresentInJSON([]byte(row.ControlJSON))
 		if err == nil {
 			s.dlgLoadMissing = miss
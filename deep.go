@@ -0,0 +1,179 @@
+package sketchy
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldernero/sketchy/internal/imageio"
+)
+
+// Deep raster save formats, besides "png": 16-bit PNG, 32-bit float TIFF
+// and 32-bit float OpenEXR (linear light).
+const (
+	formatPNG16 = "png16"
+	formatTIFF  = "tiff"
+	formatEXR   = "exr"
+)
+
+// Raster formats of the Save Image dialog.
+var (
+	rasterFormatLabels = []string{"PNG 8-bit", "PNG 16-bit", "TIFF float", "OpenEXR float"}
+	rasterFormats      = []string{"png", formatPNG16, formatTIFF, formatEXR}
+)
+
+// rasterFileExt is the extension of a raster format's files and the
+// directory under saves/ they go to.
+func rasterFileExt(format string) (ext, dir string) {
+	switch format {
+	case formatTIFF:
+		return ".tif", "tiff"
+	case formatEXR:
+		return ".exr", "exr"
+	}
+	return ".png", "png"
+}
+
+// pixelFormat is the save format of pre-captured pixels written to path:
+// picked by the extension, with 16-bit PNG for deep images.
+func pixelFormat(path string, img image.Image) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		return formatTIFF
+	case ".exr":
+		return formatEXR
+	}
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return formatPNG16
+	}
+	return "png"
+}
+
+// Deep captures supersample: each pixel averages up to maxDeepSamples²
+// samples, fewer when the supersampled frame would pass deepSampleBudget
+// (CPU) or maxGPUSide per side (GPU).
+const (
+	maxDeepSamples   = 4
+	deepSampleBudget = 256 << 20
+	maxGPUSide       = 8192
+)
+
+// deepSamples is the samples per side for a deep capture of w×h.
+func deepSamples(w, h, maxSide int) int {
+	k := int(math.Sqrt(float64(deepSampleBudget) / float64(w*h)))
+	if maxSide > 0 {
+		k = min(k, maxSide/max(w, h))
+	}
+	return max(1, min(k, maxDeepSamples))
+}
+
+// renderDeepImage replays the current frame at dpi into 16-bit pixels,
+// supersampled so antialiased edges, fine texture and dithered gradients
+// keep the levels 8 bits round away. Bands are drawn from a copy of the
+// recording, as in tiled PNG saves; the result is held whole, at 8 bytes a
+// pixel.
+func (s *Sketch) renderDeepImage(dpi float64) *image.RGBA64 {
+	scale := dpi / DefaultDPI
+	if scale <= 0 {
+		scale = 1
+	}
+	w := int(s.SketchWidth*scale + 0.5)
+	h := int(s.SketchHeight*scale + 0.5)
+	k := deepSamples(w, h, 0)
+	rec := s.copyRecording()
+
+	out := image.NewRGBA64(image.Rect(0, 0, w, h))
+	rows := max(1, min(h, tiledBandBytes/(4*w*k*k)))
+	pix := make([]byte, 4*w*k*rows*k)
+	for y0 := 0; y0 < h; y0 += rows {
+		y1 := min(y0+rows, h)
+		band := &image.RGBA{Pix: pix[:4*w*k*(y1-y0)*k], Stride: 4 * w * k, Rect: image.Rect(0, y0*k, w*k, y1*k)}
+		clear(band.Pix)
+		replayBand(rec, band, scale*float64(k))
+		imageio.Downsample(out, band, k)
+	}
+	return out
+}
+
+// CaptureGPUImage16 is [Sketch.CaptureGPUImage] with 16 bits per channel:
+// the frame is captured supersampled (up to 4×4 samples a pixel, within the
+// GPU's image size) and averaged down, so edges, fine texture and dithered
+// gradients keep levels between the 8-bit steps. What the GPU already holds
+// in 8 bits, such as a ping-pong state buffer, is saved as it is. Pass it to
+// [Sketch.EnqueueSavePixels] with a .png, .tif or .exr path. Same threading
+// rules as CaptureGPUImage; nil for CPU sketches.
+func (s *Sketch) CaptureGPUImage16() *image.RGBA64 {
+	if !s.usesGPUCanvas() {
+		return nil
+	}
+	scale := s.RasterDPI / DefaultDPI
+	if scale <= 0 {
+		scale = 1
+	}
+	w := int(s.SketchWidth*scale + 0.5)
+	h := int(s.SketchHeight*scale + 0.5)
+	k := deepSamples(w, h, maxGPUSide)
+	big := s.captureGPUAt(scale * float64(k))
+	// The capture may round to a pixel more or less than k×(w, h).
+	big.Rect = image.Rect(0, 0, w*k, h*k).Intersect(big.Rect)
+	out := image.NewRGBA64(image.Rect(0, 0, big.Rect.Dx()/k, big.Rect.Dy()/k))
+	imageio.Downsample(out, big, k)
+	return out
+}
+
+// renderDeepToFile writes the current frame at dpi as a 16-bit PNG, float
+// TIFF or EXR, tagged with its physical pixel density where the sketch has
+// a page and the format has one.
+func (s *Sketch) renderDeepToFile(full, format string, dpi float64) error {
+	if s.usesGPUCanvas() {
+		return fmt.Errorf("sketchy: GPU-rendered sketches have no vector recording to save; use EnqueueSavePixels with CaptureGPUImage16")
+	}
+	scale := dpi / DefaultDPI
+	if scale <= 0 {
+		scale = 1
+	}
+	return writePixels(full, s.renderDeepImage(dpi), s.pageDensity(scale))
+}
+
+// writePixels encodes an already-captured frame (e.g. a shader sketch's GPU
+// readback) in the format its extension names: PNG (16-bit for deep
+// images), float TIFF or EXR. density > 0 records the pixels per inch.
+func writePixels(full string, img image.Image, density float64) error {
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	format := pixelFormat(full, img)
+	if format == "png" || format == formatPNG16 {
+		var b bytes.Buffer
+		if err := png.Encode(&b, img); err != nil {
+			return err
+		}
+		data := b.Bytes()
+		if density > 0 {
+			var err error
+			if data, err = withPNGDensity(data, density); err != nil {
+				return err
+			}
+		}
+		return os.WriteFile(full, data, 0644)
+	}
+	f, err := os.Create(full)
+	if err != nil {
+		return err
+	}
+	if format == formatTIFF {
+		err = imageio.WriteFloatTIFF(f, img, density)
+	} else {
+		err = imageio.WriteEXR(f, img)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
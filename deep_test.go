@@ -0,0 +1,87 @@
+package sketchy
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldernero/gaul/render"
+)
+
+func TestPixelFormat(t *testing.T) {
+	rgba, deep := image.NewRGBA(image.Rect(0, 0, 1, 1)), image.NewRGBA64(image.Rect(0, 0, 1, 1))
+	for _, tc := range []struct {
+		path string
+		img  image.Image
+		want string
+	}{
+		{"a.png", rgba, "png"},
+		{"a.png", deep, formatPNG16},
+		{"a.TIF", rgba, formatTIFF},
+		{"a.tiff", deep, formatTIFF},
+		{"a.exr", deep, formatEXR},
+	} {
+		if got := pixelFormat(tc.path, tc.img); got != tc.want {
+			t.Errorf("pixelFormat(%q, %T) = %q, want %q", tc.path, tc.img, got, tc.want)
+		}
+	}
+}
+
+func TestDeepSamples(t *testing.T) {
+	if k := deepSamples(1000, 1000, 0); k != 4 {
+		t.Fatalf("1000² takes %d samples a side, want 4", k)
+	}
+	if k := deepSamples(8000, 8000, 0); k != 2 {
+		t.Fatalf("8000² takes %d samples a side, want 2", k)
+	}
+	if k := deepSamples(3000, 2000, maxGPUSide); k != 2 {
+		t.Fatalf("GPU 3000×2000 takes %d samples a side, want 2", k)
+	}
+	if k := deepSamples(20000, 20000, maxGPUSide); k != 1 {
+		t.Fatalf("20000² takes %d samples a side, want 1", k)
+	}
+}
+
+func TestWritePixels16BitPNG(t *testing.T) {
+	img := image.NewRGBA64(image.Rect(0, 0, 2, 1))
+	img.SetRGBA64(1, 0, color.RGBA64{R: 0x1234, A: 0xffff})
+	full := filepath.Join(t.TempDir(), "deep", "out.png")
+	if err := writePixels(full, img, 300); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := got.At(1, 0).RGBA(); r != 0x1234 {
+		t.Fatalf("red = %#x, want 0x1234 (16 bits kept)", r)
+	}
+}
+
+func TestRenderDeepToFile(t *testing.T) {
+	s := newTestSketch(40, 30, func(s *Sketch, c *render.Context) {
+		c.MoveTo(0, 0)
+		c.LineTo(40, 30)
+		c.Stroke()
+	})
+	s.renderFrame()
+	full := filepath.Join(t.TempDir(), "out.tif")
+	if err := s.renderDeepToFile(full, formatTIFF, 2*DefaultDPI); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(80 * 60 * 16); st.Size() < want {
+		t.Fatalf("TIFF is %d bytes, want over %d for 80×60 float RGBA", st.Size(), want)
+	}
+}
@@ -0,0 +1,177 @@
+package sketchy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aldernero/debugui"
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+// Default plotting speeds for the estimate, in millimetres per second and
+// seconds per pen lift, roughly an AxiDraw at its default settings.
+const (
+	defaultDrawSpeed   = 25
+	defaultTravelSpeed = 75
+	defaultPenLiftTime = 0.25
+)
+
+// plotEstimateDelay is how many ticks the controls must rest before the
+// plot estimate is measured again, so dragging a slider measures once.
+const plotEstimateDelay = 15
+
+// plotEstimateKey identifies the settings an estimate was measured with.
+type plotEstimateKey struct {
+	layers   SVGLayerMode
+	optimize bool
+	hatch    bool
+	occlude  bool
+}
+
+// plotEstimate is a measured frame, as it would be plotted.
+type plotEstimate struct {
+	key   plotEstimateKey
+	stats plot.Stats // in sketch pixels
+	err   error
+}
+
+// drawBuiltinPlotEstimateRows is the Builtins "Plot estimate" section: the
+// current frame's paths, pen-down and pen-up distance, and plotting time at
+// the speeds set there. It follows the save dialogs' layer, hatching,
+// hidden-line and optimization settings and is measured in the background
+// by updatePlotEstimate, whether or not the section is open.
+func (s *Sketch) drawBuiltinPlotEstimateRows(ctx *debugui.Context) {
+	if s.usesGPUCanvas() {
+		return
+	}
+	ctx.IDScope("plotEstimate", func() {
+		ctx.Header("Plot estimate", false, func() {
+			ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+			ctx.Text("Draw mm/s")
+			ctx.IDScope("drawSpeed", func() {
+				ctx.NumberFieldF(&s.Plot.DrawSpeed, 1, 1).On(func() {
+					s.Plot.DrawSpeed = max(s.Plot.DrawSpeed, 1)
+				})
+			})
+			ctx.Text("Travel mm/s")
+			ctx.IDScope("travelSpeed", func() {
+				ctx.NumberFieldF(&s.Plot.TravelSpeed, 1, 1).On(func() {
+					s.Plot.TravelSpeed = max(s.Plot.TravelSpeed, 1)
+				})
+			})
+			ctx.SetGridLayout([]int{-1}, nil)
+			est, _ := s.plotEstimate.Load().(*plotEstimate)
+			switch {
+			case est == nil:
+				ctx.Text("Measuring…")
+			case est.err != nil:
+				ctx.Text("Estimate failed: " + est.err.Error())
+			default:
+				for _, line := range s.Plot.estimateReport(est.stats, 25.4/s.pxPerInch()) {
+					ctx.Text(line)
+				}
+			}
+		})
+	})
+}
+
+// updatePlotEstimate runs each tick. Once the first frame is drawn it
+// measures it, and after that it measures again plotEstimateDelay ticks
+// after the controls last changed, or when the plot settings change. An
+// animated sketch is not re-measured every frame.
+func (s *Sketch) updatePlotEstimate() {
+	if s.usesGPUCanvas() || s.frameGen.Load() == 0 {
+		return
+	}
+	if s.DidControlsChange {
+		s.plotEstimateStale = true
+		s.plotEstimateAt = s.Tick + plotEstimateDelay
+	}
+	if s.Tick >= s.plotEstimateAt {
+		s.refreshPlotEstimate()
+	}
+}
+
+// refreshPlotEstimate starts measuring the current frame unless the last
+// estimate covers it. With one in flight it does nothing, and the next
+// tick tries again.
+func (s *Sketch) refreshPlotEstimate() {
+	key := plotEstimateKey{
+		layers:   SVGLayerMode(s.svgLayerModeIdx),
+		optimize: s.Plot.OptimizePaths,
+		hatch:    s.Plot.HatchFills,
+		occlude:  s.Plot.HideOccluded,
+	}
+	if est, _ := s.plotEstimate.Load().(*plotEstimate); est != nil && est.key == key && !s.plotEstimateStale {
+		return
+	}
+	if !s.plotEstimating.CompareAndSwap(false, true) {
+		return
+	}
+	s.plotEstimateStale = false
+	po := s.Plot.snapshot()
+	go func() {
+		defer s.plotEstimating.Store(false)
+		s.plotEstimate.Store(s.measurePlot(key, po))
+	}()
+}
+
+// measurePlot measures the current frame as plotted with key's settings
+// and po, a copy of Sketch.Plot made on the ebiten thread.
+func (s *Sketch) measurePlot(key plotEstimateKey, po PlotOptions) *plotEstimate {
+	opts := svgSaveOptions{layers: key.layers, hatch: key.hatch, occlude: key.occlude, plot: po}
+	s.saveMutex.Lock()
+	doc, layers, err := s.layeredSVG(opts.sourceLayers())
+	s.saveMutex.Unlock()
+	if err != nil {
+		return &plotEstimate{key: key, err: err}
+	}
+	tol := po.FlattenTolerance
+	if tol <= 0 {
+		tol = defaultFlattenTolerance
+	}
+	d, err := plot.ParseSVG(doc.source(layers), tol)
+	if err != nil {
+		return &plotEstimate{key: key, err: err}
+	}
+	d = po.prepare(d, opts)
+	if key.optimize {
+		d = plot.Optimize(d, plot.OptimizeOptions{Tolerance: po.MergeTolerance})
+	}
+	return &plotEstimate{key: key, stats: plot.Measure(d)}
+}
+
+// plotTime estimates how long st takes to plot, with distances converted
+// at mmPerPx.
+func (o PlotOptions) plotTime(st plot.Stats, mmPerPx float64) time.Duration {
+	draw, travel, lift := o.DrawSpeed, o.TravelSpeed, o.PenLiftTime
+	if draw <= 0 {
+		draw = defaultDrawSpeed
+	}
+	if travel <= 0 {
+		travel = defaultTravelSpeed
+	}
+	if lift <= 0 {
+		lift = defaultPenLiftTime
+	}
+	sec := st.PenDown*mmPerPx/draw + st.PenUp*mmPerPx/travel + float64(st.Paths)*lift
+	return time.Duration(sec * float64(time.Second)).Round(time.Second)
+}
+
+// estimateReport is the estimate section's text.
+func (o PlotOptions) estimateReport(st plot.Stats, mmPerPx float64) []string {
+	return []string{
+		fmt.Sprintf("Paths: %d", st.Paths),
+		fmt.Sprintf("Pen down: %s", formatPlotDistance(st.PenDown*mmPerPx)),
+		fmt.Sprintf("Pen up: %s", formatPlotDistance(st.PenUp*mmPerPx)),
+		fmt.Sprintf("Plot time: ~%s", o.plotTime(st, mmPerPx)),
+	}
+}
+
+// formatPlotDistance formats millimetres as mm below a metre, else m.
+func formatPlotDistance(mm float64) string {
+	if mm < 1000 {
+		return fmt.Sprintf("%.0f mm", mm)
+	}
+	return fmt.Sprintf("%.2f m", mm/1000)
+}
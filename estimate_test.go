@@ -0,0 +1,91 @@
+package sketchy
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+func TestPlotTime(t *testing.T) {
+	opts := PlotOptions{DrawSpeed: 10, TravelSpeed: 50, PenLiftTime: 0.5}
+	st := plot.Stats{Paths: 4, PenDown: 1000, PenUp: 500} // mm at 1 mm/px
+	if got := opts.plotTime(st, 1); got != 112*time.Second {
+		t.Fatalf("plotTime = %v, want 1m52s", got)
+	}
+	got := strings.Join(opts.estimateReport(st, 1), "|")
+	if got != "Paths: 4|Pen down: 1.00 m|Pen up: 500 mm|Plot time: ~1m52s" {
+		t.Fatalf("estimateReport = %q", got)
+	}
+}
+
+func TestMeasurePlot(t *testing.T) {
+	s := newTestSketch(100, 100, func(s *Sketch, c *render.Context) {
+		c.MoveTo(10, 10)
+		c.LineTo(40, 50)
+		c.Stroke()
+	})
+	s.renderFrame()
+	est := s.measurePlot(plotEstimateKey{}, s.Plot.snapshot())
+	if est.err != nil {
+		t.Fatal(est.err)
+	}
+	if est.stats.Paths != 1 || math.Abs(est.stats.PenDown-50) > 1e-6 {
+		t.Fatalf("estimate = %+v", est)
+	}
+}
+
+func TestUpdatePlotEstimate(t *testing.T) {
+	lines := 1
+	s := newTestSketch(100, 100, func(s *Sketch, c *render.Context) {
+		for i := range lines {
+			c.MoveTo(10, float64(10+10*i))
+			c.LineTo(90, float64(10+10*i))
+			c.Stroke()
+		}
+	})
+	tick := func() *plotEstimate {
+		t.Helper()
+		s.updatePlotEstimate()
+		for s.plotEstimating.Load() {
+			time.Sleep(time.Millisecond)
+		}
+		s.DidControlsChange = false
+		s.Tick++
+		est, _ := s.plotEstimate.Load().(*plotEstimate)
+		return est
+	}
+
+	// Nothing is measured before the first frame, then the first frame is.
+	if est := tick(); est != nil {
+		t.Fatalf("measured before a frame: %+v", est)
+	}
+	s.renderFrame()
+	first := tick()
+	if first == nil || first.stats.Paths != 1 {
+		t.Fatalf("first estimate %+v", first)
+	}
+
+	// New frames alone, as an animated sketch draws, are not remeasured.
+	s.renderFrame()
+	if est := tick(); est != first {
+		t.Fatal("remeasured a frame without a control change")
+	}
+
+	// A control change is measured once the controls have rested, with the
+	// Builtins panel closed.
+	lines = 3
+	s.DidControlsChange = true
+	s.renderFrame()
+	for range plotEstimateDelay {
+		if est := tick(); est != first {
+			t.Fatalf("remeasured at tick %d, before the delay", s.Tick)
+		}
+	}
+	if est := tick(); est == first || est.stats.Paths != 3 {
+		t.Fatalf("after the delay: %+v", est)
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/aldernero/gaul v0.4.0
 	github.com/aldernero/palettedb v0.1.4
 	github.com/hajimehoshi/ebiten/v2 v2.10.0-alpha.12
+	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/lucasb-eyer/go-colorful v1.4.0
 	modernc.org/sqlite v1.53.0
 )
@@ -27,9 +28,11 @@ require (
 	github.com/aldernero/interp v0.0.0-20231114035812-9ab0a3e37bf6 // indirect
 	github.com/ebitengine/gomobile v0.0.0-20260211053922-3d992dae95d1 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.5.0-alpha.8 // indirect
 	github.com/ebitengine/purego v0.11.0-alpha.6 // indirect
 	github.com/go-text/typesetting v0.3.5-0.20260505125104-04e4e76f9371 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+	github.com/jfreymuth/pulse v0.1.1 // indirect
 	github.com/peterhellberg/gfx v0.0.0-20260528221839-3f985a9df2a8 // indirect
 	github.com/pierrec/lz4/v4 v4.1.27 // indirect
 	golang.org/x/image v0.44.0
@@ -0,0 +1,35 @@
+package sketchy
+
+import (
+	"github.com/aldernero/gaul"
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+// HatchFill draws a hatch fill of the region bounded by polygons into c:
+// parallel lines per opts, clipped to the region, stroked with c's current
+// stroke color and width (opts.PenWidth is not used). Polygons are closed
+// implicitly and combine with the even-odd rule, so a polygon inside
+// another cuts a hole. Unlike a Fill, the lines are real strokes in PNG,
+// SVG and plotter saves alike.
+func HatchFill(c *render.Context, opts HatchOptions, polygons ...[]gaul.Point) {
+	paths := make([]plot.Polyline, 0, len(polygons))
+	for _, poly := range polygons {
+		pl := make(plot.Polyline, len(poly))
+		for i, p := range poly {
+			pl[i] = plot.Point{X: p.X, Y: p.Y}
+		}
+		paths = append(paths, pl)
+	}
+	lines := plot.HatchPaths(paths, true, opts)
+	if len(lines) == 0 {
+		return
+	}
+	for _, l := range lines {
+		c.MoveTo(l[0].X, l[0].Y)
+		for _, p := range l[1:] {
+			c.LineTo(p.X, p.Y)
+		}
+	}
+	c.Stroke()
+}
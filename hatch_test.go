@@ -0,0 +1,45 @@
+package sketchy
+
+import (
+	"testing"
+
+	"github.com/aldernero/gaul"
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+func TestHatchFillDrawsStrokes(t *testing.T) {
+	s := newTestSketch(100, 100, func(s *Sketch, c *render.Context) {
+		outer := []gaul.Point{{X: 10, Y: 10}, {X: 90, Y: 10}, {X: 90, Y: 90}, {X: 10, Y: 90}}
+		hole := []gaul.Point{{X: 40, Y: 40}, {X: 60, Y: 40}, {X: 60, Y: 60}, {X: 40, Y: 60}}
+		HatchFill(c, HatchOptions{Spacing: 10}, outer, hole)
+	})
+	s.renderFrame()
+	src, err := s.svgBytes(s.recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := plot.ParseSVG(src, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lines at y = 20, 30, 70, 80 cross the square; 40 and 50 are split by
+	// the hole, and 60 runs along its edge.
+	if st := plot.Measure(d); st.Paths != 9 {
+		t.Fatalf("hatch drew %d lines", st.Paths)
+	}
+}
+
+func TestHatchRule(t *testing.T) {
+	opts := PlotOptions{
+		Hatch:        HatchOptions{Angle: 45},
+		HatchByColor: map[string]HatchOptions{"red": {Angle: 0, Cross: true}},
+	}
+	rule := opts.hatchRule()
+	if h, ok := rule("#ff0000"); !ok || !h.Cross {
+		t.Fatalf("red hatch = %+v, %v", h, ok)
+	}
+	if h, ok := rule("#00ff00"); !ok || h.Angle != 45 {
+		t.Fatalf("default hatch = %+v, %v", h, ok)
+	}
+}
@@ -0,0 +1,202 @@
+package sketchy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Environment variables set by `sketchy render` to ask a sketch's main to
+// render offscreen instead of opening a window; see HeadlessFromEnv. Init
+// refuses to open a window while EnvRenderOut is set, so a sketch whose
+// main doesn't handle the request fails instead of showing up on screen.
+// EnvRenderSeed is only set for an explicit --seed.
+const (
+	EnvRenderOut   = "SKETCHY_RENDER_OUT"
+	EnvRenderTicks = "SKETCHY_RENDER_TICKS"
+	EnvRenderSeed  = "SKETCHY_RENDER_SEED"
+	EnvRenderScale = "SKETCHY_RENDER_SCALE"
+)
+
+// HeadlessOptions configures [Sketch.RenderHeadless].
+type HeadlessOptions struct {
+	// OutPath is the output file; its extension (.png, .tif or .tiff for
+	// float TIFF, .exr, .svg, .pdf, .gcode or .hpgl) picks the format.
+	// Relative paths resolve against the working directory. PDFs use the
+	// sketch's PDFPage. PNG and SVG outputs embed the sketch state, as
+	// Save Image does (see Sketch.ApplyImageState).
+	OutPath string
+	// Scale is the PNG, TIFF or EXR raster scale, like the Builtins Export
+	// scale (1 = one raster pixel per sketch pixel). Zero uses RasterDPI.
+	// Ignored for vector formats.
+	Scale float64
+	// Seed, when set, overrides RandomSeed; any value, 0 included, is a
+	// seed.
+	Seed *int64
+	// SVGLayers and SplitLayers layer a vector output as Save Image does.
+	// Sketch.Plot.OptimizePaths applies as it does to EnqueueSave.
+	SVGLayers   SVGLayerMode
+	SplitLayers bool
+}
+
+// HeadlessFromEnv reads the render request `sketchy render` passes through
+// the environment. ok is false when the sketch was started normally.
+func HeadlessFromEnv() (opts HeadlessOptions, ticks int, ok bool) {
+	opts.OutPath = os.Getenv(EnvRenderOut)
+	if opts.OutPath == "" {
+		return opts, 0, false
+	}
+	ticks = 1
+	if v := os.Getenv(EnvRenderTicks); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			ticks = n
+		}
+	}
+	if v := os.Getenv(EnvRenderSeed); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			opts.Seed = &n
+		}
+	}
+	if v := os.Getenv(EnvRenderScale); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			opts.Scale = f
+		}
+	}
+	return opts, ticks, true
+}
+
+// InitHeadless is Init without a window: it registers controls and seeds
+// the RNG but creates no ebiten images, opens no sketch.db, and starts no
+// save worker. Call it instead of Init before [Sketch.RenderHeadless] when
+// setup code has to run on an initialized sketch first.
+func (s *Sketch) InitHeadless() {
+	s.initDefaults()
+	if s.usesGPUCanvas() {
+		return // RenderHeadless reports the error
+	}
+	s.initState()
+	s.showDebugUI = false
+}
+
+// RenderHeadless runs the sketch for ticks Update/Draw cycles without an
+// ebiten game loop and writes the final frame to opts.OutPath. Each tick
+// runs Updater and, when the frame is dirty, Drawer — the same order and
+// the same Rand consumption as the windowed loop, so a seed renders the
+// picture it shows on screen. Only the final frame is rasterized, which
+// matches what Save Image writes for that tick.
+//
+// The sketch is initialized with InitHeadless unless Init or InitHeadless
+// already ran. Shader and GPUDrawer sketches need a GPU and are rejected.
+func (s *Sketch) RenderHeadless(ticks int, opts HeadlessOptions) error {
+	if s.usesGPUCanvas() {
+		return fmt.Errorf("sketchy: headless rendering is only available for CPU Drawer sketches")
+	}
+	if s.Drawer == nil {
+		return fmt.Errorf("sketchy: headless rendering requires a Drawer")
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(opts.OutPath)), ".")
+	switch format {
+	case "tif":
+		format = formatTIFF
+	case "png", formatTIFF, formatEXR, "svg", formatPDF, formatGCode, formatHPGL:
+	default:
+		return fmt.Errorf("sketchy: headless output %q must end in .png, .tif, .exr, .svg, .pdf, .gcode or .hpgl", opts.OutPath)
+	}
+	if s.recorder == nil {
+		s.InitHeadless()
+		// InitHeadless seeds a zero RandomSeed from the clock; an explicit
+		// seed goes in after it.
+		if opts.Seed != nil {
+			s.setRandomSeed(*opts.Seed)
+		}
+	} else if opts.Seed != nil && *opts.Seed != s.RandomSeed {
+		s.setRandomSeed(*opts.Seed)
+	}
+	if ticks < 1 {
+		ticks = 1
+	}
+
+	for range ticks {
+		s.updateAudio(false)
+		s.updateTimeline()
+		s.updateModulators()
+		if s.Updater != nil {
+			s.Updater(s)
+		}
+		s.Tick++
+		if s.dirty {
+			s.recordFrame()
+			s.dirty = false
+		}
+		s.DidControlsChange = false
+		s.DidSlidersChange = false
+		s.DidTogglesChange = false
+		s.DidColorPickersChange = false
+		s.DidDropdownsChange = false
+		s.DidTextBoxesChange = false
+		s.DidModulatorsChange = false
+	}
+
+	full := opts.OutPath
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(s.workDir, full)
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	vec := svgSaveOptions{layers: opts.SVGLayers, split: opts.SplitLayers, optimize: s.Plot.OptimizePaths,
+		hatch: s.Plot.HatchFills, occlude: s.Plot.HideOccluded, plot: s.Plot.snapshot()}
+	meta := s.newSaveMetadata()
+	switch format {
+	case "svg":
+		_, files, err := s.renderLayeredSVGToFile(full, vec)
+		if err != nil {
+			return err
+		}
+		paths := []string{full}
+		for _, f := range files {
+			paths = append(paths, f.path)
+		}
+		return meta.embed(paths...)
+	case formatPDF:
+		return s.renderPDFToFile(full, s.PDFPage, vec.plot)
+	case formatGCode, formatHPGL:
+		_, err := s.renderPlotToFile(full, format, vec)
+		return err
+	}
+	dpi := s.RasterDPI
+	if opts.Scale > 0 {
+		dpi = opts.Scale * DefaultDPI
+	}
+	var err error
+	if format != "png" {
+		err = s.renderDeepToFile(full, format, dpi)
+	} else {
+		err = s.renderPNGToFile(full, dpi)
+	}
+	if err != nil {
+		return err
+	}
+	return meta.embed(full)
+}
+
+// recordFrame is renderFrame without the display raster: it re-records the
+// frame for saves only. Intermediate headless ticks never reach a screen, so
+// rasterizing them would be wasted work.
+func (s *Sketch) recordFrame() {
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+	s.recorder.Reset()
+	s.ctx = s.newFrameContext(nil)
+	// Mirrors renderFrame's clear rule so the recording (and so the saved
+	// file) is identical to the windowed one for the same tick.
+	if !s.DisableClearBetweenFrames || s.needToClear {
+		s.ctx.Clear(s.DefaultBackground)
+		s.needToClear = false
+	}
+	s.ctx.SetStrokeColor(s.DefaultForeground)
+	s.ctx.SetStrokeWidth(s.DefaultStrokeWidth)
+	s.Drawer(s, s.ctx)
+}
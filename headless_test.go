@@ -0,0 +1,123 @@
+package sketchy
+
+import (
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldernero/gaul"
+	"github.com/aldernero/gaul/render"
+)
+
+func TestRenderHeadless(t *testing.T) {
+	draws := 0
+	s := New(Config{SketchWidth: 100, SketchHeight: 50, RandomSeed: 7})
+	s.PaletteDBPath = filepath.Join(t.TempDir(), "none.db")
+	s.Updater = func(s *Sketch) {
+		if s.Tick == 3 {
+			s.MarkDirty()
+		}
+	}
+	s.Drawer = func(s *Sketch, c *render.Context) {
+		draws++
+		c.SetFillColor(color.RGBA{0, 255, 0, 255})
+		c.DrawRectangle(0, 0, 50, 50)
+		c.Fill()
+	}
+
+	out := filepath.Join(t.TempDir(), "sub", "out.png")
+	seed := int64(42)
+	if err := s.RenderHeadless(5, HeadlessOptions{OutPath: out, Scale: 2, Seed: &seed}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Tick != 5 {
+		t.Fatalf("Tick = %d, want 5", s.Tick)
+	}
+	// Dirty at init, then once more when the Updater marks tick 3.
+	if draws != 2 {
+		t.Fatalf("Drawer ran %d times, want 2", draws)
+	}
+	if s.RandomSeed != 42 {
+		t.Fatalf("RandomSeed = %d, want 42", s.RandomSeed)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(f)
+	_ = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 100 {
+		t.Fatalf("PNG is %dx%d, want 200x100", b.Dx(), b.Dy())
+	}
+	if r, g, _, _ := img.At(20, 20).RGBA(); r != 0 || g>>8 != 255 {
+		t.Fatalf("pixel (20,20) = %v, want green", img.At(20, 20))
+	}
+}
+
+func TestRenderHeadlessSeedZero(t *testing.T) {
+	var got []float64
+	s := New(Config{SketchWidth: 10, SketchHeight: 10})
+	s.PaletteDBPath = filepath.Join(t.TempDir(), "none.db")
+	s.Updater = func(s *Sketch) { got = append(got, s.Rand.Float64()) }
+	s.Drawer = func(*Sketch, *render.Context) {}
+	zero := int64(0)
+	out := filepath.Join(t.TempDir(), "out.svg")
+	if err := s.RenderHeadless(3, HeadlessOptions{OutPath: out, Seed: &zero}); err != nil {
+		t.Fatal(err)
+	}
+	if s.RandomSeed != 0 {
+		t.Fatalf("RandomSeed = %d, want 0", s.RandomSeed)
+	}
+	want := gaul.NewRng(0)
+	for i, v := range got {
+		if w := want.Float64(); v != w {
+			t.Fatalf("Rand draw %d = %v, want %v from seed 0", i, v, w)
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("%d Rand draws, want 3", len(got))
+	}
+}
+
+func TestRenderHeadlessRejects(t *testing.T) {
+	s := New(Config{SketchWidth: 10, SketchHeight: 10})
+	s.Drawer = func(*Sketch, *render.Context) {}
+	if err := s.RenderHeadless(1, HeadlessOptions{OutPath: "out.jpg"}); err == nil {
+		t.Fatal("expected an error for an unsupported extension")
+	}
+
+	sh := New(Config{SketchWidth: 10, SketchHeight: 10, ShaderSrc: []byte("package main")})
+	if err := sh.RenderHeadless(1, HeadlessOptions{OutPath: "out.png"}); err == nil {
+		t.Fatal("expected an error for a shader sketch")
+	}
+}
+
+func TestHeadlessFromEnv(t *testing.T) {
+	t.Setenv(EnvRenderOut, "")
+	if _, _, ok := HeadlessFromEnv(); ok {
+		t.Fatal("HeadlessFromEnv ok without SKETCHY_RENDER_OUT")
+	}
+	t.Setenv(EnvRenderOut, "/tmp/x.svg")
+	t.Setenv(EnvRenderTicks, "60")
+	t.Setenv(EnvRenderSeed, "-3")
+	t.Setenv(EnvRenderScale, "4")
+	opts, ticks, ok := HeadlessFromEnv()
+	if !ok || ticks != 60 || opts.Seed == nil || *opts.Seed != -3 || opts.Scale != 4 || opts.OutPath != "/tmp/x.svg" {
+		t.Fatalf("HeadlessFromEnv = %+v, %d, %v", opts, ticks, ok)
+	}
+	// Seed 0 is a seed; no seed keeps the sketch's own.
+	t.Setenv(EnvRenderSeed, "0")
+	if opts, _, _ = HeadlessFromEnv(); opts.Seed == nil || *opts.Seed != 0 {
+		t.Fatalf("seed 0 read as %v", opts.Seed)
+	}
+	t.Setenv(EnvRenderSeed, "")
+	if opts, _, _ = HeadlessFromEnv(); opts.Seed != nil {
+		t.Fatalf("no seed read as %d", *opts.Seed)
+	}
+}
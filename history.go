@@ -0,0 +1,119 @@
+package sketchy
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// maxHistory bounds the undo history, in steps.
+const maxHistory = 200
+
+// historyState is one undo step: the control and builtin state as a
+// snapshot stores it (snapshot.go).
+type historyState struct {
+	controls, builtins []byte
+}
+
+func (a historyState) equal(b historyState) bool {
+	return bytes.Equal(a.controls, b.controls) && bytes.Equal(a.builtins, b.builtins)
+}
+
+// controlHistory is the undo/redo history of control edits. cur is the
+// state last committed; undo holds the ones before it, oldest first.
+type controlHistory struct {
+	cur     historyState
+	undo    []historyState
+	redo    []historyState
+	pending bool // a change is waiting to be committed
+}
+
+// captureHistoryState serializes the current state; ok is false if it does
+// not serialize.
+func (s *Sketch) captureHistoryState() (st historyState, ok bool) {
+	var err error
+	if st.controls, err = s.serializeControlState(); err != nil {
+		fmt.Println("history:", err)
+		return st, false
+	}
+	if st.builtins, err = s.serializeBuiltinState(); err != nil {
+		fmt.Println("history builtin:", err)
+		return st, false
+	}
+	return st, true
+}
+
+// trackHistory commits the frame's control changes as an undo step. It runs
+// once per Update, after the Updater, so it sees every change UpdateControls
+// flagged, as well as seed changes, palette picks and snapshot loads. While
+// the primary mouse button is held the changes are only noted, so a slider
+// or color drag becomes one step when it is released.
+func (s *Sketch) trackHistory() {
+	h := &s.history
+	if h.cur.controls == nil {
+		if st, ok := s.captureHistoryState(); ok {
+			h.cur = st
+		}
+		return
+	}
+	if s.DidControlsChange {
+		h.pending = true
+	}
+	// Timeline playback is not an edit; where it stops is one step.
+	if h.pending && !s.sketchPrimaryMouseDown && !s.timeline.playing {
+		s.commitHistory()
+	}
+}
+
+// commitHistory pushes cur onto the undo history if the state has changed
+// since, and clears the redo history.
+func (s *Sketch) commitHistory() {
+	h := &s.history
+	h.pending = false
+	st, ok := s.captureHistoryState()
+	if !ok || st.equal(h.cur) {
+		return
+	}
+	h.undo = append(h.undo, h.cur)
+	if len(h.undo) > maxHistory {
+		h.undo = append(h.undo[:0], h.undo[len(h.undo)-maxHistory:]...)
+	}
+	h.redo = nil
+	h.cur = st
+}
+
+// Undo restores the control and builtin state (including the seed) from
+// before the last committed edit, returning false if there is nothing to
+// undo. Ctrl+Z calls it.
+func (s *Sketch) Undo() bool {
+	return s.stepHistory(&s.history.undo, &s.history.redo)
+}
+
+// Redo reapplies the edit the last Undo reverted, returning false if there
+// is nothing to redo. Ctrl+Shift+Z calls it.
+func (s *Sketch) Redo() bool {
+	return s.stepHistory(&s.history.redo, &s.history.undo)
+}
+
+// stepHistory moves cur onto to and restores the top of from.
+func (s *Sketch) stepHistory(from, to *[]historyState) bool {
+	h := &s.history
+	// An edit not yet committed (its drag still held) is what undo should
+	// revert first.
+	if h.pending {
+		s.commitHistory()
+	}
+	if len(*from) == 0 {
+		return false
+	}
+	st := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, h.cur)
+	h.cur = st
+	// The restore flags a change, which trackHistory finds equal to cur.
+	if _, err := s.applyControlStateJSON(st.controls); err != nil {
+		fmt.Println("history:", err)
+	} else if err := s.applyBuiltinStateJSON(st.builtins); err != nil {
+		fmt.Println("history builtin:", err)
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package sketchy
+
+import "testing"
+
+// historyEdit runs f as one frame's control change and lets history see it.
+func historyEdit(s *Sketch, f func()) {
+	f()
+	s.DidControlsChange = true
+	s.trackHistory()
+	s.DidControlsChange = false
+}
+
+func TestUndoRedoControlEdits(t *testing.T) {
+	s := newTextBoxSketch(t, func(ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+		ui.Checkbox("Fill", false)
+	})
+	s.DefaultStrokeWidth = 1 // as Init sets it, so restores round-trip
+	s.trackHistory()         // the starting state
+	historyEdit(s, func() { s.FloatSliders[0].Val = 3 })
+	historyEdit(s, func() { s.Toggles[0].Checked = true })
+	historyEdit(s, func() { s.setRandomSeed(7) })
+
+	if !s.Undo() || s.RandomSeed != 0 || !s.Toggles[0].Checked {
+		t.Fatalf("after undo: seed %d fill %v", s.RandomSeed, s.Toggles[0].Checked)
+	}
+	s.trackHistory() // the restore is not a new step
+	if !s.Undo() || s.Toggles[0].Checked || s.FloatSliders[0].Val != 3 {
+		t.Fatalf("after 2 undos: fill %v scale %v", s.Toggles[0].Checked, s.FloatSliders[0].Val)
+	}
+	if !s.Redo() || !s.Toggles[0].Checked {
+		t.Fatal("redo did not restore the toggle")
+	}
+	if !s.Undo() || !s.Undo() || s.FloatSliders[0].Val != 1 {
+		t.Fatalf("scale = %v, want the starting 1", s.FloatSliders[0].Val)
+	}
+	if s.Undo() {
+		t.Fatal("undo past the start")
+	}
+
+	// A new edit drops what could have been redone.
+	historyEdit(s, func() { s.FloatSliders[0].Val = 5 })
+	if s.Redo() {
+		t.Fatal("redo after a new edit")
+	}
+}
+
+func TestHistoryCoalescesDrags(t *testing.T) {
+	s := newTextBoxSketch(t, func(ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+	})
+	s.trackHistory()
+	s.sketchPrimaryMouseDown = true
+	for _, v := range []float64{2, 3, 4} {
+		historyEdit(s, func() { s.FloatSliders[0].Val = v })
+	}
+	s.sketchPrimaryMouseDown = false
+	s.trackHistory()
+	if !s.Undo() || s.FloatSliders[0].Val != 1 {
+		t.Fatalf("one undo left scale at %v, want 1", s.FloatSliders[0].Val)
+	}
+	if s.Undo() {
+		t.Fatal("a drag made more than one step")
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	s := newTextBoxSketch(t, func(*UI) {})
+	s.trackHistory()
+	for i := range maxHistory + 10 {
+		historyEdit(s, func() { s.setRandomSeed(int64(i + 1)) })
+	}
+	n := 0
+	for s.Undo() {
+		n++
+	}
+	if n != maxHistory || s.RandomSeed != 10 {
+		t.Fatalf("%d undos to seed %d, want %d to seed 10", n, s.RandomSeed, maxHistory)
+	}
+}
@@ -0,0 +1,250 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"runtime"
+	"sync"
+)
+
+// BandCount is the number of frequency bands a Frame reports.
+const BandCount = 8
+
+const (
+	fftSize = 2048
+	// bandLow and bandHigh bound the bands, spaced evenly in pitch.
+	bandLow  = 40.0
+	bandHigh = 16000.0
+	// dbRange maps the decibels below a file's loudest frame to 0..1.
+	dbRange = 60.0
+	// pulseHalfLife is how long Pulse takes to halve after an onset, in
+	// seconds.
+	pulseHalfLife = 0.1
+)
+
+// Frame is the analysis of one moment of a clip.
+type Frame struct {
+	// Bands is the energy in each band, from bass to treble: 1 at the
+	// band's loudest in the clip, 0 at 60 dB below that.
+	Bands [BandCount]float64
+	// RMS is the root mean square amplitude, 1 for a full-scale square
+	// wave.
+	RMS float64
+	// Level is RMS on the same scale as Bands.
+	Level float64
+	// Onset reports a note or beat starting at this frame.
+	Onset bool
+	// Pulse is 1 at an onset and halves every 0.1 s after it.
+	Pulse float64
+}
+
+// Analyze analyses c at fps frames per second, frame i describing the
+// fftSize samples centered on i/fps seconds. Every frame is computed up
+// front, so features never depend on playback timing and the whole clip
+// can be normalized.
+func Analyze(c *Clip, fps float64) []Frame {
+	mono := c.Mono()
+	n := int(math.Ceil(c.Duration() * fps))
+	if n == 0 {
+		return nil
+	}
+	frames := make([]Frame, n)
+	flux := make([]float64, n)
+	edges := bandEdges(c.SampleRate)
+
+	// Frames are split into contiguous chunks, one per worker; each worker
+	// starts one frame early so the spectral flux has its previous spectrum.
+	workers := min(runtime.GOMAXPROCS(0), n)
+	var wg sync.WaitGroup
+	for w := range workers {
+		lo, hi := n*w/workers, n*(w+1)/workers
+		wg.Go(func() {
+			a := newAnalyzer()
+			// Before the first frame is silence, so a clip that starts on a
+			// beat has an onset at frame 0.
+			prev := make([]float64, fftSize/2)
+			if lo > 0 {
+				prev = a.spectrum(mono, center(lo-1, fps, c.SampleRate))
+			}
+			for i := lo; i < hi; i++ {
+				mag := a.spectrum(mono, center(i, fps, c.SampleRate))
+				frames[i].RMS = rms(mono, center(i, fps, c.SampleRate))
+				for b := range BandCount {
+					var sum float64
+					for k := edges[b]; k < edges[b+1]; k++ {
+						sum += mag[k] * mag[k]
+					}
+					frames[i].Bands[b] = math.Sqrt(sum / float64(edges[b+1]-edges[b]))
+				}
+				// The clip cutting off mid-window is not an onset.
+				if center(i, fps, c.SampleRate)+fftSize/2 <= len(mono) {
+					for k, m := range mag {
+						flux[i] += max(0, math.Log1p(100*m)-math.Log1p(100*prev[k]))
+					}
+				}
+				prev, a.spare = mag, prev
+			}
+		})
+	}
+	wg.Wait()
+
+	normalize(frames)
+	markOnsets(frames, flux, fps)
+	return frames
+}
+
+// center is the sample frame i is centered on.
+func center(i int, fps float64, rate int) int {
+	return int(math.Round(float64(i) / fps * float64(rate)))
+}
+
+// bandEdges are the FFT bins bounding each band, at least one bin wide.
+func bandEdges(rate int) [BandCount + 1]int {
+	hi := min(bandHigh, float64(rate)/2)
+	var e [BandCount + 1]int
+	for b := range e {
+		f := bandLow * math.Pow(hi/bandLow, float64(b)/BandCount)
+		e[b] = int(math.Round(f * fftSize / float64(rate)))
+		if b > 0 && e[b] <= e[b-1] {
+			e[b] = e[b-1] + 1
+		}
+	}
+	return e
+}
+
+// analyzer holds one worker's FFT buffers.
+type analyzer struct {
+	buf   []complex128
+	spare []float64
+}
+
+// hann is the window applied before the FFT.
+var hann = func() []float64 {
+	w := make([]float64, fftSize)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/fftSize)
+	}
+	return w
+}()
+
+func newAnalyzer() *analyzer {
+	return &analyzer{buf: make([]complex128, fftSize)}
+}
+
+// spectrum returns the magnitudes of the windowed fftSize samples centered
+// on c, zero outside the clip.
+func (a *analyzer) spectrum(mono []float32, c int) []float64 {
+	start := c - fftSize/2
+	for i := range a.buf {
+		var v float64
+		if j := start + i; j >= 0 && j < len(mono) {
+			v = float64(mono[j]) * hann[i]
+		}
+		a.buf[i] = complex(v, 0)
+	}
+	fft(a.buf)
+	mag := a.spare
+	if mag == nil {
+		mag = make([]float64, fftSize/2)
+	}
+	a.spare = nil
+	// Scaled so a full-scale sine peaks near 1.
+	for k := range mag {
+		mag[k] = cmplx.Abs(a.buf[k]) * 4 / fftSize
+	}
+	return mag
+}
+
+// rms is the root mean square of the fftSize samples centered on c.
+func rms(mono []float32, c int) float64 {
+	var sum float64
+	for j := max(c-fftSize/2, 0); j < min(c+fftSize/2, len(mono)); j++ {
+		sum += float64(mono[j]) * float64(mono[j])
+	}
+	return math.Sqrt(sum / fftSize)
+}
+
+// fft transforms x in place; len(x) is a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, -2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := range size / 2 {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// normalize maps Bands and Level onto decibels below the clip's peaks.
+func normalize(frames []Frame) {
+	var peak [BandCount + 1]float64
+	for _, f := range frames {
+		for b, v := range f.Bands {
+			peak[b] = max(peak[b], v)
+		}
+		peak[BandCount] = max(peak[BandCount], f.RMS)
+	}
+	scale := func(v, p float64) float64 {
+		if p == 0 || v == 0 {
+			return 0
+		}
+		return max(0, 1+20*math.Log10(v/p)/dbRange)
+	}
+	for i := range frames {
+		for b := range BandCount {
+			frames[i].Bands[b] = scale(frames[i].Bands[b], peak[b])
+		}
+		frames[i].Level = scale(frames[i].RMS, peak[BandCount])
+	}
+}
+
+// markOnsets picks onsets from the spectral flux: a frame is one if its
+// flux is the largest within about 50 ms and clearly above the average
+// around it. Pulse then decays from each.
+func markOnsets(frames []Frame, flux []float64, fps float64) {
+	var mean float64
+	for _, f := range flux {
+		mean += f
+	}
+	mean /= float64(len(flux))
+	peakSpan := max(1, int(math.Round(0.05*fps)))
+	avgSpan := max(1, int(math.Round(0.15*fps)))
+	decay := math.Pow(0.5, 1/(pulseHalfLife*fps))
+	pulse := 0.0
+	for i, f := range flux {
+		onset := f > 0
+		for j := max(0, i-peakSpan); onset && j <= min(len(flux)-1, i+peakSpan); j++ {
+			// Ties go to the earliest frame.
+			onset = flux[j] < f || flux[j] == f && j >= i
+		}
+		if onset {
+			lo, hi := max(0, i-avgSpan), min(len(flux)-1, i+avgSpan)
+			var local float64
+			for _, v := range flux[lo : hi+1] {
+				local += v
+			}
+			local /= float64(hi - lo + 1)
+			onset = f > 1.5*local && f > 0.5*mean
+		}
+		pulse *= decay
+		if onset {
+			pulse = 1
+		}
+		frames[i].Onset, frames[i].Pulse = onset, pulse
+	}
+}
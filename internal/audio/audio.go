@@ -0,0 +1,96 @@
+// Package audio decodes WAV, FLAC and MP3 files to float PCM and analyses
+// them into per-frame features (band energies, RMS and onsets) for
+// audio-reactive sketches.
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Clip is decoded audio: interleaved samples in -1..1.
+type Clip struct {
+	SampleRate int
+	Channels   int
+	Samples    []float32
+}
+
+// Frames is the number of samples per channel.
+func (c *Clip) Frames() int {
+	if c.Channels == 0 {
+		return 0
+	}
+	return len(c.Samples) / c.Channels
+}
+
+// Duration is the length of the clip in seconds.
+func (c *Clip) Duration() float64 {
+	if c.SampleRate == 0 {
+		return 0
+	}
+	return float64(c.Frames()) / float64(c.SampleRate)
+}
+
+// Mono mixes the channels down to one.
+func (c *Clip) Mono() []float32 {
+	if c.Channels == 1 {
+		return c.Samples
+	}
+	n := c.Frames()
+	out := make([]float32, n)
+	for i := range n {
+		var sum float32
+		for ch := range c.Channels {
+			sum += c.Samples[i*c.Channels+ch]
+		}
+		out[i] = sum / float32(c.Channels)
+	}
+	return out
+}
+
+// Load decodes the audio file at path.
+func Load(path string) (*Clip, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Decode decodes a WAV, FLAC or MP3 file, told apart by their contents.
+func Decode(b []byte) (*Clip, error) {
+	var c *Clip
+	var err error
+	// Taggers sometimes put an ID3v2 tag in front of a FLAC stream too.
+	body := b
+	if len(b) >= 10 && bytes.HasPrefix(b, []byte("ID3")) {
+		n := 10 + (int(b[6])<<21 | int(b[7])<<14 | int(b[8])<<7 | int(b[9]))
+		if b[5]&0x10 != 0 { // footer
+			n += 10
+		}
+		body = b[min(n, len(b)):]
+	}
+	switch {
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WAVE":
+		c, err = decodeWAV(b)
+	case bytes.HasPrefix(body, []byte("fLaC")):
+		c, err = decodeFLAC(body)
+	case bytes.HasPrefix(b, []byte("ID3")) || len(b) >= 2 && b[0] == 0xff && b[1]&0xe0 == 0xe0:
+		c, err = decodeMP3(b)
+	default:
+		return nil, errors.New("not a WAV, FLAC or MP3 file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Frames() == 0 {
+		return nil, errors.New("no audio samples")
+	}
+	return c, nil
+}
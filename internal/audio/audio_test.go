@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// wav builds a RIFF WAVE file around samples already encoded for format
+// and bits.
+func wav(format, channels, rate, bits int, data []byte) []byte {
+	var b bytes.Buffer
+	le := func(v any) { binary.Write(&b, binary.LittleEndian, v) }
+	b.WriteString("RIFF")
+	le(uint32(4 + 8 + 16 + 8 + len(data)))
+	b.WriteString("WAVEfmt ")
+	le(uint32(16))
+	le(uint16(format))
+	le(uint16(channels))
+	le(uint32(rate))
+	le(uint32(rate * channels * bits / 8))
+	le(uint16(channels * bits / 8))
+	le(uint16(bits))
+	b.WriteString("data")
+	le(uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestDecodeWAV(t *testing.T) {
+	pcm := []int16{0, 16384, -32768, 32767}
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, pcm)
+	c, err := Decode(wav(wavePCM, 2, 22050, 16, data.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SampleRate != 22050 || c.Channels != 2 || c.Frames() != 2 {
+		t.Fatalf("%d Hz, %d channels, %d frames", c.SampleRate, c.Channels, c.Frames())
+	}
+	for i, want := range []float32{0, 0.5, -1, 32767.0 / 32768} {
+		if c.Samples[i] != want {
+			t.Fatalf("sample %d = %v, want %v", i, c.Samples[i], want)
+		}
+	}
+
+	data.Reset()
+	binary.Write(&data, binary.LittleEndian, []float32{0.25, -0.75, 1})
+	c, err = Decode(wav(waveFloat, 1, 48000, 32, data.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Frames() != 3 || c.Samples[1] != -0.75 || c.Duration() != 3.0/48000 {
+		t.Fatalf("float: %v over %vs", c.Samples, c.Duration())
+	}
+}
+
+func TestDecodeFLAC(t *testing.T) {
+	// sine.flac is five 1024-sample blocks at 8 kHz, one for each stereo
+	// decorrelation mode and a silent one: 440 Hz on the left and 660 Hz at
+	// half amplitude on the right.
+	c, err := Load("testdata/sine.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SampleRate != 8000 || c.Channels != 2 || c.Frames() != 5*1024 {
+		t.Fatalf("%d Hz, %d channels, %d frames", c.SampleRate, c.Channels, c.Frames())
+	}
+	for i := range c.Frames() {
+		var l, r float64
+		if i < 4*1024 {
+			l = math.Round(12000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+			r = math.Round(6000 * math.Sin(2*math.Pi*660*float64(i)/8000))
+		}
+		if c.Samples[2*i] != float32(l/32768) || c.Samples[2*i+1] != float32(r/32768) {
+			t.Fatalf("frame %d = %v, %v; want %v, %v", i, c.Samples[2*i]*32768, c.Samples[2*i+1]*32768, l, r)
+		}
+	}
+}
+
+func TestDecodeRejectsUnknown(t *testing.T) {
+	if _, err := Decode([]byte("OggS not supported")); err == nil {
+		t.Fatal("decoded an Ogg file")
+	}
+	if _, err := Decode(wav(wavePCM, 1, 44100, 16, nil)); err == nil {
+		t.Fatal("decoded an empty WAV file")
+	}
+}
+
+// tone is a mono clip built from f(t) at 44.1 kHz.
+func tone(seconds float64, f func(t float64) float64) *Clip {
+	c := &Clip{SampleRate: 44100, Channels: 1, Samples: make([]float32, int(seconds*44100))}
+	for i := range c.Samples {
+		c.Samples[i] = float32(f(float64(i) / 44100))
+	}
+	return c
+}
+
+func TestAnalyzeSine(t *testing.T) {
+	frames := Analyze(tone(1, func(t float64) float64 { return math.Sin(2 * math.Pi * 1000 * t) }), 60)
+	if len(frames) != 60 {
+		t.Fatalf("%d frames", len(frames))
+	}
+	// 1 kHz falls in the band spanning about 0.8 to 1.7 kHz.
+	f := frames[30]
+	for b, v := range f.Bands {
+		if b != 4 && v > 0.5 || b == 4 && v < 0.99 {
+			t.Fatalf("bands %.2f", f.Bands)
+		}
+	}
+	if math.Abs(f.RMS-math.Sqrt(0.5)) > 0.01 || f.Level < 0.99 {
+		t.Fatalf("RMS %v, level %v", f.RMS, f.Level)
+	}
+	// A steady tone only has an onset where it starts.
+	for i, f := range frames {
+		if f.Onset != (i == 0) {
+			t.Fatalf("onset at frame %d: %v", i, f.Onset)
+		}
+	}
+}
+
+func TestAnalyzeSilence(t *testing.T) {
+	for i, f := range Analyze(tone(0.5, func(float64) float64 { return 0 }), 60) {
+		if f != (Frame{}) {
+			t.Fatalf("frame %d = %+v", i, f)
+		}
+	}
+}
+
+func TestAnalyzeOnsets(t *testing.T) {
+	// Short noise bursts every half second, starting at 0.25 s.
+	noise := uint32(1)
+	c := tone(2, func(t float64) float64 {
+		noise = noise*1664525 + 1013904223
+		if math.Mod(t-0.25, 0.5) < 0.02 && t >= 0.25 {
+			return float64(int32(noise)) / (1 << 31)
+		}
+		return 0
+	})
+	frames := Analyze(c, 60)
+	var got []int
+	for i, f := range frames {
+		if f.Onset {
+			got = append(got, i)
+		}
+	}
+	// Frames are centered on their time, so an onset lands within a
+	// frame of the burst.
+	want := []int{15, 45, 75, 105}
+	if len(got) != len(want) {
+		t.Fatalf("onsets at frames %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] < want[i]-1 || got[i] > want[i]+1 {
+			t.Fatalf("onsets at frames %v, want %v", got, want)
+		}
+	}
+	on := got[0]
+	if frames[on].Pulse != 1 || math.Abs(frames[on+6].Pulse-0.5) > 1e-9 {
+		t.Fatalf("pulse %v, then %v", frames[on].Pulse, frames[on+6].Pulse)
+	}
+}
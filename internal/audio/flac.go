@@ -0,0 +1,393 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errFLACShort reports a stream that ends inside a frame.
+var errFLACShort = errors.New("flac: unexpected end of stream")
+
+// bitReader reads big-endian bit fields from a byte slice.
+type bitReader struct {
+	b   []byte
+	pos int // in bits
+}
+
+func (r *bitReader) bits(n int) (uint64, error) {
+	if r.pos+n > len(r.b)*8 {
+		return 0, errFLACShort
+	}
+	var v uint64
+	for n > 0 {
+		byt := r.b[r.pos>>3]
+		off := r.pos & 7
+		take := min(8-off, n)
+		v = v<<take | uint64(byt>>(8-off-take))&(1<<take-1)
+		r.pos += take
+		n -= take
+	}
+	return v, nil
+}
+
+// signed reads an n-bit two's complement value.
+func (r *bitReader) signed(n int) (int64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	v, err := r.bits(n)
+	return int64(v<<(64-n)) >> (64 - n), err
+}
+
+// unary counts zero bits up to the next one bit.
+func (r *bitReader) unary() (int, error) {
+	n := 0
+	for {
+		if r.pos >= len(r.b)*8 {
+			return 0, errFLACShort
+		}
+		if r.b[r.pos>>3]&(0x80>>(r.pos&7)) != 0 {
+			r.pos++
+			return n, nil
+		}
+		r.pos++
+		n++
+	}
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+// flacInfo is the STREAMINFO block.
+type flacInfo struct {
+	rate, channels, bps int
+	total               uint64
+}
+
+// decodeFLAC decodes a native FLAC stream. Checksums are not verified.
+func decodeFLAC(b []byte) (*Clip, error) {
+	r := &bitReader{b: b, pos: 32}
+	var info flacInfo
+	for last := false; !last; {
+		hdr, err := r.bits(32)
+		if err != nil {
+			return nil, err
+		}
+		last = hdr>>31 != 0
+		kind, size := int(hdr>>24&0x7f), int(hdr&0xffffff)
+		start := r.pos
+		if kind == 0 { // STREAMINFO
+			r.bits(80) // block and frame size bounds
+			rate, _ := r.bits(20)
+			ch, _ := r.bits(3)
+			bps, _ := r.bits(5)
+			total, err := r.bits(36)
+			if err != nil {
+				return nil, err
+			}
+			info = flacInfo{rate: int(rate), channels: int(ch) + 1, bps: int(bps) + 1, total: total}
+		}
+		r.pos = start + size*8
+	}
+	if info.rate == 0 {
+		return nil, errors.New("flac: missing STREAMINFO")
+	}
+	c := &Clip{SampleRate: info.rate, Channels: info.channels}
+	if info.total > 0 {
+		c.Samples = make([]float32, 0, int(info.total)*info.channels)
+	}
+	scale := 1 / float32(int64(1)<<(info.bps-1))
+	var chans [][]int64
+	// Stop at the stream's length if known, ignoring any trailing tag.
+	for r.pos+16 <= len(b)*8 && (info.total == 0 || uint64(len(c.Samples)) < info.total*uint64(info.channels)) {
+		var err error
+		chans, err = r.frame(info, chans)
+		if errors.Is(err, errFLACShort) && len(c.Samples) > 0 {
+			break // keep what a truncated file has
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range chans[0] {
+			for ch := range chans {
+				c.Samples = append(c.Samples, float32(chans[ch][i])*scale)
+			}
+		}
+	}
+	return c, nil
+}
+
+var flacSampleSizes = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+
+// frame decodes one frame into per-channel samples, reusing bufs.
+func (r *bitReader) frame(info flacInfo, bufs [][]int64) ([][]int64, error) {
+	sync, err := r.bits(15)
+	if err != nil {
+		return nil, err
+	}
+	if sync != 0x7ffc {
+		return nil, fmt.Errorf("flac: lost frame sync at byte %d", r.pos/8-2)
+	}
+	r.bits(1) // blocking strategy
+	h, err := r.bits(16)
+	if err != nil {
+		return nil, err
+	}
+	bsCode, rateCode := int(h>>12), int(h>>8&0xf)
+	assign, sizeCode := int(h>>4&0xf), int(h>>1&7)
+	// The coded frame or sample number: a UTF-8-like 1 to 7 byte integer.
+	first, err := r.bits(8)
+	if err != nil {
+		return nil, err
+	}
+	ones := 0
+	for mask := uint64(0x80); first&mask != 0; mask >>= 1 {
+		ones++
+	}
+	for i := 1; i < ones; i++ {
+		r.bits(8)
+	}
+	var size int
+	switch {
+	case bsCode == 1:
+		size = 192
+	case bsCode >= 2 && bsCode <= 5:
+		size = 576 << (bsCode - 2)
+	case bsCode == 6:
+		v, err := r.bits(8)
+		if err != nil {
+			return nil, err
+		}
+		size = int(v) + 1
+	case bsCode == 7:
+		v, err := r.bits(16)
+		if err != nil {
+			return nil, err
+		}
+		size = int(v) + 1
+	case bsCode >= 8:
+		size = 256 << (bsCode - 8)
+	default:
+		return nil, errors.New("flac: reserved block size")
+	}
+	switch rateCode {
+	case 12:
+		r.bits(8)
+	case 13, 14:
+		r.bits(16)
+	case 15:
+		return nil, errors.New("flac: invalid sample rate code")
+	}
+	r.bits(8) // CRC-8
+	bps := info.bps
+	if sizeCode != 0 {
+		if bps = flacSampleSizes[sizeCode]; bps == 0 {
+			return nil, errors.New("flac: reserved sample size")
+		}
+	}
+	channels := assign + 1
+	if assign >= 8 {
+		if assign > 10 {
+			return nil, errors.New("flac: reserved channel assignment")
+		}
+		channels = 2
+	}
+	if channels != info.channels {
+		return nil, fmt.Errorf("flac: frame has %d channels, stream %d", channels, info.channels)
+	}
+	if len(bufs) != channels {
+		bufs = make([][]int64, channels)
+	}
+	for ch := range channels {
+		sbps := bps
+		// The side channel carries one more bit.
+		if assign == 8 && ch == 1 || assign == 9 && ch == 0 || assign == 10 && ch == 1 {
+			sbps++
+		}
+		if cap(bufs[ch]) < size {
+			bufs[ch] = make([]int64, size)
+		}
+		bufs[ch] = bufs[ch][:size]
+		if err := r.subframe(bufs[ch], sbps); err != nil {
+			return nil, err
+		}
+	}
+	r.align()
+	r.bits(16) // CRC-16
+
+	switch assign {
+	case 8: // left, side
+		for i := range size {
+			bufs[1][i] = bufs[0][i] - bufs[1][i]
+		}
+	case 9: // side, right
+		for i := range size {
+			bufs[0][i] += bufs[1][i]
+		}
+	case 10: // mid, side
+		for i := range size {
+			mid, side := bufs[0][i]<<1|bufs[1][i]&1, bufs[1][i]
+			bufs[0][i], bufs[1][i] = (mid+side)>>1, (mid-side)>>1
+		}
+	}
+	return bufs, nil
+}
+
+var fixedCoefs = [5][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}
+
+// subframe decodes one channel of a frame into out.
+func (r *bitReader) subframe(out []int64, bps int) error {
+	h, err := r.bits(8)
+	if err != nil {
+		return err
+	}
+	kind := int(h >> 1 & 0x3f)
+	wasted := 0
+	if h&1 != 0 {
+		k, err := r.unary()
+		if err != nil {
+			return err
+		}
+		wasted = k + 1
+		bps -= wasted
+	}
+	switch {
+	case kind == 0: // constant
+		v, err := r.signed(bps)
+		if err != nil {
+			return err
+		}
+		for i := range out {
+			out[i] = v
+		}
+	case kind == 1: // verbatim
+		for i := range out {
+			if out[i], err = r.signed(bps); err != nil {
+				return err
+			}
+		}
+	case kind >= 8 && kind <= 12: // fixed predictor
+		order := kind - 8
+		for i := range order {
+			if out[i], err = r.signed(bps); err != nil {
+				return err
+			}
+		}
+		if err := r.residual(out, order); err != nil {
+			return err
+		}
+		restore(out, order, fixedCoefs[order], 0)
+	case kind >= 32: // LPC
+		order := kind - 31
+		for i := range order {
+			if out[i], err = r.signed(bps); err != nil {
+				return err
+			}
+		}
+		p, err := r.bits(4)
+		if err != nil {
+			return err
+		}
+		if p == 15 {
+			return errors.New("flac: invalid LPC precision")
+		}
+		shift, err := r.signed(5)
+		if err != nil {
+			return err
+		}
+		if shift < 0 {
+			return errors.New("flac: negative LPC shift")
+		}
+		coefs := make([]int64, order)
+		for i := range coefs {
+			if coefs[i], err = r.signed(int(p) + 1); err != nil {
+				return err
+			}
+		}
+		if err := r.residual(out, order); err != nil {
+			return err
+		}
+		restore(out, order, coefs, int(shift))
+	default:
+		return fmt.Errorf("flac: reserved subframe type %d", kind)
+	}
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// restore adds the prediction to the residuals in out[order:].
+func restore(out []int64, order int, coefs []int64, shift int) {
+	for i := order; i < len(out); i++ {
+		var sum int64
+		for j, c := range coefs {
+			sum += c * out[i-1-j]
+		}
+		out[i] += sum >> shift
+	}
+}
+
+// residual reads the Rice-coded residual into out[order:].
+func (r *bitReader) residual(out []int64, order int) error {
+	method, err := r.bits(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return errors.New("flac: reserved residual coding method")
+	}
+	paramBits, escape := 4, uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	po, err := r.bits(4)
+	if err != nil {
+		return err
+	}
+	parts := 1 << po
+	if len(out)%parts != 0 || len(out)/parts < order {
+		return errors.New("flac: bad residual partition order")
+	}
+	i := order
+	for p := range parts {
+		n := len(out) / parts
+		if p == 0 {
+			n -= order
+		}
+		k, err := r.bits(paramBits)
+		if err != nil {
+			return err
+		}
+		if k == escape {
+			w, err := r.bits(5)
+			if err != nil {
+				return err
+			}
+			for range n {
+				if out[i], err = r.signed(int(w)); err != nil {
+					return err
+				}
+				i++
+			}
+			continue
+		}
+		for range n {
+			q, err := r.unary()
+			if err != nil {
+				return err
+			}
+			low, err := r.bits(int(k))
+			if err != nil {
+				return err
+			}
+			u := uint64(q)<<k | low
+			out[i] = int64(u>>1) ^ -int64(u&1)
+			i++
+		}
+	}
+	return nil
+}
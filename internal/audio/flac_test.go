@@ -0,0 +1,279 @@
+package audio
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"testing"
+)
+
+// flacWriter writes the big-endian bit fields of a FLAC stream, to build
+// streams that each take a decoder path sine.flac does not.
+type flacWriter struct {
+	b []byte
+	n int // in bits
+}
+
+func (w *flacWriter) bits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		if v>>i&1 != 0 {
+			w.b[len(w.b)-1] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+func (w *flacWriter) signed(v int64, n int) {
+	w.bits(uint64(v)&(1<<n-1), n)
+}
+
+// unary writes n zero bits and a one bit.
+func (w *flacWriter) unary(n int) {
+	w.bits(1, n+1)
+}
+
+// newFLAC starts a stream with its STREAMINFO block.
+func newFLAC(rate, channels, bps, total int) *flacWriter {
+	w := &flacWriter{}
+	for _, c := range []byte("fLaC") {
+		w.bits(uint64(c), 8)
+	}
+	w.bits(1, 1) // last block
+	w.bits(0, 7)
+	w.bits(34, 24)
+	w.bits(0, 80) // block and frame size bounds
+	w.bits(uint64(rate), 20)
+	w.bits(uint64(channels-1), 3)
+	w.bits(uint64(bps-1), 5)
+	w.bits(uint64(total), 36)
+	w.bits(0, 128) // MD5
+	return w
+}
+
+// frame writes a frame of size samples per channel around subframes. The
+// rate and sample size come from STREAMINFO, and the CRCs are left zero
+// since the decoder does not check them.
+func (w *flacWriter) frame(size, assign int, subframes func()) {
+	w.bits(0x3ffe, 14)
+	w.bits(0, 2)
+	w.bits(7, 4) // 16-bit block size at the end of the header
+	w.bits(0, 4)
+	w.bits(uint64(assign), 4)
+	w.bits(0, 4)
+	w.bits(0, 8) // frame number
+	w.bits(uint64(size-1), 16)
+	w.bits(0, 8)
+	subframes()
+	for w.n%8 != 0 {
+		w.bits(0, 1)
+	}
+	w.bits(0, 16)
+}
+
+// subframeHeader writes a subframe's type and wasted bits, and returns x
+// and bps with the wasted bits taken off.
+func (w *flacWriter) subframeHeader(kind int, x []int64, bps, wasted int) ([]int64, int) {
+	w.bits(uint64(kind), 7) // a zero bit, then the type
+	if wasted == 0 {
+		w.bits(0, 1)
+		return x, bps
+	}
+	w.bits(1, 1)
+	w.unary(wasted - 1)
+	y := make([]int64, len(x))
+	for i, v := range x {
+		y[i] = v >> wasted
+	}
+	return y, bps - wasted
+}
+
+func (w *flacWriter) constant(x []int64, bps, wasted int) {
+	x, bps = w.subframeHeader(0, x, bps, wasted)
+	w.signed(x[0], bps)
+}
+
+func (w *flacWriter) verbatim(x []int64, bps, wasted int) {
+	x, bps = w.subframeHeader(1, x, bps, wasted)
+	for _, v := range x {
+		w.signed(v, bps)
+	}
+}
+
+// fixed writes x with the fixed predictor of order and its residual in
+// 1<<parts partitions, storing the escaped ones raw.
+func (w *flacWriter) fixed(x []int64, bps, wasted, order, parts int, escaped ...int) {
+	x, bps = w.subframeHeader(8+order, x, bps, wasted)
+	for _, v := range x[:order] {
+		w.signed(v, bps)
+	}
+	w.residual(x, fixedCoefs[order], 0, parts, escaped)
+}
+
+// lpc writes x with the LPC predictor coefs, stored at precision bits and
+// shifted right by shift, and its residual as fixed does.
+func (w *flacWriter) lpc(x []int64, bps, wasted int, coefs []int64, precision, shift, parts int, escaped ...int) {
+	x, bps = w.subframeHeader(31+len(coefs), x, bps, wasted)
+	for _, v := range x[:len(coefs)] {
+		w.signed(v, bps)
+	}
+	w.bits(uint64(precision-1), 4)
+	w.signed(int64(shift), 5)
+	for _, c := range coefs {
+		w.signed(c, precision)
+	}
+	w.residual(x, coefs, shift, parts, escaped)
+}
+
+// residual writes what x[len(coefs):] is off its prediction. Escaped
+// partitions are stored at the narrowest width that holds them, zero when
+// they are all zero; the others are Rice-coded, with 5-bit parameters when
+// one needs them.
+func (w *flacWriter) residual(x, coefs []int64, shift, parts int, escaped []int) {
+	order := len(coefs)
+	e := make([]int64, len(x))
+	for i := order; i < len(x); i++ {
+		var sum int64
+		for j, c := range coefs {
+			sum += c * x[i-1-j]
+		}
+		e[i] = x[i] - sum>>shift
+	}
+	zigzag := func(v int64) uint64 { return uint64(v<<1 ^ v>>63) }
+	n := len(x) >> parts
+	span := func(p int) []int64 { return e[max(p*n, order) : (p+1)*n] }
+	ks := make([]int, 1<<parts)
+	method := 0
+	for p := range ks {
+		var m uint64
+		for _, v := range span(p) {
+			m = max(m, zigzag(v))
+		}
+		if ks[p] = max(0, bits.Len64(m)-3); ks[p] >= 15 {
+			method = 1
+		}
+	}
+	w.bits(uint64(method), 2)
+	w.bits(uint64(parts), 4)
+	escape := uint64(15 + 16*method)
+	for p, k := range ks {
+		if slices.Contains(escaped, p) {
+			width := 0
+			for _, v := range span(p) {
+				if v != 0 {
+					width = max(width, bits.Len64(uint64(v^v>>63))+1)
+				}
+			}
+			w.bits(escape, 4+method)
+			w.bits(uint64(width), 5)
+			for _, v := range span(p) {
+				w.signed(v, width)
+			}
+			continue
+		}
+		w.bits(uint64(k), 4+method)
+		for _, v := range span(p) {
+			u := zigzag(v)
+			w.unary(int(u >> k))
+			w.bits(u, k)
+		}
+	}
+}
+
+// flacSignal is n samples of two tones and some noise, within ±amp.
+func flacSignal(n int, amp float64, seed uint32) []int64 {
+	x := make([]int64, n)
+	for i := range x {
+		seed = seed*1664525 + 1013904223
+		noise := float64(seed>>8)/(1<<24)*2 - 1
+		t := float64(i) / float64(n)
+		x[i] = int64(math.Round(amp * (0.6*math.Sin(2*math.Pi*7*t) + 0.3*math.Sin(2*math.Pi*23*t) + 0.05*noise)))
+	}
+	return x
+}
+
+func TestDecodeFLACPaths(t *testing.T) {
+	const n = 256
+	left, right := flacSignal(n, 20000, 1), flacSignal(n, 9000, 2)
+	side, mid := make([]int64, n), make([]int64, n)
+	for i := range n {
+		side[i], mid[i] = left[i]-right[i], (left[i]+right[i])>>1
+	}
+	// The last partition, a ramp, leaves no order-2 residual and escapes
+	// at zero bits.
+	ramp := flacSignal(n, 20000, 3)
+	for i := 3*n/4 - 2; i < n; i++ {
+		ramp[i] = int64(100 + 3*i)
+	}
+	mono24 := flacSignal(n, 1<<22, 4)
+	lpc32 := make([]int64, 32)
+	lpc32[0], lpc32[1] = 6000, -2000
+	for j := 2; j < 32; j++ {
+		lpc32[j] = int64(j%5-2) * 3
+	}
+	coarse, fine := flacSignal(n, 3000, 5), flacSignal(n, 8000, 6)
+	for i := range n {
+		coarse[i] *= 8
+		fine[i] *= 2
+	}
+	flat := make([]int64, n)
+	for i := range flat {
+		flat[i] = -7 << 4
+	}
+
+	for _, c := range []struct {
+		name      string
+		bps       int
+		assign    int
+		want      [][]int64
+		subframes func(w *flacWriter)
+	}{
+		{"left/side", 16, 8, [][]int64{left, right}, func(w *flacWriter) {
+			w.fixed(left, 16, 0, 2, 1)
+			w.fixed(side, 17, 0, 1, 2)
+		}},
+		{"right/side", 16, 9, [][]int64{left, right}, func(w *flacWriter) {
+			w.lpc(side, 17, 0, []int64{3, -1}, 4, 1, 0)
+			w.verbatim(right, 16, 0)
+		}},
+		{"mid/side", 16, 10, [][]int64{left, right}, func(w *flacWriter) {
+			w.fixed(mid, 16, 0, 3, 2)
+			w.fixed(side, 17, 0, 4, 0)
+		}},
+		{"LPC order 32", 24, 0, [][]int64{mono24}, func(w *flacWriter) {
+			w.lpc(mono24, 24, 0, lpc32, 14, 12, 3)
+		}},
+		{"escaped partitions", 16, 0, [][]int64{ramp}, func(w *flacWriter) {
+			w.fixed(ramp, 16, 0, 2, 2, 1, 3)
+		}},
+		{"wasted bits", 16, 1, [][]int64{coarse, fine}, func(w *flacWriter) {
+			w.verbatim(coarse, 16, 3)
+			w.lpc(fine, 16, 1, []int64{7, -3}, 5, 2, 1, 0)
+		}},
+		{"constant with wasted bits", 16, 0, [][]int64{flat}, func(w *flacWriter) {
+			w.constant(flat, 16, 4)
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			w := newFLAC(44100, len(c.want), c.bps, n)
+			w.frame(n, c.assign, func() { c.subframes(w) })
+			clip, err := decodeFLAC(w.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clip.Channels != len(c.want) || clip.Frames() != n {
+				t.Fatalf("%d channels, %d frames", clip.Channels, clip.Frames())
+			}
+			scale := 1 / float32(int64(1)<<(c.bps-1))
+			for i := range n {
+				for ch, want := range c.want {
+					if got := clip.Samples[i*len(c.want)+ch]; got != float32(want[i])*scale {
+						t.Fatalf("channel %d sample %d = %v, want %d", ch, i, got/scale, want[i])
+					}
+				}
+			}
+		})
+	}
+}
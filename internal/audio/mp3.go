@@ -0,0 +1,26 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// decodeMP3 decodes MPEG-1/2 layer III, always to stereo.
+func decodeMP3(b []byte) (*Clip, error) {
+	d, err := mp3.NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	pcm, err := io.ReadAll(d)
+	if err != nil {
+		return nil, err
+	}
+	c := &Clip{SampleRate: d.SampleRate(), Channels: 2, Samples: make([]float32, len(pcm)/2)}
+	for i := range c.Samples {
+		c.Samples[i] = float32(int16(binary.LittleEndian.Uint16(pcm[2*i:]))) / (1 << 15)
+	}
+	return c, nil
+}
@@ -0,0 +1,81 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	wavePCM        = 1
+	waveFloat      = 3
+	waveExtensible = 0xfffe
+)
+
+// decodeWAV decodes RIFF WAVE: integer PCM of 8 to 32 bits, or 32- and
+// 64-bit float, including WAVE_FORMAT_EXTENSIBLE files.
+func decodeWAV(b []byte) (*Clip, error) {
+	var format, channels, bits int
+	var rate int
+	var data []byte
+	haveFmt := false
+	for p := 12; p+8 <= len(b); {
+		id := string(b[p : p+4])
+		size := int(binary.LittleEndian.Uint32(b[p+4:]))
+		body := b[p+8 : min(p+8+size, len(b))]
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, errors.New("wav: short fmt chunk")
+			}
+			format = int(binary.LittleEndian.Uint16(body))
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			rate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+			if format == waveExtensible {
+				if len(body) < 26 {
+					return nil, errors.New("wav: short extensible fmt chunk")
+				}
+				// The sub-format GUID starts with the format code.
+				format = int(binary.LittleEndian.Uint16(body[24:]))
+			}
+			haveFmt = true
+		case "data":
+			data = body
+		}
+		p += 8 + size + size&1 // chunks are padded to even sizes
+	}
+	if !haveFmt || data == nil {
+		return nil, errors.New("wav: missing fmt or data chunk")
+	}
+	if channels < 1 || rate < 1 {
+		return nil, fmt.Errorf("wav: %d channels at %d Hz", channels, rate)
+	}
+	width := (bits + 7) / 8
+	var sample func([]byte) float32
+	switch {
+	case format == wavePCM && width == 1:
+		sample = func(p []byte) float32 { return (float32(p[0]) - 128) / 128 }
+	case format == wavePCM && width == 2:
+		sample = func(p []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(p))) / (1 << 15) }
+	case format == wavePCM && width == 3:
+		sample = func(p []byte) float32 {
+			return float32(int32(uint32(p[0])<<8|uint32(p[1])<<16|uint32(p[2])<<24)>>8) / (1 << 23)
+		}
+	case format == wavePCM && width == 4:
+		sample = func(p []byte) float32 { return float32(float64(int32(binary.LittleEndian.Uint32(p))) / (1 << 31)) }
+	case format == waveFloat && width == 4:
+		sample = func(p []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(p)) }
+	case format == waveFloat && width == 8:
+		sample = func(p []byte) float32 { return float32(math.Float64frombits(binary.LittleEndian.Uint64(p))) }
+	default:
+		return nil, fmt.Errorf("wav: unsupported format %d with %d bits", format, bits)
+	}
+	n := len(data) / (width * channels) * channels
+	c := &Clip{SampleRate: rate, Channels: channels, Samples: make([]float32, n)}
+	for i := range n {
+		c.Samples[i] = sample(data[i*width:])
+	}
+	return c, nil
+}
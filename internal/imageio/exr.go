@@ -0,0 +1,77 @@
+package imageio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// WriteEXR writes img as an uncompressed scanline OpenEXR of 32-bit float
+// RGBA. EXR holds linear light with premultiplied alpha, so the sRGB
+// values img was drawn with are decoded.
+func WriteEXR(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width == 0 || height == 0 {
+		return fmt.Errorf("imageio: empty image")
+	}
+	le := binary.LittleEndian
+	var head []byte
+	head = append(head, 0x76, 0x2f, 0x31, 0x01, 2, 0, 0, 0) // magic, version 2, scanline
+	attr := func(name, typ string, value []byte) {
+		head = append(head, name...)
+		head = append(head, 0)
+		head = append(head, typ...)
+		head = append(head, 0)
+		head = le.AppendUint32(head, uint32(len(value)))
+		head = append(head, value...)
+	}
+	var chlist []byte
+	for _, c := range "ABGR" { // channels are stored alphabetically
+		chlist = append(chlist, byte(c), 0)
+		chlist = le.AppendUint32(chlist, 2) // FLOAT
+		chlist = append(chlist, 0, 0, 0, 0) // pLinear, reserved
+		chlist = le.AppendUint32(chlist, 1) // x sampling
+		chlist = le.AppendUint32(chlist, 1) // y sampling
+	}
+	chlist = append(chlist, 0)
+	box := le.AppendUint32(le.AppendUint32(le.AppendUint32(le.AppendUint32(nil, 0), 0), uint32(width-1)), uint32(height-1))
+	one := le.AppendUint32(nil, math.Float32bits(1))
+	attr("channels", "chlist", chlist)
+	attr("compression", "compression", []byte{0})
+	attr("dataWindow", "box2i", box)
+	attr("displayWindow", "box2i", box)
+	attr("lineOrder", "lineOrder", []byte{0})
+	attr("pixelAspectRatio", "float", one)
+	attr("screenWindowCenter", "v2f", make([]byte, 8))
+	attr("screenWindowWidth", "float", one)
+	head = append(head, 0)
+
+	lineLen := 8 + 16*width // y, size, then 4 channels of floats
+	base := uint64(len(head)) + 8*uint64(height)
+	for y := range height {
+		head = le.AppendUint64(head, base+uint64(y)*uint64(lineLen))
+	}
+	bw := bufio.NewWriterSize(w, 1<<20)
+	if _, err := bw.Write(head); err != nil {
+		return err
+	}
+	line := make([]byte, lineLen)
+	for y := range height {
+		le.PutUint32(line, uint32(y))
+		le.PutUint32(line[4:], uint32(16*width))
+		for x := range width {
+			p := linear(floatPixel(img, b.Min.X+x, b.Min.Y+y))
+			for i, c := range [4]int{3, 2, 1, 0} { // A, B, G, R
+				le.PutUint32(line[8+4*(i*width+x):], math.Float32bits(p[c]))
+			}
+		}
+		if _, err := bw.Write(line); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,62 @@
+// Package imageio writes raster saves deeper than 8 bits per channel:
+// 32-bit float TIFF and OpenEXR, and the box filter that turns a
+// supersampled 8-bit capture into 16-bit pixels.
+package imageio
+
+import (
+	"image"
+	"math"
+)
+
+// Downsample averages each k×k block of src into one pixel of dst, at
+// src's bounds divided by k, so a band of a supersampled capture fills its
+// rows of dst. Values stay premultiplied; the average of k² 8-bit samples
+// keeps the in-between levels a single 8-bit pixel rounds away.
+func Downsample(dst *image.RGBA64, src *image.RGBA, k int) {
+	sb := src.Bounds()
+	n := uint32(k * k)
+	for y := sb.Min.Y / k; y < sb.Max.Y/k; y++ {
+		for x := sb.Min.X / k; x < sb.Max.X/k; x++ {
+			var sum [4]uint32
+			for sy := y * k; sy < (y+1)*k; sy++ {
+				row := src.Pix[src.PixOffset(x*k, sy):]
+				for i := 0; i < 4*k; i += 4 {
+					sum[0] += uint32(row[i])
+					sum[1] += uint32(row[i+1])
+					sum[2] += uint32(row[i+2])
+					sum[3] += uint32(row[i+3])
+				}
+			}
+			o := dst.PixOffset(x, y)
+			for c, v := range sum {
+				v = (v*257 + n/2) / n
+				dst.Pix[o+2*c] = uint8(v >> 8)
+				dst.Pix[o+2*c+1] = uint8(v)
+			}
+		}
+	}
+}
+
+// floatPixel is img's pixel at x, y as premultiplied floats in [0, 1].
+func floatPixel(img image.Image, x, y int) [4]float32 {
+	r, g, b, a := img.At(x, y).RGBA()
+	return [4]float32{float32(r) / 0xffff, float32(g) / 0xffff, float32(b) / 0xffff, float32(a) / 0xffff}
+}
+
+// linear converts a premultiplied sRGB pixel to premultiplied linear light.
+func linear(p [4]float32) [4]float32 {
+	a := p[3]
+	if a == 0 {
+		return [4]float32{}
+	}
+	for c := range 3 {
+		v := float64(p[c] / a)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		p[c] = float32(v) * a
+	}
+	return p
+}
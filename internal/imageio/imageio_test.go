@@ -0,0 +1,100 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDownsample(t *testing.T) {
+	// A 2×2-supersampled band covering rows 2 and 3 of a 3×4 image.
+	src := image.NewRGBA(image.Rect(0, 4, 6, 8))
+	for y := 4; y < 8; y++ {
+		for x := 0; x < 6; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(x), A: 255})
+		}
+	}
+	dst := image.NewRGBA64(image.Rect(0, 0, 3, 4))
+	Downsample(dst, src, 2)
+	if got := dst.RGBA64At(0, 0); got != (color.RGBA64{}) {
+		t.Fatalf("row 0 was written: %v", got)
+	}
+	// Samples 2 and 3 average to 2.5 of 255, between two 8-bit levels.
+	if got := dst.RGBA64At(1, 3); got.R != (5*257+1)/2 || got.A != 0xffff {
+		t.Fatalf("pixel 1,3 = %v", got)
+	}
+}
+
+func testImage() *image.RGBA64 {
+	img := image.NewRGBA64(image.Rect(0, 0, 3, 2))
+	img.SetRGBA64(0, 0, color.RGBA64{R: 0xffff, A: 0xffff})
+	img.SetRGBA64(2, 1, color.RGBA64{G: 0x4000, B: 0x4000, A: 0x8000}) // half-covered
+	return img
+}
+
+func TestWriteFloatTIFF(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteFloatTIFF(&b, testImage(), 300); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	le := binary.LittleEndian
+	if string(data[:4]) != "II*\x00" {
+		t.Fatalf("header %q", data[:4])
+	}
+	ifd := le.Uint32(data[4:])
+	fields := map[uint16][2]uint32{} // count, value or offset
+	for i := range int(le.Uint16(data[ifd:])) {
+		e := data[ifd+2+12*uint32(i):]
+		fields[le.Uint16(e)] = [2]uint32{le.Uint32(e[4:]), le.Uint32(e[8:])}
+	}
+	if fields[256][1] != 3 || fields[257][1] != 2 || fields[277][1] != 4 || fields[338][1] != 1 {
+		t.Fatalf("fields = %v", fields)
+	}
+	if f := fields[339]; f[0] != 4 || le.Uint16(data[f[1]:]) != 3 {
+		t.Fatalf("SampleFormat = %v", f)
+	}
+	if f := fields[282]; le.Uint32(data[f[1]:])/le.Uint32(data[f[1]+4:]) != 300 {
+		t.Fatalf("XResolution = %v", data[f[1]:f[1]+8])
+	}
+	px := func(x, y, c int) float32 {
+		off := fields[273][1] // one strip, so the offset is inline
+		return math.Float32frombits(le.Uint32(data[int(off)+16*(3*y+x)+4*c:]))
+	}
+	if px(0, 0, 0) != 1 || px(0, 0, 3) != 1 || math.Abs(float64(px(2, 1, 1))-0.25) > 1e-4 || math.Abs(float64(px(2, 1, 3))-0.5) > 1e-4 {
+		t.Fatalf("pixels = %v %v", px(0, 0, 0), px(2, 1, 1))
+	}
+}
+
+func TestWriteEXR(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteEXR(&b, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	le := binary.LittleEndian
+	if !bytes.HasPrefix(data, []byte{0x76, 0x2f, 0x31, 0x01, 2, 0, 0, 0}) {
+		t.Fatalf("magic % x", data[:8])
+	}
+	if !bytes.Contains(data, []byte("dataWindow\x00box2i\x00")) {
+		t.Fatal("no dataWindow")
+	}
+	end := bytes.Index(data, []byte("screenWindowWidth\x00float\x00")) + len("screenWindowWidth\x00float\x00") + 4 + 4 + 1
+	if data[end-1] != 0 {
+		t.Fatalf("header does not end at %d", end)
+	}
+	line := le.Uint64(data[end+8:]) // second scanline
+	if y := le.Uint32(data[line:]); y != 1 {
+		t.Fatalf("scanline y = %d", y)
+	}
+	ch := func(i, x int) float32 { // channel i of A, B, G, R
+		return math.Float32frombits(le.Uint32(data[line+8+uint64(4*(i*3+x)):]))
+	}
+	// 0.5 sRGB at half coverage is 0.214 linear, premultiplied to 0.107.
+	if a, g := ch(0, 2), ch(2, 2); math.Abs(float64(a)-0.5) > 1e-4 || math.Abs(float64(g)-0.107) > 1e-3 {
+		t.Fatalf("pixel 2,1: A %v G %v", a, g)
+	}
+}
@@ -0,0 +1,111 @@
+package imageio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// TIFF field types.
+const (
+	tiffShort    = 3
+	tiffLong     = 4
+	tiffRational = 5
+)
+
+type tiffField struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte // little-endian values
+}
+
+// WriteFloatTIFF writes img as an uncompressed little-endian TIFF of 32-bit
+// float RGBA, premultiplied (associated alpha), in the sRGB values it was
+// drawn with. dpi > 0 records the resolution.
+func WriteFloatTIFF(w io.Writer, img image.Image, dpi float64) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	rowBytes := 16 * width
+	dataLen := uint64(rowBytes) * uint64(height)
+	if width == 0 || height == 0 || dataLen > math.MaxUint32-1<<20 {
+		return fmt.Errorf("imageio: %dx%d is too large for a TIFF", width, height)
+	}
+	rowsPerStrip := max(1, min(height, (1<<20)/rowBytes))
+	var offsets, counts []byte
+	for y := 0; y < height; y += rowsPerStrip {
+		offsets = binary.LittleEndian.AppendUint32(offsets, uint32(8+y*rowBytes))
+		counts = binary.LittleEndian.AppendUint32(counts, uint32(min(rowsPerStrip, height-y)*rowBytes))
+	}
+	shorts := func(v ...uint16) []byte {
+		var out []byte
+		for _, x := range v {
+			out = binary.LittleEndian.AppendUint16(out, x)
+		}
+		return out
+	}
+	long := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	fields := []tiffField{
+		{256, tiffLong, 1, long(uint32(width))},
+		{257, tiffLong, 1, long(uint32(height))},
+		{258, tiffShort, 4, shorts(32, 32, 32, 32)}, // BitsPerSample
+		{259, tiffShort, 1, shorts(1)},              // Compression: none
+		{262, tiffShort, 1, shorts(2)},              // Photometric: RGB
+		{273, tiffLong, uint32(len(offsets) / 4), offsets},
+		{277, tiffShort, 1, shorts(4)}, // SamplesPerPixel
+		{278, tiffLong, 1, long(uint32(rowsPerStrip))},
+		{279, tiffLong, uint32(len(counts) / 4), counts},
+	}
+	if dpi > 0 {
+		res := binary.LittleEndian.AppendUint32(long(uint32(math.Round(dpi*1000))), 1000)
+		fields = append(fields, tiffField{282, tiffRational, 1, res}, tiffField{283, tiffRational, 1, res})
+	}
+	fields = append(fields, tiffField{284, tiffShort, 1, shorts(1)}) // PlanarConfig: chunky
+	if dpi > 0 {
+		fields = append(fields, tiffField{296, tiffShort, 1, shorts(2)}) // ResolutionUnit: inch
+	}
+	fields = append(fields,
+		tiffField{338, tiffShort, 1, shorts(1)},          // ExtraSamples: associated alpha
+		tiffField{339, tiffShort, 4, shorts(3, 3, 3, 3)}, // SampleFormat: IEEE float
+	)
+
+	bw := bufio.NewWriterSize(w, 1<<20)
+	ifdOff := 8 + uint32(dataLen)
+	bw.WriteString("II*\x00")
+	bw.Write(long(ifdOff))
+	row := make([]byte, rowBytes)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := floatPixel(img, x, y)
+			for c, v := range p {
+				binary.LittleEndian.PutUint32(row[16*(x-b.Min.X)+4*c:], math.Float32bits(v))
+			}
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	// The IFD, then the values too long to sit in their entries.
+	ext := ifdOff + 2 + 12*uint32(len(fields)) + 4
+	var ifd, extra []byte
+	ifd = binary.LittleEndian.AppendUint16(ifd, uint16(len(fields)))
+	for _, f := range fields {
+		ifd = binary.LittleEndian.AppendUint16(ifd, f.tag)
+		ifd = binary.LittleEndian.AppendUint16(ifd, f.typ)
+		ifd = binary.LittleEndian.AppendUint32(ifd, f.count)
+		if len(f.data) <= 4 {
+			ifd = append(ifd, f.data...)
+			ifd = append(ifd, make([]byte, 4-len(f.data))...)
+			continue
+		}
+		ifd = binary.LittleEndian.AppendUint32(ifd, ext+uint32(len(extra)))
+		extra = append(extra, f.data...)
+	}
+	ifd = binary.LittleEndian.AppendUint32(ifd, 0) // no next IFD
+	bw.Write(ifd)
+	bw.Write(extra)
+	return bw.Flush()
+}
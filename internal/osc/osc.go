@@ -0,0 +1,235 @@
+// Package osc encodes and decodes Open Sound Control 1.0 messages and
+// bundles, the UDP packets TouchOSC, Max, Pure Data and most live-show
+// tools exchange.
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Message is an OSC message: an address pattern and its arguments. Args
+// hold int32, float32, string, []byte, bool, int64 or float64 values; nil
+// is OSC's Nil. Decoding yields the same types.
+type Message struct {
+	Address string
+	Args    []any
+}
+
+// MarshalBinary encodes m as an OSC packet.
+func (m Message) MarshalBinary() ([]byte, error) {
+	if len(m.Address) == 0 || m.Address[0] != '/' {
+		return nil, fmt.Errorf("osc: address %q does not start with /", m.Address)
+	}
+	tags := []byte{','}
+	var args bytes.Buffer
+	for _, a := range m.Args {
+		switch v := a.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			binary.Write(&args, binary.BigEndian, v)
+		case float32:
+			tags = append(tags, 'f')
+			binary.Write(&args, binary.BigEndian, math.Float32bits(v))
+		case string:
+			tags = append(tags, 's')
+			writeString(&args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			binary.Write(&args, binary.BigEndian, int32(len(v)))
+			args.Write(v)
+			args.Write(make([]byte, pad(len(v))))
+		case bool:
+			if v {
+				tags = append(tags, 'T')
+			} else {
+				tags = append(tags, 'F')
+			}
+		case int64:
+			tags = append(tags, 'h')
+			binary.Write(&args, binary.BigEndian, v)
+		case float64:
+			tags = append(tags, 'd')
+			binary.Write(&args, binary.BigEndian, math.Float64bits(v))
+		case nil:
+			tags = append(tags, 'N')
+		default:
+			return nil, fmt.Errorf("osc: cannot encode %T argument", a)
+		}
+	}
+	var b bytes.Buffer
+	writeString(&b, m.Address)
+	writeString(&b, string(tags))
+	b.Write(args.Bytes())
+	return b.Bytes(), nil
+}
+
+// writeString writes s NUL-terminated and padded to 4 bytes.
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteString(s)
+	b.Write(make([]byte, 4-len(s)%4))
+}
+
+// pad is the padding after n bytes of blob data.
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+var errShort = errors.New("osc: truncated packet")
+
+// Parse decodes a packet into its messages. A bundle yields the messages
+// it contains, nested bundles flattened in order; time tags are ignored,
+// so bundled messages apply on arrival.
+func Parse(b []byte) ([]Message, error) {
+	if len(b) == 0 || len(b)%4 != 0 {
+		return nil, errShort
+	}
+	if b[0] == '/' {
+		m, err := parseMessage(b)
+		if err != nil {
+			return nil, err
+		}
+		return []Message{m}, nil
+	}
+	rest, ok := bytes.CutPrefix(b, []byte("#bundle\x00"))
+	if !ok {
+		return nil, fmt.Errorf("osc: packet is neither a message nor a bundle")
+	}
+	if len(rest) < 8 {
+		return nil, errShort
+	}
+	rest = rest[8:] // time tag
+	var msgs []Message
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, errShort
+		}
+		n := int(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		if n > len(rest) {
+			return nil, errShort
+		}
+		ms, err := Parse(rest[:n])
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, ms...)
+		rest = rest[n:]
+	}
+	return msgs, nil
+}
+
+func parseMessage(b []byte) (Message, error) {
+	var m Message
+	addr, b, err := readString(b)
+	if err != nil {
+		return m, err
+	}
+	m.Address = addr
+	if len(b) == 0 {
+		return m, nil // old senders omit the type tags of an empty message
+	}
+	tags, b, err := readString(b)
+	if err != nil {
+		return m, err
+	}
+	if len(tags) == 0 || tags[0] != ',' {
+		return m, fmt.Errorf("osc: %s: missing type tags", addr)
+	}
+	for _, t := range []byte(tags[1:]) {
+		var a any
+		switch t {
+		case 'i', 'f', 'r', 'c':
+			if len(b) < 4 {
+				return m, errShort
+			}
+			u := binary.BigEndian.Uint32(b)
+			b = b[4:]
+			switch t {
+			case 'f':
+				a = math.Float32frombits(u)
+			case 'i':
+				a = int32(u)
+			default:
+				continue // RGBA colors and chars have no Go mapping here
+			}
+		case 'h', 'd', 't':
+			if len(b) < 8 {
+				return m, errShort
+			}
+			u := binary.BigEndian.Uint64(b)
+			b = b[8:]
+			switch t {
+			case 'h':
+				a = int64(u)
+			case 'd':
+				a = math.Float64frombits(u)
+			default:
+				continue
+			}
+		case 's', 'S':
+			var s string
+			if s, b, err = readString(b); err != nil {
+				return m, err
+			}
+			a = s
+		case 'b':
+			if len(b) < 4 {
+				return m, errShort
+			}
+			n := int(binary.BigEndian.Uint32(b))
+			b = b[4:]
+			if n < 0 || n+pad(n) > len(b) {
+				return m, errShort
+			}
+			a = bytes.Clone(b[:n])
+			b = b[n+pad(n):]
+		case 'T':
+			a = true
+		case 'F':
+			a = false
+		case 'N', 'I':
+			a = nil
+		default:
+			return m, fmt.Errorf("osc: %s: unknown type tag %q", addr, t)
+		}
+		m.Args = append(m.Args, a)
+	}
+	return m, nil
+}
+
+// readString reads a NUL-terminated, 4-byte padded string.
+func readString(b []byte) (string, []byte, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, errShort
+	}
+	n := i + 1 + pad(i+1)
+	if n > len(b) {
+		return "", nil, errShort
+	}
+	return string(b[:i]), b[n:], nil
+}
+
+// Float reads a numeric or boolean argument as a float64.
+func Float(a any) (float64, bool) {
+	switch v := a.(type) {
+	case int32:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,80 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := Message{
+		Address: "/sketchy/Noise/Scale",
+		Args:    []any{int32(-3), float32(0.25), "hi", []byte{1, 2, 3}, true, false, int64(1) << 40, 2.5, nil},
+	}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b)%4 != 0 {
+		t.Fatalf("packet of %d bytes is not 4-byte aligned", len(b))
+	}
+	got, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], m) {
+		t.Fatalf("got %#v, want %#v", got, m)
+	}
+}
+
+func TestParseKnownPacket(t *testing.T) {
+	// "/a" with one float 1.0, as the OSC 1.0 spec lays it out.
+	b := []byte{'/', 'a', 0, 0, ',', 'f', 0, 0, 0x3f, 0x80, 0, 0}
+	got, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Address != "/a" || got[0].Args[0] != float32(1) {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestParseBundle(t *testing.T) {
+	one, _ := Message{Address: "/x", Args: []any{int32(1)}}.MarshalBinary()
+	two, _ := Message{Address: "/y", Args: []any{"z"}}.MarshalBinary()
+	bundle := func(parts ...[]byte) []byte {
+		var b bytes.Buffer
+		b.WriteString("#bundle\x00")
+		b.Write(make([]byte, 8)) // time tag
+		for _, p := range parts {
+			binary.Write(&b, binary.BigEndian, int32(len(p)))
+			b.Write(p)
+		}
+		return b.Bytes()
+	}
+	got, err := Parse(bundle(one, bundle(two)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Address != "/x" || got[1].Args[0] != "z" {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestParseRejectsBadPackets(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		[]byte("/abc"),                 // no terminator
+		[]byte("xyz\x00"),              // not a message or bundle
+		[]byte("/a\x00\x00,i\x00\x00"), // int missing
+		[]byte("/a\x00\x00,q\x00\x00"), // unknown tag
+	} {
+		if _, err := Parse(b); err == nil {
+			t.Errorf("%q parsed", b)
+		}
+	}
+	if _, err := (Message{Address: "nope"}).MarshalBinary(); err == nil {
+		t.Error("address without / encoded")
+	}
+}
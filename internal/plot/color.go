@@ -0,0 +1,82 @@
+package plot
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// ParseColor parses an SVG color: #rgb, #rrggbb, rgb(r, g, b) with
+// numbers or percentages, or a named color.
+func ParseColor(s string) (color.RGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.HasPrefix(s, "#"):
+		h := s[1:]
+		if len(h) == 3 {
+			h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+		}
+		if len(h) != 6 {
+			return color.RGBA{}, false
+		}
+		v, err := strconv.ParseUint(h, 16, 32)
+		if err != nil {
+			return color.RGBA{}, false
+		}
+		return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		parts := strings.Split(s[4:len(s)-1], ",")
+		if len(parts) != 3 {
+			return color.RGBA{}, false
+		}
+		var c [3]uint8
+		for i, p := range parts {
+			p = strings.TrimSpace(p)
+			pct := strings.HasSuffix(p, "%")
+			p = strings.TrimSuffix(p, "%")
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil {
+				return color.RGBA{}, false
+			}
+			if pct {
+				v = v * 255 / 100
+			}
+			c[i] = uint8(min(255, max(0, math.Round(v))))
+		}
+		return color.RGBA{c[0], c[1], c[2], 255}, true
+	}
+	c, ok := colornames.Map[s]
+	return c, ok
+}
+
+// HexColor formats c as "#rrggbb".
+func HexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// normalizePaint resolves an SVG paint to "#rrggbb", or "" for none.
+// Paint servers (gradients, patterns) cannot be plotted and fall back to
+// their fallback color, else black.
+func normalizePaint(v, current string) string {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "url(") {
+		_, fallback, _ := strings.Cut(v, ")")
+		if v = strings.TrimSpace(fallback); v == "" {
+			return "#000000"
+		}
+	}
+	switch strings.ToLower(v) {
+	case "", "none", "transparent":
+		return ""
+	case "currentcolor":
+		return normalizePaint(current, "#000000")
+	}
+	if c, ok := ParseColor(v); ok {
+		return HexColor(c)
+	}
+	return strings.ToLower(v)
+}
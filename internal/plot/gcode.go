@@ -0,0 +1,124 @@
+package plot
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MMPerPixel converts CSS pixels (96 per inch) to millimetres.
+const MMPerPixel = 25.4 / 96
+
+// GCodeOptions is the template of a G-code program. Each command is one or
+// more lines; the placeholders {x}, {y} (coordinates), {feed} (Feed), and
+// {layer} (the layer name, PenChange only) are substituted. Empty fields
+// take the GRBL-style defaults noted on each.
+type GCodeOptions struct {
+	// Header starts the program. Default: "G21\nG90" (millimetres,
+	// absolute coordinates).
+	Header string
+	// Footer ends the program; the pen is up. Default: "G0 X0 Y0".
+	Footer string
+	// PenUp lifts the pen. Default: "G0 Z5".
+	PenUp string
+	// PenDown lowers the pen. Default: "G1 Z0 F{feed}".
+	PenDown string
+	// Travel moves with the pen up. Default: "G0 X{x} Y{y}".
+	Travel string
+	// Draw moves with the pen down. Default: "G1 X{x} Y{y} F{feed}".
+	Draw string
+	// PenChange runs before every layer but the first, with the pen up.
+	// Default: "M0 ; pen: {layer}" (pause for a pen swap).
+	PenChange string
+	// Feed is the drawing speed in machine units per minute. Default: 1000.
+	Feed float64
+	// UnitsPerPixel scales user units to machine units. Default:
+	// MMPerPixel.
+	UnitsPerPixel float64
+	// FlipY puts the origin at the bottom left with y up, as on most
+	// plotter beds, instead of the SVG top left.
+	FlipY bool
+}
+
+func (o GCodeOptions) withDefaults() GCodeOptions {
+	def := func(s *string, v string) {
+		if *s == "" {
+			*s = v
+		}
+	}
+	def(&o.Header, "G21\nG90")
+	def(&o.Footer, "G0 X0 Y0")
+	def(&o.PenUp, "G0 Z5")
+	def(&o.PenDown, "G1 Z0 F{feed}")
+	def(&o.Travel, "G0 X{x} Y{y}")
+	def(&o.Draw, "G1 X{x} Y{y} F{feed}")
+	def(&o.PenChange, "M0 ; pen: {layer}")
+	if o.Feed <= 0 {
+		o.Feed = 1000
+	}
+	if o.UnitsPerPixel <= 0 {
+		o.UnitsPerPixel = MMPerPixel
+	}
+	return o
+}
+
+// WriteGCode writes the stroked polylines of d as a G-code program, layer
+// by layer in order. Fills are not plotted.
+func WriteGCode(w io.Writer, d *Drawing, opts GCodeOptions) error {
+	o := opts.withDefaults()
+	bw := bufio.NewWriter(w)
+	feed := gnum(o.Feed)
+	line := func(tmpl string, p Point, layer string) {
+		x, y := d.machine(p, o.UnitsPerPixel, o.FlipY)
+		r := strings.NewReplacer("{x}", gnum(x), "{y}", gnum(y), "{feed}", feed, "{layer}", layer)
+		bw.WriteString(r.Replace(tmpl))
+		bw.WriteByte('\n')
+	}
+	line(o.Header, Point{}, "")
+	line(o.PenUp, Point{}, "")
+	for i, l := range d.Layers {
+		if i > 0 {
+			line(o.PenChange, Point{}, l.Name)
+		}
+		for _, sh := range l.Shapes {
+			if !sh.Stroked() {
+				continue
+			}
+			for _, pl := range sh.Paths {
+				if len(pl) < 2 {
+					continue
+				}
+				line(o.Travel, pl[0], l.Name)
+				line(o.PenDown, pl[0], l.Name)
+				for _, p := range pl[1:] {
+					line(o.Draw, p, l.Name)
+				}
+				line(o.PenUp, pl[len(pl)-1], l.Name)
+			}
+		}
+	}
+	line(o.Footer, Point{}, "")
+	return bw.Flush()
+}
+
+// machine maps a user-space point to machine coordinates relative to the
+// viewBox origin, optionally with y up.
+func (d *Drawing) machine(p Point, scale float64, flipY bool) (x, y float64) {
+	x = (p.X - d.ViewBox[0]) * scale
+	y = p.Y - d.ViewBox[1]
+	if flipY {
+		y = d.ViewBox[3] - y
+	}
+	return x, y * scale
+}
+
+// gnum formats a machine coordinate to three decimals.
+func gnum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package plot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func gcodeTestDrawing() *Drawing {
+	return &Drawing{ViewBox: [4]float64{0, 0, 96, 96}, Layers: []Layer{
+		{Name: "black", Shapes: []Shape{
+			strokeShape(Polyline{{0, 0}, {96, 0}, {96, 48}}),
+			{Style: Style{Fill: "#000000", FillOpacity: 1}, Paths: []Polyline{{{1, 1}, {2, 2}}}},
+		}},
+		{Name: "red", Shapes: []Shape{strokeShape(Polyline{{48, 96}, {0, 96}})}},
+	}}
+}
+
+func TestWriteGCodeDefaults(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteGCode(&b, gcodeTestDrawing(), GCodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := `G21
+G90
+G0 Z5
+G0 X0 Y0
+G1 Z0 F1000
+G1 X25.4 Y0 F1000
+G1 X25.4 Y12.7 F1000
+G0 Z5
+M0 ; pen: red
+G0 X12.7 Y25.4
+G1 Z0 F1000
+G1 X0 Y25.4 F1000
+G0 Z5
+G0 X0 Y0
+`
+	if b.String() != want {
+		t.Fatalf("G-code:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestWriteGCodeTemplate(t *testing.T) {
+	var b bytes.Buffer
+	opts := GCodeOptions{
+		Header: "; start", Footer: "; end", PenUp: "M5", PenDown: "M3 S1000",
+		Travel: "G0 X{x} Y{y}", Draw: "G1 X{x} Y{y}", PenChange: "; {layer}",
+		UnitsPerPixel: 1, FlipY: true,
+	}
+	if err := WriteGCode(&b, gcodeTestDrawing(), opts); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"; start\nM5\nG0 X0 Y96\nM3 S1000\nG1 X96 Y96\nG1 X96 Y48\nM5\n; red\n", "G1 X0 Y0\nM5\n; end\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("G-code lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHPGL(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteHPGL(&b, gcodeTestDrawing(), HPGLOptions{UnitsPerPixel: 10}); err != nil {
+		t.Fatal(err)
+	}
+	want := "IN;\nSP1;\nPU0,960;PD960,960,960,480;\nSP2;\nPU480,0;PD0,0;\nPU;SP0;\n"
+	if b.String() != want {
+		t.Fatalf("HPGL = %q, want %q", b.String(), want)
+	}
+}
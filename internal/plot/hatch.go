@@ -0,0 +1,186 @@
+package plot
+
+import (
+	"math"
+	"slices"
+)
+
+// HatchOptions describes a line fill.
+type HatchOptions struct {
+	// Angle is the direction of the hatch lines in degrees, clockwise from
+	// the x axis (y points down).
+	Angle float64
+	// Spacing is the distance between neighbouring lines in user units;
+	// 0 means DefaultHatchSpacing.
+	Spacing float64
+	// Cross adds a second set of lines at Angle+90°.
+	Cross bool
+	// PenWidth is the stroke width of the hatch lines; 0 means 1.
+	PenWidth float64
+}
+
+// DefaultHatchSpacing is the hatch line spacing when none is set.
+const DefaultHatchSpacing = 4
+
+// HatchRule picks the hatch for a fill color ("#rrggbb"); ok false leaves
+// the fill alone.
+type HatchRule func(fill string) (opts HatchOptions, ok bool)
+
+// Hatch replaces the fills of d with hatch lines clipped to each shape,
+// stroked in the fill color, as picked by rule. A shape that is also
+// stroked keeps its outline, drawn after the hatching. d is modified in
+// place and returned.
+func Hatch(d *Drawing, rule HatchRule) *Drawing {
+	for li := range d.Layers {
+		var shapes []Shape
+		for _, sh := range d.Layers[li].Shapes {
+			opts, ok := HatchOptions{}, false
+			if sh.Filled() {
+				opts, ok = rule(sh.Fill)
+			}
+			if !ok {
+				shapes = append(shapes, sh)
+				continue
+			}
+			lines := HatchPaths(sh.Paths, sh.FillRule == "evenodd", opts)
+			if len(lines) > 0 {
+				width := opts.PenWidth
+				if width <= 0 {
+					width = 1
+				}
+				shapes = append(shapes, Shape{
+					Style: Style{Stroke: sh.Fill, StrokeWidth: width, StrokeOpacity: sh.FillOpacity, LineCap: "round", LineJoin: "round"},
+					Paths: lines,
+				})
+			}
+			if sh.Stroked() {
+				sh.Fill, sh.FillOpacity = "", 0
+				shapes = append(shapes, sh)
+			}
+		}
+		d.Layers[li].Shapes = shapes
+	}
+	return d
+}
+
+// HatchPaths fills the region bounded by paths (closed implicitly) with
+// parallel lines per opts, using the even-odd rule when evenOdd is set and
+// nonzero winding otherwise. Lines alternate direction so a plotter can
+// zig-zag through them.
+func HatchPaths(paths []Polyline, evenOdd bool, opts HatchOptions) []Polyline {
+	spacing := opts.Spacing
+	if spacing <= 0 {
+		spacing = DefaultHatchSpacing
+	}
+	out := hatchAt(paths, evenOdd, opts.Angle, spacing)
+	if opts.Cross {
+		out = append(out, hatchAt(paths, evenOdd, opts.Angle+90, spacing)...)
+	}
+	return out
+}
+
+// hatchEdge is a polygon edge in hatch space, where hatch lines are
+// horizontal.
+type hatchEdge struct {
+	a, b Point
+	dir  int // +1 going down (y increasing), -1 going up
+}
+
+type hatchCrossing struct {
+	x   float64
+	dir int
+}
+
+func hatchAt(paths []Polyline, evenOdd bool, angle, spacing float64) []Polyline {
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sincos(rad)
+	// toHatch rotates by -angle so the hatch lines run along x.
+	toHatch := func(p Point) Point { return Point{p.X*cos + p.Y*sin, -p.X*sin + p.Y*cos} }
+	fromHatch := func(p Point) Point { return Point{p.X*cos - p.Y*sin, p.X*sin + p.Y*cos} }
+
+	var edges []hatchEdge
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, pl := range paths {
+		if len(pl) < 3 {
+			continue
+		}
+		for i := range pl {
+			a, b := toHatch(pl[i]), toHatch(pl[(i+1)%len(pl)])
+			minY, maxY = min(minY, a.Y), max(maxY, a.Y)
+			switch {
+			case a.Y < b.Y:
+				edges = append(edges, hatchEdge{a, b, 1})
+			case a.Y > b.Y:
+				edges = append(edges, hatchEdge{b, a, -1})
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+
+	var out []Polyline
+	var xs []hatchCrossing
+	// Lines sit on multiples of spacing, so neighbouring shapes with the
+	// same hatch line up. eps keeps rounding in the rotation from adding a
+	// line along an edge.
+	eps := spacing * 1e-9
+	first := math.Floor((minY+eps)/spacing) + 1
+	for k := first; k*spacing < maxY-eps; k++ {
+		y := k * spacing
+		xs = xs[:0]
+		for _, e := range edges {
+			// Half-open in y, so a line through a vertex counts it once.
+			if y < e.a.Y || y >= e.b.Y {
+				continue
+			}
+			t := (y - e.a.Y) / (e.b.Y - e.a.Y)
+			xs = append(xs, hatchCrossing{e.a.X + t*(e.b.X-e.a.X), e.dir})
+		}
+		slices.SortFunc(xs, func(p, q hatchCrossing) int {
+			switch {
+			case p.x < q.x:
+				return -1
+			case p.x > q.x:
+				return 1
+			}
+			return 0
+		})
+		var spans [][2]float64
+		wind := 0
+		for i, c := range xs {
+			inside := wind != 0
+			if evenOdd {
+				inside = wind%2 != 0
+			}
+			if inside && i > 0 && c.x > xs[i-1].x {
+				spans = append(spans, [2]float64{xs[i-1].x, c.x})
+			}
+			wind += c.dir
+		}
+		if int(k-first)%2 != 0 {
+			slices.Reverse(spans)
+			for i := range spans {
+				spans[i][0], spans[i][1] = spans[i][1], spans[i][0]
+			}
+		}
+		for _, sp := range spans {
+			out = append(out, Polyline{fromHatch(Point{sp[0], y}), fromHatch(Point{sp[1], y})})
+		}
+	}
+	return joinSpans(out)
+}
+
+// joinSpans merges spans that continue one another (a region split by a
+// crossing of zero width).
+func joinSpans(lines []Polyline) []Polyline {
+	var out []Polyline
+	for _, l := range lines {
+		if n := len(out); n > 0 && out[n-1][len(out[n-1])-1].Dist(l[0]) < 1e-9 {
+			out[n-1][len(out[n-1])-1] = l[1]
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package plot
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHatchPathsSquare(t *testing.T) {
+	sq := Polyline{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	got := HatchPaths([]Polyline{sq}, false, HatchOptions{Spacing: 2})
+	want := []Polyline{{{0, 2}, {10, 2}}, {{10, 4}, {0, 4}}, {{0, 6}, {10, 6}}, {{10, 8}, {0, 8}}}
+	if len(got) != len(want) {
+		t.Fatalf("hatch = %v, want %v", got, want)
+	}
+	for i := range got {
+		if !equalLines(got[i], want[i]) {
+			t.Fatalf("hatch = %v, want %v", got, want)
+		}
+	}
+
+	// Vertical and crossed lines cover the square the same way.
+	vert := HatchPaths([]Polyline{sq}, false, HatchOptions{Angle: 90, Spacing: 2})
+	for _, l := range vert {
+		if math.Abs(l[0].X-l[1].X) > 1e-9 || math.Abs(l.Length()-10) > 1e-9 {
+			t.Fatalf("90° hatch line %v", l)
+		}
+	}
+	if n := len(HatchPaths([]Polyline{sq}, false, HatchOptions{Spacing: 2, Cross: true})); n != 8 {
+		t.Fatalf("cross-hatch has %d lines, want 8", n)
+	}
+}
+
+func TestHatchPathsFillRule(t *testing.T) {
+	outer := Polyline{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	inner := Polyline{{3, 3}, {7, 3}, {7, 7}, {3, 7}, {3, 3}} // same winding
+	line := func(evenOdd bool) []Polyline {
+		var at5 []Polyline
+		for _, l := range HatchPaths([]Polyline{outer, inner}, evenOdd, HatchOptions{Spacing: 5}) {
+			if l[0].Y == 5 {
+				at5 = append(at5, l)
+			}
+		}
+		return at5
+	}
+	if got := line(true); len(got) != 2 || math.Abs(got[0].Length()-3) > 1e-9 {
+		t.Fatalf("even-odd leaves the hole: %v", got)
+	}
+	if got := line(false); len(got) != 1 || math.Abs(got[0].Length()-10) > 1e-9 {
+		t.Fatalf("nonzero fills the hole: %v", got)
+	}
+}
+
+func TestHatchDrawing(t *testing.T) {
+	sq := Polyline{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	d := &Drawing{Layers: []Layer{{Shapes: []Shape{
+		{Style: Style{Fill: "#ff0000", FillOpacity: 1, Stroke: "#000000", StrokeWidth: 1, StrokeOpacity: 1}, Paths: []Polyline{sq}},
+		{Style: Style{Fill: "#0000ff", FillOpacity: 1}, Paths: []Polyline{sq}},
+	}}}}
+	Hatch(d, func(fill string) (HatchOptions, bool) {
+		return HatchOptions{Spacing: 2, PenWidth: 0.5}, fill == "#ff0000"
+	})
+	sh := d.Layers[0].Shapes
+	if len(sh) != 3 {
+		t.Fatalf("shapes = %+v", sh)
+	}
+	if sh[0].Stroke != "#ff0000" || sh[0].StrokeWidth != 0.5 || sh[0].Filled() || len(sh[0].Paths) != 4 {
+		t.Fatalf("hatch shape = %+v", sh[0])
+	}
+	if sh[1].Stroke != "#000000" || sh[1].Filled() {
+		t.Fatalf("outline = %+v", sh[1])
+	}
+	if sh[2].Fill != "#0000ff" {
+		t.Fatalf("unhatched fill = %+v", sh[2])
+	}
+}
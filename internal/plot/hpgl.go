@@ -0,0 +1,54 @@
+package plot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// HPGLOptions configures WriteHPGL.
+type HPGLOptions struct {
+	// UnitsPerPixel scales user units to plotter units. Default: 40 units
+	// per millimetre at 96 pixels per inch.
+	UnitsPerPixel float64
+}
+
+// WriteHPGL writes the stroked polylines of d as HP-GL, selecting pen n
+// (cycling through 1-8) for the nth layer. HP-GL puts the origin at the
+// bottom left, so y is flipped.
+func WriteHPGL(w io.Writer, d *Drawing, opts HPGLOptions) error {
+	scale := opts.UnitsPerPixel
+	if scale <= 0 {
+		scale = 40 * MMPerPixel
+	}
+	bw := bufio.NewWriter(w)
+	pt := func(p Point) string {
+		x, y := d.machine(p, scale, true)
+		return fmt.Sprintf("%d,%d", int64(math.Round(x)), int64(math.Round(y)))
+	}
+	bw.WriteString("IN;\n")
+	for i, l := range d.Layers {
+		fmt.Fprintf(bw, "SP%d;\n", i%8+1)
+		for _, sh := range l.Shapes {
+			if !sh.Stroked() {
+				continue
+			}
+			for _, pl := range sh.Paths {
+				if len(pl) < 2 {
+					continue
+				}
+				fmt.Fprintf(bw, "PU%s;PD", pt(pl[0]))
+				for j, p := range pl[1:] {
+					if j > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(pt(p))
+				}
+				bw.WriteString(";\n")
+			}
+		}
+	}
+	bw.WriteString("PU;SP0;\n")
+	return bw.Flush()
+}
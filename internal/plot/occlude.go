@@ -0,0 +1,241 @@
+package plot
+
+import (
+	"math"
+	"slices"
+)
+
+// Occlude removes what later opaque fills cover: walking the shapes from
+// the top of the paint order down, each stroked path is clipped to the
+// outside of every fully opaque filled shape painted after it. Layers
+// stack in order, the last on top. Fills are left as they are, since the
+// paint order already hides them.
+//
+// With hatch set, fills it picks are hatched as in Hatch, and the hatch
+// lines are clipped the same way; the hatched shapes still hide what lies
+// below them. d is modified in place and returned.
+func Occlude(d *Drawing, hatch HatchRule) *Drawing {
+	occ := newOccluders(d)
+	for li := len(d.Layers) - 1; li >= 0; li-- {
+		l := &d.Layers[li]
+		var shapes []Shape // top first
+		for si := len(l.Shapes) - 1; si >= 0; si-- {
+			sh := l.Shapes[si]
+			opts, hatched := HatchOptions{}, false
+			if hatch != nil && sh.Filled() {
+				opts, hatched = hatch(sh.Fill)
+			}
+			// The outline is painted over the fill, so it goes first here.
+			if sh.Stroked() {
+				if paths := occ.clip(sh.Paths); len(paths) > 0 {
+					outline := sh
+					outline.Fill, outline.FillOpacity = "", 0
+					outline.Paths = paths
+					shapes = append(shapes, outline)
+				}
+			}
+			switch {
+			case hatched:
+				shapes = append(shapes, occ.hatchShape(sh, opts)...)
+			case sh.Filled():
+				fill := sh
+				fill.Stroke = ""
+				shapes = append(shapes, fill)
+			}
+			if sh.Filled() && sh.FillOpacity >= 1 {
+				occ.add(sh.Paths, sh.FillRule == "evenodd")
+			}
+		}
+		slices.Reverse(shapes)
+		l.Shapes = shapes
+	}
+	return d
+}
+
+// occluders are the opaque fills above the shape being clipped, bucketed
+// by bounding box on a grid.
+type occluders struct {
+	regions []occluder
+	cell    float64
+	grid    map[[2]int][]int
+}
+
+type occluder struct {
+	edges    [][2]Point
+	evenOdd  bool
+	min, max Point
+}
+
+func newOccluders(d *Drawing) *occluders {
+	cell := math.Max(d.ViewBox[2], d.ViewBox[3]) / 64
+	if cell <= 0 {
+		cell = 16
+	}
+	return &occluders{cell: cell, grid: map[[2]int][]int{}}
+}
+
+func (o *occluders) cellOf(p Point) [2]int {
+	return [2]int{int(math.Floor(p.X / o.cell)), int(math.Floor(p.Y / o.cell))}
+}
+
+func (o *occluders) add(paths []Polyline, evenOdd bool) {
+	r := occluder{evenOdd: evenOdd, min: Point{math.Inf(1), math.Inf(1)}, max: Point{math.Inf(-1), math.Inf(-1)}}
+	for _, pl := range paths {
+		if len(pl) < 3 {
+			continue
+		}
+		for i := range pl {
+			a, b := pl[i], pl[(i+1)%len(pl)]
+			if a != b {
+				r.edges = append(r.edges, [2]Point{a, b})
+			}
+			r.min = Point{math.Min(r.min.X, a.X), math.Min(r.min.Y, a.Y)}
+			r.max = Point{math.Max(r.max.X, a.X), math.Max(r.max.Y, a.Y)}
+		}
+	}
+	if len(r.edges) == 0 {
+		return
+	}
+	id := len(o.regions)
+	o.regions = append(o.regions, r)
+	c0, c1 := o.cellOf(r.min), o.cellOf(r.max)
+	for x := c0[0]; x <= c1[0]; x++ {
+		for y := c0[1]; y <= c1[1]; y++ {
+			o.grid[[2]int{x, y}] = append(o.grid[[2]int{x, y}], id)
+		}
+	}
+}
+
+// candidates lists the occluders whose boxes may touch the box of a and
+// b, each once.
+func (o *occluders) candidates(a, b Point, seen map[int]bool, ids []int) []int {
+	lo := Point{math.Min(a.X, b.X), math.Min(a.Y, b.Y)}
+	hi := Point{math.Max(a.X, b.X), math.Max(a.Y, b.Y)}
+	c0, c1 := o.cellOf(lo), o.cellOf(hi)
+	clear(seen)
+	ids = ids[:0]
+	for x := c0[0]; x <= c1[0]; x++ {
+		for y := c0[1]; y <= c1[1]; y++ {
+			for _, id := range o.grid[[2]int{x, y}] {
+				r := &o.regions[id]
+				if seen[id] || r.max.X < lo.X || r.min.X > hi.X || r.max.Y < lo.Y || r.min.Y > hi.Y {
+					continue
+				}
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// clip returns the parts of paths outside every occluder.
+func (o *occluders) clip(paths []Polyline) []Polyline {
+	if len(o.regions) == 0 {
+		return paths
+	}
+	var out []Polyline
+	seen := map[int]bool{}
+	var ids []int
+	var ts []float64
+	for _, pl := range paths {
+		var cur Polyline
+		flush := func() {
+			if len(cur) > 1 {
+				out = append(out, cur)
+			}
+			cur = nil
+		}
+		if len(pl) == 1 {
+			out = append(out, pl)
+			continue
+		}
+		for i := 1; i < len(pl); i++ {
+			a, b := pl[i-1], pl[i]
+			ids = o.candidates(a, b, seen, ids)
+			ts = append(ts[:0], 0, 1)
+			for _, id := range ids {
+				for _, e := range o.regions[id].edges {
+					if t, ok := segmentCrossing(a, b, e[0], e[1]); ok {
+						ts = append(ts, t)
+					}
+				}
+			}
+			slices.Sort(ts)
+			for j := 1; j < len(ts); j++ {
+				t0, t1 := ts[j-1], ts[j]
+				if t1-t0 < 1e-12 {
+					continue
+				}
+				p0 := a.add(b.sub(a).scale(t0))
+				p1 := a.add(b.sub(a).scale(t1))
+				if o.covers(p0.add(p1).scale(0.5), ids) {
+					flush()
+					continue
+				}
+				if len(cur) == 0 {
+					cur = append(cur, p0)
+				}
+				cur = append(cur, p1)
+			}
+		}
+		flush()
+	}
+	return out
+}
+
+// covers reports whether p is inside any of the occluders ids.
+func (o *occluders) covers(p Point, ids []int) bool {
+	for _, id := range ids {
+		r := &o.regions[id]
+		if p.X < r.min.X || p.X > r.max.X || p.Y < r.min.Y || p.Y > r.max.Y {
+			continue
+		}
+		wind := 0
+		for _, e := range r.edges {
+			a, b := e[0], e[1]
+			if a.Y <= p.Y {
+				if b.Y > p.Y && b.sub(a).cross(p.sub(a)) > 0 {
+					wind++
+				}
+			} else if b.Y <= p.Y && b.sub(a).cross(p.sub(a)) < 0 {
+				wind--
+			}
+		}
+		if (r.evenOdd && wind%2 != 0) || (!r.evenOdd && wind != 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// hatchShape hatches sh's fill and clips the lines, returning the hatch
+// shape, if any lines remain.
+func (o *occluders) hatchShape(sh Shape, opts HatchOptions) []Shape {
+	h := Hatch(&Drawing{Layers: []Layer{{Shapes: []Shape{{Style: Style{Fill: sh.Fill, FillOpacity: sh.FillOpacity, FillRule: sh.FillRule}, Paths: sh.Paths}}}}},
+		func(string) (HatchOptions, bool) { return opts, true })
+	var out []Shape
+	for _, hs := range h.Layers[0].Shapes {
+		if hs.Paths = o.clip(hs.Paths); len(hs.Paths) > 0 {
+			out = append(out, hs)
+		}
+	}
+	return out
+}
+
+// segmentCrossing is where segment ab crosses segment cd, as a fraction of
+// ab, when they cross at a single point.
+func segmentCrossing(a, b, c, d Point) (float64, bool) {
+	r, s := b.sub(a), d.sub(c)
+	den := r.cross(s)
+	if den == 0 {
+		return 0, false
+	}
+	ca := c.sub(a)
+	t := ca.cross(s) / den
+	u := ca.cross(r) / den
+	if t <= 0 || t >= 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
@@ -0,0 +1,97 @@
+package plot
+
+import (
+	"math"
+	"testing"
+)
+
+func square(x, y, size float64) Polyline {
+	return Polyline{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}, {x, y}}
+}
+
+func totalLength(paths []Polyline) float64 {
+	var n float64
+	for _, pl := range paths {
+		n += pl.Length()
+	}
+	return n
+}
+
+func TestOccludeClipsEarlierStrokes(t *testing.T) {
+	outline := Style{Stroke: "#000000", StrokeWidth: 1, StrokeOpacity: 1}
+	filled := outline
+	filled.Fill, filled.FillOpacity = "#ffffff", 1
+	d := &Drawing{ViewBox: [4]float64{0, 0, 20, 20}, Layers: []Layer{{Shapes: []Shape{
+		{Style: outline, Paths: []Polyline{{{0, 12}, {20, 12}}}},
+		{Style: filled, Paths: []Polyline{square(0, 0, 10)}},
+		{Style: filled, Paths: []Polyline{square(5, 5, 10)}},
+	}}}}
+	Occlude(d, nil)
+	shapes := d.Layers[0].Shapes
+	if len(shapes) != 5 {
+		t.Fatalf("got %d shapes, want line, fill+outline, fill+outline", len(shapes))
+	}
+	// The line runs below the first square and through the second;
+	// the 10 units under the second are hidden.
+	if got := totalLength(shapes[0].Paths); math.Abs(got-10) > 1e-9 {
+		t.Fatalf("line keeps %v units, want 10: %v", got, shapes[0].Paths)
+	}
+	// The second square hides a 5×5 corner of the first's outline.
+	if shapes[1].Stroked() || !shapes[1].Filled() || shapes[2].Filled() {
+		t.Fatalf("first square split wrongly: %+v %+v", shapes[1], shapes[2])
+	}
+	if got := totalLength(shapes[2].Paths); math.Abs(got-30) > 1e-9 {
+		t.Fatalf("first outline keeps %v units, want 30: %v", got, shapes[2].Paths)
+	}
+	if got := totalLength(shapes[4].Paths); math.Abs(got-40) > 1e-9 {
+		t.Fatalf("top outline keeps %v units, want 40", got)
+	}
+}
+
+func TestOccludeIgnoresTranslucentFillsAndHatches(t *testing.T) {
+	line := Shape{Style: Style{Stroke: "#000000", StrokeWidth: 1, StrokeOpacity: 1}, Paths: []Polyline{{{0, 5}, {20, 5}}}}
+	d := &Drawing{Layers: []Layer{
+		{Shapes: []Shape{line}},
+		{Shapes: []Shape{{Style: Style{Fill: "#ff0000", FillOpacity: 0.5}, Paths: []Polyline{square(0, 0, 10)}}}},
+	}}
+	Occlude(d, nil)
+	if got := totalLength(d.Layers[0].Shapes[0].Paths); got != 20 {
+		t.Fatalf("a translucent fill hid the line: %v units left", got)
+	}
+
+	// Hatching the top square hides the line below it, and the lower
+	// square's hatch lines stop at the top square's edge.
+	d = &Drawing{Layers: []Layer{
+		{Shapes: []Shape{line, {Style: Style{Fill: "#ff0000", FillOpacity: 1}, Paths: []Polyline{square(0, 0, 10)}}}},
+		{Shapes: []Shape{{Style: Style{Fill: "#0000ff", FillOpacity: 1}, Paths: []Polyline{square(5, 0, 10)}}}},
+	}}
+	Occlude(d, func(string) (HatchOptions, bool) { return HatchOptions{Spacing: 2}, true })
+	low, top := d.Layers[0].Shapes, d.Layers[1].Shapes
+	if got := totalLength(low[0].Paths); math.Abs(got-5) > 1e-9 {
+		t.Fatalf("line keeps %v units, want 5", got)
+	}
+	if low[1].Filled() || top[0].Filled() {
+		t.Fatalf("hatched shapes kept their fill")
+	}
+	for _, l := range low[1].Paths {
+		if l[0].X > 5+1e-9 || l[1].X > 5+1e-9 {
+			t.Fatalf("lower hatch line %v runs under the top square", l)
+		}
+	}
+	if len(top[0].Paths) != 4 {
+		t.Fatalf("top hatch has %d lines, want 4", len(top[0].Paths))
+	}
+}
+
+func TestGroupByPaint(t *testing.T) {
+	red := Style{Stroke: "#ff0000", StrokeWidth: 1, StrokeOpacity: 1}
+	blue := Style{Fill: "#0000ff", FillOpacity: 1}
+	line := []Polyline{{{0, 0}, {1, 0}}}
+	d := GroupByPaint(&Drawing{Layers: []Layer{
+		{Shapes: []Shape{{Style: red, Paths: line}, {Style: blue, Paths: line}}},
+		{Name: "top", Shapes: []Shape{{Style: red, Paths: line}}},
+	}})
+	if len(d.Layers) != 2 || d.Layers[0].Name != "#ff0000" || len(d.Layers[0].Shapes) != 2 || d.Layers[1].Name != "#0000ff" {
+		t.Fatalf("layers = %+v", d.Layers)
+	}
+}
@@ -0,0 +1,444 @@
+package plot
+
+import "math"
+
+// DefaultTolerance is the join and overlap distance used when
+// OptimizeOptions.Tolerance is zero, in user units.
+const DefaultTolerance = 0.1
+
+// OptimizeOptions configures Optimize.
+type OptimizeOptions struct {
+	// Tolerance is how close path ends must be to be joined, and how far
+	// apart two lines may be and still count as overlapping.
+	Tolerance float64
+}
+
+// Optimize returns a copy of d reordered for a pen plotter, in the manner
+// of vpype's linemerge and linesort. Within each layer, stroked geometry is
+// grouped by pen (stroke paint and width) in order of first use; for each
+// pen, duplicate and overlapping segments are dropped, paths whose ends
+// touch are joined, and the paths are ordered and flipped greedily to
+// shorten pen-up travel, continuing from where the previous pen stopped.
+// Fills are kept, without their outlines, ahead of the strokes.
+//
+// Paint order within a layer is not preserved, so the result is meant for
+// plotting rather than viewing.
+func Optimize(d *Drawing, opts OptimizeOptions) *Drawing {
+	tol := opts.Tolerance
+	if tol <= 0 {
+		tol = DefaultTolerance
+	}
+	out := &Drawing{Width: d.Width, Height: d.Height, ViewBox: d.ViewBox}
+	type pen struct {
+		style Style
+		lines []Polyline
+	}
+	for _, l := range d.Layers {
+		nl := Layer{Name: l.Name}
+		var pens []*pen
+		index := map[Style]int{}
+		for _, sh := range l.Shapes {
+			if sh.Filled() {
+				fill := sh
+				fill.Stroke = ""
+				nl.Shapes = append(nl.Shapes, fill)
+			}
+			if !sh.Stroked() {
+				continue
+			}
+			key := sh.Style
+			key.Fill, key.FillOpacity, key.FillRule = "", 0, ""
+			i, ok := index[key]
+			if !ok {
+				i = len(pens)
+				index[key] = i
+				pens = append(pens, &pen{style: key})
+			}
+			pens[i].lines = append(pens[i].lines, sh.Paths...)
+		}
+		var pos Point
+		for _, p := range pens {
+			var lines []Polyline
+			lines, pos = sortLines(merge(dedupe(p.lines, tol), tol), pos)
+			if len(lines) > 0 {
+				nl.Shapes = append(nl.Shapes, Shape{Style: p.style, Paths: lines})
+			}
+		}
+		out.Layers = append(out.Layers, nl)
+	}
+	return out
+}
+
+// dedupeAngleStep is the direction bucket of the overlap index, in radians.
+const dedupeAngleStep = 1e-3
+
+// drawnSegment is a segment already kept by dedupe.
+type drawnSegment struct{ a, b Point }
+
+// segmentIndex buckets kept segments by the line they lie on: direction
+// (folded to (-π/2, π/2]) and signed distance from the origin.
+type segmentIndex struct {
+	tol   float64
+	cells map[[2]int64][]drawnSegment
+}
+
+func lineOf(a, b Point) (u Point, theta, c float64) {
+	v := b.sub(a)
+	u = v.scale(1 / math.Hypot(v.X, v.Y))
+	if u.X < 0 || u.X == 0 && u.Y < 0 {
+		u = u.scale(-1)
+	}
+	return u, math.Atan2(u.Y, u.X), u.cross(a)
+}
+
+func (ix *segmentIndex) key(theta, c float64) [2]int64 {
+	return [2]int64{int64(math.Round(theta / dedupeAngleStep)), int64(math.Round(c / ix.tol))}
+}
+
+// candidates returns kept segments that may lie on the line (theta, c),
+// including near-vertical ones folded to the other end of the range.
+func (ix *segmentIndex) candidates(theta, c float64) []drawnSegment {
+	var out []drawnSegment
+	look := func(theta, c float64) {
+		k := ix.key(theta, c)
+		for i := k[0] - 1; i <= k[0]+1; i++ {
+			for j := k[1] - 1; j <= k[1]+1; j++ {
+				out = append(out, ix.cells[[2]int64{i, j}]...)
+			}
+		}
+	}
+	look(theta, c)
+	switch {
+	case theta > math.Pi/2-2*dedupeAngleStep:
+		look(theta-math.Pi, -c)
+	case theta < -math.Pi/2+2*dedupeAngleStep:
+		look(theta+math.Pi, -c)
+	}
+	return out
+}
+
+// add records a→b and returns the parts of it not already drawn, in
+// a→b order.
+func (ix *segmentIndex) add(a, b Point) [][2]Point {
+	if a == b {
+		return nil
+	}
+	u, theta, c := lineOf(a, b)
+	ta, tb := u.dot(a), u.dot(b)
+	lo, hi := math.Min(ta, tb), math.Max(ta, tb)
+	free := [][2]float64{{lo, hi}}
+	eps := ix.tol * 1e-3
+	covered := false
+	for _, s := range ix.candidates(theta, c) {
+		// Segments shorter than tol would match any line they cross.
+		if s.a.Dist(s.b) < ix.tol || distToLine(s.a, a, b) > ix.tol || distToLine(s.b, a, b) > ix.tol {
+			continue
+		}
+		sa, sb := u.dot(s.a), u.dot(s.b)
+		cl, ch := math.Min(sa, sb), math.Max(sa, sb)
+		var next [][2]float64
+		for _, f := range free {
+			// Lines that only touch end to end do not overlap.
+			if ch <= f[0]+eps || cl >= f[1]-eps {
+				next = append(next, f)
+				continue
+			}
+			covered = true
+			if cl > f[0] {
+				next = append(next, [2]float64{f[0], cl})
+			}
+			if ch < f[1] {
+				next = append(next, [2]float64{ch, f[1]})
+			}
+		}
+		if free = next; len(free) == 0 {
+			break
+		}
+	}
+	k := ix.key(theta, c)
+	ix.cells[k] = append(ix.cells[k], drawnSegment{a, b})
+	if !covered {
+		return [][2]Point{{a, b}}
+	}
+	at := func(t float64) Point {
+		switch t {
+		case ta:
+			return a
+		case tb:
+			return b
+		}
+		return a.add(u.scale(t - ta))
+	}
+	out := make([][2]Point, 0, len(free))
+	for _, f := range free {
+		if f[1]-f[0] < ix.tol {
+			continue // a sliver left between overlaps
+		}
+		out = append(out, [2]Point{at(f[0]), at(f[1])})
+	}
+	if ta > tb {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+		for i := range out {
+			out[i][0], out[i][1] = out[i][1], out[i][0]
+		}
+	}
+	return out
+}
+
+// dedupe drops segments lying on already drawn ones, first drawn wins.
+// What survives of each polyline stays chained where it still connects.
+func dedupe(lines []Polyline, tol float64) []Polyline {
+	ix := &segmentIndex{tol: tol, cells: map[[2]int64][]drawnSegment{}}
+	var out []Polyline
+	for _, l := range lines {
+		var cur Polyline
+		flush := func() {
+			if len(cur) >= 2 {
+				out = append(out, cur)
+			}
+			cur = nil
+		}
+		for i := 1; i < len(l); i++ {
+			for _, pc := range ix.add(l[i-1], l[i]) {
+				if len(cur) > 0 && cur[len(cur)-1] == pc[0] {
+					cur = append(cur, pc[1])
+					continue
+				}
+				flush()
+				cur = Polyline{pc[0], pc[1]}
+			}
+		}
+		flush()
+	}
+	return out
+}
+
+// endGrid indexes polyline endpoints for nearest-end queries. An end ref
+// is 2*line for the start and 2*line+1 for the end.
+type endGrid struct {
+	cell  float64
+	lines []Polyline
+	cells map[[2]int64][]int32
+	alive []int32 // live lines, for the linear-scan fallback
+	pos   []int32 // index of each line in alive, -1 once removed
+}
+
+func newEndGrid(lines []Polyline, cell float64, include func(Polyline) bool) *endGrid {
+	g := &endGrid{cell: cell, lines: lines, cells: map[[2]int64][]int32{}, pos: make([]int32, len(lines))}
+	for i, l := range lines {
+		if !include(l) {
+			g.pos[i] = -1
+			continue
+		}
+		g.pos[i] = int32(len(g.alive))
+		g.alive = append(g.alive, int32(i))
+		for e := range 2 {
+			ref := int32(2*i + e)
+			k := g.key(g.end(ref))
+			g.cells[k] = append(g.cells[k], ref)
+		}
+	}
+	return g
+}
+
+func (g *endGrid) key(p Point) [2]int64 {
+	return [2]int64{int64(math.Floor(p.X / g.cell)), int64(math.Floor(p.Y / g.cell))}
+}
+
+func (g *endGrid) end(ref int32) Point {
+	l := g.lines[ref>>1]
+	if ref&1 == 0 {
+		return l[0]
+	}
+	return l[len(l)-1]
+}
+
+func (g *endGrid) remove(i int) {
+	p := g.pos[i]
+	if p < 0 {
+		return
+	}
+	last := g.alive[len(g.alive)-1]
+	g.alive[p] = last
+	g.pos[last] = p
+	g.alive = g.alive[:len(g.alive)-1]
+	g.pos[i] = -1
+	for e := range 2 {
+		ref := int32(2*i + e)
+		k := g.key(g.end(ref))
+		refs := g.cells[k]
+		for j, r := range refs {
+			if r == ref {
+				refs[j] = refs[len(refs)-1]
+				refs = refs[:len(refs)-1]
+				break
+			}
+		}
+		if len(refs) == 0 {
+			delete(g.cells, k)
+		} else {
+			g.cells[k] = refs
+		}
+	}
+}
+
+// nearest returns the live end closest to p, searching rings of cells
+// outward and falling back to a scan of every live line once the rings
+// cost more than that.
+func (g *endGrid) nearest(p Point) (int32, bool) {
+	if len(g.alive) == 0 {
+		return 0, false
+	}
+	best, bestD := int32(-1), math.Inf(1)
+	consider := func(ref int32) {
+		if d := p.Dist(g.end(ref)); d < bestD {
+			best, bestD = ref, d
+		}
+	}
+	k := g.key(p)
+	scanned := 0
+	for r := int64(0); ; r++ {
+		for dx := -r; dx <= r; dx++ {
+			for dy := -r; dy <= r; dy++ {
+				if max(abs64(dx), abs64(dy)) != r {
+					continue
+				}
+				scanned++
+				for _, ref := range g.cells[[2]int64{k[0] + dx, k[1] + dy}] {
+					consider(ref)
+				}
+			}
+		}
+		// Anything in a farther ring is at least r cells away.
+		if best >= 0 && bestD <= float64(r)*g.cell {
+			return best, true
+		}
+		if scanned > 2*len(g.alive) {
+			for _, i := range g.alive {
+				consider(2 * i)
+				consider(2*i + 1)
+			}
+			return best, true
+		}
+	}
+}
+
+// within returns the live end closest to p if it is within tol, which must
+// not exceed the cell size.
+func (g *endGrid) within(p Point, tol float64) (int32, bool) {
+	best, bestD := int32(-1), math.Inf(1)
+	k := g.key(p)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for _, ref := range g.cells[[2]int64{k[0] + dx, k[1] + dy}] {
+				if d := p.Dist(g.end(ref)); d <= tol && d < bestD {
+					best, bestD = ref, d
+				}
+			}
+		}
+	}
+	return best, best >= 0
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// merge joins polylines whose ends lie within tol, flipping them as
+// needed. Closed polylines are left alone.
+func merge(lines []Polyline, tol float64) []Polyline {
+	g := newEndGrid(lines, tol, func(l Polyline) bool { return !l.Closed() })
+	var out []Polyline
+	for i, l := range lines {
+		if l.Closed() {
+			out = append(out, l)
+			continue
+		}
+		if g.pos[i] < 0 {
+			continue // already joined into an earlier line
+		}
+		g.remove(i)
+		cur := append(Polyline(nil), l...)
+		for {
+			ref, ok := g.within(cur[len(cur)-1], tol)
+			if !ok {
+				break
+			}
+			j := int(ref >> 1)
+			g.remove(j)
+			next := lines[j]
+			if ref&1 == 1 {
+				next = next.reversed()
+			}
+			cur = joined(cur, next)
+		}
+		for {
+			ref, ok := g.within(cur[0], tol)
+			if !ok {
+				break
+			}
+			j := int(ref >> 1)
+			g.remove(j)
+			prev := lines[j]
+			if ref&1 == 0 {
+				prev = prev.reversed()
+			}
+			cur = joined(prev, cur)
+		}
+		out = append(out, cur)
+	}
+	return out
+}
+
+// joined returns a followed by b in a new polyline, sharing the joint
+// point when they meet exactly.
+func joined(a, b Polyline) Polyline {
+	if a[len(a)-1] == b[0] {
+		b = b[1:]
+	}
+	out := make(Polyline, 0, len(a)+len(b))
+	return append(append(out, a...), b...)
+}
+
+// sortLines orders lines greedily, always drawing next the line with an
+// end nearest the pen, flipped to start there. It returns the ordered lines
+// and where the pen ends.
+func sortLines(lines []Polyline, from Point) ([]Polyline, Point) {
+	if len(lines) == 0 {
+		return nil, from
+	}
+	minP, maxP := lines[0][0], lines[0][0]
+	for _, l := range lines {
+		for _, p := range []Point{l[0], l[len(l)-1]} {
+			minP = Point{math.Min(minP.X, p.X), math.Min(minP.Y, p.Y)}
+			maxP = Point{math.Max(maxP.X, p.X), math.Max(maxP.Y, p.Y)}
+		}
+	}
+	span := maxP.sub(minP)
+	cell := math.Sqrt(math.Max(span.X*span.Y, 1) / float64(len(lines)))
+	cell = math.Max(cell, math.Max(span.X, span.Y)/1024)
+	g := newEndGrid(lines, math.Max(cell, 1e-6), func(Polyline) bool { return true })
+
+	out := make([]Polyline, 0, len(lines))
+	pos := from
+	for {
+		ref, ok := g.nearest(pos)
+		if !ok {
+			break
+		}
+		j := int(ref >> 1)
+		g.remove(j)
+		l := lines[j]
+		if ref&1 == 1 {
+			l = l.reversed()
+		}
+		out = append(out, l)
+		pos = l[len(l)-1]
+	}
+	return out, pos
+}
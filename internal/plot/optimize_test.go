@@ -0,0 +1,129 @@
+package plot
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func strokeShape(paths ...Polyline) Shape {
+	return Shape{Style: Style{Stroke: "#000000", StrokeWidth: 1, StrokeOpacity: 1}, Paths: paths}
+}
+
+func TestDedupeDropsDuplicateAndOverlappingSegments(t *testing.T) {
+	lines := []Polyline{
+		{{0, 0}, {10, 0}},
+		{{10, 0}, {0, 0}},              // the same line reversed
+		{{5, 0}, {15, 0}},              // half on the first line
+		{{20, 20}, {20, 30}},           // unrelated
+		{{20, 25.00001}, {20, 21}},     // inside the previous one
+		{{0, 0.5}, {10, 0.5}},          // parallel, farther than tol
+		{{10, 10}, {20, 10}, {30, 10}}, // collinear chain
+	}
+	got := dedupe(lines, 0.1)
+	want := []Polyline{
+		{{0, 0}, {10, 0}},
+		{{10, 0}, {15, 0}},
+		{{20, 20}, {20, 30}},
+		{{0, 0.5}, {10, 0.5}},
+		{{10, 10}, {20, 10}, {30, 10}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("dedupe = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !equalLines(got[i], want[i]) {
+			t.Fatalf("line %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMergeJoinsTouchingEnds(t *testing.T) {
+	lines := []Polyline{
+		{{0, 0}, {1, 0}},
+		{{2, 0}, {1.05, 0}}, // reversed, its end within tol of the first's end
+		{{-1, 0}, {0, 0}},   // joins at the start
+		{{5, 5}, {6, 5}, {6, 6}, {5, 5}},
+		{{5, 5}, {4, 4}}, // touches the closed loop, which stays alone
+	}
+	got := merge(lines, 0.1)
+	if len(got) != 3 {
+		t.Fatalf("merge = %v", got)
+	}
+	want := Polyline{{-1, 0}, {0, 0}, {1, 0}, {1.05, 0}, {2, 0}}
+	if !equalLines(got[0], want) {
+		t.Fatalf("merged line = %v, want %v", got[0], want)
+	}
+	if !got[1].Closed() || len(got[2]) != 2 {
+		t.Fatalf("closed loop or its neighbour changed: %v", got[1:])
+	}
+}
+
+func TestSortLinesFlipsAndOrders(t *testing.T) {
+	lines := []Polyline{
+		{{100, 0}, {90, 0}},
+		{{0, 0}, {10, 0}},
+		{{50, 0}, {40, 0}},
+	}
+	got, end := sortLines(lines, Point{})
+	want := []Polyline{
+		{{0, 0}, {10, 0}},
+		{{40, 0}, {50, 0}},
+		{{90, 0}, {100, 0}},
+	}
+	for i := range want {
+		if !equalLines(got[i], want[i]) {
+			t.Fatalf("sorted = %v, want %v", got, want)
+		}
+	}
+	if end != (Point{100, 0}) {
+		t.Fatalf("pen ends at %v", end)
+	}
+}
+
+func TestOptimizeReducesTravel(t *testing.T) {
+	// A grid of short dashes drawn in random order and direction.
+	r := rand.New(rand.NewSource(1))
+	var paths []Polyline
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 30; x++ {
+			p := Polyline{{float64(x * 10), float64(y * 10)}, {float64(x*10 + 5), float64(y * 10)}}
+			if r.Intn(2) == 0 {
+				p = p.reversed()
+			}
+			paths = append(paths, p)
+		}
+	}
+	r.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+	fill := Shape{Style: Style{Fill: "#ff0000", FillOpacity: 1, Stroke: "#000000", StrokeWidth: 1, StrokeOpacity: 1},
+		Paths: []Polyline{{{301, 301}, {302, 301}, {302, 302}, {301, 301}}}}
+	d := &Drawing{ViewBox: [4]float64{0, 0, 300, 300}, Layers: []Layer{{Name: "pen", Shapes: []Shape{fill, strokeShape(paths...), strokeShape(paths[:10]...)}}}}
+
+	before := Measure(d)
+	opt := Optimize(d, OptimizeOptions{})
+	after := Measure(opt)
+	if after.Paths != 901 {
+		t.Fatalf("optimized drawing has %d paths, want 900 dashes and the triangle", after.Paths)
+	}
+	if math.Abs(after.PenDown-(900*5+2+math.Sqrt2)) > 1e-6 {
+		t.Fatalf("pen-down length changed: %v", after.PenDown)
+	}
+	if after.PenUp > before.PenUp/5 {
+		t.Fatalf("travel %v → %v, expected a large reduction", before.PenUp, after.PenUp)
+	}
+	l := opt.Layers[0]
+	if l.Name != "pen" || len(l.Shapes) != 2 || l.Shapes[0].Stroked() || !l.Shapes[0].Filled() {
+		t.Fatalf("optimized layer = %+v", l.Shapes)
+	}
+}
+
+func TestMeasureRestartsEachLayerAtOrigin(t *testing.T) {
+	d := &Drawing{Layers: []Layer{
+		{Shapes: []Shape{strokeShape(Polyline{{3, 4}, {3, 5}})}},
+		{Shapes: []Shape{strokeShape(Polyline{{0, 10}, {0, 20}}), {Style: Style{Fill: "#000000", FillOpacity: 1}, Paths: []Polyline{{{50, 50}, {60, 60}}}}}},
+	}}
+	st := Measure(d)
+	if st.Paths != 2 || st.PenDown != 11 || st.PenUp != 15 {
+		t.Fatalf("Measure = %+v", st)
+	}
+}
@@ -0,0 +1,394 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// maxFlattenDepth bounds curve subdivision (2^16 segments per curve).
+const maxFlattenDepth = 16
+
+// numScanner reads the numbers, flags and command letters of SVG path data
+// and number lists.
+type numScanner struct {
+	s string
+	i int
+}
+
+func (sc *numScanner) done() bool { return sc.i >= len(sc.s) }
+
+func (sc *numScanner) skipSep() {
+	for sc.i < len(sc.s) {
+		switch sc.s[sc.i] {
+		case ' ', '\t', '\r', '\n', ',':
+			sc.i++
+		default:
+			return
+		}
+	}
+}
+
+// number reads one number; SVG allows "1.5.5" (two numbers) and "1-2".
+func (sc *numScanner) number() (float64, error) {
+	sc.skipSep()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+		sc.i++
+	}
+	digits := sc.digits()
+	if sc.i < len(sc.s) && sc.s[sc.i] == '.' {
+		sc.i++
+		digits += sc.digits()
+	}
+	if digits == 0 {
+		sc.i = start
+		return 0, fmt.Errorf("plot: expected a number at %q", sc.rest())
+	}
+	if sc.i < len(sc.s) && (sc.s[sc.i] == 'e' || sc.s[sc.i] == 'E') {
+		save := sc.i
+		sc.i++
+		if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+			sc.i++
+		}
+		if sc.digits() == 0 {
+			sc.i = save
+		}
+	}
+	return strconv.ParseFloat(sc.s[start:sc.i], 64)
+}
+
+func (sc *numScanner) digits() int {
+	n := 0
+	for sc.i < len(sc.s) && sc.s[sc.i] >= '0' && sc.s[sc.i] <= '9' {
+		sc.i++
+		n++
+	}
+	return n
+}
+
+// flag reads an arc flag, which may be written without a separator.
+func (sc *numScanner) flag() (bool, error) {
+	sc.skipSep()
+	if sc.i < len(sc.s) {
+		switch sc.s[sc.i] {
+		case '0':
+			sc.i++
+			return false, nil
+		case '1':
+			sc.i++
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("plot: expected an arc flag at %q", sc.rest())
+}
+
+func (sc *numScanner) rest() string {
+	r := sc.s[sc.i:]
+	if len(r) > 16 {
+		r = r[:16] + "…"
+	}
+	return r
+}
+
+// flattener builds polylines in output space from drawing commands in
+// local space.
+type flattener struct {
+	m     affine
+	tol   float64 // in output units
+	out   []Polyline
+	line  Polyline
+	cur   Point // local
+	start Point // local: the current subpath's start
+}
+
+func (f *flattener) flush() {
+	if len(f.line) >= 2 {
+		f.out = append(f.out, f.line)
+	}
+	f.line = nil
+}
+
+func (f *flattener) moveTo(p Point) {
+	f.flush()
+	f.cur, f.start = p, p
+	f.line = Polyline{f.m.apply(p)}
+}
+
+func (f *flattener) ensureStarted() {
+	if f.line == nil {
+		f.line = Polyline{f.m.apply(f.cur)}
+	}
+}
+
+func (f *flattener) emit(q Point) {
+	if last := f.line[len(f.line)-1]; last != q {
+		f.line = append(f.line, q)
+	}
+}
+
+func (f *flattener) lineTo(p Point) {
+	f.ensureStarted()
+	f.emit(f.m.apply(p))
+	f.cur = p
+}
+
+func (f *flattener) closePath() {
+	if f.line != nil {
+		f.emit(f.line[0])
+		if len(f.line) > 1 {
+			f.out = append(f.out, f.line)
+		}
+	}
+	f.line = nil
+	f.cur = f.start
+}
+
+func (f *flattener) cubicTo(c1, c2, p Point) {
+	f.ensureStarted()
+	f.flattenCubic(f.m.apply(f.cur), f.m.apply(c1), f.m.apply(c2), f.m.apply(p), 0)
+	f.cur = p
+}
+
+func (f *flattener) flattenCubic(p0, p1, p2, p3 Point, depth int) {
+	if depth >= maxFlattenDepth || (distToLine(p1, p0, p3) <= f.tol && distToLine(p2, p0, p3) <= f.tol) {
+		f.emit(p3)
+		return
+	}
+	// de Casteljau split at t = 0.5.
+	p01 := p0.add(p1).scale(0.5)
+	p12 := p1.add(p2).scale(0.5)
+	p23 := p2.add(p3).scale(0.5)
+	p012 := p01.add(p12).scale(0.5)
+	p123 := p12.add(p23).scale(0.5)
+	mid := p012.add(p123).scale(0.5)
+	f.flattenCubic(p0, p01, p012, mid, depth+1)
+	f.flattenCubic(mid, p123, p23, p3, depth+1)
+}
+
+// distToLine is the distance from p to the line through a and b (or to a
+// when they coincide).
+func distToLine(p, a, b Point) float64 {
+	d := b.sub(a)
+	n := math.Hypot(d.X, d.Y)
+	if n == 0 {
+		return p.Dist(a)
+	}
+	return math.Abs(d.cross(p.sub(a))) / n
+}
+
+func (f *flattener) quadTo(c, p Point) {
+	c1 := f.cur.add(c.sub(f.cur).scale(2.0 / 3))
+	c2 := p.add(c.sub(p).scale(2.0 / 3))
+	f.cubicTo(c1, c2, p)
+}
+
+// arcTo draws an SVG elliptical arc, converting the endpoint form to the
+// center form (SVG 1.1 appendix F.6.5).
+func (f *flattener) arcTo(rx, ry, rotDeg float64, large, sweep bool, p Point) {
+	p0 := f.cur
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if p0 == p {
+		return
+	}
+	if rx == 0 || ry == 0 {
+		f.lineTo(p)
+		return
+	}
+	sinPhi, cosPhi := math.Sincos(rotDeg * math.Pi / 180)
+	dx, dy := (p0.X-p.X)/2, (p0.Y-p.Y)/2
+	x1 := cosPhi*dx + sinPhi*dy
+	y1 := -sinPhi*dx + cosPhi*dy
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx, ry = rx*s, ry*s
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	co := 0.0
+	if den > 0 && num > 0 {
+		co = math.Sqrt(num / den)
+	}
+	if large == sweep {
+		co = -co
+	}
+	cx1 := co * rx * y1 / ry
+	cy1 := -co * ry * x1 / rx
+	cx := cosPhi*cx1 - sinPhi*cy1 + (p0.X+p.X)/2
+	cy := sinPhi*cx1 + cosPhi*cy1 + (p0.Y+p.Y)/2
+	theta1 := math.Atan2((y1-cy1)/ry, (x1-cx1)/rx)
+	dTheta := math.Atan2((-y1-cy1)/ry, (-x1-cx1)/rx) - theta1
+	if sweep && dTheta < 0 {
+		dTheta += 2 * math.Pi
+	} else if !sweep && dTheta > 0 {
+		dTheta -= 2 * math.Pi
+	}
+
+	f.ensureStarted()
+	n := arcSegments(math.Max(rx, ry)*f.m.scaleFactor(), math.Abs(dTheta), f.tol)
+	for i := 1; i <= n; i++ {
+		t := theta1 + dTheta*float64(i)/float64(n)
+		sin, cos := math.Sincos(t)
+		q := Point{cx + rx*cos*cosPhi - ry*sin*sinPhi, cy + rx*cos*sinPhi + ry*sin*cosPhi}
+		if i == n {
+			q = p
+		}
+		f.emit(f.m.apply(q))
+	}
+	f.cur = p
+}
+
+// arcSegments is how many chords keep an arc of radius r (output units)
+// within tol of the true curve.
+func arcSegments(r, sweep, tol float64) int {
+	if r <= tol {
+		return max(1, int(math.Ceil(sweep/(math.Pi/2))))
+	}
+	step := 2 * math.Acos(1-tol/r)
+	return max(1, min(4096, int(math.Ceil(sweep/step))))
+}
+
+// ellipse adds a closed ellipse as its own subpath.
+func (f *flattener) ellipse(cx, cy, rx, ry float64) {
+	if rx <= 0 || ry <= 0 {
+		return
+	}
+	f.moveTo(Point{cx + rx, cy})
+	f.arcTo(rx, ry, 0, false, true, Point{cx - rx, cy})
+	f.arcTo(rx, ry, 0, false, true, Point{cx + rx, cy})
+	f.closePath()
+}
+
+// flattenPathData flattens SVG path data. On malformed data it returns the
+// subpaths before the error along with it, as SVG renderers draw them.
+func flattenPathData(d string, m affine, tol float64) ([]Polyline, error) {
+	f := &flattener{m: m, tol: tol}
+	sc := &numScanner{s: d}
+	var cmd byte
+	var lastCtrl Point // reflected by S/T
+	var lastKind byte  // 'C' or 'Q' when lastCtrl applies
+	for {
+		sc.skipSep()
+		if sc.done() {
+			break
+		}
+		if c := sc.s[sc.i]; (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') && c != 'e' && c != 'E' {
+			cmd = c
+			sc.i++
+		} else if cmd == 0 || cmd|0x20 == 'z' {
+			return f.finish(), fmt.Errorf("plot: expected a path command at %q", sc.rest())
+		}
+		rel := cmd >= 'a'
+		abs := func(x, y float64) Point {
+			if rel {
+				return Point{f.cur.X + x, f.cur.Y + y}
+			}
+			return Point{x, y}
+		}
+		var nums [6]float64
+		read := func(n int) error {
+			for i := range n {
+				v, err := sc.number()
+				if err != nil {
+					return err
+				}
+				nums[i] = v
+			}
+			return nil
+		}
+		kind := byte(0)
+		var err error
+		switch cmd | 0x20 {
+		case 'm':
+			if err = read(2); err == nil {
+				f.moveTo(abs(nums[0], nums[1]))
+				// Further coordinate pairs are implicit line-tos.
+				if rel {
+					cmd = 'l'
+				} else {
+					cmd = 'L'
+				}
+			}
+		case 'l':
+			if err = read(2); err == nil {
+				f.lineTo(abs(nums[0], nums[1]))
+			}
+		case 'h':
+			if err = read(1); err == nil {
+				x := nums[0]
+				if rel {
+					x += f.cur.X
+				}
+				f.lineTo(Point{x, f.cur.Y})
+			}
+		case 'v':
+			if err = read(1); err == nil {
+				y := nums[0]
+				if rel {
+					y += f.cur.Y
+				}
+				f.lineTo(Point{f.cur.X, y})
+			}
+		case 'c':
+			if err = read(6); err == nil {
+				c1, c2, p := abs(nums[0], nums[1]), abs(nums[2], nums[3]), abs(nums[4], nums[5])
+				f.cubicTo(c1, c2, p)
+				lastCtrl, kind = c2, 'C'
+			}
+		case 's':
+			if err = read(4); err == nil {
+				c1 := f.cur
+				if lastKind == 'C' {
+					c1 = f.cur.scale(2).sub(lastCtrl)
+				}
+				c2, p := abs(nums[0], nums[1]), abs(nums[2], nums[3])
+				f.cubicTo(c1, c2, p)
+				lastCtrl, kind = c2, 'C'
+			}
+		case 'q':
+			if err = read(4); err == nil {
+				c, p := abs(nums[0], nums[1]), abs(nums[2], nums[3])
+				f.quadTo(c, p)
+				lastCtrl, kind = c, 'Q'
+			}
+		case 't':
+			if err = read(2); err == nil {
+				c := f.cur
+				if lastKind == 'Q' {
+					c = f.cur.scale(2).sub(lastCtrl)
+				}
+				f.quadTo(c, abs(nums[0], nums[1]))
+				lastCtrl, kind = c, 'Q'
+			}
+		case 'a':
+			if err = read(3); err != nil {
+				break
+			}
+			rx, ry, rot := nums[0], nums[1], nums[2]
+			var large, sweep bool
+			if large, err = sc.flag(); err != nil {
+				break
+			}
+			if sweep, err = sc.flag(); err != nil {
+				break
+			}
+			if err = read(2); err == nil {
+				f.arcTo(rx, ry, rot, large, sweep, abs(nums[0], nums[1]))
+			}
+		case 'z':
+			f.closePath()
+		default:
+			err = fmt.Errorf("plot: unknown path command %q", cmd)
+		}
+		if err != nil {
+			return f.finish(), err
+		}
+		lastKind = kind
+	}
+	return f.finish(), nil
+}
+
+func (f *flattener) finish() []Polyline {
+	f.flush()
+	return f.out
+}
@@ -0,0 +1,281 @@
+package plot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PageSize is a paper size in millimetres, portrait.
+type PageSize struct {
+	Name          string
+	Width, Height float64
+}
+
+// PageSizes are the paper presets, smallest ISO size first.
+var PageSizes = []PageSize{
+	{"A5", 148, 210},
+	{"A4", 210, 297},
+	{"A3", 297, 420},
+	{"A2", 420, 594},
+	{"Letter", 215.9, 279.4},
+	{"Legal", 215.9, 355.6},
+	{"Tabloid", 279.4, 431.8},
+	{"9x12in", 228.6, 304.8},
+	{"11x14in", 279.4, 355.6},
+}
+
+// LookupPageSize finds a paper preset by name, ignoring case. Any
+// "<w>x<h><unit>" size with unit mm, cm or in, such as "200x300mm", is
+// accepted too.
+func LookupPageSize(name string) (PageSize, bool) {
+	for _, p := range PageSizes {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	for unit, mm := range map[string]float64{"mm": 1, "cm": 10, "in": 25.4} {
+		dims, ok := strings.CutSuffix(name, unit)
+		if !ok {
+			continue
+		}
+		ws, hs, ok := strings.Cut(dims, "x")
+		if !ok {
+			return PageSize{}, false
+		}
+		w, werr := strconv.ParseFloat(strings.TrimSpace(ws), 64)
+		h, herr := strconv.ParseFloat(strings.TrimSpace(hs), 64)
+		if werr != nil || herr != nil || w <= 0 || h <= 0 {
+			return PageSize{}, false
+		}
+		return PageSize{Name: name, Width: w * mm, Height: h * mm}, true
+	}
+	return PageSize{}, false
+}
+
+// SizeMM is the document's physical size: its width and height attributes
+// where they parse, else the viewBox, at 96 user units per inch.
+func (d *Drawing) SizeMM() (w, h float64) {
+	w, okW := parseLength(d.Width)
+	h, okH := parseLength(d.Height)
+	if !okW || w <= 0 {
+		w = d.ViewBox[2]
+	}
+	if !okH || h <= 0 {
+		h = d.ViewBox[3]
+	}
+	return w * MMPerPixel, h * MMPerPixel
+}
+
+// PDFOptions sets the PDF page. A zero Width or Height makes the page the
+// drawing's own size (SizeMM).
+type PDFOptions struct {
+	// Width and Height are the page size in millimetres.
+	Width, Height float64
+	// Margin is kept clear on every side of the page, in millimetres.
+	Margin float64
+	// Title is stored in the document information.
+	Title string
+}
+
+const ptPerMM = 72 / 25.4
+
+// WritePDF writes d as a one-page vector PDF. The drawing is scaled to fit
+// inside the page margins, keeping its aspect ratio, and centered. Fills,
+// strokes, stroke widths, caps, joins and opacity are kept; layers are
+// drawn in order.
+func WritePDF(w io.Writer, d *Drawing, opts PDFOptions) error {
+	pw, ph := opts.Width, opts.Height
+	if pw <= 0 || ph <= 0 {
+		pw, ph = d.SizeMM()
+	}
+	vb := d.ViewBox
+	if vb[2] <= 0 || vb[3] <= 0 || pw <= 0 || ph <= 0 {
+		return fmt.Errorf("plot: drawing has no size to fit on a page")
+	}
+	aw, ah := pw-2*opts.Margin, ph-2*opts.Margin
+	if aw <= 0 || ah <= 0 {
+		return fmt.Errorf("plot: %gmm margins leave no room on a %gx%gmm page", opts.Margin, pw, ph)
+	}
+	// User units to points, then center in the margins.
+	scale := math.Min(aw/vb[2], ah/vb[3]) * ptPerMM
+	pageW, pageH := pw*ptPerMM, ph*ptPerMM
+	ox := (pageW - vb[2]*scale) / 2
+	oy := (pageH - vb[3]*scale) / 2
+
+	var content bytes.Buffer
+	states := map[[2]float64]string{}
+	var gsOrder [][2]float64
+	fmt.Fprintf(&content, "%s 0 0 %s %s %s cm\n", matrixNum(scale), matrixNum(-scale),
+		matrixNum(ox-vb[0]*scale), matrixNum(pageH-oy+vb[1]*scale))
+	for _, l := range d.Layers {
+		for _, sh := range l.Shapes {
+			op := pdfPaintOp(sh.Style)
+			if op == "" || len(sh.Paths) == 0 {
+				continue
+			}
+			content.WriteString("q\n")
+			alpha := [2]float64{1, 1}
+			if sh.Stroked() {
+				alpha[0] = sh.StrokeOpacity
+			}
+			if sh.Filled() {
+				alpha[1] = sh.FillOpacity
+			}
+			if alpha != [2]float64{1, 1} {
+				name, ok := states[alpha]
+				if !ok {
+					name = fmt.Sprintf("GS%d", len(gsOrder))
+					states[alpha] = name
+					gsOrder = append(gsOrder, alpha)
+				}
+				fmt.Fprintf(&content, "/%s gs\n", name)
+			}
+			if sh.Stroked() {
+				fmt.Fprintf(&content, "%s RG %s w %d J %d j 4 M\n",
+					pdfColor(sh.Stroke), num(sh.StrokeWidth), pdfCap(sh.LineCap), pdfJoin(sh.LineJoin))
+			}
+			if sh.Filled() {
+				fmt.Fprintf(&content, "%s rg\n", pdfColor(sh.Fill))
+			}
+			for _, pl := range sh.Paths {
+				writePDFPath(&content, pl)
+			}
+			content.WriteString(op)
+			content.WriteString("\nQ\n")
+		}
+	}
+
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write(content.Bytes())
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	var res strings.Builder
+	if len(gsOrder) > 0 {
+		res.WriteString("/ExtGState <<")
+		for _, a := range gsOrder {
+			fmt.Fprintf(&res, " /%s << /CA %s /ca %s >>", states[a], num(a[0]), num(a[1]))
+		}
+		res.WriteString(" >>")
+	}
+
+	out := &pdfWriter{w: bufio.NewWriter(w)}
+	out.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	out.object("<< /Type /Catalog /Pages 2 0 R >>")
+	out.object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	out.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents 4 0 R >>",
+		num(pageW), num(pageH), res.String()))
+	out.object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.Bytes()))
+	out.object(fmt.Sprintf("<< /Producer (sketchy) /Title (%s) >>", pdfEscape(opts.Title)))
+	return out.finish(1, 5)
+}
+
+// pdfPaintOp is the path painting operator for a style, or "" when it
+// paints nothing.
+func pdfPaintOp(st Style) string {
+	star := ""
+	if st.FillRule == "evenodd" {
+		star = "*"
+	}
+	switch {
+	case st.Filled() && st.Stroked():
+		return "B" + star
+	case st.Filled():
+		return "f" + star
+	case st.Stroked():
+		return "S"
+	}
+	return ""
+}
+
+func writePDFPath(b *bytes.Buffer, pl Polyline) {
+	if len(pl) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s %s m\n", num(pl[0].X), num(pl[0].Y))
+	end := len(pl)
+	if pl.Closed() {
+		end--
+	}
+	for _, p := range pl[1:end] {
+		fmt.Fprintf(b, "%s %s l\n", num(p.X), num(p.Y))
+	}
+	if pl.Closed() {
+		b.WriteString("h\n")
+	}
+}
+
+// pdfColor formats a "#rrggbb" paint as PDF RGB components; unparsed
+// colors are black.
+func pdfColor(paint string) string {
+	c, _ := ParseColor(paint)
+	return fmt.Sprintf("%s %s %s", num(float64(c.R)/255), num(float64(c.G)/255), num(float64(c.B)/255))
+}
+
+func pdfCap(v string) int {
+	switch v {
+	case "round":
+		return 1
+	case "square":
+		return 2
+	}
+	return 0
+}
+
+func pdfJoin(v string) int {
+	switch v {
+	case "round":
+		return 1
+	case "bevel":
+		return 2
+	}
+	return 0
+}
+
+// matrixNum formats a transform entry with enough precision for large
+// scale-downs.
+func matrixNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+// pdfEscape escapes a PDF literal string.
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`).Replace(s)
+}
+
+// pdfWriter numbers objects from 1 and records their offsets for the
+// cross-reference table.
+type pdfWriter struct {
+	w       *bufio.Writer
+	n       int
+	offsets []int
+}
+
+func (p *pdfWriter) printf(format string, args ...any) {
+	n, _ := fmt.Fprintf(p.w, format, args...)
+	p.n += n
+}
+
+func (p *pdfWriter) object(body string) {
+	p.offsets = append(p.offsets, p.n)
+	p.printf("%d 0 obj\n%s\nendobj\n", len(p.offsets), body)
+}
+
+func (p *pdfWriter) finish(root, info int) error {
+	xref := p.n
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, off := range p.offsets {
+		p.printf("%010d 00000 n \n", off)
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, root, info, xref)
+	return p.w.Flush()
+}
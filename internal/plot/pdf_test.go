@@ -0,0 +1,84 @@
+package plot
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWritePDF(t *testing.T) {
+	d := &Drawing{ViewBox: [4]float64{0, 0, 200, 100}, Layers: []Layer{{Shapes: []Shape{
+		{Style: Style{Stroke: "#ff0000", StrokeWidth: 2, StrokeOpacity: 0.5, LineCap: "round"},
+			Paths: []Polyline{{{0, 0}, {200, 100}}}},
+		{Style: Style{Fill: "#0000ff", FillOpacity: 1, FillRule: "evenodd"},
+			Paths: []Polyline{{{10, 10}, {20, 10}, {20, 20}, {10, 10}}}},
+	}}}}
+	a4, ok := LookupPageSize("a4")
+	if !ok {
+		t.Fatal("A4 preset missing")
+	}
+	var b bytes.Buffer
+	if err := WritePDF(&b, d, PDFOptions{Width: a4.Height, Height: a4.Width, Margin: 10, Title: "a (test)"}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{"%PDF-1.4", "/MediaBox [0 0 841.89 595.276]", `/Title (a \(test\))`, "/CA 0.5 /ca 1", "%%EOF"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("PDF lacks %q:\n%s", want, out)
+		}
+	}
+
+	// Every cross-reference entry points at its object.
+	xref := out[strings.LastIndex(out, "\nxref\n"):]
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(xref, -1)
+	if len(entries) != 5 {
+		t.Fatalf("xref has %d entries:\n%s", len(entries), xref)
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(e[1])
+		if !strings.HasPrefix(out[off:], strconv.Itoa(i+1)+" 0 obj") {
+			t.Fatalf("xref entry %d points at %q", i+1, out[off:off+10])
+		}
+	}
+
+	start := strings.Index(out, "stream\n") + len("stream\n")
+	end := strings.Index(out, "\nendstream")
+	zr, err := zlib.NewReader(strings.NewReader(out[start:end]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 200x100 units fit the 277x190mm area at 1.385mm per unit.
+	for _, want := range []string{
+		"3.925984 0 0 -3.925984 28.346457 493.937008 cm", "/GS0 gs", "1 0 0 RG 2 w 1 J 0 j",
+		"0 0 m\n200 100 l\nS", "0 0 1 rg", "10 10 m\n20 10 l\n20 20 l\nh\nf*",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Fatalf("content lacks %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestLookupPageSize(t *testing.T) {
+	for name, want := range map[string][2]float64{
+		"Letter": {215.9, 279.4}, "9x12in": {228.6, 304.8}, "200x300mm": {200, 300}, "10 x 20cm": {100, 200},
+	} {
+		p, ok := LookupPageSize(name)
+		if !ok || math.Abs(p.Width-want[0]) > 1e-9 || math.Abs(p.Height-want[1]) > 1e-9 {
+			t.Errorf("LookupPageSize(%q) = %+v, %v", name, p, ok)
+		}
+	}
+	for _, name := range []string{"B7", "12in", "0x5mm"} {
+		if _, ok := LookupPageSize(name); ok {
+			t.Errorf("LookupPageSize(%q) accepted", name)
+		}
+	}
+}
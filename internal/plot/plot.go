@@ -0,0 +1,155 @@
+// Package plot turns a sketch's SVG output into flattened polylines and
+// prepares them for pen plotters: path optimization, statistics, and
+// re-encoding. It works on SVG text rather than on render.Recorder, so it
+// can be used (and tested) without the renderer.
+//
+// Coordinates are SVG user units, which for sketchy output are sketch
+// pixels.
+package plot
+
+import "math"
+
+// Point is a position in user units.
+type Point struct {
+	X, Y float64
+}
+
+func (p Point) sub(q Point) Point     { return Point{p.X - q.X, p.Y - q.Y} }
+func (p Point) add(q Point) Point     { return Point{p.X + q.X, p.Y + q.Y} }
+func (p Point) scale(f float64) Point { return Point{p.X * f, p.Y * f} }
+func (p Point) dot(q Point) float64   { return p.X*q.X + p.Y*q.Y }
+func (p Point) cross(q Point) float64 { return p.X*q.Y - p.Y*q.X }
+
+// Dist is the distance between p and q.
+func (p Point) Dist(q Point) float64 { return math.Hypot(p.X-q.X, p.Y-q.Y) }
+
+// Polyline is one flattened subpath. A closed subpath repeats its first
+// point at the end.
+type Polyline []Point
+
+// Length is the sum of the segment lengths.
+func (l Polyline) Length() float64 {
+	var n float64
+	for i := 1; i < len(l); i++ {
+		n += l[i].Dist(l[i-1])
+	}
+	return n
+}
+
+// Closed reports whether the polyline ends where it starts.
+func (l Polyline) Closed() bool {
+	return len(l) > 2 && l[0] == l[len(l)-1]
+}
+
+func (l Polyline) reversed() Polyline {
+	out := make(Polyline, len(l))
+	for i, p := range l {
+		out[len(l)-1-i] = p
+	}
+	return out
+}
+
+// Style is the resolved paint of a shape. Colors are normalized to
+// "#rrggbb" where they parse; an empty Stroke or Fill means none.
+type Style struct {
+	Stroke        string
+	StrokeWidth   float64
+	StrokeOpacity float64
+	LineCap       string
+	LineJoin      string
+	Fill          string
+	FillOpacity   float64
+	FillRule      string // "nonzero" or "evenodd"
+}
+
+// Stroked reports whether the shape draws its outline.
+func (st Style) Stroked() bool { return st.Stroke != "" && st.StrokeWidth > 0 && st.StrokeOpacity > 0 }
+
+// Filled reports whether the shape paints its interior.
+func (st Style) Filled() bool { return st.Fill != "" && st.FillOpacity > 0 }
+
+// Shape is one drawn element: its subpaths share a style.
+type Shape struct {
+	Style
+	Paths []Polyline
+}
+
+// Layer is a named group of shapes; each is plotted with one pen. The
+// unnamed layer holds drawing outside any Inkscape layer.
+type Layer struct {
+	Name   string
+	Shapes []Shape
+}
+
+// Drawing is a flattened SVG document.
+type Drawing struct {
+	// Width and Height are the document's width and height attributes
+	// verbatim (they may carry units, e.g. "297mm").
+	Width, Height string
+	// ViewBox is the user-space rectangle: min x, min y, width, height.
+	ViewBox [4]float64
+	Layers  []Layer
+}
+
+// GroupByPaint regroups the shapes of d into one layer per stroke color
+// (fill color for unstroked shapes), named by the color, in order of first
+// use. Shapes keep their order within each layer.
+func GroupByPaint(d *Drawing) *Drawing {
+	var layers []Layer
+	index := map[string]int{}
+	for _, l := range d.Layers {
+		for _, sh := range l.Shapes {
+			name := sh.Fill
+			if sh.Stroked() {
+				name = sh.Stroke
+			}
+			if name == "" {
+				name = "none"
+			}
+			i, ok := index[name]
+			if !ok {
+				i = len(layers)
+				index[name] = i
+				layers = append(layers, Layer{Name: name})
+			}
+			layers[i].Shapes = append(layers[i].Shapes, sh)
+		}
+	}
+	d.Layers = layers
+	return d
+}
+
+// Stats summarizes how a drawing plots.
+type Stats struct {
+	// Paths is the number of stroked polylines, i.e. pen-downs.
+	Paths int
+	// PenDown is the total drawn length.
+	PenDown float64
+	// PenUp is the total travel between polylines. Each layer is a pen
+	// change, so its travel starts from the origin.
+	PenUp float64
+}
+
+// Measure walks the stroked polylines in plotting order. Unstroked shapes
+// are not plotted and are ignored.
+func Measure(d *Drawing) Stats {
+	var st Stats
+	for _, l := range d.Layers {
+		var pos Point
+		for _, sh := range l.Shapes {
+			if !sh.Stroked() {
+				continue
+			}
+			for _, pl := range sh.Paths {
+				if len(pl) < 2 {
+					continue
+				}
+				st.Paths++
+				st.PenUp += pos.Dist(pl[0])
+				st.PenDown += pl.Length()
+				pos = pl[len(pl)-1]
+			}
+		}
+	}
+	return st
+}
@@ -0,0 +1,308 @@
+package plot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// InkscapeNS is the namespace of Inkscape's layer attributes.
+const InkscapeNS = "http://www.inkscape.org/namespaces/inkscape"
+
+// skippedElements hold no directly drawn geometry.
+var skippedElements = map[string]bool{
+	"defs": true, "clipPath": true, "mask": true, "marker": true, "pattern": true,
+	"symbol": true, "style": true, "script": true, "title": true, "desc": true,
+	"metadata": true, "linearGradient": true, "radialGradient": true, "filter": true,
+	"text": true, "image": true, "foreignObject": true,
+}
+
+// paintState is the inherited part of the style cascade.
+type paintState struct {
+	stroke, fill, color string // raw values, resolved per shape
+	strokeWidth         float64
+	strokeOpacity       float64
+	fillOpacity         float64
+	opacity             float64 // product of the ancestors' opacity
+	fillRule            string
+	lineCap, lineJoin   string
+}
+
+var defaultPaint = paintState{
+	stroke: "none", fill: "black", color: "black",
+	strokeWidth: 1, strokeOpacity: 1, fillOpacity: 1, opacity: 1,
+	fillRule: "nonzero", lineCap: "butt", lineJoin: "miter",
+}
+
+type svgFrame struct {
+	m     affine
+	paint paintState
+	skip  bool
+	layer int // index into Drawing.Layers for an Inkscape layer subtree, else -1
+}
+
+// ParseSVG flattens an SVG document into polylines within tol user units
+// of the true curves. Top-level Inkscape layer groups become layers, and
+// drawing outside them goes to unnamed layers in document order.
+//
+// Only geometry is kept: text, images, clipping and masks are ignored, and
+// gradients paint as their fallback color.
+func ParseSVG(data []byte, tol float64) (*Drawing, error) {
+	if tol <= 0 {
+		tol = 0.1
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	d := &Drawing{}
+	var stack []svgFrame
+	loose := -1 // the open unnamed layer
+	root := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("plot: parse svg: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				if t.Name.Local != "svg" {
+					return nil, fmt.Errorf("plot: root element is <%s>, not <svg>", t.Name.Local)
+				}
+				d.readRoot(t.Attr)
+				root = true
+				paint := defaultPaint
+				paint.apply(attrMap(t.Attr))
+				stack = append(stack, svgFrame{m: identity, paint: paint, layer: -1})
+				continue
+			}
+			parent := stack[len(stack)-1]
+			fr := svgFrame{m: parent.m, paint: parent.paint, skip: parent.skip, layer: parent.layer}
+			attrs := attrMap(t.Attr)
+			if skippedElements[t.Name.Local] || attrs["display"] == "none" || attrs["visibility"] == "hidden" {
+				fr.skip = true
+			}
+			if fr.skip {
+				stack = append(stack, fr)
+				continue
+			}
+			if v, ok := attrs["transform"]; ok {
+				tm, err := parseTransform(v)
+				if err != nil {
+					return nil, err
+				}
+				fr.m = fr.m.then(tm)
+			}
+			fr.paint.apply(attrs)
+			if len(stack) == 1 && t.Name.Local == "g" && attrs["groupmode"] == "layer" {
+				name := attrs["label"]
+				if name == "" {
+					name = attrs["id"]
+				}
+				if name == "" {
+					name = fmt.Sprintf("layer%d", len(d.Layers)+1)
+				}
+				d.Layers = append(d.Layers, Layer{Name: name})
+				fr.layer = len(d.Layers) - 1
+				loose = -1
+			}
+			if paths := elementGeometry(t.Name.Local, attrs, fr.m, tol); len(paths) > 0 {
+				li := fr.layer
+				if li < 0 {
+					if loose < 0 {
+						d.Layers = append(d.Layers, Layer{})
+						loose = len(d.Layers) - 1
+					}
+					li = loose
+				}
+				d.Layers[li].Shapes = append(d.Layers[li].Shapes, Shape{Style: fr.paint.resolve(fr.m), Paths: paths})
+			}
+			stack = append(stack, fr)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if !root || len(stack) != 0 {
+		return nil, fmt.Errorf("plot: parse svg: no complete <svg> element")
+	}
+	return d, nil
+}
+
+// attrMap indexes attributes by local name (so inkscape:label is "label"),
+// with style declarations overriding presentation attributes.
+func attrMap(attrs []xml.Attr) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		if a.Name.Local != "style" {
+			m[a.Name.Local] = strings.TrimSpace(a.Value)
+		}
+	}
+	for _, a := range attrs {
+		if a.Name.Local != "style" {
+			continue
+		}
+		for _, decl := range strings.Split(a.Value, ";") {
+			if k, v, ok := strings.Cut(decl, ":"); ok {
+				m[strings.TrimSpace(k)] = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "!important"))
+			}
+		}
+	}
+	return m
+}
+
+func (d *Drawing) readRoot(attrs []xml.Attr) {
+	a := attrMap(attrs)
+	d.Width, d.Height = a["width"], a["height"]
+	if vb, err := parseNumberList(a["viewBox"]); err == nil && len(vb) == 4 {
+		copy(d.ViewBox[:], vb)
+		return
+	}
+	w, _ := parseLength(d.Width)
+	h, _ := parseLength(d.Height)
+	d.ViewBox = [4]float64{0, 0, w, h}
+}
+
+func (p *paintState) apply(a map[string]string) {
+	num := func(k string, dst *float64) {
+		if v, ok := a[k]; ok {
+			if f, ok := parseLength(v); ok {
+				*dst = f
+			}
+		}
+	}
+	str := func(k string, dst *string) {
+		if v, ok := a[k]; ok && v != "inherit" {
+			*dst = v
+		}
+	}
+	str("stroke", &p.stroke)
+	str("fill", &p.fill)
+	str("color", &p.color)
+	str("fill-rule", &p.fillRule)
+	str("stroke-linecap", &p.lineCap)
+	str("stroke-linejoin", &p.lineJoin)
+	num("stroke-width", &p.strokeWidth)
+	num("stroke-opacity", &p.strokeOpacity)
+	num("fill-opacity", &p.fillOpacity)
+	if v, ok := a["opacity"]; ok {
+		if f, ok := parseLength(v); ok {
+			p.opacity *= f
+		}
+	}
+}
+
+// resolve is the final style of a shape drawn under m.
+func (p paintState) resolve(m affine) Style {
+	return Style{
+		Stroke:        normalizePaint(p.stroke, p.color),
+		StrokeWidth:   p.strokeWidth * m.scaleFactor(),
+		StrokeOpacity: clamp01(p.strokeOpacity * p.opacity),
+		LineCap:       p.lineCap,
+		LineJoin:      p.lineJoin,
+		Fill:          normalizePaint(p.fill, p.color),
+		FillOpacity:   clamp01(p.fillOpacity * p.opacity),
+		FillRule:      p.fillRule,
+	}
+}
+
+func clamp01(v float64) float64 { return math.Min(1, math.Max(0, v)) }
+
+// lengthUnits converts CSS absolute units to user units (px).
+var lengthUnits = map[string]float64{
+	"": 1, "px": 1, "mm": 96 / 25.4, "cm": 96 / 2.54, "in": 96, "pt": 96.0 / 72, "pc": 16,
+}
+
+// parseLength parses a number with an optional absolute unit. Percentages
+// are not supported.
+func parseLength(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	i := len(s)
+	for i > 0 && (s[i-1] >= 'a' && s[i-1] <= 'z') {
+		i--
+	}
+	f, ok := lengthUnits[s[i:]]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s[:i]), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * f, true
+}
+
+// elementGeometry flattens a basic shape or path element.
+func elementGeometry(name string, a map[string]string, m affine, tol float64) []Polyline {
+	n := func(k string) float64 {
+		v, _ := parseLength(a[k])
+		return v
+	}
+	f := &flattener{m: m, tol: tol}
+	switch name {
+	case "path":
+		paths, _ := flattenPathData(a["d"], m, tol)
+		return paths
+	case "line":
+		f.moveTo(Point{n("x1"), n("y1")})
+		f.lineTo(Point{n("x2"), n("y2")})
+	case "polyline", "polygon":
+		nums, _ := parseNumberList(a["points"])
+		for i := 0; i+1 < len(nums); i += 2 {
+			p := Point{nums[i], nums[i+1]}
+			if i == 0 {
+				f.moveTo(p)
+			} else {
+				f.lineTo(p)
+			}
+		}
+		if name == "polygon" {
+			f.closePath()
+		}
+	case "rect":
+		x, y, w, h := n("x"), n("y"), n("width"), n("height")
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		rx, okx := parseLength(a["rx"])
+		ry, oky := parseLength(a["ry"])
+		switch {
+		case !okx && oky:
+			rx = ry
+		case okx && !oky:
+			ry = rx
+		}
+		rx, ry = math.Min(math.Max(rx, 0), w/2), math.Min(math.Max(ry, 0), h/2)
+		if rx == 0 || ry == 0 {
+			f.moveTo(Point{x, y})
+			f.lineTo(Point{x + w, y})
+			f.lineTo(Point{x + w, y + h})
+			f.lineTo(Point{x, y + h})
+		} else {
+			f.moveTo(Point{x + rx, y})
+			f.lineTo(Point{x + w - rx, y})
+			f.arcTo(rx, ry, 0, false, true, Point{x + w, y + ry})
+			f.lineTo(Point{x + w, y + h - ry})
+			f.arcTo(rx, ry, 0, false, true, Point{x + w - rx, y + h})
+			f.lineTo(Point{x + rx, y + h})
+			f.arcTo(rx, ry, 0, false, true, Point{x, y + h - ry})
+			f.lineTo(Point{x, y + ry})
+			f.arcTo(rx, ry, 0, false, true, Point{x + rx, y})
+		}
+		f.closePath()
+	case "circle":
+		r := n("r")
+		f.ellipse(n("cx"), n("cy"), r, r)
+	case "ellipse":
+		f.ellipse(n("cx"), n("cy"), n("rx"), n("ry"))
+	default:
+		return nil
+	}
+	return f.finish()
+}
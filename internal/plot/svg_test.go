@@ -0,0 +1,198 @@
+package plot
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseSVGShapesAndStyles(t *testing.T) {
+	const doc = `<svg xmlns="http://www.w3.org/2000/svg" width="100mm" height="50mm" viewBox="0 0 200 100">
+<defs><path id="hidden" d="M0 0L1 1"/></defs>
+<g stroke="red" fill="none" transform="translate(10,0)">
+  <path d="M0 0 h10 v10 z"/>
+  <line x1="0" y1="20" x2="5" y2="20" stroke-width="2" style="stroke:#00F"/>
+</g>
+<rect x="1" y="2" width="3" height="4" fill="rgb(0, 128, 0)"/>
+<circle cx="50" cy="50" r="10" stroke="black" fill="none" opacity="0.5"/>
+</svg>`
+	d, err := ParseSVG([]byte(doc), 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Width != "100mm" || d.ViewBox != [4]float64{0, 0, 200, 100} {
+		t.Fatalf("root = %q %v", d.Width, d.ViewBox)
+	}
+	if len(d.Layers) != 1 || len(d.Layers[0].Shapes) != 4 {
+		t.Fatalf("layers = %+v", d.Layers)
+	}
+	sh := d.Layers[0].Shapes
+
+	tri := sh[0]
+	if tri.Stroke != "#ff0000" || tri.Filled() || len(tri.Paths) != 1 {
+		t.Fatalf("triangle = %+v", tri)
+	}
+	want := Polyline{{10, 0}, {20, 0}, {20, 10}, {10, 0}}
+	if !equalLines(tri.Paths[0], want) || !tri.Paths[0].Closed() {
+		t.Fatalf("triangle path = %v, want %v", tri.Paths[0], want)
+	}
+	if sh[1].Stroke != "#0000ff" || sh[1].StrokeWidth != 2 || !equalLines(sh[1].Paths[0], Polyline{{10, 20}, {15, 20}}) {
+		t.Fatalf("line = %+v", sh[1])
+	}
+	if sh[2].Fill != "#008000" || sh[2].Stroked() || len(sh[2].Paths[0]) != 5 {
+		t.Fatalf("rect = %+v", sh[2])
+	}
+
+	circle := sh[3]
+	if circle.StrokeOpacity != 0.5 {
+		t.Fatalf("circle stroke opacity = %v", circle.StrokeOpacity)
+	}
+	for _, p := range circle.Paths[0] {
+		if r := p.Dist(Point{50, 50}); math.Abs(r-10) > 0.011 {
+			t.Fatalf("circle point %v is %v from the center", p, r)
+		}
+	}
+	if n := circle.Paths[0].Length(); math.Abs(n-2*math.Pi*10) > 0.05 {
+		t.Fatalf("circle length = %v", n)
+	}
+}
+
+func TestParseSVGInkscapeLayers(t *testing.T) {
+	const doc = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" viewBox="0 0 10 10">
+<path d="M0 0L1 0" stroke="#000"/>
+<g inkscape:groupmode="layer" inkscape:label="red pen"><path d="M0 1L1 1" stroke="#f00"/></g>
+<path d="M0 2L1 2" stroke="#000"/>
+</svg>`
+	d, err := ParseSVG([]byte(doc), 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range d.Layers {
+		names = append(names, l.Name)
+	}
+	if strings.Join(names, "|") != "|red pen|" {
+		t.Fatalf("layer names = %q", names)
+	}
+}
+
+func TestFlattenPathDataCommands(t *testing.T) {
+	cases := []struct {
+		d    string
+		want []Polyline
+	}{
+		{"M1,2L3-4", []Polyline{{{1, 2}, {3, -4}}}},
+		{"m1 1 2 0 0 2", []Polyline{{{1, 1}, {3, 1}, {3, 3}}}},
+		{"M0 0H5V5M10 10l1 1", []Polyline{{{0, 0}, {5, 0}, {5, 5}}, {{10, 10}, {11, 11}}}},
+		{"M0 0L1 0Z l0 1", []Polyline{{{0, 0}, {1, 0}, {0, 0}}, {{0, 0}, {0, 1}}}},
+		{"M.5.5L1e1 0", []Polyline{{{0.5, 0.5}, {10, 0}}}},
+	}
+	for _, c := range cases {
+		got, err := flattenPathData(c.d, identity, 0.1)
+		if err != nil {
+			t.Fatalf("%q: %v", c.d, err)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%q: got %v, want %v", c.d, got, c.want)
+		}
+		for i := range got {
+			if !equalLines(got[i], c.want[i]) {
+				t.Fatalf("%q: got %v, want %v", c.d, got, c.want)
+			}
+		}
+	}
+	if _, err := flattenPathData("M0 0 L1", identity, 0.1); err == nil {
+		t.Fatal("expected an error for a truncated path")
+	}
+}
+
+func TestFlattenCurvesStayWithinTolerance(t *testing.T) {
+	// A quarter circle as a compact arc and as a cubic approximation.
+	for _, d := range []string{"M10 0A10 10 0 010 10", "M10 0C10 5.523 5.523 10 0 10"} {
+		paths, err := flattenPathData(d, identity, 0.05)
+		if err != nil {
+			t.Fatalf("%q: %v", d, err)
+		}
+		pl := paths[0]
+		if pl[len(pl)-1] != (Point{0, 10}) || len(pl) < 4 {
+			t.Fatalf("%q flattened to %v", d, pl)
+		}
+		for i := 1; i < len(pl); i++ {
+			mid := pl[i-1].add(pl[i]).scale(0.5)
+			if r := mid.Dist(Point{}); 10-r > 0.06 {
+				t.Fatalf("%q: chord midpoint %v is %v inside the curve", d, mid, 10-r)
+			}
+		}
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	m, err := parseTransform("translate(10 20) rotate(90) scale(2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := m.apply(Point{1, 0})
+	if math.Abs(p.X-10) > 1e-9 || math.Abs(p.Y-22) > 1e-9 {
+		t.Fatalf("transformed point = %v, want (10, 22)", p)
+	}
+	if math.Abs(m.scaleFactor()-2) > 1e-9 {
+		t.Fatalf("scaleFactor = %v", m.scaleFactor())
+	}
+}
+
+func TestWriteSVGRoundTrip(t *testing.T) {
+	d := &Drawing{Width: "10mm", Height: "10mm", ViewBox: [4]float64{0, 0, 10, 10}, Layers: []Layer{
+		{Shapes: []Shape{{Style: Style{Fill: "#123456", FillOpacity: 1, FillRule: "evenodd"}, Paths: []Polyline{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}}},
+		{Name: "a & b", Shapes: []Shape{{Style: Style{Stroke: "#ff0000", StrokeWidth: 0.5, StrokeOpacity: 1, LineCap: "round"},
+			Paths: []Polyline{{{0, 0}, {1.00049, 2}}, {{3, 3}, {4, 4}}}}}},
+	}}
+	out := string(EncodeSVG(d))
+	for _, want := range []string{
+		`width="10mm"`, `inkscape:label="a &amp; b"`, `d="M0 0L1 0L1 1Z"`, `fill-rule="evenodd"`,
+		`d="M0 0L1 2 M3 3L4 4"`, `stroke-linecap="round"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %s:\n%s", want, out)
+		}
+	}
+	back, err := ParseSVG([]byte(out), 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Layers) != 2 || back.Layers[1].Name != "a & b" || len(back.Layers[1].Shapes[0].Paths) != 2 {
+		t.Fatalf("round trip = %+v", back.Layers)
+	}
+	if !back.Layers[0].Shapes[0].Paths[0].Closed() {
+		t.Fatal("closed path did not round-trip as closed")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for in, want := range map[string]string{
+		"#F0a": "#ff00aa", "#102030": "#102030", "rgb(255, 0, 10)": "#ff000a",
+		"rgb(100%,0%,50%)": "#ff0080", "Red": "#ff0000",
+	} {
+		c, ok := ParseColor(in)
+		if !ok || HexColor(c) != want {
+			t.Errorf("ParseColor(%q) = %v, %v; want %s", in, HexColor(c), ok, want)
+		}
+	}
+	if _, ok := ParseColor("#12"); ok {
+		t.Error("expected #12 to be rejected")
+	}
+	if got := normalizePaint("url(#g) blue", ""); got != "#0000ff" {
+		t.Errorf("gradient fallback = %q", got)
+	}
+}
+
+func equalLines(a, b Polyline) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Dist(b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// affine is the SVG matrix(a b c d e f): x' = a*x + c*y + e, y' = b*x + d*y + f.
+type affine [6]float64
+
+var identity = affine{1, 0, 0, 1, 0, 0}
+
+func (m affine) apply(p Point) Point {
+	return Point{m[0]*p.X + m[2]*p.Y + m[4], m[1]*p.X + m[3]*p.Y + m[5]}
+}
+
+// then returns the transform applying n first, then m.
+func (m affine) then(n affine) affine {
+	return affine{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// scaleFactor is the geometric mean scale, used for stroke widths and
+// flattening tolerances.
+func (m affine) scaleFactor() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+// parseTransform parses an SVG transform list.
+func parseTransform(s string) (affine, error) {
+	m := identity
+	s = strings.TrimSpace(s)
+	for s != "" {
+		open := strings.IndexByte(s, '(')
+		end := strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			return identity, fmt.Errorf("plot: bad transform %q", s)
+		}
+		name := strings.TrimSpace(s[:open])
+		args, err := parseNumberList(s[open+1 : end])
+		if err != nil {
+			return identity, err
+		}
+		s = strings.TrimLeft(s[end+1:], " \t\r\n,")
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+		var t affine
+		switch name {
+		case "matrix":
+			if len(args) != 6 {
+				return identity, fmt.Errorf("plot: matrix needs 6 values")
+			}
+			copy(t[:], args)
+		case "translate":
+			t = affine{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			t = affine{sx, 0, 0, arg(1, sx), 0, 0}
+		case "rotate":
+			a := arg(0, 0) * math.Pi / 180
+			sin, cos := math.Sincos(a)
+			cx, cy := arg(1, 0), arg(2, 0)
+			t = affine{1, 0, 0, 1, cx, cy}.then(affine{cos, sin, -sin, cos, 0, 0}).then(affine{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			t = affine{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = affine{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return identity, fmt.Errorf("plot: unknown transform %q", name)
+		}
+		m = m.then(t)
+	}
+	return m, nil
+}
+
+// parseNumberList parses whitespace- or comma-separated numbers.
+func parseNumberList(s string) ([]float64, error) {
+	sc := numScanner{s: s}
+	var out []float64
+	for {
+		sc.skipSep()
+		if sc.done() {
+			return out, nil
+		}
+		v, err := sc.number()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
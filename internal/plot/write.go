@@ -0,0 +1,124 @@
+package plot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WriteSVG encodes d as an SVG document. Named layers become Inkscape
+// layer groups; each shape is one path element.
+func WriteSVG(w io.Writer, d *Drawing) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:inkscape=\"%s\"", InkscapeNS)
+	if d.Width != "" {
+		fmt.Fprintf(bw, " width=\"%s\"", attrEscape(d.Width))
+	}
+	if d.Height != "" {
+		fmt.Fprintf(bw, " height=\"%s\"", attrEscape(d.Height))
+	}
+	vb := d.ViewBox
+	fmt.Fprintf(bw, " viewBox=\"%s %s %s %s\">\n", num(vb[0]), num(vb[1]), num(vb[2]), num(vb[3]))
+	for i, l := range d.Layers {
+		if l.Name != "" {
+			fmt.Fprintf(bw, "<g inkscape:groupmode=\"layer\" inkscape:label=\"%s\" id=\"layer%d\">\n", attrEscape(l.Name), i+1)
+		}
+		for _, sh := range l.Shapes {
+			writeShape(bw, sh)
+		}
+		if l.Name != "" {
+			bw.WriteString("</g>\n")
+		}
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// EncodeSVG is WriteSVG into a byte slice.
+func EncodeSVG(d *Drawing) []byte {
+	var b bytes.Buffer
+	_ = WriteSVG(&b, d)
+	return b.Bytes()
+}
+
+func writeShape(w *bufio.Writer, sh Shape) {
+	if len(sh.Paths) == 0 {
+		return
+	}
+	w.WriteString(`<path d="`)
+	for i, pl := range sh.Paths {
+		if i > 0 {
+			w.WriteByte(' ')
+		}
+		writePathData(w, pl)
+	}
+	w.WriteByte('"')
+	if sh.Filled() {
+		fmt.Fprintf(w, ` fill="%s"`, attrEscape(sh.Fill))
+		if sh.FillOpacity < 1 {
+			fmt.Fprintf(w, ` fill-opacity="%s"`, num(sh.FillOpacity))
+		}
+		if sh.FillRule == "evenodd" {
+			w.WriteString(` fill-rule="evenodd"`)
+		}
+	} else {
+		w.WriteString(` fill="none"`)
+	}
+	if sh.Stroked() {
+		fmt.Fprintf(w, ` stroke="%s" stroke-width="%s"`, attrEscape(sh.Stroke), num(sh.StrokeWidth))
+		if sh.StrokeOpacity < 1 {
+			fmt.Fprintf(w, ` stroke-opacity="%s"`, num(sh.StrokeOpacity))
+		}
+		if sh.LineCap != "" && sh.LineCap != "butt" {
+			fmt.Fprintf(w, ` stroke-linecap="%s"`, attrEscape(sh.LineCap))
+		}
+		if sh.LineJoin != "" && sh.LineJoin != "miter" {
+			fmt.Fprintf(w, ` stroke-linejoin="%s"`, attrEscape(sh.LineJoin))
+		}
+	}
+	w.WriteString("/>\n")
+}
+
+// writePathData writes one polyline as M/L commands, closing it with Z
+// when it returns to its start.
+func writePathData(w *bufio.Writer, pl Polyline) {
+	n := len(pl)
+	closed := pl.Closed()
+	if closed {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			w.WriteByte('M')
+		} else {
+			w.WriteByte('L')
+		}
+		w.WriteString(num(pl[i].X))
+		w.WriteByte(' ')
+		w.WriteString(num(pl[i].Y))
+	}
+	if closed {
+		w.WriteByte('Z')
+	}
+}
+
+// num formats a coordinate to a thousandth of a unit.
+func num(v float64) string {
+	v = math.Round(v*1000) / 1000
+	if v == 0 {
+		v = 0 // no "-0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func attrEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,317 @@
+package sketchdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MaxRating is the highest snapshot star rating; 0 means unrated.
+const MaxRating = 5
+
+// SnapshotSort orders ListSnapshots results.
+type SnapshotSort int
+
+const (
+	SortNewest SnapshotSort = iota
+	SortOldest
+	SortRating // highest first, newest first among equal ratings
+	SortName
+)
+
+var snapshotOrder = map[SnapshotSort]string{
+	SortNewest: `s.created_at DESC`,
+	SortOldest: `s.created_at ASC`,
+	SortRating: `s.rating DESC, s.created_at DESC`,
+	SortName:   `s.name COLLATE NOCASE ASC`,
+}
+
+// SnapshotFilter selects and orders snapshots for ListSnapshots. The zero
+// value lists every snapshot, newest first.
+type SnapshotFilter struct {
+	// Tag keeps snapshots with this tag (case-insensitive).
+	Tag string
+	// Text keeps snapshots whose name, description or tags contain every
+	// word of it (case-insensitive).
+	Text string
+	// MinRating keeps snapshots rated at least this many stars.
+	MinRating int
+	// Favorites keeps only favorites.
+	Favorites bool
+	Sort      SnapshotSort
+}
+
+// SnapshotInfo is a snapshot as listed by ListSnapshots, without its
+// control state or saves.
+type SnapshotInfo struct {
+	Name        string
+	CreatedAt   string
+	Description string
+	Tags        []string
+	Rating      int
+	Favorite    bool
+}
+
+// ListSnapshots returns the snapshots matching f, in f.Sort order.
+func (d *DB) ListSnapshots(f SnapshotFilter) ([]SnapshotInfo, error) {
+	order, ok := snapshotOrder[f.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown snapshot sort %d", f.Sort)
+	}
+	q := `SELECT s.id, s.name, s.created_at, s.description, s.rating, s.favorite FROM snapshots s WHERE s.rating >= ?`
+	args := []any{f.MinRating}
+	if f.Favorites {
+		q += ` AND s.favorite != 0`
+	}
+	if f.Tag != "" {
+		q += ` AND EXISTS (SELECT 1 FROM snapshot_tags t WHERE t.snapshot_id = s.id AND t.tag = ?)`
+		args = append(args, f.Tag)
+	}
+	for _, w := range strings.Fields(f.Text) {
+		q += ` AND (s.name LIKE ? ESCAPE '\' OR s.description LIKE ? ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM snapshot_tags t WHERE t.snapshot_id = s.id AND t.tag LIKE ? ESCAPE '\'))`
+		like := "%" + escapeLike(w) + "%"
+		args = append(args, like, like, like)
+	}
+	q += ` ORDER BY ` + order
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rows, err := d.sql.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []SnapshotInfo
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var r SnapshotInfo
+		if err := rows.Scan(&id, &r.Name, &r.CreatedAt, &r.Description, &r.Rating, &r.Favorite); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		out = append(out, r)
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		if out[i].Tags, err = d.snapshotTags(id); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// escapeLike escapes LIKE's wildcards in s, for ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// snapshotTags lists a snapshot's tags alphabetically. The caller holds
+// d.mu.
+func (d *DB) snapshotTags(id int64) ([]string, error) {
+	return d.queryStrings(`SELECT tag FROM snapshot_tags WHERE snapshot_id = ? ORDER BY tag COLLATE NOCASE`, id)
+}
+
+// ListTags lists every tag in use, alphabetically.
+func (d *DB) ListTags() ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.queryStrings(`SELECT tag FROM snapshot_tags GROUP BY tag ORDER BY tag COLLATE NOCASE`)
+}
+
+// queryStrings runs a query returning one text column. The caller holds d.mu.
+func (d *DB) queryStrings(query string, args ...any) ([]string, error) {
+	rows, err := d.sql.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// snapshotID looks up a snapshot by name. The caller holds d.mu.
+func (d *DB) snapshotID(name string) (int64, error) {
+	var id int64
+	err := d.sql.QueryRow(`SELECT id FROM snapshots WHERE name = ?`, name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("no snapshot %q", name)
+	}
+	return id, err
+}
+
+// SetSnapshotTags replaces a snapshot's tags. Tags are trimmed, blank ones
+// dropped, and duplicates differing only in case kept once.
+func (d *DB) SetSnapshotTags(name string, tags []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id, err := d.snapshotID(name)
+	if err != nil {
+		return err
+	}
+	tx, err := d.sql.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := tx.Exec(`DELETE FROM snapshot_tags WHERE snapshot_id = ?`, id); err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO snapshot_tags (snapshot_id, tag) VALUES (?, ?)`, id, t); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetSnapshotRating sets a snapshot's star rating, 0 (unrated) to
+// MaxRating.
+func (d *DB) SetSnapshotRating(name string, rating int) error {
+	if rating < 0 || rating > MaxRating {
+		return fmt.Errorf("rating %d is outside 0-%d", rating, MaxRating)
+	}
+	return d.updateSnapshot(name, `rating = ?`, rating)
+}
+
+// SetSnapshotFavorite marks or unmarks a snapshot as a favorite.
+func (d *DB) SetSnapshotFavorite(name string, favorite bool) error {
+	return d.updateSnapshot(name, `favorite = ?`, favorite)
+}
+
+// updateSnapshot sets one column of the named snapshot.
+func (d *DB) updateSnapshot(name, set string, v any) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res, err := d.sql.Exec(`UPDATE snapshots SET `+set+` WHERE name = ?`, v, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no snapshot %q", name)
+	}
+	return nil
+}
+
+// RenameSnapshot renames a snapshot, refusing a name another snapshot has.
+// The check and the rename run in one transaction.
+func (d *DB) RenameSnapshot(name, newName string) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return errors.New("empty snapshot name")
+	}
+	if newName == name {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tx, err := d.sql.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var taken int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM snapshots WHERE name = ?`, newName).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return fmt.Errorf("snapshot %q already exists", newName)
+	}
+	res, err := tx.Exec(`UPDATE snapshots SET name = ? WHERE name = ?`, newName, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no snapshot %q", name)
+	}
+	return tx.Commit()
+}
+
+// DeleteSnapshot removes a snapshot with its tags and thumbnail. Its saves
+// stay in the saves table, and their files on disk.
+func (d *DB) DeleteSnapshot(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id, err := d.snapshotID(name)
+	if err != nil {
+		return err
+	}
+	tx, err := d.sql.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	// Foreign keys are not enforced on this connection, so the ON DELETE
+	// CASCADE clauses do not fire.
+	for _, q := range []string{
+		`DELETE FROM snapshot_tags WHERE snapshot_id = ?`,
+		`DELETE FROM snapshot_thumbs WHERE snapshot_id = ?`,
+		`DELETE FROM snapshots WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ParseTags splits a comma-separated tag list as typed in a text field.
+func ParseTags(s string) []string {
+	var tags []string
+	for t := range strings.SplitSeq(s, ",") {
+		t = strings.TrimSpace(t)
+		if t != "" && !slices.ContainsFunc(tags, func(u string) bool { return strings.EqualFold(t, u) }) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// SnapshotThumb returns a snapshot's cached thumbnail PNG, or nil if none
+// has been stored.
+func (d *DB) SnapshotThumb(name string) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var png []byte
+	err := d.sql.QueryRow(`SELECT t.png FROM snapshot_thumbs t JOIN snapshots s ON s.id = t.snapshot_id WHERE s.name = ?`, name).Scan(&png)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return png, err
+}
+
+// SetSnapshotThumb caches a snapshot's thumbnail PNG, replacing any before.
+func (d *DB) SetSnapshotThumb(name string, png []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id, err := d.snapshotID(name)
+	if err != nil {
+		return err
+	}
+	_, err = d.sql.Exec(`INSERT OR REPLACE INTO snapshot_thumbs (snapshot_id, png) VALUES (?, ?)`, id, png)
+	return err
+}
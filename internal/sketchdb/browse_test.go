@@ -0,0 +1,205 @@
+package sketchdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func names(t *testing.T, d *DB, f SnapshotFilter) []string {
+	t.Helper()
+	rows, err := d.ListSnapshots(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, r := range rows {
+		out = append(out, r.Name)
+	}
+	return out
+}
+
+func TestSnapshotBrowsing(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, s := range []struct {
+		name, desc string
+		tags       []string
+		rating     int
+		fav        bool
+	}{
+		{"alpha", "first try", []string{"Blue", "grid"}, 3, false},
+		{"beta", "a 50% grid", []string{"blue"}, 5, true},
+		{"gamma", "noise field", nil, 0, false},
+	} {
+		if err := d.InsertSnapshot(s.name, s.desc, "{}", "{}", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SetSnapshotTags(s.name, s.tags); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SetSnapshotRating(s.name, s.rating); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SetSnapshotFavorite(s.name, s.fav); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		f    SnapshotFilter
+		want []string
+	}{
+		{SnapshotFilter{}, []string{"gamma", "beta", "alpha"}},
+		{SnapshotFilter{Sort: SortOldest}, []string{"alpha", "beta", "gamma"}},
+		{SnapshotFilter{Sort: SortRating}, []string{"beta", "alpha", "gamma"}},
+		{SnapshotFilter{Sort: SortName}, []string{"alpha", "beta", "gamma"}},
+		{SnapshotFilter{Tag: "BLUE", Sort: SortName}, []string{"alpha", "beta"}},
+		{SnapshotFilter{Text: "grid", Sort: SortName}, []string{"alpha", "beta"}},
+		{SnapshotFilter{Text: "GRID first", Sort: SortName}, []string{"alpha"}},
+		{SnapshotFilter{Text: "50%"}, []string{"beta"}},
+		{SnapshotFilter{Text: "5%"}, nil},
+		{SnapshotFilter{MinRating: 3, Sort: SortName}, []string{"alpha", "beta"}},
+		{SnapshotFilter{Favorites: true}, []string{"beta"}},
+	} {
+		if got := names(t, d, c.f); !slices.Equal(got, c.want) {
+			t.Errorf("%+v: got %v, want %v", c.f, got, c.want)
+		}
+	}
+
+	tags, err := d.ListTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || !slices.Contains(tags, "grid") {
+		t.Fatalf("tags %v", tags)
+	}
+	row, err := d.GetSnapshotByName("alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(row.Tags, []string{"Blue", "grid"}) || row.Rating != 3 || row.Favorite {
+		t.Fatalf("alpha: tags %v, rating %d, favorite %v", row.Tags, row.Rating, row.Favorite)
+	}
+
+	if err := d.SetSnapshotRating("alpha", 6); err == nil {
+		t.Fatal("rating 6 accepted")
+	}
+	if err := d.SetSnapshotTags("missing", []string{"x"}); err == nil {
+		t.Fatal("tagged a missing snapshot")
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	got := ParseTags(" blue, grid,,Blue , warm ")
+	if !slices.Equal(got, []string{"blue", "grid", "warm"}) {
+		t.Fatalf("%q", got)
+	}
+}
+
+func TestSnapshotThumb(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.InsertSnapshot("a", "", "{}", "{}", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if png, err := d.SnapshotThumb("a"); err != nil || png != nil {
+		t.Fatalf("before caching: %v, %v", png, err)
+	}
+	for _, want := range []string{"first", "second"} {
+		if err := d.SetSnapshotThumb("a", []byte(want)); err != nil {
+			t.Fatal(err)
+		}
+		if png, err := d.SnapshotThumb("a"); err != nil || string(png) != want {
+			t.Fatalf("got %q, %v; want %q", png, err, want)
+		}
+	}
+	if err := d.SetSnapshotThumb("missing", []byte("x")); err == nil {
+		t.Fatal("cached a thumbnail for a missing snapshot")
+	}
+}
+
+func TestRenameAndDeleteSnapshot(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, n := range []string{"a", "b"} {
+		if err := d.InsertSnapshot(n, "", "{}", "{}", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.SetSnapshotTags("a", []string{"keep"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RenameSnapshot("a", "b"); err == nil {
+		t.Fatal("renamed onto an existing snapshot")
+	}
+	if err := d.RenameSnapshot("missing", "c"); err == nil {
+		t.Fatal("renamed a missing snapshot")
+	}
+	if err := d.RenameSnapshot("a", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := d.GetSnapshotByName("c"); err != nil || r == nil || !slices.Equal(r.Tags, []string{"keep"}) {
+		t.Fatalf("renamed snapshot %+v, %v", r, err)
+	}
+
+	if err := d.SetSnapshotThumb("c", []byte("png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteSnapshot("c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(t, d, SnapshotFilter{Sort: SortName}); !slices.Equal(got, []string{"b"}) {
+		t.Fatalf("after delete: %v", got)
+	}
+	if tags, err := d.ListTags(); err != nil || len(tags) != 0 {
+		t.Fatalf("tags left behind: %v, %v", tags, err)
+	}
+	if err := d.DeleteSnapshot("c"); err == nil {
+		t.Fatal("deleted a missing snapshot twice")
+	}
+}
+
+func TestConcurrentRenamesToOneName(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	const n = 8
+	for i := range n {
+		if err := d.InsertSnapshot(fmt.Sprintf("s%d", i), "", "{}", "{}", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Go(func() { errs[i] = d.RenameSnapshot(fmt.Sprintf("s%d", i), "same") })
+	}
+	wg.Wait()
+	ok := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case !strings.Contains(err.Error(), "already exists"):
+			t.Errorf("rename failed with %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d renames to one name succeeded", ok)
+	}
+}
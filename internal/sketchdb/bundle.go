@@ -0,0 +1,397 @@
+package sketchdb
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BundleFormat and BundleVersion identify a snapshot bundle file.
+const (
+	BundleFormat  = "sketchy-snapshots"
+	BundleVersion = 1
+	// BundleExt is the file extension bundles are written with.
+	BundleExt = ".sketchy.json"
+)
+
+// Bundle is a self-contained set of snapshots, the content of a
+// .sketchy.json file: everything a snapshot row holds, plus optionally its
+// linked images, so it can be added to another sketch.db.
+type Bundle struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	Sketch     string           `json:"sketch,omitempty"`
+	ExportedAt string           `json:"exported_at"`
+	Snapshots  []BundleSnapshot `json:"snapshots"`
+}
+
+// BundleSnapshot is one snapshot in a Bundle. Controls and Builtins are the
+// snapshot's control_json and builtin_json, embedded as JSON.
+type BundleSnapshot struct {
+	Name        string          `json:"name"`
+	CreatedAt   string          `json:"created_at"`
+	Description string          `json:"description,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	Rating      int             `json:"rating,omitempty"`
+	Favorite    bool            `json:"favorite,omitempty"`
+	Controls    json.RawMessage `json:"controls"`
+	Builtins    json.RawMessage `json:"builtins,omitempty"`
+	Images      []BundleImage   `json:"images,omitempty"`
+}
+
+// BundleImage is a linked save embedded in a bundle.
+type BundleImage struct {
+	// Format is "png", "svg" or "pdf".
+	Format string `json:"format"`
+	// Name is the file's base name.
+	Name   string   `json:"name"`
+	Layers []string `json:"layers,omitempty"`
+	Data   []byte   `json:"data"`
+}
+
+// ExportBundle bundles the named snapshots, or every snapshot (oldest first)
+// when names is empty. With images, the linked PNG, SVG and PDF saves are
+// read from under dir, the sketch directory, and embedded; one whose file is
+// gone is left out.
+func (d *DB) ExportBundle(dir string, names []string, images bool) (*Bundle, error) {
+	if len(names) == 0 {
+		list, err := d.ListSnapshots(SnapshotFilter{Sort: SortOldest})
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range list {
+			names = append(names, info.Name)
+		}
+	}
+	b := &Bundle{
+		Format:     BundleFormat,
+		Version:    BundleVersion,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	var err error
+	if b.Sketch, err = d.SketchName(); err != nil {
+		return nil, err
+	}
+	for _, n := range names {
+		r, err := d.GetSnapshotByName(n)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			return nil, fmt.Errorf("no snapshot %q", n)
+		}
+		bs := BundleSnapshot{
+			Name:        r.Name,
+			CreatedAt:   r.CreatedAt,
+			Description: r.Description,
+			Tags:        r.Tags,
+			Rating:      r.Rating,
+			Favorite:    r.Favorite,
+			Controls:    rawJSON(r.ControlJSON),
+			Builtins:    rawJSON(r.BuiltinJSON),
+		}
+		if images {
+			for _, im := range []struct {
+				format, rel string
+				layers      []string
+			}{{"png", r.PNGPath, nil}, {"svg", r.SVGPath, r.SVGLayers}, {"pdf", r.PDFPath, nil}} {
+				if im.rel == "" {
+					continue
+				}
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(im.rel)))
+				if err != nil {
+					continue
+				}
+				bs.Images = append(bs.Images, BundleImage{Format: im.format, Name: filepath.Base(im.rel), Layers: im.layers, Data: data})
+			}
+		}
+		b.Snapshots = append(b.Snapshots, bs)
+	}
+	return b, nil
+}
+
+// rawJSON embeds a stored JSON column, nil when it is empty.
+func rawJSON(s string) json.RawMessage {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// WriteBundle writes b to path as indented JSON.
+func WriteBundle(path string, b *Bundle) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadBundle reads a bundle written by WriteBundle.
+func ReadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if b.Format != BundleFormat {
+		return nil, fmt.Errorf("%s is not a snapshot bundle", filepath.Base(path))
+	}
+	if b.Version > BundleVersion {
+		return nil, fmt.Errorf("%s is bundle version %d; this sketchy reads up to %d", filepath.Base(path), b.Version, BundleVersion)
+	}
+	return &b, nil
+}
+
+// ImportedSnapshot reports what ImportBundle did with one bundled snapshot.
+type ImportedSnapshot struct {
+	// Bundled is the name in the bundle; Name is the one stored, which has
+	// a _2, _3, … suffix when the bundled name was taken.
+	Bundled, Name string
+	// Skipped reports a snapshot already present under its name with the
+	// same state, which is not added again.
+	Skipped bool
+	// ControlJSON is the imported control state, for checking against the
+	// sketch's controls.
+	ControlJSON string
+}
+
+// ImportBundle adds a bundle's snapshots without touching existing ones,
+// writing embedded images under dir/saves/<format>/ (renamed rather than
+// overwriting a file) and linking them. Creation times, descriptions, tags,
+// ratings and favorite flags are kept. The whole bundle is checked before
+// anything is written, and each snapshot goes in with its saves in one
+// transaction: one that fails leaves no rows or files behind, and the
+// snapshots before it stay imported.
+func (d *DB) ImportBundle(dir string, b *Bundle) ([]ImportedSnapshot, error) {
+	prepared := make([]preparedSnapshot, len(b.Snapshots))
+	for i, bs := range b.Snapshots {
+		p, err := prepareImport(bs)
+		if err != nil {
+			return nil, err
+		}
+		prepared[i] = p
+	}
+	var out []ImportedSnapshot
+	for _, p := range prepared {
+		imp := ImportedSnapshot{Bundled: p.Name, ControlJSON: p.controls}
+		name, same, err := d.importName(p.Name, p.controls, p.builtins)
+		if err != nil {
+			return out, err
+		}
+		imp.Name, imp.Skipped = name, same
+		if !same {
+			if err := d.importSnapshot(dir, name, p); err != nil {
+				return out, err
+			}
+		}
+		out = append(out, imp)
+	}
+	return out, nil
+}
+
+// preparedSnapshot is a bundled snapshot checked by prepareImport, with its
+// state compacted.
+type preparedSnapshot struct {
+	BundleSnapshot
+	controls, builtins string
+}
+
+// prepareImport checks a bundled snapshot before anything is imported.
+func prepareImport(bs BundleSnapshot) (preparedSnapshot, error) {
+	p := preparedSnapshot{BundleSnapshot: bs}
+	if strings.TrimSpace(bs.Name) == "" {
+		return p, errors.New("bundled snapshot without a name")
+	}
+	var err error
+	if p.controls, err = compactJSON(bs.Controls); err != nil || p.controls == "" {
+		return p, fmt.Errorf("bundled snapshot %q has no valid controls", bs.Name)
+	}
+	if p.builtins, err = compactJSON(bs.Builtins); err != nil {
+		return p, fmt.Errorf("bundled snapshot %q: builtins: %w", bs.Name, err)
+	}
+	for _, im := range bs.Images {
+		switch im.Format {
+		case "png", "svg", "pdf":
+		default:
+			return p, fmt.Errorf("bundled snapshot %q: unknown image format %q", bs.Name, im.Format)
+		}
+	}
+	if p.Rating < 0 || p.Rating > MaxRating {
+		p.Rating = 0
+	}
+	if p.CreatedAt == "" {
+		p.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	return p, nil
+}
+
+// importedFile is an embedded image written to disk by importSnapshot.
+type importedFile struct {
+	rel    string
+	format string
+	layers []string
+}
+
+// importSnapshot writes p's images and adds it, linked to them, as name.
+// When it fails, the files it wrote are removed.
+func (d *DB) importSnapshot(dir, name string, p preparedSnapshot) (err error) {
+	var files []importedFile
+	defer func() {
+		if err != nil {
+			for _, f := range files {
+				_ = os.Remove(filepath.Join(dir, filepath.FromSlash(f.rel)))
+			}
+		}
+	}()
+	for _, im := range p.Images {
+		rel, err := writeImportedImage(dir, im)
+		if err != nil {
+			return err
+		}
+		files = append(files, importedFile{rel: rel, format: im.Format, layers: im.Layers})
+	}
+	return d.insertImported(name, p, files)
+}
+
+// compactJSON undoes WriteBundle's indenting, so an imported state is stored
+// as sketchy stores its own.
+func compactJSON(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// importName picks the name to import a snapshot under: its own if free,
+// else the first free name with a numeric suffix. same reports that one of
+// those names already holds the very same state.
+func (d *DB) importName(name, controls, builtins string) (_ string, same bool, _ error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := 1; ; i++ {
+		n := name
+		if i > 1 {
+			n = fmt.Sprintf("%s_%d", name, i)
+		}
+		var c, b string
+		err := d.sql.QueryRow(`SELECT control_json, builtin_json FROM snapshots WHERE name = ?`, n).Scan(&c, &b)
+		if errors.Is(err, sql.ErrNoRows) {
+			return n, false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if c == controls && b == builtins {
+			return n, true, nil
+		}
+	}
+}
+
+// writeImportedImage writes an embedded image under dir/saves/<format>/,
+// returning its path relative to dir.
+func writeImportedImage(dir string, im BundleImage) (string, error) {
+	// The name comes from a file someone else wrote: keep it in the saves
+	// directory.
+	base := filepath.Base(filepath.FromSlash(im.Name))
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		base = "imported." + im.Format
+	}
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	saveDir := filepath.Join(dir, "saves", im.Format)
+	if err := os.MkdirAll(saveDir, 0755); err != nil {
+		return "", err
+	}
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s_%d%s", stem, i, ext)
+		}
+		full := filepath.Join(saveDir, name)
+		f, err := os.OpenFile(full, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(im.Data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(full)
+			return "", err
+		}
+		return filepath.ToSlash(filepath.Join("saves", im.Format, name)), nil
+	}
+}
+
+// insertImported adds an imported snapshot, its saves, tags, rating and
+// favorite flag in one transaction.
+func (d *DB) insertImported(name string, p preparedSnapshot, files []importedFile) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tx, err := d.sql.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	saveIDs := map[string]any{"png": nil, "svg": nil, "pdf": nil}
+	for _, f := range files {
+		layers, err := encodeLayers(f.layers)
+		if err != nil {
+			return err
+		}
+		res, err := tx.Exec(`INSERT INTO saves (rel_path, format, created_at, layers) VALUES (?, ?, ?, ?)`, f.rel, f.format, now, layers)
+		if err != nil {
+			return err
+		}
+		if saveIDs[f.format], err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	res, err := tx.Exec(
+		`INSERT INTO snapshots (name, created_at, control_json, builtin_json, png_save_id, svg_save_id, pdf_save_id, description, rating, favorite)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		name, p.CreatedAt, p.controls, p.builtins, saveIDs["png"], saveIDs["svg"], saveIDs["pdf"], p.Description, p.Rating, p.Favorite,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, t := range p.Tags {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO snapshot_tags (snapshot_id, tag) VALUES (?, ?)`, id, t); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
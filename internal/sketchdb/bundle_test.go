@@ -0,0 +1,190 @@
+package sketchdb
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func openTestDB(t *testing.T, dir string) *DB {
+	t.Helper()
+	d, err := Open(filepath.Join(dir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	srcDir := t.TempDir()
+	src := openTestDB(t, srcDir)
+	if err := src.InitMetadata("waves", srcDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(srcDir, "saves", "png"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "saves", "png", "good.png"), []byte("png bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	png, err := src.InsertSave("saves/png/good.png", "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertSnapshot("good", "the one", `{"sliders":{"a":1}}`, `{"random_seed":7}`, &png, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetSnapshotTags("good", []string{"keep"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetSnapshotRating("good", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.InsertSnapshot("other", "", `{"sliders":{"a":2}}`, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := src.ExportBundle(srcDir, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "out"+BundleExt)
+	if err := WriteBundle(path, b); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = ReadBundle(path); err != nil {
+		t.Fatal(err)
+	}
+	if b.Sketch != "waves" || len(b.Snapshots) != 2 || b.Snapshots[0].Name != "good" || len(b.Snapshots[0].Images) != 1 {
+		t.Fatalf("bundle %+v", b)
+	}
+
+	// The destination already has a different "good" and a file in the way.
+	dstDir := t.TempDir()
+	dst := openTestDB(t, dstDir)
+	if err := dst.InsertSnapshot("good", "", `{"sliders":{"a":9}}`, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dstDir, "saves", "png"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dstDir, "saves", "png", "good.png"), []byte("theirs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dst.ImportBundle(dstDir, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "good_2" || got[0].Skipped || got[1].Name != "other" {
+		t.Fatalf("imported %+v", got)
+	}
+	r, err := dst.GetSnapshotByName("good_2")
+	if err != nil || r == nil {
+		t.Fatalf("good_2: %v, %v", r, err)
+	}
+	orig, _ := src.GetSnapshotByName("good")
+	if r.CreatedAt != orig.CreatedAt || r.Description != "the one" || r.ControlJSON != orig.ControlJSON ||
+		r.BuiltinJSON != `{"random_seed":7}` || r.Rating != 4 || !slices.Equal(r.Tags, []string{"keep"}) {
+		t.Fatalf("imported row %+v", r)
+	}
+	if r.PNGPath != "saves/png/good_2.png" {
+		t.Fatalf("png at %q", r.PNGPath)
+	}
+	if data, err := os.ReadFile(filepath.Join(dstDir, "saves", "png", "good.png")); err != nil || string(data) != "theirs" {
+		t.Fatalf("existing file now %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dstDir, filepath.FromSlash(r.PNGPath))); err != nil || string(data) != "png bytes" {
+		t.Fatalf("imported file %q, %v", data, err)
+	}
+
+	// Importing again adds nothing.
+	got, err = dst.ImportBundle(dstDir, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Skipped || got[0].Name != "good_2" || !got[1].Skipped {
+		t.Fatalf("reimported %+v", got)
+	}
+}
+
+func TestReadBundleRejectsOtherJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.json")
+	if err := os.WriteFile(path, []byte(`{"sliders":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBundle(path); err == nil {
+		t.Fatal("read a snapshot payload as a bundle")
+	}
+}
+
+func TestSVGLayerFiles(t *testing.T) {
+	d, err := Open(filepath.Join(t.TempDir(), "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for _, s := range []struct {
+		rel    string
+		layers []string
+	}{
+		{"saves/svg/art.svg", []string{"red", "blue"}},
+		{"saves/svg/art_red.svg", []string{"red"}},
+		{"saves/svg/art_blue.svg", []string{"blue"}},
+		{"saves/svg/art_2.svg", nil},
+		{"saves/svg/artist_red.svg", []string{"red"}},
+	} {
+		if _, err := d.InsertSaveLayers(s.rel, "svg", s.layers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := d.SVGLayerFiles("saves/svg/art.svg", []string{"red", "blue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"saves/svg/art_red.svg", "saves/svg/art_blue.svg"}; !slices.Equal(got, want) {
+		t.Fatalf("layer files %v, want %v", got, want)
+	}
+}
+
+func TestImportBundleChecksEverySnapshotFirst(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestDB(t, dir)
+	b := &Bundle{Snapshots: []BundleSnapshot{
+		{Name: "fine", Controls: []byte(`{"sliders":{"a":1}}`), Images: []BundleImage{{Format: "png", Name: "fine.png", Data: []byte("png bytes")}}},
+		{Name: "broken", Controls: []byte(`{"sliders":`)},
+	}}
+	if _, err := d.ImportBundle(dir, b); err == nil {
+		t.Fatal("imported a bundle with broken controls")
+	}
+	if r, err := d.GetSnapshotByName("fine"); err != nil || r != nil {
+		t.Fatalf("fine imported anyway: %+v, %v", r, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "saves")); !os.IsNotExist(err) {
+		t.Fatalf("wrote images: %v", err)
+	}
+}
+
+func TestImportBundleRemovesFilesOfFailedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	d := openTestDB(t, dir)
+	// Make the tag insert, the last step of a snapshot's import, fail.
+	if _, err := d.sql.Exec(`DROP TABLE snapshot_tags`); err != nil {
+		t.Fatal(err)
+	}
+	b := &Bundle{Snapshots: []BundleSnapshot{
+		{Name: "tagged", Controls: []byte(`{"sliders":{"a":1}}`), Tags: []string{"keep"},
+			Images: []BundleImage{{Format: "png", Name: "tagged.png", Data: []byte("png bytes")}}},
+	}}
+	if _, err := d.ImportBundle(dir, b); err == nil {
+		t.Fatal("import succeeded without a tags table")
+	}
+	var n int
+	if err := d.sql.QueryRow(`SELECT (SELECT COUNT(*) FROM snapshots) + (SELECT COUNT(*) FROM saves)`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("%d rows left, %v", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "saves", "png", "tagged.png")); !os.IsNotExist(err) {
+		t.Fatalf("image left behind: %v", err)
+	}
+}
@@ -2,10 +2,14 @@ package sketchdb
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -64,57 +68,53 @@ func (d *DB) migrate() error {
 			svg_save_id INTEGER REFERENCES saves(id),
 			description TEXT NOT NULL DEFAULT ''
 		);`,
+		`CREATE TABLE IF NOT EXISTS snapshot_tags (
+			snapshot_id INTEGER NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+			tag TEXT NOT NULL COLLATE NOCASE,
+			PRIMARY KEY (snapshot_id, tag)
+		);`,
+		`CREATE INDEX IF NOT EXISTS snapshot_tags_tag ON snapshot_tags (tag);`,
+		`CREATE TABLE IF NOT EXISTS snapshot_thumbs (
+			snapshot_id INTEGER PRIMARY KEY REFERENCES snapshots(id) ON DELETE CASCADE,
+			png BLOB NOT NULL
+		);`,
 	}
 	for _, s := range stmts {
 		if _, err := d.sql.Exec(s); err != nil {
 			return fmt.Errorf("migrate: %w", err)
 		}
 	}
-	if err := d.ensureSnapshotDescriptionColumn(); err != nil {
+	if err := d.ensureColumn("snapshots", "description", `TEXT NOT NULL DEFAULT ''`); err != nil {
 		return err
 	}
-	return d.ensureSnapshotBuiltinJSONColumn()
-}
-
-func (d *DB) ensureSnapshotDescriptionColumn() error {
-	rows, err := d.sql.Query(`PRAGMA table_info(snapshots)`)
-	if err != nil {
+	if err := d.ensureColumn("snapshots", "builtin_json", `TEXT NOT NULL DEFAULT ''`); err != nil {
 		return err
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
-	var hasDesc bool
-	for rows.Next() {
-		var cid, notnull, pk int
-		var name, ctype string
-		var dflt any
-		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
-			return err
-		}
-		if name == "description" {
-			hasDesc = true
-		}
+	if err := d.ensureColumn("saves", "layers", `TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
 	}
-	if err := rows.Err(); err != nil {
+	// Browsing: a 0-5 star rating (0 = unrated) and a favorite flag; tags
+	// live in snapshot_tags.
+	if err := d.ensureColumn("snapshots", "rating", `INTEGER NOT NULL DEFAULT 0`); err != nil {
 		return err
 	}
-	if hasDesc {
-		return nil
+	if err := d.ensureColumn("snapshots", "favorite", `INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
 	}
-	_, err = d.sql.Exec(`ALTER TABLE snapshots ADD COLUMN description TEXT NOT NULL DEFAULT ''`)
-	return err
+	return d.ensureColumn("snapshots", "pdf_save_id", `INTEGER REFERENCES saves(id)`)
 }
 
-func (d *DB) ensureSnapshotBuiltinJSONColumn() error {
-	rows, err := d.sql.Query(`PRAGMA table_info(snapshots)`)
+// ensureColumn adds column to table with the given type/default clause when
+// an older database lacks it.
+func (d *DB) ensureColumn(table, column, decl string) error {
+	rows, err := d.sql.Query(`PRAGMA table_info(` + table + `)`)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
-	var hasBuiltin bool
+	var has bool
 	for rows.Next() {
 		var cid, notnull, pk int
 		var name, ctype string
@@ -122,17 +122,17 @@ func (d *DB) ensureSnapshotBuiltinJSONColumn() error {
 		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
 			return err
 		}
-		if name == "builtin_json" {
-			hasBuiltin = true
+		if name == column {
+			has = true
 		}
 	}
 	if err := rows.Err(); err != nil {
 		return err
 	}
-	if hasBuiltin {
+	if has {
 		return nil
 	}
-	_, err = d.sql.Exec(`ALTER TABLE snapshots ADD COLUMN builtin_json TEXT NOT NULL DEFAULT ''`)
+	_, err = d.sql.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + decl)
 	return err
 }
 
@@ -158,17 +158,60 @@ func (d *DB) InitMetadata(sketchName, sketchDir string) error {
 	return err
 }
 
+// SketchName is the sketch name InitMetadata last recorded, "" if none.
+func (d *DB) SketchName() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var name string
+	err := d.sql.QueryRow(`SELECT sketch_name FROM metadata WHERE id = 1`).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return name, err
+}
+
 func (d *DB) InsertSave(relPath, format string) (int64, error) {
+	return d.InsertSaveLayers(relPath, format, nil)
+}
+
+// InsertSaveLayers records a save along with the names of the layers it
+// contains (layered SVG exports); nil layers is the same as InsertSave.
+func (d *DB) InsertSaveLayers(relPath, format string, layers []string) (int64, error) {
+	layerJSON, err := encodeLayers(layers)
+	if err != nil {
+		return 0, err
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	now := time.Now().UTC().Format(time.RFC3339Nano)
-	res, err := d.sql.Exec(`INSERT INTO saves (rel_path, format, created_at) VALUES (?, ?, ?)`, relPath, format, now)
+	res, err := d.sql.Exec(`INSERT INTO saves (rel_path, format, created_at, layers) VALUES (?, ?, ?, ?)`, relPath, format, now, layerJSON)
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
 }
 
+// encodeLayers is the saves.layers value of layers; empty for none.
+func encodeLayers(layers []string) (string, error) {
+	if len(layers) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(layers)
+	return string(b), err
+}
+
+// decodeLayers parses a saves.layers value; empty means no layers.
+func decodeLayers(v sql.NullString) []string {
+	if v.String == "" {
+		return nil
+	}
+	var layers []string
+	if err := json.Unmarshal([]byte(v.String), &layers); err != nil {
+		return nil
+	}
+	return layers
+}
+
 type SnapshotRow struct {
 	Name        string
 	CreatedAt   string
@@ -177,9 +220,16 @@ type SnapshotRow struct {
 	Description string
 	PNGPath     string
 	SVGPath     string
-	PNGSaveID   sql.NullInt64
-	SVGSaveID   sql.NullInt64
-	ID          int64
+	PDFPath     string
+	// SVGLayers names the layers of the linked SVG save, if it was layered.
+	SVGLayers []string
+	Tags      []string
+	PNGSaveID sql.NullInt64
+	SVGSaveID sql.NullInt64
+	PDFSaveID sql.NullInt64
+	ID        int64
+	Rating    int
+	Favorite  bool
 }
 
 func (d *DB) ListSnapshotNames() ([]string, error) {
@@ -207,19 +257,24 @@ func (d *DB) GetSnapshotByName(name string) (*SnapshotRow, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	var r SnapshotRow
-	var pngPath, svgPath sql.NullString
+	var pngPath, svgPath, svgLayers, pdfPath sql.NullString
 	err := d.sql.QueryRow(`
 		SELECT s.id, s.name, s.created_at, s.control_json, s.builtin_json, s.description, s.png_save_id, s.svg_save_id,
-			p.rel_path, v.rel_path
+			s.pdf_save_id, p.rel_path, v.rel_path, v.layers, f.rel_path, s.rating, s.favorite
 		FROM snapshots s
 		LEFT JOIN saves p ON s.png_save_id = p.id
 		LEFT JOIN saves v ON s.svg_save_id = v.id
+		LEFT JOIN saves f ON s.pdf_save_id = f.id
 		WHERE s.name = ?`, name).Scan(
-		&r.ID, &r.Name, &r.CreatedAt, &r.ControlJSON, &r.BuiltinJSON, &r.Description, &r.PNGSaveID, &r.SVGSaveID, &pngPath, &svgPath,
+		&r.ID, &r.Name, &r.CreatedAt, &r.ControlJSON, &r.BuiltinJSON, &r.Description, &r.PNGSaveID, &r.SVGSaveID,
+		&r.PDFSaveID, &pngPath, &svgPath, &svgLayers, &pdfPath, &r.Rating, &r.Favorite,
 	)
 	if err == nil {
 		r.PNGPath = pngPath.String
 		r.SVGPath = svgPath.String
+		r.PDFPath = pdfPath.String
+		r.SVGLayers = decodeLayers(svgLayers)
+		r.Tags, err = d.snapshotTags(r.ID)
 	}
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
@@ -230,6 +285,38 @@ func (d *DB) GetSnapshotByName(name string) (*SnapshotRow, error) {
 	return &r, nil
 }
 
+// SVGLayerFiles lists the per-layer files saved beside the layered SVG at
+// svgRel, one per name in layers that was written: the saves recorded as
+// <stem>_<slug>.svg holding just that layer.
+func (d *DB) SVGLayerFiles(svgRel string, layers []string) ([]string, error) {
+	if svgRel == "" || len(layers) == 0 {
+		return nil, nil
+	}
+	stem := strings.TrimSuffix(svgRel, path.Ext(svgRel))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rows, err := d.sql.Query(`SELECT rel_path, layers FROM saves WHERE format = 'svg' AND rel_path LIKE ? ESCAPE '\' ORDER BY id`,
+		escapeLike(stem+"_")+"%.svg")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var out []string
+	for rows.Next() {
+		var rel string
+		var names sql.NullString
+		if err := rows.Scan(&rel, &names); err != nil {
+			return nil, err
+		}
+		if l := decodeLayers(names); len(l) == 1 && slices.Contains(layers, l[0]) && !slices.Contains(out, rel) {
+			out = append(out, rel)
+		}
+	}
+	return out, rows.Err()
+}
+
 func (d *DB) InsertSnapshot(name, description, controlJSON, builtinJSON string, pngSaveID, svgSaveID *int64) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -248,4 +335,12 @@ func (d *DB) InsertSnapshot(name, description, controlJSON, builtinJSON string,
 	return err
 }
 
+// SetSnapshotPDF links a PDF save to an existing snapshot.
+func (d *DB) SetSnapshotPDF(name string, pdfSaveID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.sql.Exec(`UPDATE snapshots SET pdf_save_id = ? WHERE name = ?`, pdfSaveID, name)
+	return err
+}
+
 func (d *DB) Path() string { return d.path }
@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example handshake of RFC 6455 section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("accept key %s", got)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			op, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			c.WriteMessage(op, msg)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http") + "/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, msg := range [][]byte{[]byte("hi"), bytes.Repeat([]byte("x"), 300), bytes.Repeat([]byte("y"), 70000)} {
+		if err := c.WriteText(msg); err != nil {
+			t.Fatal(err)
+		}
+		op, got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op != OpText || !bytes.Equal(got, msg) {
+			t.Fatalf("echo of %d bytes: op %d, %d bytes", len(msg), op, len(got))
+		}
+	}
+	if err := c.WriteMessage(OpClose, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.ReadMessage(); err != io.EOF {
+		t.Fatalf("after close: %v", err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if _, err := Upgrade(rec, httptest.NewRequest("GET", "/", nil)); err == nil || rec.Code != http.StatusBadRequest {
+		t.Fatalf("err %v, status %d", err, rec.Code)
+	}
+}
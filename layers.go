@@ -0,0 +1,309 @@
+package sketchy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldernero/debugui"
+	"github.com/aldernero/gaul/render"
+)
+
+// SVGLayerMode selects how an SVG save is split into layers for multi-pen
+// plotting.
+type SVGLayerMode int
+
+const (
+	// SVGLayersNone writes one flat drawing (the default).
+	SVGLayersNone SVGLayerMode = iota
+	// SVGLayersNamed writes one Inkscape layer per [Sketch.Layer] used in the
+	// frame, after a base layer holding everything drawn on the Drawer's
+	// own context.
+	SVGLayersNamed
+	// SVGLayersByColor writes one Inkscape layer per stroke color (fill
+	// color for unstroked shapes), named by the color.
+	SVGLayersByColor
+)
+
+var svgLayerModeLabels = []string{"None", "Sketch layers", "By stroke color"}
+
+// BaseLayerName names the layer holding what the Drawer draws on the
+// context it is handed, as opposed to a [Sketch.Layer] context.
+const BaseLayerName = "base"
+
+// sketchLayer is a named layer of the current frame.
+type sketchLayer struct {
+	name string
+	rec  *render.Recorder
+	ctx  *render.Context
+}
+
+// Layer returns a drawing context for the named layer of the current frame,
+// creating it on first use; call it from the Drawer. Drawing on it shows on
+// screen and in PNG saves exactly like drawing on the Drawer's context —
+// layers only matter to SVG saves with [SVGLayersNamed], where each becomes
+// an Inkscape layer (in order of first use) and can be written as its own
+// file, e.g. one per pen:
+//
+//	red := s.Layer("red pen")
+//	red.SetStrokeColor(colornames.Red)
+//
+// Each layer context keeps its own state (colors, width, transforms),
+// starting from the default foreground and stroke width. Layer("") and
+// Layer(BaseLayerName) return the Drawer's context.
+func (s *Sketch) Layer(name string) *render.Context {
+	if name == "" || name == BaseLayerName {
+		return s.ctx
+	}
+	for _, l := range s.layers {
+		if l.name == name {
+			return l.ctx
+		}
+	}
+	rec := render.NewRecorder(s.SketchWidth, s.SketchHeight)
+	var ctx *render.Context
+	if s.frameRaster != nil {
+		ctx = render.NewContext(s.recorder, rec, s.frameRaster)
+	} else {
+		ctx = render.NewContext(s.recorder, rec)
+	}
+	ctx.SetStrokeColor(s.DefaultForeground)
+	ctx.SetStrokeWidth(s.DefaultStrokeWidth)
+	s.layers = append(s.layers, sketchLayer{name: name, rec: rec, ctx: ctx})
+	return ctx
+}
+
+// newFrameContext starts a frame: it drops the previous frame's layers and
+// returns the Drawer's context, recording into the frame recorder, the base
+// layer, and ras (nil when only recording). Called with saveMutex held.
+func (s *Sketch) newFrameContext(ras *render.Raster) *render.Context {
+	s.layers = s.layers[:0]
+	s.frameRaster = ras
+	if s.baseLayer == nil {
+		s.baseLayer = render.NewRecorder(s.SketchWidth, s.SketchHeight)
+	} else {
+		s.baseLayer.Reset()
+	}
+	if ras == nil {
+		return render.NewContext(s.recorder, s.baseLayer)
+	}
+	return render.NewContext(s.recorder, s.baseLayer, ras)
+}
+
+// svgBytes replays rec into an SVG document. render.SVG only saves to a
+// path, so the document goes through a temporary file.
+func (s *Sketch) svgBytes(rec *render.Recorder) ([]byte, error) {
+	f, err := os.CreateTemp("", "sketchy-*.svg")
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+	_ = f.Close()
+	defer os.Remove(tmp)
+	svg := render.NewSVG(s.SketchWidth, s.SketchHeight)
+	rec.Replay(svg)
+	if err := svg.Save(tmp); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return nil, err
+	}
+	if w, h := s.svgPageSize(); w != "" {
+		return withSVGSize(data, w, h, s.SketchWidth, s.SketchHeight)
+	}
+	return data, nil
+}
+
+// savedLayer is one per-layer file written next to a layered SVG.
+type savedLayer struct {
+	name string
+	path string // full path
+}
+
+// svgSaveOptions are the per-save settings of a vector export, fixed when
+// the save is requested.
+type svgSaveOptions struct {
+	layers   SVGLayerMode
+	split    bool // also write each layer as its own file (SVG only)
+	optimize bool // run the plotter path optimization (plot.go)
+	hatch    bool // turn fills into hatch lines (PlotOptions.HatchFills)
+	occlude  bool // remove hidden lines (PlotOptions.HideOccluded)
+	// plot is Sketch.Plot as it was when the save was asked for (see
+	// PlotOptions.snapshot), so the save worker never reads the live one.
+	plot PlotOptions
+}
+
+// plotted reports whether opts run the save through the plotter stages.
+func (opts svgSaveOptions) plotted() bool {
+	return opts.optimize || opts.hatch || opts.occlude
+}
+
+// renderLayeredSVGToFile writes the current frame as an SVG split into
+// layers per opts and, with split, each layer as <stem>_<layer>.svg beside
+// it. It returns the layer names in document order and the per-layer files.
+// Empty layers are left out.
+func (s *Sketch) renderLayeredSVGToFile(full string, opts svgSaveOptions) ([]string, []savedLayer, error) {
+	if s.usesGPUCanvas() {
+		return nil, nil, fmt.Errorf("sketchy: GPU-rendered sketches have no vector representation to save as SVG")
+	}
+	if opts.layers == SVGLayersNone && !opts.plotted() {
+		return nil, nil, s.renderSVGToFile(full)
+	}
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	if opts.plotted() {
+		d, names, err := s.plotLayers(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		files, err := writeOptimizedSVG(full, d, names, opts.split)
+		return names, files, err
+	}
+	doc, layers, err := s.layeredSVG(opts.layers)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := svgLayerNames(layers)
+	if err := os.WriteFile(full, doc.layered(layers), 0644); err != nil {
+		return nil, nil, err
+	}
+	if !opts.split {
+		return names, nil, nil
+	}
+	files := layerFiles(full, names)
+	for i, l := range layers {
+		if err := os.WriteFile(files[i].path, doc.standalone(l.elems), 0644); err != nil {
+			return names, files[:i], err
+		}
+	}
+	return names, files, nil
+}
+
+// layeredSVG replays the current frame into an SVG document and groups its
+// drawing into layers per mode; SVGLayersNone returns no layers. Called
+// with saveMutex held.
+func (s *Sketch) layeredSVG(mode SVGLayerMode) (svgDoc, []svgLayer, error) {
+	switch mode {
+	case SVGLayersNone, SVGLayersByColor:
+		data, err := s.svgBytes(s.recorder)
+		if err != nil {
+			return svgDoc{}, nil, err
+		}
+		doc, err := parseSVGDoc(data)
+		if err != nil || mode == SVGLayersNone {
+			return doc, nil, err
+		}
+		return doc, doc.layersByPaint(), nil
+	case SVGLayersNamed:
+		var doc svgDoc
+		var layers []svgLayer
+		recs := []sketchLayer{{name: BaseLayerName, rec: s.baseLayer}}
+		recs = append(recs, s.layers...)
+		for i, l := range recs {
+			if l.rec == nil {
+				continue
+			}
+			data, err := s.svgBytes(l.rec)
+			if err != nil {
+				return svgDoc{}, nil, err
+			}
+			d, err := parseSVGDoc(data)
+			if err != nil {
+				return svgDoc{}, nil, err
+			}
+			if i == 0 {
+				doc = d
+			}
+			if len(d.elems) > 0 {
+				layers = append(layers, svgLayer{name: l.name, elems: d.raws()})
+			}
+		}
+		return doc, layers, nil
+	}
+	return svgDoc{}, nil, fmt.Errorf("sketchy: unknown SVG layer mode %d", mode)
+}
+
+func svgLayerNames(layers []svgLayer) []string {
+	var names []string
+	for _, l := range layers {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+// layerFiles names the per-layer files <stem>_<layer>.svg written beside
+// full, falling back to the layer number for names that slug to nothing or
+// collide.
+func layerFiles(full string, names []string) []savedLayer {
+	stem := strings.TrimSuffix(full, filepath.Ext(full))
+	files := make([]savedLayer, len(names))
+	used := map[string]bool{}
+	for i, name := range names {
+		slug := layerFileSlug(name)
+		if slug == "" || used[slug] {
+			slug = fmt.Sprintf("layer%d", i+1)
+		}
+		used[slug] = true
+		files[i] = savedLayer{name: name, path: stem + "_" + slug + ".svg"}
+	}
+	return files
+}
+
+// drawSVGLayerRows shows the SVG layering and plotting options of the save
+// dialogs.
+func (s *Sketch) drawSVGLayerRows(ctx *debugui.Context) {
+	ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+	ctx.Text("SVG layers")
+	ctx.IDScope("svgLayers", func() {
+		ctx.Dropdown(&s.svgLayerModeIdx, svgLayerModeLabels)
+	})
+	ctx.SetGridLayout([]int{-1}, nil)
+	if SVGLayerMode(s.svgLayerModeIdx) != SVGLayersNone {
+		ctx.Checkbox(&s.svgSplitLayers, "Also save each layer as a file")
+	}
+	ctx.Checkbox(&s.Plot.HatchFills, "Hatch fills")
+	ctx.Checkbox(&s.Plot.HideOccluded, "Hide occluded lines")
+	ctx.Checkbox(&s.Plot.OptimizePaths, "Optimize paths for plotting")
+}
+
+// enqueueVectorSave queues an SVG, PDF, G-code or HP-GL save with the
+// dialogs' layer, plotting and page settings.
+func (s *Sketch) enqueueVectorSave(relPath, format string) {
+	select {
+	case s.saveRequests <- SaveRequest{RelPath: relPath, Format: format, RecordDB: true,
+		SVGLayers: SVGLayerMode(s.svgLayerModeIdx), SplitLayers: s.svgSplitLayers, OptimizePaths: s.Plot.OptimizePaths,
+		HatchFills: s.Plot.HatchFills, HideOccluded: s.Plot.HideOccluded, PDFPage: s.PDFPage, plot: s.Plot.snapshot(),
+		meta: s.newSaveMetadata()}:
+		fmt.Println("Queued save:", relPath)
+	default:
+		fmt.Println("Save queue full, skipping save")
+	}
+}
+
+// writeLayeredSVG writes the snapshot dialog's SVG synchronously with the
+// dialogs' layer and plotting settings, embedding meta in it and its layer
+// files, and returns the layer names.
+func (s *Sketch) writeLayeredSVG(full string, meta *saveMetadata) ([]string, error) {
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return nil, err
+	}
+	layers, files, err := s.renderLayeredSVGToFile(full, svgSaveOptions{
+		layers:   SVGLayerMode(s.svgLayerModeIdx),
+		split:    s.svgSplitLayers,
+		optimize: s.Plot.OptimizePaths,
+		hatch:    s.Plot.HatchFills,
+		occlude:  s.Plot.HideOccluded,
+		plot:     s.Plot.snapshot(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{full}
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	return layers, meta.embed(paths...)
+}
@@ -0,0 +1,162 @@
+package sketchy
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+const testLayerSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100" viewBox="0 0 100 100">
+<defs><clipPath id="c"><rect width="10" height="10"/></clipPath></defs>
+<rect x="0" y="0" width="100" height="100" fill="#000000"/>
+<path d="M0 0L10 10" stroke="#FF0000" fill="none"/>
+<g style="fill:none;stroke:#00ff00"><path d="M1 1L2 2"/></g>
+<circle cx="5" cy="5" r="2" style="stroke: #ff0000; stroke-width: 2"/>
+</svg>
+`
+
+func TestParseSVGDocSplitsTopLevelElements(t *testing.T) {
+	doc, err := parseSVGDoc([]byte(testLayerSVG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(doc.head, `viewBox="0 0 100 100">`) {
+		t.Fatalf("head = %q", doc.head)
+	}
+	if len(doc.shared) != 1 || !strings.HasPrefix(doc.shared[0], "<defs>") {
+		t.Fatalf("shared = %q", doc.shared)
+	}
+	wantPaint := []string{"#000000", "#ff0000", "#00ff00", "#ff0000"}
+	if len(doc.elems) != len(wantPaint) {
+		t.Fatalf("got %d elements, want %d", len(doc.elems), len(wantPaint))
+	}
+	for i, e := range doc.elems {
+		if e.paint != wantPaint[i] {
+			t.Errorf("element %d paint = %q, want %q", i, e.paint, wantPaint[i])
+		}
+	}
+	if doc.elems[2].raw != `<g style="fill:none;stroke:#00ff00"><path d="M1 1L2 2"/></g>` {
+		t.Fatalf("group raw = %q", doc.elems[2].raw)
+	}
+	if !strings.HasPrefix(doc.tail, "</svg>") {
+		t.Fatalf("tail = %q", doc.tail)
+	}
+}
+
+func TestSVGLayersByPaint(t *testing.T) {
+	doc, err := parseSVGDoc([]byte(testLayerSVG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := doc.layersByPaint()
+	if len(layers) != 3 {
+		t.Fatalf("got %d layers, want 3", len(layers))
+	}
+	// The two red shapes share a pen.
+	if layers[1].name != "#ff0000" || len(layers[1].elems) != 2 {
+		t.Fatalf("red layer = %+v", layers[1])
+	}
+
+	out := string(doc.layered(layers))
+	if !strings.Contains(out, `xmlns:inkscape="`+inkscapeNS+`"`) {
+		t.Fatal("layered SVG lacks the inkscape namespace")
+	}
+	if n := strings.Count(out, `inkscape:groupmode="layer"`); n != 3 {
+		t.Fatalf("layered SVG has %d layer groups, want 3", n)
+	}
+	if !strings.Contains(out, `inkscape:label="#00ff00" id="layer3"`) {
+		t.Fatal("layered SVG missing the green layer label")
+	}
+	// Shared definitions stay outside the layers.
+	if strings.Index(out, "<defs>") > strings.Index(out, "inkscape:groupmode") {
+		t.Fatal("defs were moved into a layer")
+	}
+
+	single := string(doc.standalone(layers[2].elems))
+	if strings.Contains(single, "#ff0000") || !strings.Contains(single, "<defs>") || !strings.HasSuffix(strings.TrimSpace(single), "</svg>") {
+		t.Fatalf("standalone layer = %s", single)
+	}
+}
+
+func TestLayerFileSlug(t *testing.T) {
+	for in, want := range map[string]string{
+		"red pen":      "red-pen",
+		"#FF0000":      "ff0000",
+		"  A/B  ":      "a-b",
+		"日本":           "",
+		"Pen 2 (.5mm)": "pen-2-5mm",
+	} {
+		if got := layerFileSlug(in); got != want {
+			t.Errorf("layerFileSlug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSnapshotSVGLayersThroughDatabase(t *testing.T) {
+	db, err := sketchdb.Open(filepath.Join(t.TempDir(), "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	id, err := db.InsertSaveLayers("saves/svg/a.svg", "svg", []string{"base", "red pen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertSnapshot("a", "", "{}", "", nil, &id); err != nil {
+		t.Fatal(err)
+	}
+	row, err := db.GetSnapshotByName("a")
+	if err != nil || row == nil {
+		t.Fatalf("GetSnapshotByName = %v, %v", row, err)
+	}
+	if strings.Join(row.SVGLayers, "|") != "base|red pen" {
+		t.Fatalf("SVGLayers = %q", row.SVGLayers)
+	}
+}
+
+func TestNamedLayersSVGExport(t *testing.T) {
+	s := newTestSketch(100, 100, func(s *Sketch, c *render.Context) {
+		c.DrawCircle(20, 20, 10)
+		c.Stroke()
+		red := s.Layer("red pen")
+		red.SetStrokeColor(color.RGBA{255, 0, 0, 255})
+		red.DrawCircle(60, 60, 10)
+		red.Stroke()
+	})
+	s.renderFrame()
+	if len(s.layers) != 1 || s.Layer("red pen") != s.layers[0].ctx {
+		t.Fatal("Layer did not reuse the frame's layer context")
+	}
+
+	full := filepath.Join(t.TempDir(), "out.svg")
+	names, files, err := s.renderLayeredSVGToFile(full, svgSaveOptions{layers: SVGLayersNamed, split: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, "|") != "base|red pen" {
+		t.Fatalf("layers = %q, want base|red pen", names)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `inkscape:label="red pen"`) {
+		t.Fatal("layered SVG missing the red pen layer")
+	}
+	if len(files) != 2 || filepath.Base(files[1].path) != "out_red-pen.svg" {
+		t.Fatalf("layer files = %+v", files)
+	}
+
+	// A new frame starts without the previous frame's layers.
+	s.Drawer = func(*Sketch, *render.Context) {}
+	s.renderFrame()
+	if len(s.layers) != 0 {
+		t.Fatalf("layers carried into the next frame: %d", len(s.layers))
+	}
+}
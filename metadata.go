@@ -0,0 +1,305 @@
+package sketchy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// saveMetadata is what PNG and SVG saves embed about how they were made, so
+// a copied image restores its sketch state without sketch.db (Load
+// Snapshot…). Controls and Builtins are the JSON a snapshot row stores.
+type saveMetadata struct {
+	Title    string          `json:"title,omitempty"`
+	Time     time.Time       `json:"time"`
+	Controls json.RawMessage `json:"controls"`
+	Builtins json.RawMessage `json:"builtins"`
+}
+
+// pngMetadataKey is the tEXt keyword, and svgMetadataID the <metadata> id,
+// the state is stored under.
+const (
+	pngMetadataKey = "sketchy"
+	svgMetadataID  = "sketchy"
+)
+
+// newSaveMetadata captures the current state for a save. It is nil, and the
+// save goes ahead without metadata, if the state does not serialize.
+func (s *Sketch) newSaveMetadata() *saveMetadata {
+	data, err := s.serializeControlState()
+	if err != nil {
+		fmt.Println("save metadata:", err)
+		return nil
+	}
+	bdata, err := s.serializeBuiltinState()
+	if err != nil {
+		fmt.Println("save metadata builtin:", err)
+		return nil
+	}
+	return s.metadataFor(data, bdata)
+}
+
+// metadataFor is the metadata of already-serialized control and builtin
+// state, as snapshots and sweeps hold it.
+func (s *Sketch) metadataFor(data, bdata []byte) *saveMetadata {
+	return &saveMetadata{Title: s.Title, Time: time.Now(), Controls: data, Builtins: bdata}
+}
+
+// embed writes m into each of the PNG and SVG files at paths; other formats
+// are left as they are. A nil m embeds nothing.
+func (m *saveMetadata) embed(paths ...string) error {
+	if m == nil {
+		return nil
+	}
+	for _, p := range paths {
+		var err error
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".png":
+			err = m.embedPNG(p)
+		case ".svg":
+			err = m.embedSVG(p)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// embedPNG appends tEXt chunks before the IEND chunk: the sketchy state as
+// JSON, plus the standard Title, Software and Creation Time keywords for
+// other tools. Only the file's tail is rewritten, so tiled saves of any
+// size are cheap to tag.
+func (m *saveMetadata) embedPNG(full string) error {
+	f, err := os.OpenFile(full, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	iend := pngChunk("IEND", nil)
+	end := st.Size() - int64(len(iend))
+	if end < 8 {
+		return fmt.Errorf("not a PNG")
+	}
+	tail := make([]byte, len(iend))
+	if _, err := f.ReadAt(tail, end); err != nil {
+		return err
+	}
+	if !bytes.Equal(tail, iend) {
+		return fmt.Errorf("PNG does not end in IEND")
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if m.Title != "" {
+		b.Write(pngText("Title", latin1(m.Title)))
+	}
+	b.Write(pngText("Software", "sketchy"))
+	b.Write(pngText("Creation Time", m.Time.Format(time.RFC1123Z)))
+	b.Write(pngText(pngMetadataKey, string(asciiJSON(data))))
+	b.Write(iend)
+	_, err = f.WriteAt(b.Bytes(), end)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// pngText is a tEXt chunk; text must be Latin-1.
+func pngText(key, text string) []byte {
+	return pngChunk("tEXt", append(append([]byte(key), 0), text...))
+}
+
+// latin1 converts s for a tEXt chunk, replacing what Latin-1 lacks by "?".
+func latin1(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return string(b)
+}
+
+// asciiJSON escapes the non-ASCII characters of JSON text as \u sequences,
+// which tEXt's Latin-1 keeps intact. They can only occur inside strings.
+func asciiJSON(data []byte) []byte {
+	var b bytes.Buffer
+	for _, r := range string(data) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteByte(byte(r))
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.Bytes()
+}
+
+// embedSVG inserts a <metadata id="sketchy"> element holding the state as
+// JSON at the start of the root <svg> element.
+func (m *saveMetadata) embedSVG(full string) error {
+	src, err := os.ReadFile(full)
+	if err != nil {
+		return err
+	}
+	d := xml.NewDecoder(bytes.NewReader(src))
+	var at int64 = -1
+	for at < 0 {
+		tok, err := d.Token()
+		if err != nil {
+			return fmt.Errorf("no <svg> element: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "svg" {
+			at = d.InputOffset()
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	b.Write(src[:at])
+	b.WriteString("\n<metadata id=\"" + svgMetadataID + "\">")
+	xml.EscapeText(&b, data)
+	b.WriteString("</metadata>")
+	b.Write(src[at:])
+	return os.WriteFile(full, b.Bytes(), 0644)
+}
+
+// readSaveMetadata reads the metadata embedded in a PNG or SVG save.
+func readSaveMetadata(full string) (*saveMetadata, error) {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(full)) {
+	case ".png":
+		data, err = readPNGMetadata(full)
+	case ".svg":
+		data, err = readSVGMetadata(full)
+	default:
+		return nil, fmt.Errorf("sketchy: %s is not a PNG or SVG", full)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sketchy: %s: %w", full, err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("sketchy: %s has no sketchy metadata", full)
+	}
+	var m saveMetadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("sketchy: %s: %w", full, err)
+	}
+	if len(m.Controls) == 0 {
+		return nil, fmt.Errorf("sketchy: %s has no control state", full)
+	}
+	return &m, nil
+}
+
+// readPNGMetadata walks a PNG's chunks for the sketchy tEXt, skipping over
+// image data without reading it. It is nil if there is none.
+func readPNGMetadata(full string) ([]byte, error) {
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(f, head); err != nil || string(head) != "\x89PNG\r\n\x1a\n" {
+		return nil, fmt.Errorf("not a PNG")
+	}
+	for {
+		if _, err := io.ReadFull(f, head); err != nil {
+			return nil, fmt.Errorf("truncated PNG: %w", err)
+		}
+		n := int64(binary.BigEndian.Uint32(head))
+		switch string(head[4:]) {
+		case "IEND":
+			return nil, nil
+		case "tEXt":
+			body := make([]byte, n)
+			if _, err := io.ReadFull(f, body); err != nil {
+				return nil, fmt.Errorf("truncated PNG: %w", err)
+			}
+			if key, text, ok := bytes.Cut(body, []byte{0}); ok && string(key) == pngMetadataKey {
+				return text, nil
+			}
+			n = 0
+		}
+		if _, err := f.Seek(n+4, io.SeekCurrent); err != nil { // body and CRC
+			return nil, err
+		}
+	}
+}
+
+// readSVGMetadata is the text of an SVG's sketchy <metadata> element, or
+// nil if there is none.
+func readSVGMetadata(full string) ([]byte, error) {
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := xml.NewDecoder(f)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "metadata" {
+			continue
+		}
+		for _, a := range se.Attr {
+			if a.Name.Local == "id" && a.Value == svgMetadataID {
+				var text string
+				if err := d.DecodeElement(&text, &se); err != nil {
+					return nil, err
+				}
+				return []byte(text), nil
+			}
+		}
+	}
+}
+
+// ApplyImageState restores the control and builtin state (seed, palettes,
+// colors, export scale) embedded in a PNG or SVG saved by sketchy,
+// returning the control keys that no longer match this sketch. A relative
+// path is taken from the sketch's working directory.
+func (s *Sketch) ApplyImageState(path string) (missing []string, err error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.workDir, path)
+	}
+	m, err := readSaveMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	if missing, err = s.applyControlStateJSON(m.Controls); err != nil {
+		return nil, err
+	}
+	if err := s.applyBuiltinStateJSON(m.Builtins); err != nil {
+		return missing, err
+	}
+	return missing, nil
+}
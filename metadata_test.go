@@ -0,0 +1,95 @@
+package sketchy
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveMetadataRoundTrip(t *testing.T) {
+	build := func(ui *UI) {
+		ui.Folder("Noise", func() {
+			ui.FloatSlider("Scale", 0, 5, 1, 0.1)
+		})
+		ui.TextBox("note", "", nil)
+	}
+	src := newTextBoxSketch(t, build)
+	src.Title = "Flow"
+	src.FloatSliders[0].Val = 2.5
+	src.TextBoxes[0].Val = "café ✓ 🎨" // non-ASCII survives PNG's Latin-1 tEXt
+	src.RandomSeed = 42
+	meta := src.newSaveMetadata()
+
+	dir := t.TempDir()
+	pngPath := filepath.Join(dir, "a.png")
+	if err := writePixels(pngPath, image.NewRGBA(image.Rect(0, 0, 4, 3)), 0); err != nil {
+		t.Fatal(err)
+	}
+	svgPath := filepath.Join(dir, "a.svg")
+	svg := `<?xml version="1.0"?>` + "\n" +
+		`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><path d="M0 0L10 10"/></svg>`
+	if err := os.WriteFile(svgPath, []byte(svg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := meta.embed(pngPath, svgPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// The PNG still decodes, and the SVG keeps its content after the
+	// metadata.
+	f, err := os.Open(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := png.Decode(f); err != nil {
+		t.Fatalf("decode tagged PNG: %v", err)
+	}
+	data, err := os.ReadFile(svgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, j := strings.Index(string(data), `<metadata id="sketchy">`), strings.Index(string(data), "<path"); i < 0 || j < i {
+		t.Fatalf("metadata not at the start of <svg>:\n%s", data)
+	}
+
+	for _, p := range []string{"a.png", "a.svg"} {
+		dst := newTextBoxSketch(t, build)
+		dst.workDir = dir
+		missing, err := dst.ApplyImageState(p)
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+		if len(missing) > 0 {
+			t.Fatalf("%s: missing %v", p, missing)
+		}
+		if dst.FloatSliders[0].Val != 2.5 || dst.TextBoxes[0].Val != "café ✓ 🎨" || dst.RandomSeed != 42 {
+			t.Fatalf("%s: restored %v %q seed %d", p, dst.FloatSliders[0].Val, dst.TextBoxes[0].Val, dst.RandomSeed)
+		}
+		m, err := readSaveMetadata(filepath.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Title != "Flow" || m.Time.IsZero() {
+			t.Fatalf("%s: title %q time %v", p, m.Title, m.Time)
+		}
+	}
+}
+
+func TestApplyImageStateWithoutMetadata(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "plain.png")
+	if err := writePixels(p, image.NewRGBA(image.Rect(0, 0, 2, 2)), 0); err != nil {
+		t.Fatal(err)
+	}
+	s := newTextBoxSketch(t, func(*UI) {})
+	if _, err := s.ApplyImageState(p); err == nil || !strings.Contains(err.Error(), "no sketchy metadata") {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := s.ApplyImageState(filepath.Join(dir, "a.jpg")); err == nil {
+		t.Fatal("no error for a JPEG")
+	}
+}
@@ -0,0 +1,294 @@
+package sketchy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"slices"
+)
+
+// ModShape is the waveform of a slider modulator.
+type ModShape int
+
+const (
+	ModSine     ModShape = iota
+	ModTriangle          // linear up and down, in phase with ModSine
+	ModSaw               // linear rise, then a jump back
+	ModSquare            // +1 for the first half of each cycle, -1 for the second
+	ModWalk              // a seeded random walk that closes on itself each cycle
+	ModNoise             // seeded 1D simplex noise, periodic each cycle
+)
+
+var (
+	modShapeNames  = []string{"sine", "triangle", "saw", "square", "walk", "noise"}
+	modShapeLabels = []string{"Sine", "Triangle", "Saw", "Square", "Random walk", "Noise"}
+)
+
+// MarshalText stores a ModShape by name in snapshot JSON.
+func (m ModShape) MarshalText() ([]byte, error) {
+	if m < 0 || int(m) >= len(modShapeNames) {
+		return nil, fmt.Errorf("sketchy: unknown modulator shape %d", m)
+	}
+	return []byte(modShapeNames[m]), nil
+}
+
+// UnmarshalText reads a ModShape name; unknown names are sine.
+func (m *ModShape) UnmarshalText(b []byte) error {
+	*m = ModSine
+	if i := slices.Index(modShapeNames, string(b)); i >= 0 {
+		*m = ModShape(i)
+	}
+	return nil
+}
+
+// Modulator oscillates a float or int slider around its value. The slider
+// keeps its value (the base, which stays editable and is what the panel
+// shows); GetFloat and GetInt return the base plus the modulation at the
+// current Tick, clamped to the slider's range. The modulation depends on
+// nothing but Tick, the seed and the control, so a sketch renders the same
+// at the same tick, and a modulator whose period divides a loop recording's
+// length loops perfectly.
+type Modulator struct {
+	Shape ModShape `json:"shape"`
+	// Rate is in cycles per second of Tick at 60 ticks per second, like the
+	// shader Time uniform: a period of 60/Rate ticks.
+	Rate float64 `json:"rate"`
+	// Depth is the peak swing as a fraction of the slider's range: 0.5
+	// swings from half the range below the base to half above.
+	Depth float64 `json:"depth"`
+	// Phase offsets the wave, in cycles.
+	Phase float64 `json:"phase,omitempty"`
+
+	walk     []float64 // ModWalk's cycle, for walkSeed
+	walkSeed uint64
+	last     float64 // the wave last tick, for change detection
+}
+
+// modWalkSteps and modNoiseCells divide a cycle into random-walk steps and
+// noise lattice cells.
+const (
+	modWalkSteps  = 64
+	modNoiseCells = 8
+)
+
+// modSeed derives a modulator's random stream from the sketch seed and the
+// control key, so two sliders walking at once move independently.
+func modSeed(seed int64, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64() ^ uint64(seed)*0x9e3779b97f4a7c15
+}
+
+// splitmix64 hashes x to a well-mixed 64-bit value.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// unitHash maps (seed, i) to [-1, 1).
+func unitHash(seed uint64, i int) float64 {
+	return float64(splitmix64(seed+uint64(i))>>11)/(1<<52) - 1
+}
+
+// wave is the modulator's output in [-1, 1] at tick.
+func (m *Modulator) wave(tick int64, seed uint64) float64 {
+	p := m.Phase
+	if m.Rate > 0 {
+		period := shaderTimeTPS / m.Rate
+		// A whole-tick period repeats exactly, however long the sketch runs.
+		if n := math.Round(period); n >= 1 && math.Abs(period-n) < 1e-9 {
+			tick %= int64(n)
+		}
+		p += float64(tick) / period
+	}
+	p -= math.Floor(p)
+	switch m.Shape {
+	case ModTriangle:
+		q := p - 0.25
+		return 4*math.Abs(q-math.Floor(q)-0.5) - 1
+	case ModSaw:
+		q := p + 0.5
+		return 2*(q-math.Floor(q)) - 1
+	case ModSquare:
+		if p < 0.5 {
+			return 1
+		}
+		return -1
+	case ModWalk:
+		return m.walkAt(p, seed)
+	case ModNoise:
+		return noise1D(p*modNoiseCells, seed)
+	}
+	return math.Sin(2 * math.Pi * p)
+}
+
+// walkCycle is one cycle of ModWalk: a random walk bridged back to its
+// start (its drift subtracted) and scaled to span at most [-1, 1].
+func walkCycle(seed uint64) []float64 {
+	w := make([]float64, modWalkSteps+1)
+	for i := 1; i <= modWalkSteps; i++ {
+		w[i] = w[i-1] + unitHash(seed, i)
+	}
+	drift := w[modWalkSteps]
+	peak := 0.0
+	for i := range w {
+		w[i] -= drift * float64(i) / modWalkSteps
+		peak = max(peak, math.Abs(w[i]))
+	}
+	if peak > 0 {
+		for i := range w {
+			w[i] /= peak
+		}
+	}
+	return w
+}
+
+// walkAt is the random walk at p in [0, 1). It only reads the cached cycle
+// (updateModulators fills it), so sketches may read sliders from several
+// goroutines.
+func (m *Modulator) walkAt(p float64, seed uint64) float64 {
+	w := m.walk
+	if w == nil || m.walkSeed != seed {
+		w = walkCycle(seed)
+	}
+	x := p * modWalkSteps
+	i := int(x)
+	return w[i] + (w[i+1]-w[i])*(x-float64(i))
+}
+
+// noise1D is 1D simplex noise at x, periodic over modNoiseCells, in
+// [-1, 1].
+func noise1D(x float64, seed uint64) float64 {
+	i0 := int(math.Floor(x))
+	x0 := x - float64(i0)
+	x1 := x0 - 1
+	grad := func(i int) float64 {
+		i = ((i % modNoiseCells) + modNoiseCells) % modNoiseCells
+		return unitHash(seed, i) * 8
+	}
+	t0 := 1 - x0*x0
+	t1 := 1 - x1*x1
+	n := t0*t0*t0*t0*grad(i0)*x0 + t1*t1*t1*t1*grad(i0+1)*x1
+	return clampFloat(0.395*n, -1, 1)
+}
+
+// modulate is base plus m's swing at the current tick, clamped to
+// [lo, hi]; base itself when the control has no modulator.
+func (s *Sketch) modulate(key string, base, lo, hi float64) float64 {
+	m := s.modulators[key]
+	if m == nil {
+		return base
+	}
+	w := m.wave(s.Tick, modSeed(s.RandomSeed, key))
+	return clampFloat(base+w*m.Depth*(hi-lo), lo, hi)
+}
+
+// floatValue is a float slider's value as the sketch reads it: modulated,
+// if it has a modulator.
+func (s *Sketch) floatValue(key string, sl *FloatSlider) float64 {
+	return s.modulate(key, sl.Val, sl.MinVal, sl.MaxVal)
+}
+
+// intValue is an int slider's value as the sketch reads it, the modulated
+// value rounded.
+func (s *Sketch) intValue(key string, sl *IntSlider) int {
+	if s.modulators[key] == nil {
+		return sl.Val
+	}
+	return int(math.Round(s.modulate(key, float64(sl.Val), float64(sl.MinVal), float64(sl.MaxVal))))
+}
+
+// updateModulators runs once per tick, at the end of UpdateControls and per
+// headless tick. When a modulator moved its slider, the frame is marked
+// dirty and DidModulatorsChange set. No control's value changed, so the
+// control change flags stay clear and history and the OSC and HTTP
+// publishers have nothing to look at.
+func (s *Sketch) updateModulators() {
+	moved := false
+	for k, m := range s.modulators {
+		if !s.isModulatable(k) {
+			continue
+		}
+		seed := modSeed(s.RandomSeed, k)
+		if m.Shape == ModWalk && (m.walk == nil || m.walkSeed != seed) {
+			m.walk, m.walkSeed = walkCycle(seed), seed
+		}
+		w := m.wave(s.Tick, seed)
+		if w != m.last {
+			moved = true
+		}
+		m.last = w
+	}
+	if moved {
+		s.DidModulatorsChange = true
+		s.dirty = true
+	}
+}
+
+// isModulatable reports whether the control key names a float or int
+// slider.
+func (s *Sketch) isModulatable(k string) bool {
+	if _, ok := s.floatSliderControlMap[k]; ok {
+		return true
+	}
+	_, ok := s.intSliderControlMap[k]
+	return ok
+}
+
+// modulatorsPayload is the modulators in snapshot control_json (schema 5),
+// or nil when there are none.
+func (s *Sketch) modulatorsPayload() map[string]Modulator {
+	if len(s.modulators) == 0 {
+		return nil
+	}
+	p := make(map[string]Modulator, len(s.modulators))
+	for k, m := range s.modulators {
+		p[k] = Modulator{Shape: m.Shape, Rate: m.Rate, Depth: m.Depth, Phase: m.Phase}
+	}
+	return p
+}
+
+// restoreModulators replaces the modulators with a snapshot's, returning
+// the keys whose slider is gone; those are dropped.
+func (s *Sketch) restoreModulators(p map[string]Modulator) (missing []string) {
+	s.modulators = nil
+	for k, m := range p {
+		if !s.isModulatable(k) {
+			missing = append(missing, k)
+			continue
+		}
+		s.setModulator(k, m)
+	}
+	return missing
+}
+
+func (s *Sketch) setModulator(key string, m Modulator) {
+	if s.modulators == nil {
+		s.modulators = make(map[string]*Modulator)
+	}
+	s.modulators[key] = &Modulator{Shape: m.Shape, Rate: m.Rate, Depth: m.Depth, Phase: m.Phase, last: math.NaN()}
+}
+
+// SetModulator attaches m to a float or int slider, replacing any
+// modulator it has.
+func (s *Sketch) SetModulator(folder, name string, m Modulator) {
+	k := controlMapKey(folder, name)
+	if !s.isModulatable(k) {
+		log.Fatalf("%q is not a slider", k)
+	}
+	s.setModulator(k, m)
+	s.dirty = true
+}
+
+// ClearModulator detaches a slider's modulator, leaving it at its base
+// value.
+func (s *Sketch) ClearModulator(folder, name string) {
+	k := controlMapKey(folder, name)
+	if _, ok := s.modulators[k]; ok {
+		delete(s.modulators, k)
+		s.DidSlidersChange, s.DidControlsChange, s.dirty = true, true, true
+	}
+}
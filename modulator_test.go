@@ -0,0 +1,172 @@
+package sketchy
+
+import (
+	"math"
+	"testing"
+)
+
+// modulatedControls adds the sliders the modulator tests modulate.
+func modulatedControls(ui *UI) {
+	ui.FloatSlider("Scale", 0, 10, 5, 0.1)
+	ui.IntSlider("Count", 0, 100, 50, 1)
+}
+
+func TestModulatorWaves(t *testing.T) {
+	// Rate 1 is a 60-tick period: tick 15 is a quarter cycle.
+	for _, tc := range []struct {
+		shape    ModShape
+		at0, q1  float64
+		at45, hf float64
+	}{
+		{ModSine, 0, 1, -1, 0},
+		{ModTriangle, 0, 1, -1, 0},
+		{ModSaw, 0, 0.5, -0.5, -1},
+		{ModSquare, 1, 1, -1, -1},
+	} {
+		m := Modulator{Shape: tc.shape, Rate: 1}
+		for _, c := range []struct {
+			tick int64
+			want float64
+		}{{0, tc.at0}, {15, tc.q1}, {45, tc.at45}, {30, tc.hf}} {
+			if got := m.wave(c.tick, 0); math.Abs(got-c.want) > 1e-9 {
+				t.Errorf("%s at %d = %v, want %v", modShapeNames[tc.shape], c.tick, got, c.want)
+			}
+		}
+	}
+
+	// The random shapes are bounded, repeat every cycle, and depend on the
+	// seed.
+	for _, shape := range []ModShape{ModWalk, ModNoise} {
+		m := Modulator{Shape: shape, Rate: 0.5}
+		differs := false
+		for tick := range int64(120) {
+			w := m.wave(tick, 7)
+			if w < -1 || w > 1 {
+				t.Fatalf("%s at %d = %v", modShapeNames[shape], tick, w)
+			}
+			if again := m.wave(tick+120, 7); again != w {
+				t.Fatalf("%s is not periodic: %v then %v", modShapeNames[shape], w, again)
+			}
+			differs = differs || m.wave(tick, 8) != w
+		}
+		if !differs {
+			t.Errorf("%s ignores the seed", modShapeNames[shape])
+		}
+	}
+}
+
+func TestModulatedSliderValues(t *testing.T) {
+	s := newTextBoxSketch(t, modulatedControls)
+	s.SetModulator("", "Scale", Modulator{Shape: ModSine, Rate: 1, Depth: 0.2})
+	s.SetModulator("", "Count", Modulator{Shape: ModSquare, Rate: 1, Depth: 0.8})
+
+	s.Tick = 15 // sine peak, square high
+	if v := s.GetFloat("", "Scale"); math.Abs(v-7) > 1e-9 {
+		t.Errorf("scale = %v, want 5 + 0.2*10", v)
+	}
+	if v := s.GetInt("", "Count"); v != 100 {
+		t.Errorf("count = %d, want clamped to 100", v)
+	}
+	if s.FloatSliders[0].Val != 5 || s.IntSliders[0].Val != 50 {
+		t.Fatal("modulation changed the base values")
+	}
+
+	// Moving the sliders redraws each tick, but flags no control change, so
+	// history takes no step.
+	s.trackHistory()
+	for range 3 {
+		s.DidModulatorsChange, s.dirty = false, false
+		s.updateModulators()
+		if !s.DidModulatorsChange || !s.dirty {
+			t.Fatalf("tick %d: no change flagged", s.Tick)
+		}
+		if s.DidSlidersChange || s.DidControlsChange {
+			t.Fatalf("tick %d: modulation flagged a control change", s.Tick)
+		}
+		s.trackHistory()
+		s.Tick++
+	}
+	if len(s.history.undo) != 0 {
+		t.Fatalf("%d history steps from modulation", len(s.history.undo))
+	}
+
+	s.ClearModulator("", "Scale")
+	if v := s.GetFloat("", "Scale"); v != 5 {
+		t.Errorf("cleared scale = %v", v)
+	}
+}
+
+func TestModulatorSnapshotRoundTrip(t *testing.T) {
+	src := newTextBoxSketch(t, modulatedControls)
+	src.SetModulator("", "Scale", Modulator{Shape: ModNoise, Rate: 0.25, Depth: 0.1, Phase: 0.5})
+	data, err := src.serializeControlState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTextBoxSketch(t, modulatedControls)
+	dst.SetModulator("", "Count", Modulator{Rate: 1, Depth: 1}) // replaced by the snapshot's
+	missing, err := dst.applyControlStateJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) > 0 {
+		t.Fatalf("missing %v", missing)
+	}
+	if len(dst.modulators) != 1 {
+		t.Fatalf("modulators %v", dst.modulators)
+	}
+	m := dst.modulators["Scale"]
+	if m == nil || m.Shape != ModNoise || m.Rate != 0.25 || m.Depth != 0.1 || m.Phase != 0.5 {
+		t.Fatalf("restored %+v", m)
+	}
+	src.Tick, dst.Tick = 77, 77
+	if a, b := src.GetFloat("", "Scale"), dst.GetFloat("", "Scale"); a != b {
+		t.Fatalf("restored sketch reads %v, want %v", b, a)
+	}
+
+	other := newTextBoxSketch(t, func(*UI) {})
+	missing, err = other.applyControlStateJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, k := range missing {
+		found = found || k == "Scale"
+	}
+	if !found || len(other.modulators) != 0 {
+		t.Fatalf("missing %v, modulators %v", missing, other.modulators)
+	}
+}
+
+func TestSliderRangeModalModulator(t *testing.T) {
+	s := newTextBoxSketch(t, func(ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 5, 0.1)
+	})
+	s.openFloatSliderRangeModal(0)
+	s.sliderRangeEditModIdx = int(ModTriangle) + 1
+	s.sliderRangeEditModDepth = 2
+	s.applyFloatSliderRangeOK()
+	if !s.sliderRangeModalOpen || s.sliderRangeModalErr == "" || s.modulators["Scale"] != nil {
+		t.Fatal("a depth over 1 was accepted")
+	}
+	s.sliderRangeEditModDepth = 0.5
+	s.applyFloatSliderRangeOK()
+	if s.sliderRangeModalOpen {
+		t.Fatalf("modal still open: %s", s.sliderRangeModalErr)
+	}
+	if m := s.modulators["Scale"]; m == nil || m.Shape != ModTriangle || m.Depth != 0.5 {
+		t.Fatalf("modulator %+v", m)
+	}
+
+	// Reopening shows it; Off removes it.
+	s.openFloatSliderRangeModal(0)
+	if s.sliderRangeEditModIdx != int(ModTriangle)+1 {
+		t.Fatalf("shape field %d", s.sliderRangeEditModIdx)
+	}
+	s.sliderRangeEditModIdx = 0
+	s.applyFloatSliderRangeOK()
+	if s.modulators["Scale"] != nil {
+		t.Fatal("Off kept the modulator")
+	}
+}
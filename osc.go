@@ -0,0 +1,355 @@
+package sketchy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/aldernero/sketchy/internal/osc"
+)
+
+// oscPrefix starts the OSC address of every control: /sketchy/<name> for
+// the root folder, /sketchy/<folder>/<name> otherwise.
+const oscPrefix = "/sketchy/"
+
+// oscSyncAddress asks for every control's value, sent back to the asker.
+const oscSyncAddress = "/sketchy/sync"
+
+// oscInboxSize bounds the packets waiting for the next Update; more are
+// dropped rather than stalling the listener.
+const oscInboxSize = 256
+
+// oscMaxPeers bounds the clients control changes are mirrored to.
+const oscMaxPeers = 16
+
+// oscPacket is a decoded packet and who sent it.
+type oscPacket struct {
+	from *net.UDPAddr
+	msgs []osc.Message
+}
+
+// oscServer is the OSC listener of Config.OSCAddr. The listener goroutine
+// only decodes packets into inbox; they are applied on the Update goroutine
+// (updateOSC), like every other control change. peers and sent belong to
+// Update too.
+type oscServer struct {
+	conn  *net.UDPConn
+	inbox chan oscPacket
+	send  *net.UDPAddr // Config.OSCSendAddr, or nil
+	peers map[string]*net.UDPAddr
+	// sent is the last value published for each address, so only changes
+	// go out.
+	sent map[string]any
+}
+
+// startOSC listens on OSCAddr and resolves OSCSendAddr.
+func (s *Sketch) startOSC() error {
+	laddr, err := net.ResolveUDPAddr("udp", s.OSCAddr)
+	if err != nil {
+		return err
+	}
+	o := &oscServer{
+		inbox: make(chan oscPacket, oscInboxSize),
+		peers: make(map[string]*net.UDPAddr),
+		sent:  make(map[string]any),
+	}
+	if s.OSCSendAddr != "" {
+		if o.send, err = net.ResolveUDPAddr("udp", s.OSCSendAddr); err != nil {
+			return err
+		}
+	}
+	if o.conn, err = net.ListenUDP("udp", laddr); err != nil {
+		return err
+	}
+	s.osc = o
+	go o.listen()
+	return nil
+}
+
+// listen decodes packets until the connection closes.
+func (o *oscServer) listen() {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := o.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("sketchy: osc: %v", err)
+			}
+			return
+		}
+		msgs, err := osc.Parse(buf[:n])
+		if err != nil {
+			log.Printf("sketchy: osc from %v: %v", from, err)
+			continue
+		}
+		select {
+		case o.inbox <- oscPacket{from: from, msgs: msgs}:
+		default:
+			log.Printf("sketchy: osc: dropped a packet from %v; Update is behind", from)
+		}
+	}
+}
+
+// closeOSC stops the listener.
+func (s *Sketch) closeOSC() {
+	if s.osc != nil {
+		s.osc.conn.Close()
+		s.osc = nil
+	}
+}
+
+// updateOSC applies the messages received since the last tick. A client
+// heard from for the first time is sent every control's value, then
+// mirrors changes from then on.
+func (s *Sketch) updateOSC() {
+	if s.osc == nil {
+		return
+	}
+	for {
+		select {
+		case p := <-s.osc.inbox:
+			if k := p.from.String(); s.osc.peers[k] == nil && len(s.osc.peers) < oscMaxPeers {
+				s.osc.peers[k] = p.from
+				s.sendOSC(s.oscState(), p.from)
+			}
+			for _, m := range p.msgs {
+				s.applyOSC(m, p.from)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// applyOSC sets the control m addresses from its arguments, or with no
+// arguments, sends the control's value back to from.
+func (s *Sketch) applyOSC(m osc.Message, from *net.UDPAddr) {
+	if m.Address == oscSyncAddress {
+		s.sendOSC(s.oscState(), from)
+		return
+	}
+	k, ok := strings.CutPrefix(m.Address, oscPrefix)
+	if !ok {
+		return
+	}
+	if len(m.Args) == 0 {
+		if v, ok := s.oscValue(k); ok {
+			s.sendOSC([]osc.Message{{Address: m.Address, Args: []any{v}}}, from)
+		}
+		return
+	}
+	if err := s.setControl(k, m.Args); err != nil {
+		log.Printf("sketchy: osc %s: %v", m.Address, err)
+		return
+	}
+	// Mirror the change now to everyone but the sender, who already shows
+	// it: publishing it back would fight a fader still being dragged.
+	if v, ok := s.oscValue(k); ok && s.osc.sent[m.Address] != v {
+		s.osc.sent[m.Address] = v
+		s.publishOSCTo([]osc.Message{{Address: m.Address, Args: []any{v}}}, from)
+	}
+}
+
+// setControl sets control k from OSC arguments, or from the value of an
+// HTTP API request (api.go) as its only argument: a number for sliders
+// (clamped to the range), checkboxes (nonzero is checked) and dropdowns
+// (the index), or an option name for dropdowns; a string for text boxes
+// (validated as if typed) and color pickers ("#RRGGBB" or a color name);
+// or r, g, b for color pickers, in 0–1 if all are at most 1, else 0–255.
+// Booleans count as 0 and 1. A button is pressed by a nonzero number.
+func (s *Sketch) setControl(k string, args []any) error {
+	f, isNum := osc.Float(args[0])
+	str, isStr := args[0].(string)
+	if i, ok := s.floatSliderControlMap[k]; ok && isNum {
+		sl := &s.FloatSliders[i]
+		sl.Val = clampFloat(f, sl.MinVal, sl.MaxVal)
+		return nil
+	}
+	if i, ok := s.intSliderControlMap[k]; ok && isNum {
+		sl := &s.IntSliders[i]
+		sl.Val = clampInt(int(math.Round(f)), sl.MinVal, sl.MaxVal)
+		return nil
+	}
+	if i, ok := s.toggleControlMap[k]; ok && isNum {
+		t := &s.Toggles[i]
+		if t.IsButton {
+			if f != 0 {
+				t.Checked = !t.Checked
+			}
+		} else {
+			t.Checked = f != 0
+		}
+		return nil
+	}
+	if i, ok := s.colorPickerControlMap[k]; ok {
+		cp := &s.ColorPickers[i]
+		switch {
+		case isStr:
+			r, g, b, _ := stringToColor(str).RGBA()
+			cp.r, cp.g, cp.b = int(r>>8), int(g>>8), int(b>>8)
+			return nil
+		case len(args) >= 3:
+			var rgb [3]float64
+			scale := 1.0
+			for j := range rgb {
+				v, ok := osc.Float(args[j])
+				if !ok {
+					return fmt.Errorf("color component %d is %T", j, args[j])
+				}
+				rgb[j] = v
+			}
+			if max(rgb[0], rgb[1], rgb[2]) <= 1 {
+				scale = 255
+			}
+			cp.r = clampInt(int(rgb[0]*scale+0.5), 0, 255)
+			cp.g = clampInt(int(rgb[1]*scale+0.5), 0, 255)
+			cp.b = clampInt(int(rgb[2]*scale+0.5), 0, 255)
+			return nil
+		}
+	}
+	if i, ok := s.dropdownControlMap[k]; ok {
+		d := &s.Dropdowns[i]
+		idx := -1
+		if isNum {
+			idx = int(f)
+		} else if isStr {
+			idx = slices.Index(d.Options, str)
+		}
+		if idx < 0 || idx >= len(d.Options) {
+			return fmt.Errorf("no option %v", args[0])
+		}
+		d.Index = idx
+		return nil
+	}
+	if i, ok := s.textBoxControlMap[k]; ok && isStr {
+		t := &s.TextBoxes[i]
+		if t.Validate != nil {
+			v, ok := t.Validate(str)
+			if !ok {
+				return fmt.Errorf("%q rejected", str)
+			}
+			str = v
+		}
+		t.Val = str
+		t.maybeSyncTextBufFromVal(s.InputCaptured())
+		return nil
+	}
+	if !s.hasControl(splitControlKey(k)) {
+		return fmt.Errorf("no control %q", k)
+	}
+	return fmt.Errorf("cannot set %q from %T", k, args[0])
+}
+
+// oscValue is control k's value as it is published: float32 for float
+// sliders, int32 for int sliders, checkboxes (0 or 1) and dropdowns, and a
+// string for colors ("#RRGGBB") and text boxes. Buttons have none.
+func (s *Sketch) oscValue(k string) (any, bool) {
+	if i, ok := s.floatSliderControlMap[k]; ok {
+		return float32(s.FloatSliders[i].Val), true
+	}
+	if i, ok := s.intSliderControlMap[k]; ok {
+		return int32(s.IntSliders[i].Val), true
+	}
+	if i, ok := s.toggleControlMap[k]; ok && !s.Toggles[i].IsButton {
+		if s.Toggles[i].Checked {
+			return int32(1), true
+		}
+		return int32(0), true
+	}
+	if i, ok := s.colorPickerControlMap[k]; ok {
+		return s.ColorPickers[i].GetHex(), true
+	}
+	if i, ok := s.dropdownControlMap[k]; ok {
+		return int32(s.Dropdowns[i].Index), true
+	}
+	if i, ok := s.textBoxControlMap[k]; ok {
+		return s.TextBoxes[i].Val, true
+	}
+	return nil, false
+}
+
+// controlKeys lists every control key, in panel order by kind.
+func (s *Sketch) controlKeys() []string {
+	var keys []string
+	for _, c := range s.FloatSliders {
+		keys = append(keys, controlMapKey(c.Folder, c.Name))
+	}
+	for _, c := range s.IntSliders {
+		keys = append(keys, controlMapKey(c.Folder, c.Name))
+	}
+	for _, c := range s.Toggles {
+		keys = append(keys, controlMapKey(c.Folder, c.Name))
+	}
+	for _, c := range s.ColorPickers {
+		keys = append(keys, controlMapKey(c.Folder, c.Name))
+	}
+	for _, c := range s.Dropdowns {
+		keys = append(keys, controlMapKey(c.Folder, c.Name))
+	}
+	for _, c := range s.TextBoxes {
+		keys = append(keys, controlMapKey(c.Folder, c.Name))
+	}
+	return keys
+}
+
+// oscState is a message per control carrying its value.
+func (s *Sketch) oscState() []osc.Message {
+	var msgs []osc.Message
+	for _, k := range s.controlKeys() {
+		if v, ok := s.oscValue(k); ok {
+			msgs = append(msgs, osc.Message{Address: oscPrefix + k, Args: []any{v}})
+		}
+	}
+	return msgs
+}
+
+// publishOSC sends the controls whose value changed since they were last
+// published to OSCSendAddr and every client heard from.
+func (s *Sketch) publishOSC() {
+	o := s.osc
+	if o == nil || (!s.DidControlsChange && len(o.sent) > 0) {
+		return
+	}
+	var changed []osc.Message
+	for _, m := range s.oscState() {
+		if o.sent[m.Address] != m.Args[0] {
+			o.sent[m.Address] = m.Args[0]
+			changed = append(changed, m)
+		}
+	}
+	if len(changed) > 0 {
+		s.publishOSCTo(changed, nil)
+	}
+}
+
+// publishOSCTo sends msgs to OSCSendAddr and every client heard from,
+// except skip.
+func (s *Sketch) publishOSCTo(msgs []osc.Message, skip *net.UDPAddr) {
+	o := s.osc
+	if o.send != nil && (skip == nil || o.send.String() != skip.String()) {
+		s.sendOSC(msgs, o.send)
+	}
+	for k, p := range o.peers {
+		if skip == nil || k != skip.String() {
+			s.sendOSC(msgs, p)
+		}
+	}
+}
+
+// sendOSC sends msgs to addr, one packet each.
+func (s *Sketch) sendOSC(msgs []osc.Message, addr *net.UDPAddr) {
+	for _, m := range msgs {
+		b, err := m.MarshalBinary()
+		if err == nil {
+			_, err = s.osc.conn.WriteToUDP(b, addr)
+		}
+		if err != nil {
+			log.Printf("sketchy: osc to %v: %v", addr, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package sketchy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aldernero/sketchy/internal/osc"
+)
+
+// oscClient is a local UDP peer of the sketch's OSC listener.
+type oscClient struct {
+	t    *testing.T
+	conn *net.UDPConn
+	to   *net.UDPAddr
+}
+
+func (c *oscClient) send(addr string, args ...any) {
+	c.t.Helper()
+	b, err := osc.Message{Address: addr, Args: args}.MarshalBinary()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.conn.WriteToUDP(b, c.to); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// await reads messages until one for addr arrives, returning its first
+// argument.
+func (c *oscClient) await(addr string) any {
+	c.t.Helper()
+	buf := make([]byte, 65536)
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, _, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			c.t.Fatalf("waiting for %s: %v", addr, err)
+		}
+		msgs, err := osc.Parse(buf[:n])
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		for _, m := range msgs {
+			if m.Address == addr && len(m.Args) > 0 {
+				return m.Args[0]
+			}
+		}
+	}
+}
+
+func newOSCSketch(t *testing.T) (*Sketch, *oscClient) {
+	t.Helper()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	s := New(Config{
+		SketchWidth: 100, SketchHeight: 100,
+		OSCAddr:     "127.0.0.1:0",
+		OSCSendAddr: client.LocalAddr().String(),
+	})
+	s.BuildUI = func(_ *Sketch, ui *UI) {
+		ui.Folder("Noise", func() {
+			ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+		})
+		ui.IntSlider("Count", 0, 10, 5, 1)
+		ui.Checkbox("Fill", false)
+		ui.ColorPicker("Tint", "#000000")
+		ui.Dropdown("Mode", []string{"lines", "dots"}, 0)
+		ui.TextBox("Note", "", nil)
+	}
+	s.rebuildControls()
+	if err := s.startOSC(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.closeOSC)
+	return s, &oscClient{t: t, conn: client, to: s.osc.conn.LocalAddr().(*net.UDPAddr)}
+}
+
+// pollOSC runs updateOSC until done reports true.
+func pollOSC(t *testing.T, s *Sketch, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(2 * time.Millisecond) {
+		s.updateOSC()
+		if done() {
+			return
+		}
+	}
+	t.Fatal("OSC messages not applied")
+}
+
+func TestOSCSetsControls(t *testing.T) {
+	s, c := newOSCSketch(t)
+	c.send("/sketchy/Noise/Scale", float32(3.5))
+	c.send("/sketchy/Count", float32(42)) // clamped
+	c.send("/sketchy/Fill", int32(1))
+	c.send("/sketchy/Tint", float32(1), float32(0.5), float32(0))
+	c.send("/sketchy/Mode", "dots")
+	c.send("/sketchy/Note", "hello")
+	pollOSC(t, s, func() bool { return s.TextBoxes[0].Val == "hello" })
+
+	if s.FloatSliders[0].Val != 3.5 || s.IntSliders[0].Val != 10 || !s.Toggles[0].Checked {
+		t.Errorf("scale %v count %d fill %v", s.FloatSliders[0].Val, s.IntSliders[0].Val, s.Toggles[0].Checked)
+	}
+	if h := s.ColorPickers[0].GetHex(); h != "#FF8000" {
+		t.Errorf("tint %s", h)
+	}
+	if s.Dropdowns[0].Index != 1 {
+		t.Errorf("mode %d", s.Dropdowns[0].Index)
+	}
+
+	// The first packet from a client is answered with every control's
+	// value, from before the packet applies.
+	if v := c.await("/sketchy/Count"); v != int32(5) {
+		t.Errorf("state sent count %v", v)
+	}
+}
+
+func TestOSCPublishesChanges(t *testing.T) {
+	s, c := newOSCSketch(t)
+	s.publishOSC() // the starting state
+	if v := c.await("/sketchy/Noise/Scale"); v != float32(1) {
+		t.Fatalf("initial scale %v", v)
+	}
+
+	s.FloatSliders[0].Val = 7
+	s.DidControlsChange = true
+	s.publishOSC()
+	if v := c.await("/sketchy/Noise/Scale"); v != float32(7) {
+		t.Fatalf("published scale %v", v)
+	}
+
+	// A query without arguments is answered with the value.
+	c.send("/sketchy/Mode")
+	pollOSC(t, s, func() bool { return len(s.osc.peers) == 1 })
+	if v := c.await("/sketchy/Mode"); v != int32(0) {
+		t.Fatalf("queried mode %v", v)
+	}
+}
@@ -0,0 +1,209 @@
+package sketchy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+// Page describes the physical sheet a sketch is drawn for. With a page set,
+// the sketch size follows from it, saves carry real units (SVG width and
+// height in mm or in, PNG pixel density), and plotter output is scaled to
+// it. The zero Page leaves the sketch in plain pixels.
+type Page struct {
+	// Paper is a preset — "A5", "A4", "A3", "A2", "Letter", "Legal",
+	// "Tabloid", "9x12in", "11x14in" — or any "<w>x<h><unit>" size with
+	// unit mm, cm or in. Empty uses Width and Height.
+	Paper string
+	// Width and Height are a custom page size in Units.
+	Width, Height float64
+	// Units is "mm" (default) or "in", for Width, Height and Margin, and
+	// the unit the SVG size is written in.
+	Units string
+	// Landscape turns the page so it is wider than tall.
+	Landscape bool
+	// Margin is the border kept clear on every side, in Units; see
+	// Sketch.CanvasRect.
+	Margin float64
+	// DPI is how many sketch pixels make an inch of paper. Zero fits
+	// SketchWidth to the page width, or uses 96 when SketchWidth is unset.
+	// The sketch size is derived from the page and DPI.
+	DPI float64
+}
+
+// IsZero reports whether no page is set.
+func (p Page) IsZero() bool {
+	return p.Paper == "" && p.Width <= 0 && p.Height <= 0
+}
+
+// unitMM is the length of one Units in millimetres.
+func (p Page) unitMM() (float64, error) {
+	switch p.Units {
+	case "", "mm":
+		return 1, nil
+	case "in":
+		return 25.4, nil
+	}
+	return 0, fmt.Errorf("sketchy: page units %q are not mm or in", p.Units)
+}
+
+// sizeMM is the page size in millimetres, turned per Landscape.
+func (p Page) sizeMM() (w, h float64, err error) {
+	u, err := p.unitMM()
+	if err != nil {
+		return 0, 0, err
+	}
+	if p.Paper != "" {
+		ps, ok := plot.LookupPageSize(p.Paper)
+		if !ok {
+			return 0, 0, fmt.Errorf("sketchy: unknown paper %q", p.Paper)
+		}
+		w, h = ps.Width, ps.Height
+	} else {
+		w, h = p.Width*u, p.Height*u
+	}
+	if w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("sketchy: page size %gx%g%s is empty", p.Width, p.Height, p.Units)
+	}
+	if p.Landscape != (w > h) {
+		w, h = h, w
+	}
+	return w, h, nil
+}
+
+// applyPage sizes the sketch for its page and fixes the pixels-per-inch
+// mapping. A configured SketchWidth picks the DPI when Page.DPI is zero.
+func (s *Sketch) applyPage() error {
+	if s.Page.IsZero() {
+		return nil
+	}
+	w, h, err := s.Page.sizeMM()
+	if err != nil {
+		return err
+	}
+	u, _ := s.Page.unitMM()
+	if 2*s.Page.Margin*u >= min(w, h) {
+		return fmt.Errorf("sketchy: page margin %g%s leaves no room on the page", s.Page.Margin, s.Page.Units)
+	}
+	dpi := s.Page.DPI
+	if dpi <= 0 {
+		dpi = DefaultDPI
+		if s.SketchWidth > 0 {
+			dpi = s.SketchWidth / (w / 25.4)
+		}
+	}
+	s.pageDPI = dpi
+	s.pageMM = [2]float64{w, h}
+	// Whole pixels, so the canvas is exactly the sketch.
+	s.SketchWidth = math.Round(w / 25.4 * dpi)
+	s.SketchHeight = math.Round(h / 25.4 * dpi)
+	return nil
+}
+
+// pxPerInch is how many sketch pixels make an inch: the page DPI, or 96
+// without a page.
+func (s *Sketch) pxPerInch() float64 {
+	if s.pageDPI > 0 {
+		return s.pageDPI
+	}
+	return DefaultDPI
+}
+
+// pageDensity is the pixels per inch of the page in an image rendered at
+// scale, for its density tag; 0 without a page, whose pixels have no
+// physical size.
+func (s *Sketch) pageDensity(scale float64) float64 {
+	if s.pageDPI <= 0 {
+		return 0
+	}
+	return scale * s.pageDPI
+}
+
+// MM converts millimetres on the page to sketch pixels. Without a Page a
+// sketch pixel is 1/96 inch.
+func (s *Sketch) MM(v float64) float64 {
+	return v * s.pxPerInch() / 25.4
+}
+
+// Inch converts inches on the page to sketch pixels.
+func (s *Sketch) Inch(v float64) float64 {
+	return v * s.pxPerInch()
+}
+
+// marginPx is the page margin in sketch pixels; 0 without a page.
+func (s *Sketch) marginPx() float64 {
+	if s.Page.IsZero() {
+		return 0
+	}
+	u, _ := s.Page.unitMM()
+	return s.MM(s.Page.Margin * u)
+}
+
+// svgPageSize is the width and height attributes of a saved SVG: the page
+// size in its units, or "" without a page.
+func (s *Sketch) svgPageSize() (w, h string) {
+	if s.Page.IsZero() {
+		return "", ""
+	}
+	u, err := s.Page.unitMM()
+	if err != nil {
+		return "", ""
+	}
+	unit := s.Page.Units
+	if unit == "" {
+		unit = "mm"
+	}
+	f := func(mm float64) string {
+		return strconv.FormatFloat(math.Round(mm/u*1000)/1000, 'f', -1, 64) + unit
+	}
+	return f(s.pageMM[0]), f(s.pageMM[1])
+}
+
+// withPNGDensity records the physical pixel density of an encoded PNG in a
+// pHYs chunk after the header, replacing any there is.
+func withPNGDensity(data []byte, dpi float64) ([]byte, error) {
+	const sig = "\x89PNG\r\n\x1a\n"
+	const ihdrEnd = len(sig) + 8 + 13 + 4
+	if len(data) < ihdrEnd || string(data[:len(sig)]) != sig || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("not a PNG")
+	}
+	var b bytes.Buffer
+	b.Write(data[:ihdrEnd])
+	b.Write(pngChunk("pHYs", pngDensity(dpi)))
+	for rest := data[ihdrEnd:]; len(rest) >= 12; {
+		n := int(binary.BigEndian.Uint32(rest))
+		if 12+n > len(rest) {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		if string(rest[4:8]) != "pHYs" {
+			b.Write(rest[:12+n])
+		}
+		rest = rest[12+n:]
+	}
+	return b.Bytes(), nil
+}
+
+// pngDensity is the body of a pHYs chunk for dpi.
+func pngDensity(dpi float64) []byte {
+	ppm := uint32(dpi/0.0254 + 0.5)
+	body := make([]byte, 9)
+	binary.BigEndian.PutUint32(body, ppm)
+	binary.BigEndian.PutUint32(body[4:], ppm)
+	body[8] = 1 // unit: metre
+	return body
+}
+
+// pngChunk frames body as a PNG chunk of type typ.
+func pngChunk(typ string, body []byte) []byte {
+	chunk := make([]byte, 8+len(body)+4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(body)))
+	copy(chunk[4:], typ)
+	copy(chunk[8:], body)
+	binary.BigEndian.PutUint32(chunk[8+len(body):], crc32.ChecksumIEEE(chunk[4:8+len(body)]))
+	return chunk
+}
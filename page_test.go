@@ -0,0 +1,111 @@
+package sketchy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldernero/gaul/render"
+)
+
+func TestPageSizesSketch(t *testing.T) {
+	s := New(Config{SketchWidth: 1123, Page: Page{Paper: "A4", Landscape: true, Margin: 10}})
+	if math.Abs(s.pxPerInch()-96) > 0.1 || s.SketchWidth != 1123 || s.SketchHeight != 794 {
+		t.Fatalf("A4 landscape = %vx%v at %v dpi", s.SketchWidth, s.SketchHeight, s.pxPerInch())
+	}
+	s = New(Config{Page: Page{Width: 9, Height: 12, Units: "in", Margin: 0.5, DPI: 100}})
+	if s.SketchWidth != 900 || s.SketchHeight != 1200 {
+		t.Fatalf("9x12in = %vx%v", s.SketchWidth, s.SketchHeight)
+	}
+	if s.MM(25.4) != 100 || s.Inch(2) != 200 {
+		t.Fatalf("MM(25.4) = %v, Inch(2) = %v", s.MM(25.4), s.Inch(2))
+	}
+	if r := s.CanvasRect(); r.X != 50 || r.Y != 50 || r.W != 800 || r.H != 1100 {
+		t.Fatalf("CanvasRect = %+v", r)
+	}
+	if w, h := s.svgPageSize(); w != "9in" || h != "12in" {
+		t.Fatalf("svgPageSize = %q, %q", w, h)
+	}
+	if w, h := New(Config{Page: Page{Paper: "A3"}}).svgPageSize(); w != "297mm" || h != "420mm" {
+		t.Fatalf("A3 svgPageSize = %q, %q", w, h)
+	}
+	if err := (&Sketch{Page: Page{Paper: "B7"}}).applyPage(); err == nil {
+		t.Fatal("expected an error for an unknown paper")
+	}
+}
+
+func TestWithSVGSize(t *testing.T) {
+	src := `<?xml version="1.0"?>` + "\n" + `<svg xmlns="http://www.w3.org/2000/svg" width="400" height="300"><path d="M0 0"/></svg>`
+	out, err := withSVGSize([]byte(src), "100mm", "75mm", 400, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" width="100mm" height="75mm" viewBox="0 0 400 300"><path d="M0 0"/></svg>`
+	if !strings.HasSuffix(string(out), want) {
+		t.Fatalf("withSVGSize = %s", out)
+	}
+}
+
+func TestWithPNGDensity(t *testing.T) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	out, err := withPNGDensity(b.Bytes(), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err = withPNGDensity(out, 300); err != nil { // replaces, not duplicates
+		t.Fatal(err)
+	}
+	if n := bytes.Count(out, []byte("pHYs")); n != 1 {
+		t.Fatalf("%d pHYs chunks", n)
+	}
+	i := bytes.Index(out, []byte("pHYs"))
+	if ppm := binary.BigEndian.Uint32(out[i+4:]); ppm != 11811 || out[i+12] != 1 {
+		t.Fatalf("pHYs = %d px/unit, unit %d", ppm, out[i+12])
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPNGSavesTagDensityOnlyWithPage(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name string
+		page Page
+		want bool
+	}{
+		{"plain", Page{}, false},
+		{"paged", Page{Width: 2, Height: 1, Units: "in", DPI: 50}, true},
+	} {
+		s := New(Config{SketchWidth: 100, SketchHeight: 50, Page: tc.page})
+		s.recorder = render.NewRecorder(s.SketchWidth, s.SketchHeight)
+		full := filepath.Join(dir, tc.name+".png")
+		if err := s.renderPNGToFile(full, 2*DefaultDPI); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.Contains(data, []byte("pHYs")); got != tc.want {
+			t.Fatalf("%s: pHYs = %v, want %v", tc.name, got, tc.want)
+		}
+		if !tc.want {
+			continue
+		}
+		// Twice the page's 50 dpi.
+		i := bytes.Index(data, []byte("pHYs"))
+		if ppm := binary.BigEndian.Uint32(data[i+4:]); ppm != 3937 {
+			t.Fatalf("pHYs = %d px/m", ppm)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package sketchy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aldernero/debugui"
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+// formatPDF is the vector PDF save format.
+const formatPDF = "pdf"
+
+// pdfPageLabels are the PDF page dropdown options: the sketch's own size,
+// then the paper presets.
+var pdfPageLabels = func() []string {
+	labels := []string{"Sketch size"}
+	for _, p := range plot.PageSizes {
+		labels = append(labels, p.Name)
+	}
+	return labels
+}()
+
+// pdfPageOptions resolves a paper preset name to the page of a drawing
+// w×h pixels: the preset turned to the drawing's orientation, or for ""
+// the drawing's own size at mmPerPx.
+func pdfPageOptions(page string, margin, w, h, mmPerPx float64) (plot.PDFOptions, error) {
+	if page == "" {
+		return plot.PDFOptions{Width: w * mmPerPx, Height: h * mmPerPx, Margin: margin}, nil
+	}
+	p, ok := plot.LookupPageSize(page)
+	if !ok {
+		return plot.PDFOptions{}, fmt.Errorf("sketchy: unknown PDF page size %q", page)
+	}
+	if w > h {
+		p.Width, p.Height = p.Height, p.Width
+	}
+	return plot.PDFOptions{Width: p.Width, Height: p.Height, Margin: margin}, nil
+}
+
+// renderPDFToFile writes the current frame as a vector PDF on the page
+// size named by page (see Sketch.PDFPage).
+func (s *Sketch) renderPDFToFile(full, page string, po PlotOptions) error {
+	if s.usesGPUCanvas() {
+		return fmt.Errorf("sketchy: GPU-rendered sketches have no vector representation to save as PDF")
+	}
+	opts, err := pdfPageOptions(page, s.PDFMargin, s.SketchWidth, s.SketchHeight, 25.4/s.pxPerInch())
+	if err != nil {
+		return err
+	}
+	opts.Title = s.Title
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	src, err := s.svgBytes(s.recorder)
+	if err != nil {
+		return err
+	}
+	d, err := s.plotDrawing(src, svgSaveOptions{plot: po})
+	if err != nil {
+		return err
+	}
+	if d.ViewBox[2] <= 0 || d.ViewBox[3] <= 0 {
+		d.ViewBox = [4]float64{0, 0, s.SketchWidth, s.SketchHeight}
+	}
+	var b bytes.Buffer
+	if err := plot.WritePDF(&b, d, opts); err != nil {
+		return err
+	}
+	return os.WriteFile(full, b.Bytes(), 0644)
+}
+
+// writeSnapshotPDF writes the snapshot dialog's PDF synchronously.
+func (s *Sketch) writeSnapshotPDF(full string) error {
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	return s.renderPDFToFile(full, s.PDFPage, s.Plot.snapshot())
+}
+
+// drawPDFPageRow is the PDF page dropdown of the save dialogs.
+func (s *Sketch) drawPDFPageRow(ctx *debugui.Context) {
+	idx := 0
+	for i, l := range pdfPageLabels[1:] {
+		if l == s.PDFPage {
+			idx = i + 1
+		}
+	}
+	ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+	ctx.Text("PDF page")
+	ctx.IDScope("pdfPage", func() {
+		ctx.Dropdown(&idx, pdfPageLabels).On(func() {
+			s.PDFPage = ""
+			if idx > 0 {
+				s.PDFPage = pdfPageLabels[idx]
+			}
+		})
+	})
+	ctx.SetGridLayout([]int{-1}, nil)
+}
@@ -0,0 +1,75 @@
+package sketchy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/plot"
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+func TestPDFSave(t *testing.T) {
+	s := newTestSketch(200, 100, func(s *Sketch, c *render.Context) {
+		c.SetStrokeWidth(3)
+		c.MoveTo(10, 10)
+		c.LineTo(190, 90)
+		c.Stroke()
+	})
+	s.PDFPage = "A4"
+	s.renderFrame()
+
+	full := filepath.Join(t.TempDir(), "out.pdf")
+	if err := s.renderPDFToFile(full, s.PDFPage, s.Plot); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A landscape sketch turns the page landscape.
+	if out := string(data); !strings.HasPrefix(out, "%PDF-") || !strings.Contains(out, "/MediaBox [0 0 841.89 595.276]") {
+		t.Fatalf("PDF:\n%s", out)
+	}
+	if err := s.renderPDFToFile(full, "B7", s.Plot); err == nil {
+		t.Fatal("expected an error for an unknown page size")
+	}
+}
+
+func TestPDFPageOptions(t *testing.T) {
+	opts, err := pdfPageOptions("", 0, 960, 480, plot.MMPerPixel)
+	if err != nil || opts.Width != 254 || opts.Height != 127 {
+		t.Fatalf("sketch size page = %+v, %v", opts, err)
+	}
+	opts, err = pdfPageOptions("letter", 10, 100, 200, plot.MMPerPixel)
+	if err != nil || opts.Width != 215.9 || opts.Height != 279.4 || opts.Margin != 10 {
+		t.Fatalf("Letter page = %+v, %v", opts, err)
+	}
+}
+
+func TestSnapshotPDFThroughDatabase(t *testing.T) {
+	db, err := sketchdb.Open(filepath.Join(t.TempDir(), "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.InsertSnapshot("a", "", "{}", "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.InsertSave("saves/pdf/a.pdf", formatPDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetSnapshotPDF("a", id); err != nil {
+		t.Fatal(err)
+	}
+	row, err := db.GetSnapshotByName("a")
+	if err != nil || row == nil {
+		t.Fatalf("GetSnapshotByName = %v, %v", row, err)
+	}
+	if row.PDFPath != "saves/pdf/a.pdf" || row.PDFSaveID.Int64 != id {
+		t.Fatalf("PDF = %q (%v)", row.PDFPath, row.PDFSaveID)
+	}
+}
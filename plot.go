@@ -0,0 +1,251 @@
+package sketchy
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+// defaultFlattenTolerance is how far, in sketch pixels, flattened curves
+// may stray from the recorded ones when PlotOptions.FlattenTolerance is 0.
+const defaultFlattenTolerance = 0.05
+
+// GCodeOptions is the G-code program template for "gcode" saves: pen up,
+// pen down, travel and draw commands with {x}, {y}, {feed} and {layer}
+// placeholders, feed rate, scale, and y direction. The zero value writes
+// GRBL-style millimetre G-code with a Z-axis pen; see the field docs for
+// each default.
+type GCodeOptions = plot.GCodeOptions
+
+// HatchOptions is a line fill: angle in degrees, spacing in sketch pixels,
+// optional cross-hatching, and pen width. See PlotOptions.HatchFills and
+// HatchFill.
+type HatchOptions = plot.HatchOptions
+
+// PlotOptions configures the pen-plotter stages of vector saves.
+type PlotOptions struct {
+	// OptimizePaths runs SVG, G-code and HP-GL saves through path
+	// optimization, like vpype's linemerge and linesort: duplicate and
+	// overlapping lines are dropped, paths whose ends touch are joined, and
+	// paths are reordered and flipped to cut pen-up travel. The save
+	// dialogs toggle it. Curves are written flattened, and paint order
+	// within a layer is not kept.
+	OptimizePaths bool
+	// MergeTolerance is how close, in sketch pixels, path ends must be to
+	// be joined and lines to count as overlapping; 0 means 0.1.
+	MergeTolerance float64
+	// FlattenTolerance is how far, in sketch pixels, the line segments that
+	// replace curves in optimized SVG, G-code and HP-GL may stray from the
+	// curve; 0 means 0.05.
+	FlattenTolerance float64
+	// GCode is the program template for "gcode" saves.
+	GCode GCodeOptions
+	// HatchFills turns filled shapes in SVG, G-code and HP-GL saves into
+	// hatch lines clipped to the shape and stroked in the fill color, so a
+	// plotter draws what the PNG shows. The save dialogs toggle it. Each
+	// fill color uses its HatchByColor entry ("#rrggbb" or a color name),
+	// else Hatch.
+	HatchFills   bool
+	Hatch        HatchOptions
+	HatchByColor map[string]HatchOptions
+	// HideOccluded removes hidden lines from SVG, G-code and HP-GL saves:
+	// strokes, and hatch lines, are clipped where a fully opaque fill
+	// drawn later covers them, so overlapping shapes plot as they look.
+	// Translucent fills hide nothing, and a shape's own fill never hides
+	// its outline. With "Sketch layers", later layers count as drawn on
+	// top. The save dialogs toggle it.
+	HideOccluded bool
+	// DrawSpeed and TravelSpeed are the pen-down and pen-up speeds, in
+	// millimetres of paper per second, and PenLiftTime the seconds each
+	// pen lift and drop takes, for the Builtins plot estimate (estimate.go).
+	// Zero means 25 mm/s, 75 mm/s and 0.25 s.
+	DrawSpeed   float64
+	TravelSpeed float64
+	PenLiftTime float64
+}
+
+// Plotter save formats, besides "png" and "svg".
+const (
+	formatGCode = "gcode"
+	formatHPGL  = "hpgl"
+)
+
+// plotDrawing flattens an SVG document for plotter output, hides occluded
+// lines and hatches fills per opts (prepare) and, with opts.optimize,
+// optimizes it, reporting the pen-up travel before and after in the
+// Builtins panel.
+func (s *Sketch) plotDrawing(src []byte, opts svgSaveOptions) (*plot.Drawing, error) {
+	tol := opts.plot.FlattenTolerance
+	if tol <= 0 {
+		tol = defaultFlattenTolerance
+	}
+	d, err := plot.ParseSVG(src, tol)
+	if err != nil {
+		return nil, err
+	}
+	d = opts.plot.prepare(d, opts)
+	if !opts.optimize {
+		return d, nil
+	}
+	before := plot.Measure(d)
+	d = plot.Optimize(d, plot.OptimizeOptions{Tolerance: opts.plot.MergeTolerance})
+	s.setPlotStatus(travelReport(before, plot.Measure(d)))
+	fmt.Println(s.PlotStatus())
+	return d, nil
+}
+
+// plotLayers replays the current frame into a drawing prepared for the
+// plotter per opts, returning it with its layer names. Hiding occluded
+// lines needs the recording's paint order, so with color layers the flat
+// drawing is occluded and then grouped. Called with saveMutex held.
+func (s *Sketch) plotLayers(opts svgSaveOptions) (*plot.Drawing, []string, error) {
+	doc, layers, err := s.layeredSVG(opts.sourceLayers())
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := s.plotDrawing(doc.source(layers), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.sourceLayers() == opts.layers {
+		return d, svgLayerNames(layers), nil
+	}
+	names := make([]string, len(d.Layers))
+	for i, l := range d.Layers {
+		names[i] = l.Name
+	}
+	return d, names, nil
+}
+
+// sourceLayers is the layering to replay the frame in for opts.
+func (opts svgSaveOptions) sourceLayers() SVGLayerMode {
+	if opts.occlude && opts.layers == SVGLayersByColor {
+		return SVGLayersNone
+	}
+	return opts.layers
+}
+
+// prepare hides occluded lines and hatches the fills of d per opts, then
+// regroups it by color if sourceLayers flattened it.
+func (o PlotOptions) prepare(d *plot.Drawing, opts svgSaveOptions) *plot.Drawing {
+	var rule plot.HatchRule
+	if opts.hatch {
+		rule = o.hatchRule()
+	}
+	switch {
+	case opts.occlude:
+		d = plot.Occlude(d, rule)
+	case rule != nil:
+		d = plot.Hatch(d, rule)
+	}
+	if opts.sourceLayers() != opts.layers {
+		d = plot.GroupByPaint(d)
+	}
+	return d
+}
+
+// snapshot copies o for a save or estimate that runs off the ebiten thread,
+// where the panel may change o meanwhile; HatchByColor is copied too.
+func (o PlotOptions) snapshot() PlotOptions {
+	o.HatchByColor = maps.Clone(o.HatchByColor)
+	return o
+}
+
+// hatchRule picks each fill color's hatch: its HatchByColor entry, else
+// Hatch.
+func (o PlotOptions) hatchRule() plot.HatchRule {
+	byColor := make(map[string]HatchOptions, len(o.HatchByColor))
+	for k, v := range o.HatchByColor {
+		if c, ok := plot.ParseColor(k); ok {
+			k = plot.HexColor(c)
+		}
+		byColor[k] = v
+	}
+	return func(fill string) (HatchOptions, bool) {
+		if h, ok := byColor[fill]; ok {
+			return h, true
+		}
+		return o.Hatch, true
+	}
+}
+
+// writeOptimizedSVG writes a plotter-prepared (hatched or optimized)
+// drawing to full and, with split, each named layer beside it.
+func writeOptimizedSVG(full string, d *plot.Drawing, names []string, split bool) ([]savedLayer, error) {
+	if err := os.WriteFile(full, plot.EncodeSVG(d), 0644); err != nil {
+		return nil, err
+	}
+	if !split {
+		return nil, nil
+	}
+	files := layerFiles(full, names)
+	for i, l := range d.Layers {
+		if i >= len(files) {
+			break
+		}
+		one := &plot.Drawing{Width: d.Width, Height: d.Height, ViewBox: d.ViewBox, Layers: []plot.Layer{l}}
+		if err := os.WriteFile(files[i].path, plot.EncodeSVG(one), 0644); err != nil {
+			return files[:i], err
+		}
+	}
+	return files, nil
+}
+
+// renderPlotToFile writes the current frame as G-code or HP-GL. Layers per
+// opts become pen changes; it returns their names.
+func (s *Sketch) renderPlotToFile(full, format string, opts svgSaveOptions) ([]string, error) {
+	if s.usesGPUCanvas() {
+		return nil, fmt.Errorf("sketchy: GPU-rendered sketches have no vector representation to save as %s", format)
+	}
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	d, names, err := s.plotLayers(opts)
+	if err != nil {
+		return nil, err
+	}
+	// Machine units follow the page: millimetres of paper per sketch pixel.
+	mmPerPx := 25.4 / s.pxPerInch()
+	gcode := opts.plot.GCode
+	if gcode.UnitsPerPixel <= 0 {
+		gcode.UnitsPerPixel = mmPerPx
+	}
+	var b bytes.Buffer
+	switch format {
+	case formatGCode:
+		err = plot.WriteGCode(&b, d, gcode)
+	case formatHPGL:
+		err = plot.WriteHPGL(&b, d, plot.HPGLOptions{UnitsPerPixel: 40 * mmPerPx})
+	default:
+		err = fmt.Errorf("sketchy: unknown plotter format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return names, os.WriteFile(full, b.Bytes(), 0644)
+}
+
+// travelReport describes what optimization saved.
+func travelReport(before, after plot.Stats) string {
+	pct := 0.0
+	if before.PenUp > 0 {
+		pct = 100 * (before.PenUp - after.PenUp) / before.PenUp
+	}
+	return fmt.Sprintf("Pen-up travel %.0f → %.0f px (-%.0f%%), %d → %d paths",
+		before.PenUp, after.PenUp, pct, before.Paths, after.Paths)
+}
+
+// PlotStatus is the result of the last optimized save, as shown in the
+// Builtins panel; empty before the first.
+func (s *Sketch) PlotStatus() string {
+	v, _ := s.plotStatus.Load().(string)
+	return v
+}
+
+// setPlotStatus is called from the save worker, so the status is atomic.
+func (s *Sketch) setPlotStatus(msg string) {
+	s.plotStatus.Store(msg)
+}
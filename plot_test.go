@@ -0,0 +1,149 @@
+package sketchy
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/plot"
+)
+
+func TestOptimizedSVGSave(t *testing.T) {
+	s := newTestSketch(100, 100, func(s *Sketch, c *render.Context) {
+		c.MoveTo(90, 90)
+		c.LineTo(80, 80)
+		c.Stroke()
+		red := s.Layer("red pen")
+		red.MoveTo(10, 10)
+		red.LineTo(20, 20)
+		red.Stroke()
+	})
+	s.renderFrame()
+
+	full := filepath.Join(t.TempDir(), "out.svg")
+	names, files, err := s.renderLayeredSVGToFile(full, svgSaveOptions{layers: SVGLayersNamed, split: true, optimize: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, "|") != "base|red pen" || len(files) != 2 {
+		t.Fatalf("layers = %q, files = %+v", names, files)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := plot.ParseSVG(data, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Layers) != 2 || d.Layers[1].Name != "red pen" {
+		t.Fatalf("optimized layers = %+v", d.Layers)
+	}
+	if _, err := os.Stat(files[1].path); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s.PlotStatus(), "Pen-up travel") {
+		t.Fatalf("PlotStatus = %q", s.PlotStatus())
+	}
+}
+
+func TestTravelReport(t *testing.T) {
+	got := travelReport(plot.Stats{Paths: 10, PenUp: 2000}, plot.Stats{Paths: 4, PenUp: 500})
+	if got != "Pen-up travel 2000 → 500 px (-75%), 10 → 4 paths" {
+		t.Fatalf("travelReport = %q", got)
+	}
+}
+
+func TestPlotterSave(t *testing.T) {
+	s := newTestSketch(100, 100, func(s *Sketch, c *render.Context) {
+		c.MoveTo(10, 10)
+		c.LineTo(20, 20)
+		c.Stroke()
+		red := s.Layer("red pen")
+		red.MoveTo(30, 30)
+		red.LineTo(40, 40)
+		red.Stroke()
+	})
+	s.renderFrame()
+
+	dir := t.TempDir()
+	full := filepath.Join(dir, "out.gcode")
+	names, err := s.renderPlotToFile(full, formatGCode, svgSaveOptions{layers: SVGLayersNamed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, "|") != "base|red pen" {
+		t.Fatalf("layers = %q", names)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(data); !strings.HasPrefix(out, "G21\n") || !strings.Contains(out, "M0 ; pen: red pen") {
+		t.Fatalf("G-code:\n%s", out)
+	}
+
+	full = filepath.Join(dir, "out.hpgl")
+	if _, err := s.renderPlotToFile(full, formatHPGL, svgSaveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(full); err != nil || !strings.HasPrefix(string(data), "IN;") {
+		t.Fatalf("HPGL = %q, %v", data, err)
+	}
+}
+
+func TestOccludedSVGSave(t *testing.T) {
+	s := newTestSketch(100, 100, func(s *Sketch, c *render.Context) {
+		c.SetStrokeColor(color.RGBA{R: 255, A: 255})
+		c.MoveTo(10, 50)
+		c.LineTo(90, 50)
+		c.Stroke()
+		c.MoveTo(50, 20)
+		c.LineTo(80, 20)
+		c.LineTo(80, 80)
+		c.LineTo(50, 80)
+		c.ClosePath()
+		c.Fill()
+	})
+	s.renderFrame()
+
+	full := filepath.Join(t.TempDir(), "out.svg")
+	names, _, err := s.renderLayeredSVGToFile(full, svgSaveOptions{layers: SVGLayersByColor, occlude: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, "|") != "#ff0000|#ffffff" {
+		t.Fatalf("layers = %q", names)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := plot.ParseSVG(data, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The square hides x 50..80 of the line.
+	if st := plot.Measure(d); st.Paths != 2 || math.Abs(st.PenDown-50) > 1e-6 {
+		t.Fatalf("occluded line = %+v", st)
+	}
+}
+
+// TestSaveRequestCopiesPlotOptions checks that a queued save carries its own
+// copy of Sketch.Plot, so panel edits cannot race the save worker.
+func TestSaveRequestCopiesPlotOptions(t *testing.T) {
+	s := newTestSketch(100, 100, func(*Sketch, *render.Context) {})
+	s.saveRequests = make(chan SaveRequest, 1)
+	s.Plot.HatchByColor = map[string]HatchOptions{"red": {Spacing: 2}}
+	s.enqueueVectorSave("saves/gcode/out.gcode", formatGCode)
+	s.Plot.HatchByColor["red"] = HatchOptions{Spacing: 5}
+	s.Plot.FlattenTolerance = 1
+	req := <-s.saveRequests
+	if po := req.svgOptions().plot; po.HatchByColor["red"].Spacing != 2 || po.FlattenTolerance != 0 {
+		t.Fatalf("queued plot options follow the panel: %+v", po)
+	}
+}
@@ -3,7 +3,6 @@ package sketchy
 import (
 	"fmt"
 	"image"
-	"image/png"
 	"os"
 	"path/filepath"
 
@@ -12,7 +11,10 @@ import (
 
 // renderPNGToFile replays the current frame's recording into a fresh raster
 // at the given DPI (96 = one raster pixel per sketch pixel) and writes it as
-// PNG. It holds saveMutex so it never observes a half-rebuilt frame.
+// PNG, tagged with its physical pixel density when the sketch has a page
+// (page.go). It holds saveMutex while replaying so it never observes a
+// half-rebuilt frame. Images past tiledPNGPixels are rendered in bands
+// instead (tiled.go).
 func (s *Sketch) renderPNGToFile(full string, dpi float64) error {
 	if s.usesGPUCanvas() {
 		// The recorder is empty for a GPU-rendered sketch; replaying it would
@@ -25,13 +27,29 @@ func (s *Sketch) renderPNGToFile(full string, dpi float64) error {
 	}
 	w := int(s.SketchWidth*scale + 0.5)
 	h := int(s.SketchHeight*scale + 0.5)
+	if w*h > tiledPNGPixels {
+		return s.renderTiledPNGToFile(full, scale, w, h)
+	}
+	return writePixels(full, s.rasterizeRecording(dpi), s.pageDensity(scale))
+}
+
+// rasterizeRecording replays the current frame's recording into a new RGBA
+// image at the given DPI, for captures that must outlive the next frame.
+func (s *Sketch) rasterizeRecording(dpi float64) *image.RGBA {
+	scale := dpi / DefaultDPI
+	if scale <= 0 {
+		scale = 1
+	}
+	w := int(s.SketchWidth*scale + 0.5)
+	h := int(s.SketchHeight*scale + 0.5)
 
 	s.saveMutex.Lock()
 	defer s.saveMutex.Unlock()
-	ras := render.NewRaster(w, h)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	ras := render.NewRasterFromImage(img)
 	ras.SetScale(scale)
 	s.recorder.Replay(ras)
-	return ras.SavePNG(full)
+	return img
 }
 
 // renderSVGToFile replays the current frame's recording into an SVG document.
@@ -41,6 +59,13 @@ func (s *Sketch) renderSVGToFile(full string) error {
 	}
 	s.saveMutex.Lock()
 	defer s.saveMutex.Unlock()
+	if w, _ := s.svgPageSize(); w != "" {
+		data, err := s.svgBytes(s.recorder) // sized in page units
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(full, data, 0644)
+	}
 	svg := render.NewSVG(s.SketchWidth, s.SketchHeight)
 	s.recorder.Replay(svg)
 	return svg.Save(full)
@@ -53,36 +78,18 @@ func writePNG(full string, s *Sketch) error {
 	return s.renderPNGToFile(full, s.RasterDPI)
 }
 
-func writeSVG(full string, s *Sketch) error {
-	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
-		return err
-	}
-	return s.renderSVGToFile(full)
-}
-
-// writePixelsPNG encodes an already-captured frame (e.g. a shader sketch's
-// GPU readback) as PNG.
-func writePixelsPNG(full string, img *image.RGBA) error {
-	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
-		return err
+// writeSnapshotPNG writes the current frame for the snapshot dialog,
+// dispatching between the CPU recorder replay and the GPU capture, and
+// embeds meta. Must run on the ebiten thread for GPU-rendered sketches.
+func (s *Sketch) writeSnapshotPNG(full string, meta *saveMetadata) error {
+	var err error
+	if s.usesGPUCanvas() {
+		err = writePixels(full, s.CaptureGPUImage(), 0)
+	} else {
+		err = writePNG(full, s)
 	}
-	f, err := os.Create(full)
 	if err != nil {
 		return err
 	}
-	if err := png.Encode(f, img); err != nil {
-		_ = f.Close()
-		return err
-	}
-	return f.Close()
-}
-
-// writeSnapshotPNG writes the current frame for the snapshot dialog,
-// dispatching between the CPU recorder replay and the GPU capture.
-// Must run on the ebiten thread for GPU-rendered sketches.
-func (s *Sketch) writeSnapshotPNG(full string) error {
-	if s.usesGPUCanvas() {
-		return writePixelsPNG(full, s.CaptureGPUImage())
-	}
-	return writePNG(full, s)
+	return meta.embed(full)
 }
@@ -327,7 +327,7 @@ func (s *Sketch) recomputeShaderTraits() {
 			continue
 		}
 		switch u.Name {
-		case "Time", "Tick":
+		case "Time", "Tick", "AudioBands", "AudioLevel", "AudioPulse", "AudioTime":
 			s.shaderAnimates = true
 		case "Mouse":
 			s.shaderUsesMouse = true
@@ -417,6 +417,19 @@ func (s *Sketch) buildUniformsFor(uniforms []shaderUniform, w, h int) map[string
 			// Index of the current state-pass iteration within this tick
 			// (0 when Steps=1). Declared //sketchy:none on the state shader.
 			m[u.Name] = s.stateSubstep
+		case "AudioBands":
+			a := s.Audio()
+			bands := make([]float32, AudioBandCount)
+			for i, v := range a.Bands {
+				bands[i] = float32(v)
+			}
+			m[u.Name] = bands
+		case "AudioLevel":
+			m[u.Name] = s.Audio().Level
+		case "AudioPulse":
+			m[u.Name] = s.Audio().Pulse
+		case "AudioTime":
+			m[u.Name] = s.Audio().Time
 		}
 	}
 	if s.ExtraUniforms != nil {
@@ -440,10 +453,10 @@ func (s *Sketch) uniformControlValue(u shaderUniform) (any, bool) {
 	case "slider":
 		if u.Kind == ukInt {
 			if i, ok := s.intSliderControlMap[key]; ok {
-				return s.IntSliders[i].Val, true
+				return s.intValue(key, &s.IntSliders[i]), true
 			}
 		} else if i, ok := s.floatSliderControlMap[key]; ok {
-			return s.FloatSliders[i].Val, true
+			return s.floatValue(key, &s.FloatSliders[i]), true
 		}
 	case "checkbox":
 		if i, ok := s.toggleControlMap[key]; ok {
@@ -507,7 +520,7 @@ func (s *Sketch) stateSteps() int {
 		}
 		key := controlMapKey(u.Directive.Folder, u.controlName())
 		if i, ok := s.intSliderControlMap[key]; ok {
-			if v := s.IntSliders[i].Val; v >= 1 {
+			if v := s.intValue(key, &s.IntSliders[i]); v >= 1 {
 				return v
 			}
 		}
@@ -597,6 +610,11 @@ func (s *Sketch) CaptureGPUImage() *image.RGBA {
 	if scale <= 0 {
 		scale = 1
 	}
+	return s.captureGPUAt(scale)
+}
+
+// captureGPUAt is CaptureGPUImage at any scale of the sketch size.
+func (s *Sketch) captureGPUAt(scale float64) *image.RGBA {
 	w := int(s.SketchWidth*scale + 0.5)
 	h := int(s.SketchHeight*scale + 0.5)
 	if s.shaderTarget == nil || s.shaderTarget.Bounds().Dx() != w || s.shaderTarget.Bounds().Dy() != h {
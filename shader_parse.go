@@ -20,7 +20,8 @@ const (
 	ukVec2
 	ukVec3
 	ukVec4
-	ukOther // mat2/mat3/mat4, arrays, … — usable only via ExtraUniforms
+	ukFloatArray // [N]float; N is shaderUniform.Len
+	ukOther      // mat2/mat3/mat4, other arrays, … — usable only via ExtraUniforms
 )
 
 var uniformKindNames = map[string]uniformKind{
@@ -32,6 +33,9 @@ var uniformKindNames = map[string]uniformKind{
 }
 
 func (k uniformKind) String() string {
+	if k == ukFloatArray {
+		return "[]float"
+	}
 	for name, kind := range uniformKindNames {
 		if kind == k {
 			return name
@@ -46,6 +50,7 @@ type shaderUniform struct {
 	Directive *uniformDirective // nil = no directive
 	Name      string
 	Kind      uniformKind
+	Len       int // array length, for ukFloatArray
 }
 
 // uniformDirective is a parsed, validated //sketchy: comment.
@@ -80,11 +85,17 @@ var builtinUniformKinds = map[string]uniformKind{
 	"Mouse":      ukVec2,  // cursor in canvas coordinates
 	"Seed":       ukFloat, // RandomSeed
 	"Substep":    ukInt,   // 0..Steps-1 within a tick's state-pass loop
+	// Audio analysis at the playhead (Sketch.Audio); declaring any of them
+	// auto-animates.
+	"AudioBands": ukFloatArray, // [AudioBandCount]float band energies, 0..1
+	"AudioLevel": ukFloat,      // loudness, 0..1
+	"AudioPulse": ukFloat,      // 1 at an onset, halving every 0.1 s
+	"AudioTime":  ukFloat,      // playhead in seconds
 }
 
 func isBuiltinUniform(u shaderUniform) bool {
 	k, ok := builtinUniformKinds[u.Name]
-	return ok && k == u.Kind
+	return ok && k == u.Kind && (k != ukFloatArray || u.Len == AudioBandCount)
 }
 
 var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
@@ -108,11 +119,21 @@ func parseShaderUniforms(src []byte) ([]shaderUniform, error) {
 			if !ok {
 				continue
 			}
-			kind := ukOther
-			if id, ok := vs.Type.(*ast.Ident); ok {
-				if k, known := uniformKindNames[id.Name]; known {
+			kind, n := ukOther, 0
+			switch t := vs.Type.(type) {
+			case *ast.Ident:
+				if k, known := uniformKindNames[t.Name]; known {
 					kind = k
 				}
+			case *ast.ArrayType:
+				// Only [N]float with a literal N, as the Audio builtins use.
+				id, isIdent := t.Elt.(*ast.Ident)
+				lit, isLit := t.Len.(*ast.BasicLit)
+				if isIdent && id.Name == "float" && isLit && lit.Kind == token.INT {
+					if v, err := strconv.Atoi(lit.Value); err == nil && v > 0 {
+						kind, n = ukFloatArray, v
+					}
+				}
 			}
 			directive, err := directiveFromComment(vs.Comment)
 			if err != nil {
@@ -122,7 +143,7 @@ func parseShaderUniforms(src []byte) ([]shaderUniform, error) {
 				return nil, fmt.Errorf("//sketchy: directive on multi-name declaration %q — declare one uniform per line", vs.Names[0].Name)
 			}
 			for _, name := range vs.Names {
-				u := shaderUniform{Name: name.Name, Kind: kind, Directive: directive}
+				u := shaderUniform{Name: name.Name, Kind: kind, Len: n, Directive: directive}
 				if directive != nil {
 					if err := validateDirective(&u); err != nil {
 						return nil, fmt.Errorf("uniform %s: %w", u.Name, err)
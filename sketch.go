@@ -8,7 +8,10 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aldernero/debugui"
@@ -63,12 +66,50 @@ type (
 type SaveRequest struct {
 	// Pixels is a pre-captured frame (shader sketches: GPU output must be
 	// read back on the ebiten thread, so the capture happens at enqueue
-	// time and the worker only encodes). When set, Format must be "png".
-	Pixels   *image.RGBA
+	// time and the worker only encodes). It is written in the format
+	// RelPath's extension names (see EnqueueSavePixels).
+	Pixels   image.Image
 	RelPath  string // e.g. saves/png/foo.png
-	Format   string // "png" or "svg"
+	Format   string // "png", "png16", "tiff", "exr", "svg", "pdf", "gcode" or "hpgl"
 	DPI      float64
 	RecordDB bool
+	// SVGLayers splits an SVG save into Inkscape layers (G-code and HP-GL:
+	// pen changes), and SplitLayers also writes each layer of an SVG as its
+	// own file beside it (see layers.go).
+	SVGLayers   SVGLayerMode
+	SplitLayers bool
+	// OptimizePaths optimizes a vector save for pen plotters (see
+	// PlotOptions.OptimizePaths).
+	OptimizePaths bool
+	// HatchFills hatches the fills of a vector save (see
+	// PlotOptions.HatchFills).
+	HatchFills bool
+	// HideOccluded removes hidden lines from a vector save (see
+	// PlotOptions.HideOccluded).
+	HideOccluded bool
+	// PDFPage is the paper preset of a PDF save (see Sketch.PDFPage).
+	PDFPage string
+	// plot is Sketch.Plot at enqueue time, for the vector saves.
+	plot PlotOptions
+	// snapshot, when set with RecordDB, is inserted as a snapshot row
+	// linked to this save once it is written (seed sweeps).
+	snapshot *snapshotRecord
+	// meta is the state captured at enqueue time, embedded in PNG and SVG
+	// saves (metadata.go).
+	meta *saveMetadata
+}
+
+func (req SaveRequest) svgOptions() svgSaveOptions {
+	return svgSaveOptions{layers: req.SVGLayers, split: req.SplitLayers, optimize: req.OptimizePaths, hatch: req.HatchFills,
+		occlude: req.HideOccluded, plot: req.plot}
+}
+
+// snapshotRecord is a snapshot row waiting on its PNG save's id.
+type snapshotRecord struct {
+	name        string
+	description string
+	controlJSON string
+	builtinJSON string
 }
 
 type Sketch struct {
@@ -107,8 +148,15 @@ type Sketch struct {
 	// recorder keeps the current frame's draw operations so saves can
 	// replay the exact displayed frame into a PNG raster (at any DPI) or an
 	// SVG without re-running Drawer.
-	recorder     *render.Recorder
-	ctx          *render.Context
+	recorder *render.Recorder
+	ctx      *render.Context
+	// baseLayer records what the Drawer draws on ctx, and layers what it
+	// draws through Sketch.Layer, for layered SVG saves (layers.go).
+	// frameRaster is the current frame's raster target, nil when recording
+	// only.
+	baseLayer    *render.Recorder
+	layers       []sketchLayer
+	frameRaster  *render.Raster
 	saveRequests chan SaveRequest
 	db           *sketchdb.DB
 
@@ -149,6 +197,12 @@ type Sketch struct {
 	dlgSnapshotName        string
 	dlgSnapshotDescription string
 	dlgLoadSelected        string
+	dlgLoadImagePath       string
+	dlgLoadImageErr        string
+	dlgLoadSearch          string
+	dlgLoadEditTags        string
+	dlgBundlePath          string
+	dlgSnapshotTags        string
 	modalHexBuf            string
 	modalErr               string
 
@@ -176,7 +230,10 @@ type Sketch struct {
 	Labels               []Label
 	uiPlan               []controlEntry
 	dlgLoadNames         []string
+	dlgLoadMarks         []string
+	dlgLoadTagNames      []string
 	dlgLoadMissing       []string
+	dlgBundleStatus      []string
 	discretePaletteNames []string
 	sinePaletteNames     []string
 	ShaderSrc            []byte
@@ -209,10 +266,35 @@ type Sketch struct {
 	// RasterDPI sets raster resolution (default 96, where one canvas pixel
 	// matches one logical sketch pixel). The sketch is always displayed at
 	// SketchWidth x SketchHeight; higher DPI affects raster/save detail only.
-	RasterDPI      float64
-	RandomSeed     int64
-	Tick           int64
+	RasterDPI  float64
+	RandomSeed int64
+	Tick       int64
+	// Plot configures the pen-plotter stages of SVG saves (plot.go).
+	Plot PlotOptions
+	// PDFPage names the paper preset of PDF saves ("A4", "Letter", …; see
+	// pdf.go); empty prints the sketch at its own size: its Page, else 96
+	// pixels per inch.
+	// The drawing is scaled to fit inside PDFMargin millimetres, and the
+	// page turns landscape for landscape sketches.
+	PDFPage   string
+	PDFMargin float64
+	// Page is the physical sheet the sketch is drawn for (page.go); New
+	// sizes the sketch from it.
+	Page           Page
+	pageDPI        float64    // sketch pixels per inch of Page; 0 without one
+	pageMM         [2]float64 // Page width and height in mm, as turned
 	uiCaptureState debugui.InputCapturingState
+	// OSCAddr and OSCSendAddr set up remote control over OSC (osc.go);
+	// Init starts the listener.
+	OSCAddr     string
+	OSCSendAddr string
+	osc         *oscServer
+	// HTTPAddr serves the HTTP API (api.go); Init starts the server.
+	HTTPAddr string
+	api      *apiServer
+	// AudioPath is loaded at Init for audio-reactive sketches (audio.go).
+	AudioPath string
+	audio     *audioTrack
 
 	viewportW, viewportH int
 	scrollX, scrollY     float64
@@ -235,6 +317,12 @@ type Sketch struct {
 	sliderRangeEditMinI    int
 	sliderRangeEditMaxI    int
 	sliderRangeEditIncrI   int
+	// Modulator fields of the range modal; sliderRangeEditModIdx indexes
+	// modulatorOptions, 0 being none.
+	sliderRangeEditModIdx   int
+	sliderRangeEditModRate  float64
+	sliderRangeEditModDepth float64
+	sliderRangeEditModPhase float64
 
 	// builtinSeedInt mirrors RandomSeed for the Builtins NumberField (debugui uses *int).
 	builtinSeedInt int
@@ -259,6 +347,22 @@ type Sketch struct {
 	recModulus   int
 	recScaleIdx  int
 	saveMutex    sync.Mutex
+	// plotStatus is the last optimized SVG save's travel report (string),
+	// written by the save worker.
+	plotStatus atomic.Value
+	// tileStatus is the running or last tiled PNG save's progress (string),
+	// written by the save worker.
+	tileStatus atomic.Value
+	// frameGen counts renderFrame rebuilds, so the plot estimate waits for
+	// a first frame; plotEstimate holds the last *plotEstimate and
+	// plotEstimating is set while one is measured. After a control change
+	// plotEstimateStale is set and the estimate is redone at tick
+	// plotEstimateAt (estimate.go).
+	frameGen          atomic.Uint64
+	plotEstimate      atomic.Value
+	plotEstimating    atomic.Bool
+	plotEstimateStale bool
+	plotEstimateAt    int64
 
 	// DisableClearBetweenFrames keeps the previous frame's raster under each
 	// new frame so strokes accumulate on screen; Clear() wipes to
@@ -278,20 +382,70 @@ type Sketch struct {
 	DidColorPickersChange bool
 	DidDropdownsChange    bool
 	DidTextBoxesChange    bool
-	needToClear           bool
-	showDebugUI           bool
-	dirty                 bool
+	// DidModulatorsChange reports that a modulator moved a slider's value
+	// this tick (modulator.go); the slider's own value is unchanged.
+	DidModulatorsChange bool
+	needToClear         bool
+	showDebugUI         bool
+	dirty               bool
 
 	dlgSaveImageOpen bool
 	dlgSavePNG       bool
+	dlgPNGScale      float64
 	dlgSaveSVG       bool
+	dlgSaveGCode     bool
+	dlgSaveHPGL      bool
+	dlgSavePDF       bool
+	// dlgRasterFormatIdx indexes rasterFormats.
+	dlgRasterFormatIdx int
+	// SVG layering for the Save Image and Take Snapshot dialogs.
+	svgLayerModeIdx int
+	svgSplitLayers  bool
 
 	dlgSnapshotOpen bool
 	dlgSnapshotPNG  bool
 	dlgSnapshotSVG  bool
+	dlgSnapshotPDF  bool
+	// Take Snapshot labels and Load Snapshot filters (snapshot_ui.go).
+	dlgSnapshotFavorite bool
+	dlgSnapshotRating   int
+	dlgLoadFavorites    bool
+	dlgLoadMinRating    int
+	dlgLoadTagIdx       int
+	dlgLoadSortIdx      int
+	dlgLoadShown        int // gallery thumbnails shown, a page at a time
+	dlgBundleImages     bool
+	// thumbs caches Load Snapshot thumbnails by snapshot name; nil for one
+	// that could not be made (snapshot_thumbs.go).
+	thumbs map[string]*ebiten.Image
 
 	dlgLoadOpen bool
 
+	// Compare Snapshots dialog (snapshot_diff_ui.go). diffSources index 0
+	// is the live state; diffPick parallels diffs.
+	dlgDiffOpen        bool
+	diffSources        []string
+	diffAIdx, diffBIdx int
+	diffs              []stateDiff
+	diffPick           []bool
+	diffStatus         string
+
+	// Seed sweep dialog (sweep_ui.go).
+	dlgSweepOpen bool
+	sweepModeIdx int
+	sweepStart   int
+	sweepCount   int
+	sweepColumns int
+	sweepStatus  string
+
+	// Parameter sweep dialog (sweep_ui.go). psControls lists the sweepable
+	// sliders; psYIdx 0 is "(none)", so Y entries are offset by one.
+	dlgParamSweepOpen bool
+	psControls        []SweepAxis
+	psLabels          []string
+	psXIdx, psYIdx    int
+	psX, psY          SweepAxis
+
 	sliderRangeModalOpen  bool
 	sliderRangeModalFloat bool // true = FloatSliders[idx], false = IntSliders[idx]
 	shaderAnimates        bool // Time or Tick declared (or StatePath set): dirty every tick
@@ -304,6 +458,13 @@ type Sketch struct {
 	// Primary mouse edge (see refreshPrimaryMouseEdge): avoids relying on inpututil JustPressed tick matching.
 	sketchPrimaryMouseDown     bool
 	sketchPrimaryMouseJustDown bool
+
+	// history is the undo/redo history of control edits (history.go).
+	history controlHistory
+	// timeline animates controls from keyframes (timeline.go).
+	timeline timeline
+	// modulators oscillate sliders by control key (modulator.go).
+	modulators map[string]*Modulator
 }
 
 // Width is the drawing surface width in pixels (same as SketchWidth).
@@ -347,6 +508,65 @@ func (s *Sketch) WindowSize() (w, h int) {
 }
 
 func (s *Sketch) Init() {
+	if os.Getenv(EnvRenderOut) != "" {
+		log.Fatal("sketchy: this sketch doesn't support headless rendering; add the sketchy.HeadlessFromEnv block from the sketch template to its main.go")
+	}
+	s.initDefaults()
+	s.initShader()
+	s.initState()
+
+	dbPath := filepath.Join(s.workDir, "sketch.db")
+	if s.db != nil { // Init() may run more than once
+		if cerr := s.db.Close(); cerr != nil {
+			log.Printf("sketchy: close %s: %v", dbPath, cerr)
+		}
+		s.db = nil
+	}
+	if db, derr := sketchdb.Open(dbPath); derr != nil {
+		log.Printf("sketchy: could not open %s: %v", dbPath, derr)
+	} else {
+		s.db = db
+		if merr := db.InitMetadata(s.Title, s.workDir); merr != nil {
+			log.Printf("sketchy: metadata init: %v", merr)
+		}
+	}
+
+	s.StopRecording() // Init() may run more than once; never record across a re-init
+	if s.recFPS == 0 {
+		s.recFPS = recordDefaultFPS
+		s.recFrames = 300
+		s.recModulus = 600
+	}
+	s.showDebugUI = true
+
+	s.offscreen = ebiten.NewImage(int(s.SketchWidth), int(s.SketchHeight))
+	if s.saveRequests == nil { // keep a single worker across repeated Init()
+		s.saveRequests = make(chan SaveRequest, SaveChannelBuffer)
+		go s.saveWorker()
+	}
+	if s.OSCAddr != "" && s.osc == nil { // likewise a single OSC listener
+		if err := s.startOSC(); err != nil {
+			log.Printf("sketchy: osc: %v", err)
+		}
+	}
+	if s.HTTPAddr != "" && (s.api == nil || s.api.srv == nil) {
+		if err := s.startAPI(); err != nil {
+			log.Printf("sketchy: http: %v", err)
+		}
+	}
+
+	if os.Getenv("EBITEN_SCREENSHOT_KEY") == "" {
+		if err := os.Setenv("EBITEN_SCREENSHOT_KEY", "escape"); err != nil {
+			log.Fatal("error while setting ebiten screenshot key: ", err)
+		}
+	}
+
+	s.applyDebugUITheme()
+}
+
+// initDefaults resolves the working directory, loads image assets, and fills
+// in zero-valued configuration. Shared by Init and InitHeadless.
+func (s *Sketch) initDefaults() {
 	wd, err := os.Getwd()
 	if err != nil {
 		log.Fatal(err)
@@ -356,6 +576,11 @@ func (s *Sketch) Init() {
 		log.Fatalf("sketchy: %v", err)
 	}
 	s.loadImages()
+	if s.AudioPath != "" && (s.audio == nil || s.audio.path != s.AudioPath) {
+		if err := s.LoadAudio(s.AudioPath); err != nil {
+			log.Fatalf("sketchy: audio: %v", err)
+		}
+	}
 
 	if s.Title == "" {
 		s.Title = DefaultTitle
@@ -396,57 +621,23 @@ func (s *Sketch) Init() {
 	if s.DefaultStrokeWidth <= 0 {
 		s.DefaultStrokeWidth = 1
 	}
+}
 
-	s.initShader()
+// initState registers the controls and resets the per-run render state
+// (RNG, recorder, canvas context). It never touches ebiten, so it is shared
+// by Init and InitHeadless.
+func (s *Sketch) initState() {
 	s.rebuildControls()
-
 	s.initPaletteDB()
 
-	dbPath := filepath.Join(s.workDir, "sketch.db")
-	if s.db != nil { // Init() may run more than once
-		if cerr := s.db.Close(); cerr != nil {
-			log.Printf("sketchy: close %s: %v", dbPath, cerr)
-		}
-		s.db = nil
-	}
-	if db, derr := sketchdb.Open(dbPath); derr != nil {
-		log.Printf("sketchy: could not open %s: %v", dbPath, derr)
-	} else {
-		s.db = db
-		if merr := db.InitMetadata(s.Title, s.workDir); merr != nil {
-			log.Printf("sketchy: metadata init: %v", merr)
-		}
-	}
-
 	s.Rand = gaul.NewRng(s.RandomSeed)
 	s.builtinSeedInt = int(s.RandomSeed)
 	s.syncExportScaleIdxFromDPI()
-	s.StopRecording() // Init() may run more than once; never record across a re-init
-	if s.recFPS == 0 {
-		s.recFPS = recordDefaultFPS
-		s.recFrames = 300
-		s.recModulus = 600
-	}
 	s.recorder = render.NewRecorder(s.SketchWidth, s.SketchHeight)
 	s.needToClear = true
-	s.showDebugUI = true
 	s.dirty = true
 	s.colorModalIdx = -1
-
-	s.offscreen = ebiten.NewImage(int(s.SketchWidth), int(s.SketchHeight))
 	s.ctx = render.NewContext(s.recorder)
-	if s.saveRequests == nil { // keep a single worker across repeated Init()
-		s.saveRequests = make(chan SaveRequest, SaveChannelBuffer)
-		go s.saveWorker()
-	}
-
-	if os.Getenv("EBITEN_SCREENSHOT_KEY") == "" {
-		if err := os.Setenv("EBITEN_SCREENSHOT_KEY", "escape"); err != nil {
-			log.Fatal("error while setting ebiten screenshot key: ", err)
-		}
-	}
-
-	s.applyDebugUITheme()
 }
 
 // rebuildControls re-registers every control from scratch: the user's
@@ -479,14 +670,15 @@ func (s *Sketch) rebuildControls() {
 	s.uiFolders = buildFolderPlan(s.uiPlan)
 }
 
-// GetFloat returns a float slider value in folder (use "" for root).
+// GetFloat returns a float slider value in folder (use "" for root),
+// modulated if the slider has a [Modulator].
 func (s *Sketch) GetFloat(folder, name string) float64 {
 	k := controlMapKey(folder, name)
 	i, ok := s.floatSliderControlMap[k]
 	if !ok {
 		log.Fatalf("%q is not a float slider", k)
 	}
-	return s.FloatSliders[i].Val
+	return s.floatValue(k, &s.FloatSliders[i])
 }
 
 // SetFloat sets a float slider value.
@@ -499,14 +691,15 @@ func (s *Sketch) SetFloat(folder, name string, v float64) {
 	s.FloatSliders[i].Val = v
 }
 
-// GetInt returns an int slider value in folder (use "" for root).
+// GetInt returns an int slider value in folder (use "" for root),
+// modulated if the slider has a [Modulator].
 func (s *Sketch) GetInt(folder, name string) int {
 	k := controlMapKey(folder, name)
 	i, ok := s.intSliderControlMap[k]
 	if !ok {
 		log.Fatalf("%q is not an int slider", k)
 	}
-	return s.IntSliders[i].Val
+	return s.intValue(k, &s.IntSliders[i])
 }
 
 // SetInt sets an int slider value (clamped to min/max on next UI sync; immediate assign here).
@@ -708,6 +901,15 @@ func (s *Sketch) UpdateControls() {
 	if ctrlDown && inpututil.IsKeyJustPressed(ebiten.KeyR) {
 		s.toggleRecordingHotkey()
 	}
+	// Ctrl+Z / Ctrl+Shift+Z undo and redo control edits (history.go),
+	// except while a text field has focus.
+	if ctrlDown && !s.InputCaptured() && inpututil.IsKeyJustPressed(ebiten.KeyZ) {
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			s.Redo()
+		} else {
+			s.Undo()
+		}
+	}
 
 	if s.showDebugUI && s.uiCaptureState == 0 {
 		_, dy := ebiten.Wheel()
@@ -768,6 +970,7 @@ func (s *Sketch) UpdateControls() {
 		s.DidControlsChange = true
 		s.dirty = true
 	}
+	s.updateModulators()
 }
 
 // InputCaptured reports whether a control-panel widget currently has keyboard
@@ -876,10 +1079,22 @@ func (s *Sketch) Update() error {
 	} else {
 		s.uiCaptureState = 0
 	}
+	// Remote edits land before UpdateControls, so it detects and flags them
+	// as it does panel edits.
+	s.updateOSC()
+	s.updateAPI()
+	s.updateAudio(true)
+	s.updateTimeline()
 	s.UpdateControls()
 	if s.Updater != nil {
 		s.Updater(s)
 	}
+	s.trackHistory()
+	s.updatePlotEstimate()
+	// Publishing after the Updater sends out the changes the sketch makes
+	// itself too.
+	s.publishOSC()
+	s.publishAPI()
 	if ok, _, _ := s.PrimaryPointerPressInSketch(); ok {
 		s.MarkDirty()
 	}
@@ -964,6 +1179,7 @@ func (s *Sketch) Draw(screen *ebiten.Image) {
 	s.DidColorPickersChange = false
 	s.DidDropdownsChange = false
 	s.DidTextBoxesChange = false
+	s.DidModulatorsChange = false
 }
 
 // renderFrame rebuilds the current frame: it re-records the drawing (for
@@ -976,6 +1192,7 @@ func (s *Sketch) renderFrame() *image.RGBA {
 	s.saveMutex.Lock()
 	defer s.saveMutex.Unlock()
 	s.recorder.Reset()
+	s.frameGen.Add(1)
 
 	dpi := s.RasterDPI
 	if s.PreviewMode {
@@ -999,7 +1216,7 @@ func (s *Sketch) renderFrame() *image.RGBA {
 	}
 	ras := render.NewRasterFromImage(s.rasterBuf)
 	ras.SetScale(scale)
-	s.ctx = render.NewContext(s.recorder, ras)
+	s.ctx = s.newFrameContext(ras)
 
 	if clearFrame {
 		s.ctx.Clear(s.DefaultBackground)
@@ -1111,8 +1328,11 @@ func (s *Sketch) pressInSketchIgnoringPanel(px, py float64) bool {
 	return true
 }
 
+// CanvasRect is the drawable area in sketch pixels: the whole sketch, inset
+// on every side by the Page margin when a page is set.
 func (s *Sketch) CanvasRect() gaul.Rect {
-	return gaul.Rect{X: 0, Y: 0, W: s.Width(), H: s.Height()}
+	m := s.marginPx()
+	return gaul.Rect{X: m, Y: m, W: s.Width() - 2*m, H: s.Height() - 2*m}
 }
 
 func (s *Sketch) RandomWidth() float64 {
@@ -1137,6 +1357,13 @@ func (s *Sketch) IsMouseOverControlPanel() bool {
 	return s.uiCaptureState&debugui.InputCapturingStateHover != 0
 }
 
+// SetRandomSeed reseeds Rand with v, as the Builtins Seed field does. Unlike
+// RandomSeed before Init, 0 is a seed like any other. Call it after Init or
+// InitHeadless.
+func (s *Sketch) SetRandomSeed(v int64) {
+	s.setRandomSeed(v)
+}
+
 func (s *Sketch) setRandomSeed(v int64) {
 	s.RandomSeed = v
 	s.builtinSeedInt = int(v)
@@ -1171,71 +1398,186 @@ func (s *Sketch) MarkDirty() {
 
 func (s *Sketch) EnqueueSave(relPath, format string, dpi float64, recordDB bool) {
 	select {
-	case s.saveRequests <- SaveRequest{RelPath: relPath, Format: format, DPI: dpi, RecordDB: recordDB}:
+	case s.saveRequests <- SaveRequest{RelPath: relPath, Format: format, DPI: dpi, RecordDB: recordDB,
+		OptimizePaths: s.Plot.OptimizePaths, HatchFills: s.Plot.HatchFills, HideOccluded: s.Plot.HideOccluded,
+		PDFPage: s.PDFPage, plot: s.Plot.snapshot(), meta: s.newSaveMetadata()}:
 		fmt.Println("Queued save:", relPath)
 	default:
 		fmt.Println("Save queue full, skipping save")
 	}
 }
 
-// EnqueueSavePixels queues an async PNG encode of an already-captured
-// frame. Used by shader sketches, whose pixels are read back from the GPU
-// on the ebiten thread before enqueueing.
-func (s *Sketch) EnqueueSavePixels(relPath string, img *image.RGBA, recordDB bool) {
+// EnqueueSavePixels queues an async encode of an already-captured frame.
+// Used by shader sketches, whose pixels are read back from the GPU on the
+// ebiten thread before enqueueing. The extension of relPath picks the
+// format: .png (16-bit for an *image.RGBA64 such as CaptureGPUImage16's),
+// .tif for 32-bit float TIFF, or .exr for OpenEXR.
+func (s *Sketch) EnqueueSavePixels(relPath string, img image.Image, recordDB bool) {
 	select {
-	case s.saveRequests <- SaveRequest{RelPath: relPath, Format: "png", RecordDB: recordDB, Pixels: img}:
+	case s.saveRequests <- SaveRequest{RelPath: relPath, Format: pixelFormat(relPath, img), RecordDB: recordDB, Pixels: img,
+		meta: s.newSaveMetadata()}:
 		fmt.Println("Queued save:", relPath)
 	default:
 		fmt.Println("Save queue full, skipping save")
 	}
 }
 
-func (s *Sketch) saveWorker() {
-	for req := range s.saveRequests {
-		full := filepath.Join(s.workDir, filepath.FromSlash(req.RelPath))
-		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
-			fmt.Printf("Error mkdir %s: %v\n", filepath.Dir(full), err)
+// checkSaveName rejects a save or snapshot name that would put its files
+// outside saves/<format>/: one with a path separator, or "." or "..".
+func checkSaveName(name string) error {
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("name %q must not contain a path", name)
+	}
+	return nil
+}
+
+// saveDesign queues a save of the current frame in each of formats, named
+// base (a timestamped name when blank) under saves/<format>/. Raster
+// formats render at scale times the sketch size. GPU frames are read back
+// here, so it must run on the ebiten thread. Used by the Save Image dialog
+// and the HTTP API; it returns the queued paths.
+func (s *Sketch) saveDesign(base string, formats []string, scale float64) ([]string, error) {
+	base = strings.TrimSpace(base)
+	if err := checkSaveName(base); err != nil {
+		return nil, err
+	}
+	if base == "" {
+		base = s.Prefix + "_" + gaul.GetTimestampString()
+	}
+	for _, format := range formats {
+		switch {
+		case slices.Contains(rasterFormats, format):
+		case format == "svg" || format == formatPDF || format == formatGCode || format == formatHPGL:
+			if s.usesGPUCanvas() {
+				return nil, fmt.Errorf("%s is not available for GPU-rendered sketches", format)
+			}
+		default:
+			return nil, fmt.Errorf("unknown format %q", format)
+		}
+	}
+	var rels []string
+	for _, format := range formats {
+		if !slices.Contains(rasterFormats, format) {
+			rel := filepath.ToSlash(filepath.Join("saves", format, base+"."+format))
+			s.enqueueVectorSave(rel, format)
+			rels = append(rels, rel)
 			continue
 		}
-		var err error
+		ext, dir := rasterFileExt(format)
+		rel := filepath.ToSlash(filepath.Join("saves", dir, base+ext))
 		switch {
-		case req.Pixels != nil:
-			err = writePixelsPNG(full, req.Pixels)
-		case req.Format == "png":
-			err = s.renderPNGToFile(full, req.DPI)
-		case req.Format == "svg":
-			if s.IsShaderSketch() {
-				err = fmt.Errorf("SVG export is not available for shader sketches")
-			} else {
-				err = s.renderSVGToFile(full)
-			}
+		// GPU readback must happen here on the ebiten thread; the worker
+		// only encodes.
+		case s.usesGPUCanvas() && format == "png":
+			s.EnqueueSavePixels(rel, s.CaptureGPUImage(), true)
+		case s.usesGPUCanvas():
+			s.EnqueueSavePixels(rel, s.CaptureGPUImage16(), true)
 		default:
-			err = fmt.Errorf("unknown format %q", req.Format)
+			s.EnqueueSave(rel, format, DefaultDPI*scale, true)
 		}
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
 
-		if err != nil {
-			fmt.Printf("Error saving %s: %v\n", full, err)
-			continue
+func (s *Sketch) saveWorker() {
+	for req := range s.saveRequests {
+		s.handleSave(req)
+	}
+}
+
+// handleSave writes one save request and records it in sketch.db.
+func (s *Sketch) handleSave(req SaveRequest) {
+	full := filepath.Join(s.workDir, filepath.FromSlash(req.RelPath))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		fmt.Printf("Error mkdir %s: %v\n", filepath.Dir(full), err)
+		return
+	}
+	var err error
+	var layers []string
+	var layerFiles []savedLayer
+	switch {
+	case req.Pixels != nil:
+		err = writePixels(full, req.Pixels, 0)
+	case req.Format == "png":
+		err = s.renderPNGToFile(full, req.DPI)
+	case req.Format == formatPNG16 || req.Format == formatTIFF || req.Format == formatEXR:
+		err = s.renderDeepToFile(full, req.Format, req.DPI)
+	case req.Format == "svg":
+		if s.IsShaderSketch() {
+			err = fmt.Errorf("SVG export is not available for shader sketches")
+		} else {
+			layers, layerFiles, err = s.renderLayeredSVGToFile(full, req.svgOptions())
 		}
-		fmt.Println("Saved ", full)
-		if req.RecordDB && s.db != nil {
-			if _, err := s.db.InsertSave(req.RelPath, req.Format); err != nil {
-				fmt.Printf("sketch.db insert save: %v\n", err)
-			}
+	case req.Format == formatPDF:
+		err = s.renderPDFToFile(full, req.PDFPage, req.plot)
+	case req.Format == formatGCode || req.Format == formatHPGL:
+		layers, err = s.renderPlotToFile(full, req.Format, req.svgOptions())
+	default:
+		err = fmt.Errorf("unknown format %q", req.Format)
+	}
+
+	if err != nil {
+		fmt.Printf("Error saving %s: %v\n", full, err)
+		return
+	}
+	paths := []string{full}
+	for _, lf := range layerFiles {
+		paths = append(paths, lf.path)
+	}
+	if err := req.meta.embed(paths...); err != nil {
+		fmt.Printf("Error embedding metadata: %v\n", err)
+	}
+	fmt.Println("Saved ", full)
+	for _, lf := range layerFiles {
+		fmt.Println("Saved ", lf.path)
+	}
+	if !req.RecordDB || s.db == nil {
+		return
+	}
+	id, err := s.db.InsertSaveLayers(req.RelPath, req.Format, layers)
+	if err != nil {
+		fmt.Printf("sketch.db insert save: %v\n", err)
+		return
+	}
+	for _, lf := range layerFiles {
+		rel, rerr := filepath.Rel(s.workDir, lf.path)
+		if rerr != nil {
+			rel = lf.path
+		}
+		if _, err := s.db.InsertSaveLayers(filepath.ToSlash(rel), req.Format, []string{lf.name}); err != nil {
+			fmt.Printf("sketch.db insert save: %v\n", err)
+		}
+	}
+	if snap := req.snapshot; snap != nil {
+		if err := s.dbInsertSnapshot(snap.name, snap.description, snap.controlJSON, snap.builtinJSON, &id, nil); err != nil {
+			fmt.Printf("sketch.db insert snapshot: %v\n", err)
 		}
 	}
 }
 
-func (s *Sketch) dbListSnapshots() []string {
+func (s *Sketch) dbListSnapshots(f sketchdb.SnapshotFilter) []sketchdb.SnapshotInfo {
 	if s.db == nil {
 		return nil
 	}
-	names, err := s.db.ListSnapshotNames()
+	list, err := s.db.ListSnapshots(f)
 	if err != nil {
 		fmt.Printf("list snapshots: %v\n", err)
 		return nil
 	}
-	return names
+	return list
+}
+
+func (s *Sketch) dbListTags() []string {
+	if s.db == nil {
+		return nil
+	}
+	tags, err := s.db.ListTags()
+	if err != nil {
+		fmt.Printf("list snapshot tags: %v\n", err)
+		return nil
+	}
+	return tags
 }
 
 func (s *Sketch) dbGetSnapshot(name string) *sketchdb.SnapshotRow {
@@ -1250,6 +1592,101 @@ func (s *Sketch) dbGetSnapshot(name string) *sketchdb.SnapshotRow {
 	return row
 }
 
+// takeSnapshot stores the current state in sketch.db as snapshot name (a
+// timestamped name when blank), writing the chosen images alongside. An
+// image that fails to write is reported and skipped. Used by the Take
+// Snapshot dialog and the HTTP API; it returns the name used.
+func (s *Sketch) takeSnapshot(name, desc string, withPNG, withSVG, withPDF bool) (string, error) {
+	n := strings.TrimSpace(name)
+	if err := checkSaveName(n); err != nil {
+		return n, err
+	}
+	if n == "" {
+		n = s.Prefix + "_snap_" + gaul.GetTimestampString()
+	}
+	data, err := s.serializeControlState()
+	if err != nil {
+		return n, fmt.Errorf("serialize: %w", err)
+	}
+	bdata, err := s.serializeBuiltinState()
+	if err != nil {
+		return n, fmt.Errorf("builtin serialize: %w", err)
+	}
+	meta := s.metadataFor(data, bdata)
+	var pngID, svgID *int64
+	var pngVal, svgVal int64
+	if withPNG {
+		rel := filepath.ToSlash(filepath.Join("saves", "png", n+".png"))
+		full := filepath.Join(s.workDir, filepath.FromSlash(rel))
+		if err := s.writeSnapshotPNG(full, meta); err != nil {
+			fmt.Println("snapshot png:", err)
+		} else if s.db != nil {
+			id, ierr := s.db.InsertSave(rel, "png")
+			if ierr != nil {
+				fmt.Println("snapshot db png:", ierr)
+			} else {
+				pngVal = id
+				pngID = &pngVal
+			}
+		}
+	}
+	if withSVG && !s.usesGPUCanvas() {
+		rel := filepath.ToSlash(filepath.Join("saves", "svg", n+".svg"))
+		full := filepath.Join(s.workDir, filepath.FromSlash(rel))
+		if layers, err := s.writeLayeredSVG(full, meta); err != nil {
+			fmt.Println("snapshot svg:", err)
+		} else if s.db != nil {
+			id, ierr := s.db.InsertSaveLayers(rel, "svg", layers)
+			if ierr != nil {
+				fmt.Println("snapshot db svg:", ierr)
+			} else {
+				svgVal = id
+				svgID = &svgVal
+			}
+		}
+	}
+	var pdfID int64
+	if withPDF && !s.usesGPUCanvas() {
+		rel := filepath.ToSlash(filepath.Join("saves", "pdf", n+".pdf"))
+		full := filepath.Join(s.workDir, filepath.FromSlash(rel))
+		if err := s.writeSnapshotPDF(full); err != nil {
+			fmt.Println("snapshot pdf:", err)
+		} else if s.db != nil {
+			id, ierr := s.db.InsertSave(rel, formatPDF)
+			if ierr != nil {
+				fmt.Println("snapshot db pdf:", ierr)
+			} else {
+				pdfID = id
+			}
+		}
+	}
+	if err := s.dbInsertSnapshot(n, strings.TrimSpace(desc), string(data), string(bdata), pngID, svgID); err != nil {
+		return n, err
+	}
+	if pdfID != 0 {
+		if err := s.db.SetSnapshotPDF(n, pdfID); err != nil {
+			fmt.Println("snapshot db pdf:", err)
+		}
+	}
+	log.Printf("sketchy: saved snapshot %q", n)
+	return n, nil
+}
+
+// labelSnapshot sets a snapshot's tags, rating and favorite flag, as the
+// Take Snapshot dialog and the HTTP API do right after takeSnapshot.
+func (s *Sketch) labelSnapshot(name string, tags []string, rating int, favorite bool) error {
+	if s.db == nil {
+		return fmt.Errorf("no database")
+	}
+	if err := s.db.SetSnapshotRating(name, rating); err != nil {
+		return err
+	}
+	if err := s.db.SetSnapshotTags(name, tags); err != nil {
+		return err
+	}
+	return s.db.SetSnapshotFavorite(name, favorite)
+}
+
 func (s *Sketch) dbInsertSnapshot(name, description, controlJSON, builtinJSON string, pngID, svgID *int64) error {
 	if s.db == nil {
 		return fmt.Errorf("no database")
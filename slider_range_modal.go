@@ -7,6 +7,54 @@ import (
 	"github.com/aldernero/debugui"
 )
 
+// modulatorOptions is the range modal's Modulator dropdown: none, then the
+// ModShape labels.
+var modulatorOptions = append([]string{"Off"}, modShapeLabels...)
+
+// openModulatorFields loads the range modal's modulator fields from the
+// slider's modulator, or defaults for a new one.
+func (s *Sketch) openModulatorFields(key string) {
+	s.sliderRangeEditModIdx = 0
+	s.sliderRangeEditModRate, s.sliderRangeEditModDepth, s.sliderRangeEditModPhase = 0.5, 0.25, 0
+	if m := s.modulators[key]; m != nil {
+		s.sliderRangeEditModIdx = int(m.Shape) + 1
+		s.sliderRangeEditModRate, s.sliderRangeEditModDepth, s.sliderRangeEditModPhase = m.Rate, m.Depth, m.Phase
+	}
+}
+
+// applyModulatorFields attaches, replaces or removes the slider's
+// modulator from the range modal's fields, reporting false (with the
+// modal error set) if they are invalid.
+func (s *Sketch) applyModulatorFields(key string) bool {
+	if s.sliderRangeEditModIdx == 0 {
+		if _, ok := s.modulators[key]; ok {
+			delete(s.modulators, key)
+			s.DidSlidersChange, s.DidControlsChange = true, true
+			s.history.pending = true
+		}
+		return true
+	}
+	if s.sliderRangeEditModRate < 0 {
+		s.sliderRangeModalErr = "rate must be >= 0"
+		return false
+	}
+	if s.sliderRangeEditModDepth < 0 || s.sliderRangeEditModDepth > 1 {
+		s.sliderRangeModalErr = "depth must be between 0 and 1"
+		return false
+	}
+	m := Modulator{
+		Shape: ModShape(s.sliderRangeEditModIdx - 1),
+		Rate:  s.sliderRangeEditModRate,
+		Depth: s.sliderRangeEditModDepth,
+		Phase: s.sliderRangeEditModPhase,
+	}
+	if old := s.modulators[key]; old == nil || old.Shape != m.Shape || old.Rate != m.Rate || old.Depth != m.Depth || old.Phase != m.Phase {
+		s.setModulator(key, m)
+		s.history.pending = true
+	}
+	return true
+}
+
 func (s *Sketch) openFloatSliderRangeModal(idx int) {
 	if idx < 0 || idx >= len(s.FloatSliders) {
 		return
@@ -19,6 +67,7 @@ func (s *Sketch) openFloatSliderRangeModal(idx int) {
 	s.sliderRangeEditMaxF = sl.MaxVal
 	s.sliderRangeEditIncrF = sl.Incr
 	s.sliderRangeModalErr = ""
+	s.openModulatorFields(controlMapKey(sl.Folder, sl.Name))
 }
 
 func (s *Sketch) openIntSliderRangeModal(idx int) {
@@ -33,6 +82,7 @@ func (s *Sketch) openIntSliderRangeModal(idx int) {
 	s.sliderRangeEditMaxI = sl.MaxVal
 	s.sliderRangeEditIncrI = sl.Incr
 	s.sliderRangeModalErr = ""
+	s.openModulatorFields(controlMapKey(sl.Folder, sl.Name))
 }
 
 func (s *Sketch) closeSliderRangeModal() {
@@ -58,6 +108,9 @@ func (s *Sketch) applyFloatSliderRangeOK() {
 		return
 	}
 	sl := &s.FloatSliders[i]
+	if !s.applyModulatorFields(controlMapKey(sl.Folder, sl.Name)) {
+		return
+	}
 	sl.MinVal = min
 	sl.MaxVal = max
 	sl.Incr = incr
@@ -86,6 +139,9 @@ func (s *Sketch) applyIntSliderRangeOK() {
 		return
 	}
 	sl := &s.IntSliders[i]
+	if !s.applyModulatorFields(controlMapKey(sl.Folder, sl.Name)) {
+		return
+	}
 	sl.MinVal = min
 	sl.MaxVal = max
 	sl.Incr = incr
@@ -114,7 +170,7 @@ func (s *Sketch) drawSliderRangeModal(ctx *debugui.Context) {
 		title = s.IntSliders[s.sliderRangeModalIdx].Name
 	}
 
-	ctx.Window("Slider range", image.Rect(240, 120, 540, 340), func(layout debugui.ContainerLayout) {
+	ctx.Window("Slider range", image.Rect(240, 120, 540, 470), func(layout debugui.ContainerLayout) {
 		ctx.BringRootContainerToFront()
 		ctx.SetGridLayout([]int{-1}, nil)
 		ctx.Text(fmt.Sprintf("%s - min, max, step", title))
@@ -149,6 +205,28 @@ func (s *Sketch) drawSliderRangeModal(ctx *debugui.Context) {
 			})
 		}
 
+		ctx.SetGridLayout([]int{-1}, nil)
+		ctx.Text("Modulator - shape, rate (Hz), depth, phase")
+		ctx.SetGridLayout([]int{40, -1}, nil)
+		ctx.Text("Shape")
+		ctx.IDScope("srmshape", func() {
+			ctx.Dropdown(&s.sliderRangeEditModIdx, modulatorOptions)
+		})
+		if s.sliderRangeEditModIdx > 0 {
+			ctx.Text("Rate")
+			ctx.IDScope("srmrate", func() {
+				ctx.NumberFieldF(&s.sliderRangeEditModRate, 0.05, 3).On(func() {})
+			})
+			ctx.Text("Depth")
+			ctx.IDScope("srmdepth", func() {
+				ctx.NumberFieldF(&s.sliderRangeEditModDepth, 0.05, 3).On(func() {})
+			})
+			ctx.Text("Phase")
+			ctx.IDScope("srmphase", func() {
+				ctx.NumberFieldF(&s.sliderRangeEditModPhase, 0.05, 3).On(func() {})
+			})
+		}
+
 		if s.sliderRangeModalErr != "" {
 			ctx.SetGridLayout([]int{-1}, nil)
 			ctx.Text(s.sliderRangeModalErr)
@@ -7,20 +7,23 @@ import (
 	"strings"
 )
 
-const snapshotSchemaVersion = 3
+const snapshotSchemaVersion = 5
 
 // snapshotPayload is stored in sqlite control_json.
 // Schema 1 had only "sliders" (float). Schema 2 adds "int_sliders" for IntSlider values.
-// Schema 3 adds "texts" for TextBox values. Older rows simply lack the newer
-// keys, so loading them still works.
+// Schema 3 adds "texts" for TextBox values. Schema 4 adds "timeline" for
+// keyframes (timeline.go), and schema 5 "modulators" (modulator.go). Older
+// rows simply lack the newer keys, so loading them still works.
 type snapshotPayload struct {
-	Sliders    map[string]float64 `json:"sliders,omitempty"`
-	IntSliders map[string]int     `json:"int_sliders,omitempty"`
-	Toggles    map[string]bool    `json:"toggles"`
-	Colors     map[string]string  `json:"colors"`
-	Dropdowns  map[string]int     `json:"dropdowns"`
-	Texts      map[string]string  `json:"texts,omitempty"`
-	Schema     int                `json:"_schema"`
+	Sliders    map[string]float64   `json:"sliders,omitempty"`
+	IntSliders map[string]int       `json:"int_sliders,omitempty"`
+	Toggles    map[string]bool      `json:"toggles"`
+	Colors     map[string]string    `json:"colors"`
+	Dropdowns  map[string]int       `json:"dropdowns"`
+	Texts      map[string]string    `json:"texts,omitempty"`
+	Timeline   *timelinePayload     `json:"timeline,omitempty"`
+	Modulators map[string]Modulator `json:"modulators,omitempty"`
+	Schema     int                  `json:"_schema"`
 }
 
 func controlMapKey(folder, name string) string {
@@ -86,6 +89,8 @@ func (s *Sketch) serializeControlState() ([]byte, error) {
 			p.Texts[k] = s.TextBoxes[i].Val
 		}
 	}
+	p.Timeline = s.timeline.payload()
+	p.Modulators = s.modulatorsPayload()
 	return json.Marshal(p)
 }
 
@@ -139,6 +144,8 @@ func (s *Sketch) applyControlStateJSON(data []byte) ([]string, error) {
 		}
 		s.DidTextBoxesChange = true
 	}
+	missing = append(missing, s.restoreTimeline(p.Timeline)...)
+	missing = append(missing, s.restoreModulators(p.Modulators)...)
 	s.syncControlLastState()
 	s.syncBuiltinDefaultsFromColorPickers()
 	s.DidControlsChange = true
@@ -272,6 +279,18 @@ func (s *Sketch) snapshotKeysPresentInJSON(data []byte) (missing []string, err e
 	for k := range p.Texts {
 		check(k)
 	}
+	if p.Timeline != nil {
+		for k := range p.Timeline.Tracks {
+			if !s.isAnimatable(k) {
+				missing = append(missing, k)
+			}
+		}
+	}
+	for k := range p.Modulators {
+		if !s.isModulatable(k) {
+			missing = append(missing, k)
+		}
+	}
 	return missing, nil
 }
 
@@ -0,0 +1,93 @@
+package sketchy
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+// SnapshotImport reports one snapshot of a bundle read by ImportSnapshots.
+type SnapshotImport struct {
+	// Name is the snapshot's name in sketch.db. It is Bundled with a _2,
+	// _3, … suffix when another snapshot already had that name.
+	Name, Bundled string
+	// Skipped reports that sketch.db already held this exact state, so
+	// nothing was added.
+	Skipped bool
+	// Missing lists the snapshot's control keys this sketch has no control
+	// for, as Load Snapshot… reports them.
+	Missing []string
+}
+
+// bundlePath resolves a bundle path against the working directory.
+func (s *Sketch) bundlePath(path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.Join(s.workDir, path)
+	}
+	return path
+}
+
+// ExportSnapshots writes the named snapshots, or all of them when names is
+// empty, to a .sketchy.json bundle at path (relative paths resolve against
+// the working directory). With images, the snapshots' linked PNG, SVG and
+// PDF saves are embedded too, so the bundle stands alone.
+func (s *Sketch) ExportSnapshots(path string, names []string, images bool) error {
+	if s.db == nil {
+		return fmt.Errorf("sketchy: no sketch.db to export from")
+	}
+	b, err := s.db.ExportBundle(s.workDir, names, images)
+	if err != nil {
+		return err
+	}
+	if err := sketchdb.WriteBundle(s.bundlePath(path), b); err != nil {
+		return err
+	}
+	log.Printf("sketchy: exported %d snapshots to %s", len(b.Snapshots), path)
+	return nil
+}
+
+// ImportSnapshots adds the snapshots in a .sketchy.json bundle to sketch.db,
+// leaving the existing ones alone: a taken name gets a numeric suffix, and
+// embedded images are written under saves/ without replacing any file.
+func (s *Sketch) ImportSnapshots(path string) ([]SnapshotImport, error) {
+	if s.db == nil {
+		return nil, fmt.Errorf("sketchy: no sketch.db to import into")
+	}
+	b, err := sketchdb.ReadBundle(s.bundlePath(path))
+	if err != nil {
+		return nil, err
+	}
+	imported, err := s.db.ImportBundle(s.workDir, b)
+	out := make([]SnapshotImport, len(imported))
+	for i, imp := range imported {
+		out[i] = SnapshotImport{Name: imp.Name, Bundled: imp.Bundled, Skipped: imp.Skipped}
+		if miss, merr := s.snapshotKeysPresentInJSON([]byte(imp.ControlJSON)); merr == nil {
+			out[i].Missing = miss
+		}
+	}
+	return out, err
+}
+
+// importSummary describes an import for the Load Snapshot dialog, one line
+// per note.
+func importSummary(imported []SnapshotImport) []string {
+	added, skipped := 0, 0
+	var notes []string
+	for _, imp := range imported {
+		if imp.Skipped {
+			skipped++
+			continue
+		}
+		added++
+		if imp.Name != imp.Bundled {
+			notes = append(notes, fmt.Sprintf("%s imported as %s", imp.Bundled, imp.Name))
+		}
+		if len(imp.Missing) > 0 {
+			notes = append(notes, fmt.Sprintf("%s: unknown keys %s", imp.Name, strings.Join(imp.Missing, ", ")))
+		}
+	}
+	return append([]string{fmt.Sprintf("Imported %d snapshots, %d already present", added, skipped)}, notes...)
+}
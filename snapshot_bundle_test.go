@@ -0,0 +1,64 @@
+package sketchy
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+func TestSnapshotBundleImportReportsMissingControls(t *testing.T) {
+	src := newTextBoxSketch(t, func(ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+		ui.Checkbox("Fill", false)
+	})
+	// The importing sketch has no Fill.
+	dst := newTextBoxSketch(t, func(ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+	})
+	for _, s := range []*Sketch{src, dst} {
+		s.workDir = t.TempDir()
+		db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		s.db = db
+	}
+
+	src.SetFloat("", "Scale", 3)
+	if _, err := src.takeSnapshot("wide", "", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "wide"+sketchdb.BundleExt)
+	if err := src.ExportSnapshots(path, nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := dst.ImportSnapshots(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 || imported[0].Name != "wide" || imported[0].Skipped {
+		t.Fatalf("imported %+v", imported)
+	}
+	if !slices.Equal(imported[0].Missing, []string{"Fill"}) {
+		t.Fatalf("missing %v, want [Fill]", imported[0].Missing)
+	}
+	if row := dst.dbGetSnapshot("wide"); row == nil {
+		t.Fatal("imported snapshot not in sketch.db")
+	}
+
+	// Importing the same bundle again adds nothing.
+	again, err := dst.ImportSnapshots(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 || !again[0].Skipped {
+		t.Fatalf("re-import %+v", again)
+	}
+	if got := importSummary(again); got[0] != "Imported 0 snapshots, 1 already present" {
+		t.Fatalf("summary %q", got)
+	}
+}
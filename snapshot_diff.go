@@ -0,0 +1,281 @@
+package sketchy
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// absentValue stands for a value one of two compared states lacks, e.g. a
+// control added to the sketch after a snapshot was taken.
+const absentValue = "—"
+
+// builtinsSection is the stateDiff section of builtin values.
+const builtinsSection = "builtins"
+
+// stateDiff is one control or builtin value that differs between two states
+// (see diffStates).
+type stateDiff struct {
+	// Section is the snapshotPayload map the value is in (its JSON name,
+	// e.g. "sliders"), "timeline" for a control's keyframes, or
+	// builtinsSection.
+	Section string
+	Key     string
+	// A and B are the two values as shown in the diff table.
+	A, B string
+}
+
+// diffSection compares and copies one part of a snapshotPayload.
+type diffSection struct {
+	name string
+	diff func(a, b *snapshotPayload) []stateDiff
+	// take copies key from src into dst, deleting it from dst if src
+	// lacks it.
+	take func(dst, src *snapshotPayload, key string)
+}
+
+// mapSection is a diffSection over one of the payload's keyed maps, showing
+// values with format.
+func mapSection[V any](name string, field func(*snapshotPayload) *map[string]V, format func(key string, v V) string) diffSection {
+	return diffSection{
+		name: name,
+		diff: func(a, b *snapshotPayload) []stateDiff {
+			ma, mb := *field(a), *field(b)
+			keys := slices.Sorted(maps.Keys(ma))
+			for k := range mb {
+				if _, ok := ma[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			var out []stateDiff
+			for _, k := range keys {
+				va, okA := ma[k]
+				vb, okB := mb[k]
+				if okA == okB && reflect.DeepEqual(va, vb) {
+					continue
+				}
+				d := stateDiff{Section: name, Key: k, A: absentValue, B: absentValue}
+				if okA {
+					d.A = format(k, va)
+				}
+				if okB {
+					d.B = format(k, vb)
+				}
+				out = append(out, d)
+			}
+			return out
+		},
+		take: func(dst, src *snapshotPayload, k string) {
+			m := field(dst)
+			v, ok := (*field(src))[k]
+			if !ok {
+				delete(*m, k)
+				return
+			}
+			if *m == nil {
+				*m = make(map[string]V)
+			}
+			(*m)[k] = v
+		},
+	}
+}
+
+// byValue adapts a formatter that needs no key for mapSection.
+func byValue[V any](f func(V) string) func(string, V) string {
+	return func(_ string, v V) string { return f(v) }
+}
+
+// timelineSettingsKey is the stateDiff key of the timeline's length and
+// loop flag, in the "timeline" section next to the per-control tracks.
+const timelineSettingsKey = "(length, loop)"
+
+// diffSections lists the payload parts diffStates compares, in table order.
+func (s *Sketch) diffSections() []diffSection {
+	tracks := mapSection("timeline", func(p *snapshotPayload) *map[string][]Keyframe { return &p.Timeline.Tracks },
+		byValue(func(keys []Keyframe) string { return fmt.Sprintf("%d keys", len(keys)) }))
+	timeline := diffSection{
+		name: "timeline",
+		diff: func(a, b *snapshotPayload) []stateDiff {
+			out := tracks.diff(a, b)
+			ta, tb := a.Timeline, b.Timeline
+			if ta.Length != tb.Length || ta.Loop != tb.Loop {
+				out = append(out, stateDiff{Section: "timeline", Key: timelineSettingsKey,
+					A: formatTimelineSettings(ta), B: formatTimelineSettings(tb)})
+			}
+			return out
+		},
+		take: func(dst, src *snapshotPayload, k string) {
+			if k == timelineSettingsKey {
+				dst.Timeline.Length, dst.Timeline.Loop = src.Timeline.Length, src.Timeline.Loop
+				return
+			}
+			tracks.take(dst, src, k)
+		},
+	}
+	return []diffSection{
+		mapSection("sliders", func(p *snapshotPayload) *map[string]float64 { return &p.Sliders },
+			byValue(func(v float64) string { return strconv.FormatFloat(v, 'g', 6, 64) })),
+		mapSection("int_sliders", func(p *snapshotPayload) *map[string]int { return &p.IntSliders }, byValue(strconv.Itoa)),
+		mapSection("toggles", func(p *snapshotPayload) *map[string]bool { return &p.Toggles }, byValue(strconv.FormatBool)),
+		mapSection("colors", func(p *snapshotPayload) *map[string]string { return &p.Colors },
+			byValue(func(v string) string { return v })),
+		mapSection("dropdowns", func(p *snapshotPayload) *map[string]int { return &p.Dropdowns }, s.formatDropdown),
+		mapSection("texts", func(p *snapshotPayload) *map[string]string { return &p.Texts }, byValue(strconv.Quote)),
+		timeline,
+		mapSection("modulators", func(p *snapshotPayload) *map[string]Modulator { return &p.Modulators }, byValue(formatModulator)),
+	}
+}
+
+// formatDropdown shows a dropdown index as its option, where the sketch
+// still has one.
+func (s *Sketch) formatDropdown(key string, v int) string {
+	if i, ok := s.dropdownControlMap[key]; ok && v >= 0 && v < len(s.Dropdowns[i].Options) {
+		return s.Dropdowns[i].Options[v]
+	}
+	return strconv.Itoa(v)
+}
+
+func formatTimelineSettings(p *timelinePayload) string {
+	l := "no length"
+	if p.Length > 0 {
+		l = fmt.Sprintf("%d ticks", p.Length)
+	}
+	if p.Loop {
+		return l + ", loop"
+	}
+	return l
+}
+
+func formatModulator(m Modulator) string {
+	shape, _ := m.Shape.MarshalText()
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', 4, 64) }
+	return fmt.Sprintf("%s rate %s depth %s phase %s", shape, f(m.Rate), f(m.Depth), f(m.Phase))
+}
+
+// builtinDiffField is one builtinSnapshotPayload value in a diff.
+type builtinDiffField struct {
+	key string
+	get func(*builtinSnapshotPayload) string
+	// take copies the value from src into dst.
+	take func(dst, src *builtinSnapshotPayload)
+}
+
+var builtinDiffFields = []builtinDiffField{
+	{"background", func(p *builtinSnapshotPayload) string { return p.DefaultBackground },
+		func(d, s *builtinSnapshotPayload) { d.DefaultBackground = s.DefaultBackground }},
+	{"foreground", func(p *builtinSnapshotPayload) string { return p.DefaultForeground },
+		func(d, s *builtinSnapshotPayload) { d.DefaultForeground = s.DefaultForeground }},
+	{"stroke width", func(p *builtinSnapshotPayload) string {
+		return strconv.FormatFloat(p.DefaultStrokeWidthMM, 'g', 6, 64)
+	}, func(d, s *builtinSnapshotPayload) { d.DefaultStrokeWidthMM = s.DefaultStrokeWidthMM }},
+	{"seed", func(p *builtinSnapshotPayload) string { return strconv.FormatInt(p.RandomSeed, 10) },
+		func(d, s *builtinSnapshotPayload) { d.RandomSeed = s.RandomSeed }},
+	{"export scale", func(p *builtinSnapshotPayload) string {
+		if p.ExportScale == 0 {
+			return absentValue
+		}
+		return strconv.FormatFloat(p.ExportScale, 'g', 6, 64) + "×"
+	}, func(d, s *builtinSnapshotPayload) { d.ExportScale = s.ExportScale }},
+	{"discrete palette", func(p *builtinSnapshotPayload) string { return p.DiscretePalette },
+		func(d, s *builtinSnapshotPayload) { d.DiscretePalette = s.DiscretePalette }},
+	{"sine palette", func(p *builtinSnapshotPayload) string { return p.SinePalette },
+		func(d, s *builtinSnapshotPayload) { d.SinePalette = s.SinePalette }},
+}
+
+// decodedState is a historyState's JSON decoded, with the timeline always
+// present so diffs and merges need no nil checks.
+type decodedState struct {
+	controls snapshotPayload
+	builtins builtinSnapshotPayload
+}
+
+func decodeState(st historyState) (decodedState, error) {
+	var d decodedState
+	if err := json.Unmarshal(st.controls, &d.controls); err != nil {
+		return d, fmt.Errorf("controls: %w", err)
+	}
+	if d.controls.Timeline == nil {
+		d.controls.Timeline = &timelinePayload{}
+	}
+	if len(strings.TrimSpace(string(st.builtins))) > 0 {
+		if err := json.Unmarshal(st.builtins, &d.builtins); err != nil {
+			return d, fmt.Errorf("builtins: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// diffStates lists every control and builtin value that differs between a
+// and b: controls section by section in key order, then builtins.
+func (s *Sketch) diffStates(a, b historyState) ([]stateDiff, error) {
+	da, err := decodeState(a)
+	if err != nil {
+		return nil, err
+	}
+	db, err := decodeState(b)
+	if err != nil {
+		return nil, err
+	}
+	var out []stateDiff
+	for _, sec := range s.diffSections() {
+		out = append(out, sec.diff(&da.controls, &db.controls)...)
+	}
+	for _, f := range builtinDiffFields {
+		if va, vb := f.get(&da.builtins), f.get(&db.builtins); va != vb {
+			out = append(out, stateDiff{Section: builtinsSection, Key: f.key, A: va, B: vb})
+		}
+	}
+	return out, nil
+}
+
+// applyStateDiffs sets the values diffs name to their values in from,
+// leaving the rest of the live state as it is. It returns the control keys
+// this sketch no longer has, as applyControlStateJSON does.
+func (s *Sketch) applyStateDiffs(from historyState, diffs []stateDiff) ([]string, error) {
+	cur, ok := s.captureHistoryState()
+	if !ok {
+		return nil, fmt.Errorf("sketchy: cannot serialize the live state")
+	}
+	live, err := decodeState(cur)
+	if err != nil {
+		return nil, err
+	}
+	src, err := decodeState(from)
+	if err != nil {
+		return nil, err
+	}
+	sections := s.diffSections()
+	for _, d := range diffs {
+		if d.Section == builtinsSection {
+			for _, f := range builtinDiffFields {
+				if f.key == d.Key {
+					f.take(&live.builtins, &src.builtins)
+				}
+			}
+			continue
+		}
+		for _, sec := range sections {
+			if sec.name == d.Section {
+				sec.take(&live.controls, &src.controls, d.Key)
+			}
+		}
+	}
+	controls, err := json.Marshal(live.controls)
+	if err != nil {
+		return nil, err
+	}
+	builtins, err := json.Marshal(live.builtins)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := s.applyControlStateJSON(controls)
+	if err != nil {
+		return nil, err
+	}
+	return missing, s.applyBuiltinStateJSON(builtins)
+}
@@ -0,0 +1,100 @@
+package sketchy
+
+import (
+	"slices"
+	"testing"
+)
+
+func captureState(t *testing.T, s *Sketch) historyState {
+	t.Helper()
+	st, ok := s.captureHistoryState()
+	if !ok {
+		t.Fatal("state does not serialize")
+	}
+	return st
+}
+
+func TestDiffStates(t *testing.T) {
+	s := newTextBoxSketch(t, func(ui *UI) {
+		ui.Folder("Noise", func() {
+			ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+		})
+		ui.IntSlider("Count", 0, 10, 5, 1)
+		ui.Checkbox("Fill", false) // unchanged, so not listed
+		ui.Dropdown("Mode", []string{"lines", "dots"}, 0)
+	})
+	s.RandomSeed = 1
+	a := captureState(t, s)
+	s.SetFloat("Noise", "Scale", 2.5)
+	s.SetInt("", "Count", 7)
+	if err := s.setDropdownQuiet("", "Mode", 1); err != nil {
+		t.Fatal(err)
+	}
+	s.SetModulator("Noise", "Scale", Modulator{Shape: ModSine, Rate: 1, Depth: 0.2})
+	s.RandomSeed = 2
+	b := captureState(t, s)
+
+	diffs, err := s.diffStates(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []stateDiff{
+		{"sliders", "Noise/Scale", "1", "2.5"},
+		{"int_sliders", "Count", "5", "7"},
+		{"dropdowns", "Mode", "lines", "dots"},
+		{"modulators", "Noise/Scale", absentValue, "sine rate 1 depth 0.2 phase 0"},
+		{builtinsSection, "seed", "1", "2"},
+	}
+	if !slices.Equal(diffs, want) {
+		t.Fatalf("diffs\n%+v\nwant\n%+v", diffs, want)
+	}
+	if d, err := s.diffStates(b, b); err != nil || len(d) != 0 {
+		t.Fatalf("a state differs from itself: %+v, %v", d, err)
+	}
+}
+
+func TestApplyStateDiffs(t *testing.T) {
+	s := newTextBoxSketch(t, func(ui *UI) {
+		ui.Folder("Noise", func() {
+			ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+		})
+		ui.IntSlider("Count", 0, 10, 5, 1)
+	})
+	s.SetFloat("Noise", "Scale", 4)
+	s.SetInt("", "Count", 9)
+	s.RandomSeed = 11
+	snap := captureState(t, s)
+
+	s.SetFloat("Noise", "Scale", 1)
+	s.SetInt("", "Count", 2)
+	s.SetModulator("Noise", "Scale", Modulator{Shape: ModSine, Rate: 1, Depth: 0.2})
+	s.RandomSeed = 22
+	diffs, err := s.diffStates(captureState(t, s), snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Take the snapshot's scale, seed and lack of a modulator; keep the
+	// live count.
+	var picked []stateDiff
+	for _, d := range diffs {
+		if d.Key != "Count" {
+			picked = append(picked, d)
+		}
+	}
+	missing, err := s.applyStateDiffs(snap, picked)
+	if err != nil || len(missing) > 0 {
+		t.Fatalf("apply: %v, missing %v", err, missing)
+	}
+	if v := s.GetFloat("Noise", "Scale"); v != 4 {
+		t.Errorf("scale = %v, want the snapshot's 4", v)
+	}
+	if v := s.GetInt("", "Count"); v != 2 {
+		t.Errorf("count = %v, want the live 2", v)
+	}
+	if s.RandomSeed != 11 {
+		t.Errorf("seed = %d, want the snapshot's 11", s.RandomSeed)
+	}
+	if len(s.modulators) != 0 {
+		t.Errorf("modulators %v, want the snapshot's none", s.modulators)
+	}
+}
@@ -0,0 +1,158 @@
+package sketchy
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/aldernero/debugui"
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+// liveStateLabel is the Compare Snapshots source for the sketch's current
+// state.
+const liveStateLabel = "Live state"
+
+// openDiffDialog compares the newest snapshot with the live state.
+func (s *Sketch) openDiffDialog() {
+	s.dlgDiffOpen = true
+	s.diffSources = []string{liveStateLabel}
+	for _, info := range s.dbListSnapshots(sketchdb.SnapshotFilter{}) {
+		s.diffSources = append(s.diffSources, info.Name)
+	}
+	s.diffAIdx = min(1, len(s.diffSources)-1)
+	s.diffBIdx = 0
+	s.refreshDiff()
+}
+
+// diffSource is the state the Compare Snapshots source at idx names.
+func (s *Sketch) diffSource(idx int) (historyState, error) {
+	if idx <= 0 || idx >= len(s.diffSources) {
+		st, ok := s.captureHistoryState()
+		if !ok {
+			return st, fmt.Errorf("cannot serialize the live state")
+		}
+		return st, nil
+	}
+	row := s.dbGetSnapshot(s.diffSources[idx])
+	if row == nil {
+		return historyState{}, fmt.Errorf("no snapshot %q", s.diffSources[idx])
+	}
+	return historyState{controls: []byte(row.ControlJSON), builtins: []byte(row.BuiltinJSON)}, nil
+}
+
+// refreshDiff recompares the two sources, with no differences picked.
+func (s *Sketch) refreshDiff() {
+	s.diffs, s.diffPick, s.diffStatus = nil, nil, ""
+	a, err := s.diffSource(s.diffAIdx)
+	if err == nil {
+		var b historyState
+		if b, err = s.diffSource(s.diffBIdx); err == nil {
+			s.diffs, err = s.diffStates(a, b)
+		}
+	}
+	if err != nil {
+		s.diffStatus = "Error: " + err.Error()
+		return
+	}
+	s.diffPick = make([]bool, len(s.diffs))
+}
+
+// applyPickedDiffs sets the picked differences to their values in the
+// source at idx.
+func (s *Sketch) applyPickedDiffs(idx int) {
+	from, err := s.diffSource(idx)
+	if err != nil {
+		s.diffStatus = "Error: " + err.Error()
+		return
+	}
+	var picked []stateDiff
+	for i, d := range s.diffs {
+		if s.diffPick[i] {
+			picked = append(picked, d)
+		}
+	}
+	missing, err := s.applyStateDiffs(from, picked)
+	s.refreshDiff()
+	switch {
+	case err != nil:
+		s.diffStatus = "Error: " + err.Error()
+	case len(missing) > 0:
+		s.diffStatus = fmt.Sprintf("Applied %d values; not in this sketch: %v", len(picked)-len(missing), missing)
+	default:
+		s.diffStatus = fmt.Sprintf("Applied %d values from %s", len(picked), s.diffSources[idx])
+	}
+}
+
+// dialogDiff is the Compare Snapshots window: every control and builtin
+// value that differs between two snapshots, or a snapshot and the live
+// state, with checkboxes to apply some of them to the live state.
+func (s *Sketch) dialogDiff(ctx *debugui.Context) {
+	if !s.dlgDiffOpen {
+		return
+	}
+	ctx.Window("Compare Snapshots", image.Rect(140, 60, 700, 620), func(layout debugui.ContainerLayout) {
+		ctx.BringRootContainerToFront()
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("A")
+		ctx.IDScope("diffA", func() {
+			ctx.Dropdown(&s.diffAIdx, s.diffSources).On(s.refreshDiff)
+		})
+		ctx.Text("B")
+		ctx.IDScope("diffB", func() {
+			ctx.Dropdown(&s.diffBIdx, s.diffSources).On(s.refreshDiff)
+		})
+		ctx.SetGridLayout([]int{-1, 72, 72, 72}, nil)
+		ctx.Text(fmt.Sprintf("%d differences", len(s.diffs)))
+		ctx.Button("All").On(func() {
+			for i := range s.diffPick {
+				s.diffPick[i] = true
+			}
+		})
+		ctx.Button("None").On(func() { clear(s.diffPick) })
+		ctx.Button("Refresh").On(s.refreshDiff)
+		if s.diffStatus != "" {
+			ctx.SetGridLayout([]int{-1}, nil)
+			ctx.Text(s.diffStatus)
+		}
+
+		ctx.IDScope("diffRows", func() {
+			ctx.SetGridLayout([]int{24, -1, -1, -1}, nil)
+			ctx.Text("")
+			ctx.Text("Value")
+			ctx.Text("A")
+			ctx.Text("B")
+			section := ""
+			for i, d := range s.diffs {
+				if d.Section != section {
+					section = d.Section
+					ctx.SetGridLayout([]int{-1}, nil)
+					ctx.Text("[" + section + "]")
+					ctx.SetGridLayout([]int{24, -1, -1, -1}, nil)
+				}
+				ctx.IDScope(fmt.Sprintf("d%d", i), func() {
+					ctx.Checkbox(&s.diffPick[i], "")
+				})
+				ctx.Text(d.Key)
+				ctx.Text(d.A)
+				ctx.Text(d.B)
+			}
+		})
+
+		ctx.SetGridLayout([]int{-1, 120, 120, 72}, nil)
+		ctx.Text("Apply picked:")
+		for _, side := range []struct {
+			label string
+			idx   int
+		}{{"A values", s.diffAIdx}, {"B values", s.diffBIdx}} {
+			ctx.IDScope(side.label, func() {
+				// Applying the live state's own values would change nothing.
+				if side.idx == 0 {
+					ctx.Text("")
+					return
+				}
+				ctx.Button(side.label).On(func() { s.applyPickedDiffs(side.idx) })
+			})
+		}
+		ctx.Button("Close").On(func() { s.dlgDiffOpen = false })
+	})
+}
@@ -0,0 +1,177 @@
+package sketchy
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/aldernero/gaul/render"
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/image/draw"
+)
+
+// thumbSize is the long edge, in pixels, of snapshot thumbnails.
+const thumbSize = 256
+
+// snapshotThumbnail makes a snapshot's thumbnail: its linked PNG scaled
+// down, or, without one (or with the file gone), the snapshot rendered at
+// thumbnail size. Rendering applies the snapshot's state for one frame and
+// puts the current state back after, so it runs on the ebiten thread.
+func (s *Sketch) snapshotThumbnail(name string) (*image.RGBA, error) {
+	row := s.dbGetSnapshot(name)
+	if row == nil {
+		return nil, fmt.Errorf("no snapshot %q", name)
+	}
+	if row.PNGPath != "" {
+		if img, err := decodePNGFile(filepath.Join(s.workDir, filepath.FromSlash(row.PNGPath))); err == nil {
+			return fitThumb(img), nil
+		}
+	}
+
+	cur, ok := s.captureHistoryState()
+	if !ok {
+		return nil, fmt.Errorf("sketchy: cannot save the current state to render %q", name)
+	}
+	// Putting the state back changes nothing, so the change flags are put
+	// back too: an Updater that clears its canvas on a change must not see
+	// one.
+	did := []*bool{&s.DidControlsChange, &s.DidSlidersChange, &s.DidTogglesChange,
+		&s.DidColorPickersChange, &s.DidDropdownsChange, &s.DidTextBoxesChange, &s.dirty}
+	was := make([]bool, len(did))
+	for i, p := range did {
+		was[i] = *p
+	}
+	// Applying the builtins reseeds Rand, twice. The render draws on a copy,
+	// keeping the noise settings, and the live generator is put back
+	// untouched, so an Updater's stream doesn't change.
+	rng, seed, seedInt := s.Rand, s.RandomSeed, s.builtinSeedInt
+	defer func() {
+		if _, err := s.applyControlStateJSON(cur.controls); err != nil {
+			fmt.Println("thumbnail restore:", err)
+		} else if err := s.applyBuiltinStateJSON(cur.builtins); err != nil {
+			fmt.Println("thumbnail restore builtin:", err)
+		}
+		s.Rand, s.RandomSeed, s.builtinSeedInt = rng, seed, seedInt
+		for i, p := range did {
+			*p = was[i]
+		}
+	}()
+	if _, err := s.applyControlStateJSON([]byte(row.ControlJSON)); err != nil {
+		return nil, err
+	}
+	if err := s.applyBuiltinStateJSON([]byte(row.BuiltinJSON)); err != nil {
+		return nil, err
+	}
+	scale := min(1, thumbSize/max(s.SketchWidth, s.SketchHeight))
+	if s.usesGPUCanvas() {
+		return s.captureGPUAt(scale), nil
+	}
+	return s.renderThumb(scale), nil
+}
+
+// renderThumb runs the Drawer once into a raster of its own at scale. The
+// live frame is left alone: rasterBuf keeps what an accumulating sketch has
+// drawn, and the recording and its layers still hold the frame that saves
+// and recordings read.
+func (s *Sketch) renderThumb(scale float64) *image.RGBA {
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+	w := max(1, int(s.SketchWidth*scale+0.5))
+	h := max(1, int(s.SketchHeight*scale+0.5))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	ras := render.NewRasterFromImage(img)
+	ras.SetScale(scale)
+
+	rec, ctx, base, layers, frameRaster := s.recorder, s.ctx, s.baseLayer, s.layers, s.frameRaster
+	defer func() {
+		s.recorder, s.ctx, s.baseLayer, s.layers, s.frameRaster = rec, ctx, base, layers, frameRaster
+	}()
+	s.recorder = render.NewRecorder(s.SketchWidth, s.SketchHeight)
+	s.baseLayer, s.layers = nil, nil
+	s.ctx = s.newFrameContext(ras)
+	s.ctx.Clear(s.DefaultBackground)
+	s.ctx.SetStrokeColor(s.DefaultForeground)
+	s.ctx.SetStrokeWidth(s.DefaultStrokeWidth)
+	s.Drawer(s, s.ctx)
+	return img
+}
+
+func decodePNGFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// fitThumb scales img down to fit thumbSize, keeping its aspect ratio.
+func fitThumb(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	k := min(1, float64(thumbSize)/float64(max(b.Dx(), b.Dy())))
+	w, h := max(1, int(float64(b.Dx())*k+0.5)), max(1, int(float64(b.Dy())*k+0.5))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// loadThumbs fills the Load Snapshot thumbnail cache for names: thumbnails
+// cached in sketch.db are loaded as found, while at most one is made (and
+// stored in sketch.db) per call, so a large gallery fills in over a few
+// frames instead of stalling one. A snapshot whose thumbnail cannot be made
+// is cached as nil and drawn blank.
+func (s *Sketch) loadThumbs(names []string) {
+	if s.db == nil {
+		return
+	}
+	if s.thumbs == nil {
+		s.thumbs = make(map[string]*ebiten.Image)
+	}
+	made := false
+	for _, n := range names {
+		if _, ok := s.thumbs[n]; ok {
+			continue
+		}
+		data, err := s.db.SnapshotThumb(n)
+		if err != nil {
+			fmt.Println("snapshot thumbnail:", err)
+		}
+		var img image.Image
+		if data != nil {
+			if img, err = png.Decode(bytes.NewReader(data)); err != nil {
+				fmt.Println("snapshot thumbnail:", err)
+			}
+		}
+		if img == nil {
+			if made {
+				continue
+			}
+			made = true
+			if img, err = s.makeThumb(n); err != nil {
+				fmt.Println("snapshot thumbnail:", err)
+				s.thumbs[n] = nil
+				continue
+			}
+		}
+		s.thumbs[n] = ebiten.NewImageFromImage(img)
+	}
+}
+
+// makeThumb makes a snapshot's thumbnail and caches it in sketch.db.
+func (s *Sketch) makeThumb(name string) (image.Image, error) {
+	img, err := s.snapshotThumbnail(name)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	if err := s.db.SetSnapshotThumb(name, b.Bytes()); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
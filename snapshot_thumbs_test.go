@@ -0,0 +1,152 @@
+package sketchy
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldernero/gaul"
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+func TestSnapshotThumbnail(t *testing.T) {
+	var drewRed []bool
+	s := newTestSketch(512, 256, func(s *Sketch, c *render.Context) {
+		drewRed = append(drewRed, s.GetBool("", "Red"))
+	})
+	s.BuildUI = func(_ *Sketch, ui *UI) {
+		ui.Checkbox("Red", false)
+	}
+	s.rebuildControls()
+	s.workDir = t.TempDir()
+	db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s.db = db
+
+	s.SetBool("", "Red", true)
+	if _, err := s.takeSnapshot("red", "", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	s.SetBool("", "Red", false)
+
+	// Without a PNG the snapshot is rendered in its own state, which is
+	// then put back, and the thumbnail cached.
+	img, err := s.makeThumb("red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drewRed) != 1 || !drewRed[0] || s.GetBool("", "Red") {
+		t.Fatalf("drew with Red %v; Red is now %v", drewRed, s.GetBool("", "Red"))
+	}
+	if b := img.Bounds(); b.Dx() != thumbSize || b.Dy() != thumbSize/2 {
+		t.Fatalf("thumbnail %v", b)
+	}
+	data, err := db.SnapshotThumb("red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := png.DecodeConfig(bytes.NewReader(data)); err != nil || cfg.Width != thumbSize {
+		t.Fatalf("cached thumbnail %+v, %v", cfg, err)
+	}
+
+	// With one, the linked PNG is scaled down instead.
+	full := image.NewRGBA(image.Rect(0, 0, 1024, 512))
+	draw.Draw(full, full.Bounds(), image.NewUniform(color.RGBA{0, 0, 255, 255}), image.Point{}, draw.Src)
+	var b bytes.Buffer
+	if err := png.Encode(&b, full); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.workDir, "blue.png"), b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.InsertSave("blue.png", "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertSnapshot("blue", "", "{}", "{}", &id, nil); err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := s.snapshotThumbnail("blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != thumbSize || b.Dy() != thumbSize/2 {
+		t.Fatalf("PNG thumbnail %v", b)
+	}
+	if c := thumb.RGBAAt(10, 10); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Fatalf("PNG thumbnail pixel %v", c)
+	}
+	if len(drewRed) != 1 {
+		t.Fatal("rendered a snapshot that has a PNG")
+	}
+}
+
+// TestSnapshotThumbnailKeepsLiveFrame checks that rendering a thumbnail
+// leaves an accumulating sketch's canvas and the live recording alone.
+func TestSnapshotThumbnailKeepsLiveFrame(t *testing.T) {
+	s := newTestSketch(200, 100, func(*Sketch, *render.Context) {})
+	s.DisableClearBetweenFrames = true
+	s.workDir = t.TempDir()
+	db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s.db = db
+	if _, err := s.takeSnapshot("acc", "", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	buf := s.renderFrame()
+	rec, ctx, gen := s.recorder, s.ctx, s.frameGen.Load()
+	s.DidControlsChange, s.dirty = false, false
+
+	if _, err := s.snapshotThumbnail("acc"); err != nil {
+		t.Fatal(err)
+	}
+	if s.rasterBuf != buf || s.needToClear {
+		t.Fatal("thumbnail touched the accumulated raster")
+	}
+	if s.recorder != rec || s.ctx != ctx || s.frameGen.Load() != gen {
+		t.Fatal("thumbnail replaced the live frame")
+	}
+	if s.DidControlsChange || s.dirty {
+		t.Fatal("thumbnail reported a control change")
+	}
+}
+
+func TestSnapshotThumbnailKeepsRandStream(t *testing.T) {
+	s := newTestSketch(200, 100, func(s *Sketch, _ *render.Context) {
+		s.Rand.Float64()
+	})
+	s.workDir = t.TempDir()
+	db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s.db = db
+	s.setRandomSeed(9)
+	if _, err := s.takeSnapshot("nine", "", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	s.setRandomSeed(5)
+	s.Rand.Float64()
+
+	if _, err := s.snapshotThumbnail("nine"); err != nil {
+		t.Fatal(err)
+	}
+	want := gaul.NewRng(5)
+	want.Float64()
+	if got, w := s.Rand.Float64(), want.Float64(); got != w || s.RandomSeed != 5 {
+		t.Fatalf("after a thumbnail: seed %d, next draw %v, want seed 5 and %v", s.RandomSeed, got, w)
+	}
+}
@@ -0,0 +1,360 @@
+package sketchy
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/aldernero/debugui"
+	"github.com/aldernero/sketchy/internal/sketchdb"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Load Snapshot sort orders, by dropdown index.
+var (
+	snapshotSorts      = []sketchdb.SnapshotSort{sketchdb.SortNewest, sketchdb.SortOldest, sketchdb.SortRating, sketchdb.SortName}
+	snapshotSortLabels = []string{"Newest", "Oldest", "Rating", "Name"}
+)
+
+// snapshotRatingLabels and snapshotMinRatingLabels index star counts, for
+// rating a snapshot and for filtering by rating.
+var (
+	snapshotRatingLabels    = []string{"Unrated", "*", "**", "***", "****", "*****"}
+	snapshotMinRatingLabels = []string{"Any rating", "* or more", "** or more", "*** or more", "**** or more", "*****"}
+)
+
+// allTagsLabel is the Load Snapshot tag filter's "no filter" entry.
+const allTagsLabel = "All tags"
+
+func (s *Sketch) openLoadSnapshotDialog() {
+	s.dlgLoadOpen = true
+	s.dlgLoadImageErr = ""
+	s.dlgLoadSelected = ""
+	s.dlgLoadShown = 0
+	s.dlgBundleStatus = nil
+	if s.dlgBundlePath == "" {
+		s.dlgBundlePath = s.Prefix + "_snapshots" + sketchdb.BundleExt
+	}
+	s.refreshLoadList()
+}
+
+// loadFilter is the Load Snapshot dialog's filter and sort.
+func (s *Sketch) loadFilter() sketchdb.SnapshotFilter {
+	f := sketchdb.SnapshotFilter{
+		Text:      s.dlgLoadSearch,
+		MinRating: s.dlgLoadMinRating,
+		Favorites: s.dlgLoadFavorites,
+		Sort:      snapshotSorts[s.dlgLoadSortIdx],
+	}
+	if s.dlgLoadTagIdx > 0 {
+		f.Tag = s.dlgLoadTagNames[s.dlgLoadTagIdx]
+	}
+	return f
+}
+
+// loadFiltered reports whether the Load Snapshot list is narrowed at all.
+func (s *Sketch) loadFiltered() bool {
+	f := s.loadFilter()
+	return f.Tag != "" || strings.TrimSpace(f.Text) != "" || f.MinRating > 0 || f.Favorites
+}
+
+// refreshLoadList re-queries the Load Snapshot list after the filter or a
+// snapshot's labels change, keeping the selection while it is still listed.
+func (s *Sketch) refreshLoadList() {
+	tag := ""
+	if s.dlgLoadTagIdx > 0 && s.dlgLoadTagIdx < len(s.dlgLoadTagNames) {
+		tag = s.dlgLoadTagNames[s.dlgLoadTagIdx]
+	}
+	s.dlgLoadTagNames = append([]string{allTagsLabel}, s.dbListTags()...)
+	s.dlgLoadTagIdx = 0
+	for i, t := range s.dlgLoadTagNames[1:] {
+		if strings.EqualFold(t, tag) {
+			s.dlgLoadTagIdx = i + 1
+		}
+	}
+
+	s.dlgLoadNames = s.dlgLoadNames[:0]
+	s.dlgLoadMarks = s.dlgLoadMarks[:0]
+	keep := false
+	for _, info := range s.dbListSnapshots(s.loadFilter()) {
+		s.dlgLoadNames = append(s.dlgLoadNames, info.Name)
+		s.dlgLoadMarks = append(s.dlgLoadMarks, snapshotMarks(info))
+		keep = keep || info.Name == s.dlgLoadSelected
+	}
+	if !keep {
+		s.dlgLoadSelected = ""
+		if len(s.dlgLoadNames) > 0 {
+			s.dlgLoadSelected = s.dlgLoadNames[0]
+		}
+	}
+	s.refreshLoadPreview()
+}
+
+// snapshotMarks is the caption under a snapshot's name in the Load Snapshot
+// gallery: its stars, and a heart for favorites.
+func snapshotMarks(info sketchdb.SnapshotInfo) string {
+	m := strings.Repeat("*", info.Rating)
+	if info.Favorite {
+		m = strings.TrimSpace(m + " <3")
+	}
+	return m
+}
+
+// drawLoadFilterRows filters and sorts the Load Snapshot list.
+func (s *Sketch) drawLoadFilterRows(ctx *debugui.Context) {
+	ctx.IDScope("loadFilter", func() {
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("Search")
+		ctx.IDScope("search", func() {
+			ctx.TextField(&s.dlgLoadSearch).On(s.refreshLoadList)
+		})
+		ctx.Text("Tag")
+		ctx.IDScope("tag", func() {
+			ctx.Dropdown(&s.dlgLoadTagIdx, s.dlgLoadTagNames).On(s.refreshLoadList)
+		})
+		ctx.Text("Rating")
+		ctx.IDScope("rating", func() {
+			ctx.Dropdown(&s.dlgLoadMinRating, snapshotMinRatingLabels).On(s.refreshLoadList)
+		})
+		ctx.Text("Sort by")
+		ctx.IDScope("sort", func() {
+			ctx.Dropdown(&s.dlgLoadSortIdx, snapshotSortLabels).On(s.refreshLoadList)
+		})
+		ctx.SetGridLayout([]int{-1}, nil)
+		ctx.Checkbox(&s.dlgLoadFavorites, "Favorites only").On(s.refreshLoadList)
+		ctx.Text(fmt.Sprintf("%d snapshots", len(s.dlgLoadNames)))
+	})
+}
+
+// drawSnapshotLabelRows edits the selected snapshot's rating, favorite flag
+// and tags, saving each change to sketch.db as it is made.
+func (s *Sketch) drawSnapshotLabelRows(ctx *debugui.Context) {
+	row := s.dlgLoadPreviewRow
+	if row == nil {
+		return
+	}
+	ctx.IDScope("loadLabels", func() {
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("Rating")
+		ctx.IDScope("rating", func() {
+			ctx.Dropdown(&row.Rating, snapshotRatingLabels).On(func() {
+				s.editLoadSelected(func(name string) error { return s.db.SetSnapshotRating(name, row.Rating) })
+			})
+		})
+		ctx.Text("Tags")
+		ctx.IDScope("tags", func() {
+			ctx.TextField(&s.dlgLoadEditTags).On(func() {
+				s.editLoadSelected(func(name string) error {
+					return s.db.SetSnapshotTags(name, sketchdb.ParseTags(s.dlgLoadEditTags))
+				})
+			})
+		})
+		ctx.SetGridLayout([]int{-1}, nil)
+		ctx.Checkbox(&row.Favorite, "Favorite").On(func() {
+			s.editLoadSelected(func(name string) error { return s.db.SetSnapshotFavorite(name, row.Favorite) })
+		})
+	})
+}
+
+// editLoadSelected applies edit to the selected snapshot and re-lists, since
+// the edit may move it in or out of the filtered list.
+func (s *Sketch) editLoadSelected(edit func(name string) error) {
+	if s.db == nil || s.dlgLoadSelected == "" {
+		return
+	}
+	if err := edit(s.dlgLoadSelected); err != nil {
+		fmt.Println("label snapshot:", err)
+	}
+	s.refreshLoadList()
+}
+
+// drawSnapshotLabelInputs is the Take Snapshot dialog's tags, rating and
+// favorite flag for the new snapshot.
+func (s *Sketch) drawSnapshotLabelInputs(ctx *debugui.Context) {
+	ctx.IDScope("snapLabels", func() {
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("Tags")
+		ctx.IDScope("tags", func() {
+			ctx.TextField(&s.dlgSnapshotTags).On(func() {})
+		})
+		ctx.Text("Rating")
+		ctx.IDScope("rating", func() {
+			ctx.Dropdown(&s.dlgSnapshotRating, snapshotRatingLabels)
+		})
+		ctx.SetGridLayout([]int{-1}, nil)
+		ctx.Checkbox(&s.dlgSnapshotFavorite, "Favorite")
+	})
+}
+
+// Load Snapshot gallery layout, in panel units.
+const (
+	galleryColumns      = 4
+	galleryThumbHeight  = 72
+	galleryPreviewSize  = 240
+	galleryPageSize     = 24
+	galleryLabelRunes   = 14
+	gallerySelectBorder = 2
+)
+
+// gallerySelectColor outlines the selected thumbnail.
+var gallerySelectColor = color.RGBA{0xe8, 0xb8, 0x4a, 0xff}
+
+// drawLoadGallery shows the listed snapshots as a grid of thumbnails, a page
+// at a time; clicking one selects it.
+func (s *Sketch) drawLoadGallery(ctx *debugui.Context) {
+	shown := s.dlgLoadNames[:min(len(s.dlgLoadNames), max(s.dlgLoadShown, galleryPageSize))]
+	// The selection first, so the preview fills in before the grid.
+	s.loadThumbs(append([]string{s.dlgLoadSelected}, shown...))
+	widths := make([]int, galleryColumns)
+	for i := range widths {
+		widths[i] = -1
+	}
+	ctx.IDScope("gallery", func() {
+		for row := 0; row < len(shown); row += galleryColumns {
+			names := shown[row:min(row+galleryColumns, len(shown))]
+			ctx.SetGridLayout(widths, []int{galleryThumbHeight})
+			for i, n := range names {
+				ctx.IDScope(fmt.Sprintf("t%d", row+i), func() {
+					ctx.DragArea(
+						func(screen *ebiten.Image, bounds image.Rectangle) {
+							drawThumb(screen, bounds, ctx.Scale(), s.thumbs[n], n == s.dlgLoadSelected)
+						},
+						func(bounds image.Rectangle, pos image.Point) bool {
+							if n == s.dlgLoadSelected {
+								return false
+							}
+							s.dlgLoadSelected = n
+							return true
+						},
+					).On(s.refreshLoadPreview)
+				})
+			}
+			ctx.SetGridLayout(widths, nil)
+			for _, n := range names {
+				ctx.Text(galleryLabel(n))
+			}
+			for i := range names {
+				ctx.Text(s.dlgLoadMarks[row+i])
+			}
+		}
+		if len(shown) < len(s.dlgLoadNames) {
+			ctx.SetGridLayout([]int{-1}, nil)
+			ctx.Button(fmt.Sprintf("Show more (%d of %d)", len(shown), len(s.dlgLoadNames))).On(func() {
+				s.dlgLoadShown = len(shown) + galleryPageSize
+			})
+		}
+	})
+}
+
+// drawLoadPreview is the selected snapshot's thumbnail at a larger size.
+func (s *Sketch) drawLoadPreview(ctx *debugui.Context) {
+	ctx.SetGridLayout([]int{-1}, []int{galleryPreviewSize})
+	ctx.IDScope("preview", func() {
+		ctx.DragArea(
+			func(screen *ebiten.Image, bounds image.Rectangle) {
+				drawThumb(screen, bounds, ctx.Scale(), s.thumbs[s.dlgLoadSelected], false)
+			},
+			func(image.Rectangle, image.Point) bool { return false },
+		)
+	})
+}
+
+// galleryLabel shortens a snapshot name to fit under its thumbnail.
+func galleryLabel(name string) string {
+	r := []rune(name)
+	if len(r) <= galleryLabelRunes {
+		return name
+	}
+	return string(r[:galleryLabelRunes-1]) + "…"
+}
+
+// drawThumb fits img into bounds (panel units; scale converts to screen
+// pixels) on the scrubber background, outlined when selected. A nil img
+// (not made yet, or failed) leaves the background blank.
+func drawThumb(screen *ebiten.Image, bounds image.Rectangle, scale int, img *ebiten.Image, selected bool) {
+	sc := float32(scale)
+	x0, y0 := float32(bounds.Min.X)*sc, float32(bounds.Min.Y)*sc
+	w, h := float32(bounds.Dx())*sc, float32(bounds.Dy())*sc
+	if w <= 0 || h <= 0 {
+		return
+	}
+	vector.FillRect(screen, x0, y0, w, h, scrubberBG, false)
+	if img != nil {
+		ib := img.Bounds()
+		k := min(float64(w)/float64(ib.Dx()), float64(h)/float64(ib.Dy()))
+		op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+		op.GeoM.Scale(k, k)
+		op.GeoM.Translate(float64(x0)+(float64(w)-k*float64(ib.Dx()))/2, float64(y0)+(float64(h)-k*float64(ib.Dy()))/2)
+		screen.DrawImage(img, op)
+	}
+	if selected {
+		vector.StrokeRect(screen, x0+sc, y0+sc, w-2*sc, h-2*sc, gallerySelectBorder*sc, gallerySelectColor, false)
+	}
+}
+
+// drawLoadBundleRows exports snapshots to, and imports them from, a
+// .sketchy.json bundle (snapshot_bundle.go).
+func (s *Sketch) drawLoadBundleRows(ctx *debugui.Context) {
+	ctx.SetGridLayout([]int{-1}, nil)
+	ctx.Text("Snapshot bundle (" + sketchdb.BundleExt + "):")
+	ctx.IDScope("bundle", func() {
+		ctx.TextField(&s.dlgBundlePath).On(func() {})
+		ctx.SetGridLayout([]int{-1, 120, 96, 72}, nil)
+		ctx.Checkbox(&s.dlgBundleImages, "Embed images")
+		ctx.Button("Export selected").On(func() {
+			if s.dlgLoadSelected == "" {
+				s.dlgBundleStatus = []string{"Select a snapshot to export"}
+				return
+			}
+			s.exportBundle([]string{s.dlgLoadSelected})
+		})
+		ctx.Button("Export all").On(func() { s.exportBundle(nil) })
+		ctx.Button("Import").On(s.importBundle)
+	})
+	ctx.SetGridLayout([]int{-1}, nil)
+	for _, line := range s.dlgBundleStatus {
+		ctx.Text(line)
+	}
+	ctx.Text("")
+}
+
+// bundleDialogPath is the bundle path typed in the Load Snapshot dialog.
+func (s *Sketch) bundleDialogPath() string {
+	return strings.Trim(strings.TrimSpace(s.dlgBundlePath), `"'`)
+}
+
+func (s *Sketch) exportBundle(names []string) {
+	p := s.bundleDialogPath()
+	if p == "" {
+		s.dlgBundleStatus = []string{"Enter the path of a bundle"}
+		return
+	}
+	if err := s.ExportSnapshots(p, names, s.dlgBundleImages); err != nil {
+		s.dlgBundleStatus = []string{"Error: " + err.Error()}
+		return
+	}
+	s.dlgBundleStatus = []string{"Exported to " + p}
+}
+
+// importBundle imports the bundle and selects the first snapshot it added.
+func (s *Sketch) importBundle() {
+	p := s.bundleDialogPath()
+	if p == "" {
+		s.dlgBundleStatus = []string{"Enter the path of a bundle"}
+		return
+	}
+	imported, err := s.ImportSnapshots(p)
+	s.dlgBundleStatus = importSummary(imported)
+	if err != nil {
+		s.dlgBundleStatus = append(s.dlgBundleStatus, "Error: "+err.Error())
+	}
+	for _, imp := range imported {
+		if !imp.Skipped {
+			s.dlgLoadSelected = imp.Name
+			break
+		}
+	}
+	s.refreshLoadList()
+}
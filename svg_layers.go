@@ -0,0 +1,280 @@
+package sketchy
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const inkscapeNS = "http://www.inkscape.org/namespaces/inkscape"
+
+// svgDoc is an SVG document cut at its root element's children, so they can
+// be regrouped into layers without re-encoding any of the drawing.
+type svgDoc struct {
+	head   string    // prolog and the root start tag
+	shared []string  // defs, style and the like: kept in every output
+	elems  []svgElem // drawable top-level children, in document order
+	tail   string    // the root end tag and anything after it
+}
+
+// svgElem is one top-level element, verbatim, with the paint it is drawn in.
+type svgElem struct {
+	raw   string
+	paint string // stroke color, or fill color for unstroked elements
+}
+
+// svgLayer is a named group of top-level elements.
+type svgLayer struct {
+	name  string
+	elems []string
+}
+
+// sharedSVGElements are top-level children that are not drawing and belong
+// in every layer file rather than in one layer.
+var sharedSVGElements = map[string]bool{
+	"defs": true, "style": true, "title": true, "desc": true, "metadata": true,
+}
+
+func parseSVGDoc(data []byte) (svgDoc, error) {
+	var doc svgDoc
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	var start int64
+	var cur *svgElem
+	var curName string
+	for {
+		off := d.InputOffset()
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return svgDoc{}, fmt.Errorf("parse svg: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch depth {
+			case 0:
+				doc.head = string(data[:d.InputOffset()])
+			case 1:
+				start = off
+				curName = t.Name.Local
+				cur = &svgElem{paint: elementPaint(t.Attr)}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			switch depth {
+			case 0:
+				doc.tail = string(data[off:])
+				return doc, nil
+			case 1:
+				cur.raw = string(data[start:d.InputOffset()])
+				if sharedSVGElements[curName] {
+					doc.shared = append(doc.shared, cur.raw)
+				} else {
+					doc.elems = append(doc.elems, *cur)
+				}
+				cur = nil
+			}
+		}
+	}
+	return svgDoc{}, fmt.Errorf("parse svg: no root element")
+}
+
+// elementPaint is the stroke color of an element from its attributes or
+// style, falling back to its fill when it is not stroked.
+func elementPaint(attrs []xml.Attr) string {
+	var stroke, fill string
+	for _, a := range attrs {
+		switch a.Name.Local {
+		case "stroke":
+			stroke = a.Value
+		case "fill":
+			fill = a.Value
+		case "style":
+			for _, decl := range strings.Split(a.Value, ";") {
+				k, v, ok := strings.Cut(decl, ":")
+				if !ok {
+					continue
+				}
+				switch strings.TrimSpace(k) {
+				case "stroke":
+					stroke = strings.TrimSpace(v)
+				case "fill":
+					fill = strings.TrimSpace(v)
+				}
+			}
+		}
+	}
+	stroke = strings.ToLower(strings.TrimSpace(stroke))
+	if stroke != "" && stroke != "none" {
+		return stroke
+	}
+	return strings.ToLower(strings.TrimSpace(fill))
+}
+
+// layersByPaint groups the drawable elements by paint, in order of first
+// appearance. Each color is one pen.
+func (doc svgDoc) layersByPaint() []svgLayer {
+	var layers []svgLayer
+	index := map[string]int{}
+	for _, e := range doc.elems {
+		name := e.paint
+		if name == "" {
+			name = "none"
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(layers)
+			index[name] = i
+			layers = append(layers, svgLayer{name: name})
+		}
+		layers[i].elems = append(layers[i].elems, e.raw)
+	}
+	return layers
+}
+
+// raws returns the drawable elements verbatim.
+func (doc svgDoc) raws() []string {
+	out := make([]string, len(doc.elems))
+	for i, e := range doc.elems {
+		out[i] = e.raw
+	}
+	return out
+}
+
+// layered writes the document with each layer as an Inkscape layer group.
+func (doc svgDoc) layered(layers []svgLayer) []byte {
+	var b bytes.Buffer
+	b.WriteString(withInkscapeNS(doc.head))
+	for _, sh := range doc.shared {
+		b.WriteString(sh)
+	}
+	for i, l := range layers {
+		fmt.Fprintf(&b, "\n<g inkscape:groupmode=\"layer\" inkscape:label=\"%s\" id=\"layer%d\">", xmlAttrEscape(l.name), i+1)
+		for _, e := range l.elems {
+			b.WriteString(e)
+		}
+		b.WriteString("</g>")
+	}
+	b.WriteString("\n")
+	b.WriteString(doc.tail)
+	return b.Bytes()
+}
+
+// source is the document with layers as Inkscape layers, or as it was
+// drawn when there are none.
+func (doc svgDoc) source(layers []svgLayer) []byte {
+	if len(layers) == 0 {
+		return doc.standalone(doc.raws())
+	}
+	return doc.layered(layers)
+}
+
+// standalone writes the document's header and shared elements around elems,
+// for a single-layer file.
+func (doc svgDoc) standalone(elems []string) []byte {
+	var b bytes.Buffer
+	b.WriteString(doc.head)
+	for _, sh := range doc.shared {
+		b.WriteString(sh)
+	}
+	for _, e := range elems {
+		b.WriteString(e)
+	}
+	b.WriteString(doc.tail)
+	return b.Bytes()
+}
+
+// withInkscapeNS declares the inkscape namespace on the root start tag (the
+// last tag in head) unless it already is.
+func withInkscapeNS(head string) string {
+	if strings.Contains(head, inkscapeNS) {
+		return head
+	}
+	i := strings.LastIndex(head, ">")
+	if i < 0 {
+		return head
+	}
+	if i > 0 && head[i-1] == '/' {
+		i--
+	}
+	return head[:i] + ` xmlns:inkscape="` + inkscapeNS + `"` + head[i:]
+}
+
+func xmlAttrEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// layerFileSlug turns a layer name into a file-name suffix: lower case, with
+// runs of anything but letters and digits collapsed to '-'.
+func layerFileSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// withSVGSize sets the root width and height attributes of an SVG document,
+// e.g. to physical units, adding a viewBox of the given user size when the
+// root has none so the drawing scales with them.
+func withSVGSize(data []byte, width, height string, vbW, vbH float64) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		off := d.InputOffset()
+		tok, err := d.RawToken()
+		if err != nil {
+			return nil, fmt.Errorf("parse svg: %w", err)
+		}
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		end := d.InputOffset()
+		var b strings.Builder
+		b.Write(data[:off])
+		b.WriteString("<" + xmlName(t.Name))
+		hasViewBox := false
+		for _, a := range t.Attr {
+			switch xmlName(a.Name) {
+			case "width", "height":
+				continue
+			case "viewBox":
+				hasViewBox = true
+			}
+			fmt.Fprintf(&b, " %s=\"%s\"", xmlName(a.Name), xmlAttrEscape(a.Value))
+		}
+		fmt.Fprintf(&b, " width=\"%s\" height=\"%s\"", xmlAttrEscape(width), xmlAttrEscape(height))
+		if !hasViewBox {
+			fmt.Fprintf(&b, " viewBox=\"0 0 %g %g\"", vbW, vbH)
+		}
+		if bytes.HasSuffix(bytes.TrimRight(data[off:end], " \t\r\n"), []byte("/>")) {
+			b.WriteString("/>")
+		} else {
+			b.WriteString(">")
+		}
+		b.Write(data[end:])
+		return []byte(b.String()), nil
+	}
+}
+
+// xmlName is a raw token name with its prefix.
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
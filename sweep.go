@@ -0,0 +1,363 @@
+package sketchy
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aldernero/gaul"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Contact sheet layout, in sheet pixels.
+const (
+	DefaultSweepCellWidth = 256
+	sweepSheetPad         = 8
+	sweepLabelHeight      = 18
+	maxSweepCount         = 256
+)
+
+// SeedSweepOptions configures [Sketch.SeedSweep]. The seeds come from Seeds
+// when it is non-empty, otherwise Count random seeds when Random is set, and
+// otherwise the range Start, Start+1, …, Start+Count-1.
+type SeedSweepOptions struct {
+	Seeds  []int64
+	Start  int64
+	Count  int
+	Random bool
+	// Name is the base name for the output directory, files, and snapshot
+	// rows (default <Prefix>_sweep_<timestamp>).
+	Name string
+	// Columns of the contact sheet (0 = roughly square).
+	Columns int
+	// CellWidth is the contact-sheet thumbnail width in pixels
+	// (0 = DefaultSweepCellWidth); height follows the sketch aspect.
+	CellWidth int
+}
+
+// SweepResult lists the files a sweep wrote, relative to the sketch
+// working directory.
+type SweepResult struct {
+	// Seeds are the swept seeds (seed sweeps only).
+	Seeds []int64
+	// Paths are the per-cell PNGs in contact-sheet order: by seed, or row
+	// by row for a parameter sweep.
+	Paths []string
+	Sheet string
+}
+
+// sheetCell is one labelled thumbnail of a contact sheet.
+type sheetCell struct {
+	img   *image.RGBA
+	label string
+}
+
+// SeedSweep renders the current controls once per seed and writes each
+// frame as a PNG under saves/sweep/<name>/, plus a labelled contact sheet of
+// all of them. Every frame is recorded in sketch.db as a snapshot named
+// <name>_seed<N> linked to its PNG, so a cell picked from the sheet reopens
+// with Load Snapshot. RandomSeed is restored afterwards.
+//
+// Frames are captured at the current Export scale on the calling goroutine
+// and encoded by the save worker; shader and GPUDrawer sketches must call
+// this on the ebiten thread (from Update or a control callback).
+func (s *Sketch) SeedSweep(opts SeedSweepOptions) (SweepResult, error) {
+	seeds := sweepSeeds(opts)
+	if len(seeds) == 0 {
+		return SweepResult{}, fmt.Errorf("sketchy: seed sweep needs at least one seed")
+	}
+	if len(seeds) > maxSweepCount {
+		return SweepResult{}, fmt.Errorf("sketchy: seed sweep of %d seeds exceeds the limit of %d", len(seeds), maxSweepCount)
+	}
+	name := opts.Name
+	if name == "" {
+		name = s.Prefix + "_sweep_" + gaul.GetTimestampString()
+	}
+	if err := checkSaveName(name); err != nil {
+		return SweepResult{}, err
+	}
+
+	dir := sweepDir(name)
+	res := SweepResult{Seeds: seeds}
+	cells := make([]sheetCell, 0, len(seeds))
+	orig := s.RandomSeed
+	defer s.setRandomSeed(orig)
+	for _, seed := range seeds {
+		s.setRandomSeed(seed)
+		rel, cell, err := s.sweepCapture(dir, fmt.Sprintf("%s_seed%d", name, seed),
+			fmt.Sprintf("Seed sweep %s, seed %d", name, seed), fmt.Sprintf("seed %d", seed))
+		if err != nil {
+			return res, err
+		}
+		res.Paths = append(res.Paths, rel)
+		cells = append(cells, cell)
+	}
+
+	res.Sheet = s.queueSheet(dir, name, contactSheet(cells, opts.Columns, opts.CellWidth))
+	return res, nil
+}
+
+// SweepAxis is one control varied by [Sketch.ParamSweep]: a float or int
+// slider, stepped evenly from Min to Max inclusive. Int slider values are
+// rounded to the nearest integer.
+type SweepAxis struct {
+	Folder string
+	Name   string
+	Min    float64
+	Max    float64
+	Steps  int
+}
+
+// value is the axis value at step i.
+func (a SweepAxis) value(i int) float64 {
+	if a.Steps <= 1 {
+		return a.Min
+	}
+	return a.Min + (a.Max-a.Min)*float64(i)/float64(a.Steps-1)
+}
+
+// ParamSweepOptions configures [Sketch.ParamSweep].
+type ParamSweepOptions struct {
+	// X varies across the contact-sheet columns.
+	X SweepAxis
+	// Y, when set, varies down the rows; otherwise the sheet is one row.
+	Y *SweepAxis
+	// Name is the base name for the output directory, files, and snapshot
+	// rows (default <Prefix>_sweep_<timestamp>).
+	Name string
+	// CellWidth is the contact-sheet thumbnail width in pixels
+	// (0 = DefaultSweepCellWidth).
+	CellWidth int
+}
+
+// ParamSweep renders a grid over one or two slider controls, setting each
+// cell's values with SetFloat/SetInt, and writes each cell as a PNG under
+// saves/sweep/<name>/ (named <name>_r<row>_c<col>) plus a labelled contact
+// sheet with one column per X step and one row per Y step. Every cell's
+// exact control state is stored in sketch.db as a snapshot of the same name,
+// so it can be restored with Load Snapshot. The swept controls are put back
+// afterwards.
+//
+// Like SeedSweep, shader and GPUDrawer sketches must call this on the ebiten
+// thread.
+func (s *Sketch) ParamSweep(opts ParamSweepOptions) (SweepResult, error) {
+	axes := []SweepAxis{opts.X}
+	if opts.Y != nil {
+		axes = append(axes, *opts.Y)
+	}
+	total := 1
+	for i := range axes {
+		a := &axes[i]
+		if !s.isSliderControl(a.Folder, a.Name) {
+			return SweepResult{}, fmt.Errorf("sketchy: %q is not a float or int slider", controlMapKey(a.Folder, a.Name))
+		}
+		a.Steps = max(a.Steps, 1)
+		total *= a.Steps
+	}
+	if total > maxSweepCount {
+		return SweepResult{}, fmt.Errorf("sketchy: parameter sweep of %d cells exceeds the limit of %d", total, maxSweepCount)
+	}
+	name := opts.Name
+	if name == "" {
+		name = s.Prefix + "_sweep_" + gaul.GetTimestampString()
+	}
+	if err := checkSaveName(name); err != nil {
+		return SweepResult{}, err
+	}
+
+	orig := make([]float64, len(axes))
+	for i, a := range axes {
+		orig[i] = s.sweepGet(a)
+	}
+	defer func() {
+		for i, a := range axes {
+			s.sweepSet(a, orig[i])
+		}
+		s.dirty = true
+	}()
+
+	dir := sweepDir(name)
+	rows := 1
+	if len(axes) == 2 {
+		rows = axes[1].Steps
+	}
+	var res SweepResult
+	cells := make([]sheetCell, 0, total)
+	for r := range rows {
+		for c := range axes[0].Steps {
+			s.sweepSet(axes[0], axes[0].value(c))
+			label := s.sweepLabel(axes[0])
+			if len(axes) == 2 {
+				s.sweepSet(axes[1], axes[1].value(r))
+				label += " " + s.sweepLabel(axes[1])
+			}
+			rel, cell, err := s.sweepCapture(dir, fmt.Sprintf("%s_r%d_c%d", name, r, c),
+				fmt.Sprintf("Parameter sweep %s: %s", name, label), label)
+			if err != nil {
+				return res, err
+			}
+			res.Paths = append(res.Paths, rel)
+			cells = append(cells, cell)
+		}
+	}
+
+	res.Sheet = s.queueSheet(dir, name, contactSheet(cells, axes[0].Steps, opts.CellWidth))
+	return res, nil
+}
+
+// isSliderControl reports whether folder/name is a float or int slider.
+func (s *Sketch) isSliderControl(folder, name string) bool {
+	k := controlMapKey(folder, name)
+	_, isFloat := s.floatSliderControlMap[k]
+	_, isInt := s.intSliderControlMap[k]
+	return isFloat || isInt
+}
+
+// sweepGet reads an axis's slider as set, without its modulator, so the
+// value put back after the sweep and shown in labels is the base one.
+func (s *Sketch) sweepGet(a SweepAxis) float64 {
+	k := controlMapKey(a.Folder, a.Name)
+	if i, ok := s.floatSliderControlMap[k]; ok {
+		return s.FloatSliders[i].Val
+	}
+	return float64(s.IntSliders[s.intSliderControlMap[k]].Val)
+}
+
+func (s *Sketch) sweepSet(a SweepAxis, v float64) {
+	if _, ok := s.floatSliderControlMap[controlMapKey(a.Folder, a.Name)]; ok {
+		s.SetFloat(a.Folder, a.Name, v)
+		return
+	}
+	s.SetInt(a.Folder, a.Name, int(math.Round(v)))
+}
+
+// sweepLabel is the contact-sheet label for an axis's current value.
+func (s *Sketch) sweepLabel(a SweepAxis) string {
+	return a.Name + "=" + strconv.FormatFloat(s.sweepGet(a), 'g', 4, 64)
+}
+
+func sweepDir(name string) string {
+	return filepath.ToSlash(filepath.Join("saves", "sweep", name))
+}
+
+// sweepCapture renders the current state as one sweep cell: it queues the
+// PNG at dir/base.png together with a snapshot row named base, and returns
+// the PNG path and the cell for the contact sheet.
+func (s *Sketch) sweepCapture(dir, base, description, label string) (string, sheetCell, error) {
+	img := s.captureFrame()
+	data, err := s.serializeControlState()
+	if err != nil {
+		return "", sheetCell{}, err
+	}
+	bdata, err := s.serializeBuiltinState()
+	if err != nil {
+		return "", sheetCell{}, err
+	}
+	rel := dir + "/" + base + ".png"
+	s.queueSave(SaveRequest{RelPath: rel, Format: "png", RecordDB: true, Pixels: img,
+		snapshot: &snapshotRecord{
+			name:        base,
+			description: description,
+			controlJSON: string(data),
+			builtinJSON: string(bdata),
+		},
+		meta: s.metadataFor(data, bdata)})
+	return rel, sheetCell{img: img, label: label}, nil
+}
+
+// queueSheet queues a sweep's contact sheet and returns its path.
+func (s *Sketch) queueSheet(dir, name string, sheet *image.RGBA) string {
+	rel := dir + "/" + name + "_sheet.png"
+	s.queueSave(SaveRequest{RelPath: rel, Format: "png", RecordDB: true, Pixels: sheet})
+	return rel
+}
+
+// sweepSeeds resolves the seed list of a SeedSweepOptions.
+func sweepSeeds(opts SeedSweepOptions) []int64 {
+	if len(opts.Seeds) > 0 {
+		return append([]int64(nil), opts.Seeds...)
+	}
+	if opts.Count <= 0 {
+		return nil
+	}
+	seeds := make([]int64, opts.Count)
+	for i := range seeds {
+		if opts.Random {
+			seeds[i] = rand.Int63()
+		} else {
+			seeds[i] = opts.Start + int64(i)
+		}
+	}
+	return seeds
+}
+
+// captureFrame renders the current state and returns a private copy of it
+// at the Export scale: the GPU readback for GPU sketches, and for CPU
+// sketches a renderFrame followed by a replay of its recording (the display
+// raster may be at preview resolution, and is reused by the next frame).
+func (s *Sketch) captureFrame() *image.RGBA {
+	if s.usesGPUCanvas() {
+		return s.CaptureGPUImage()
+	}
+	// Each capture stands alone, even when the display accumulates.
+	s.needToClear = true
+	s.renderFrame()
+	return s.rasterizeRecording(s.RasterDPI)
+}
+
+// queueSave hands a request to the save worker, waiting for room rather
+// than dropping it: a sweep produces more files than the queue holds. With
+// no worker running (InitHeadless) the request is handled synchronously.
+func (s *Sketch) queueSave(req SaveRequest) {
+	if s.saveRequests == nil {
+		s.handleSave(req)
+		return
+	}
+	s.saveRequests <- req
+}
+
+// contactSheet lays cells out in a labelled grid on the panel background.
+func contactSheet(cells []sheetCell, cols, cellW int) *image.RGBA {
+	if len(cells) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 1, 1))
+	}
+	if cols <= 0 {
+		cols = int(math.Ceil(math.Sqrt(float64(len(cells)))))
+	}
+	cols = min(cols, len(cells))
+	rows := (len(cells) + cols - 1) / cols
+	if cellW <= 0 {
+		cellW = DefaultSweepCellWidth
+	}
+	b := cells[0].img.Bounds()
+	cellH := max(1, int(float64(cellW)*float64(b.Dy())/float64(b.Dx())+0.5))
+
+	w := sweepSheetPad + cols*(cellW+sweepSheetPad)
+	h := sweepSheetPad + rows*(cellH+sweepLabelHeight+sweepSheetPad)
+	sheet := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.RGBA{0x1e, 0x1e, 0x1e, 0xff}), image.Point{}, draw.Src)
+	face := basicfont.Face7x13
+	for i, c := range cells {
+		x := sweepSheetPad + (i%cols)*(cellW+sweepSheetPad)
+		y := sweepSheetPad + (i/cols)*(cellH+sweepLabelHeight+sweepSheetPad)
+		dst := image.Rect(x, y, x+cellW, y+cellH)
+		draw.CatmullRom.Scale(sheet, dst, c.img, c.img.Bounds(), draw.Over, nil)
+		// Clip the label to its cell so long labels don't run into the next.
+		labelRect := image.Rect(x, y+cellH, x+cellW, y+cellH+sweepLabelHeight)
+		d := font.Drawer{
+			Dst:  sheet.SubImage(labelRect).(*image.RGBA),
+			Src:  image.NewUniform(color.RGBA{0xe8, 0xe8, 0xe8, 0xff}),
+			Face: face,
+			Dot:  fixed.P(x, y+cellH+face.Ascent+3),
+		}
+		d.DrawString(c.label)
+	}
+	return sheet
+}
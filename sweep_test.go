@@ -0,0 +1,219 @@
+package sketchy
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldernero/gaul/render"
+	"github.com/aldernero/sketchy/internal/sketchdb"
+)
+
+func TestSeedSweepWritesFramesSheetAndSnapshots(t *testing.T) {
+	s := newTestSketch(40, 20, func(_ *Sketch, c *render.Context) {
+		c.SetFillColor(color.RGBA{255, 0, 0, 255})
+		c.DrawRectangle(0, 0, 20, 20)
+		c.Fill()
+	})
+	s.workDir = t.TempDir()
+	s.RandomSeed = 99
+	db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s.db = db
+
+	res, err := s.SeedSweep(SeedSweepOptions{Start: 10, Count: 3, Name: "sw", Columns: 2, CellWidth: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RandomSeed != 99 {
+		t.Fatalf("RandomSeed = %d after sweep, want it restored to 99", s.RandomSeed)
+	}
+	want := []string{"saves/sweep/sw/sw_seed10.png", "saves/sweep/sw/sw_seed11.png", "saves/sweep/sw/sw_seed12.png"}
+	for i, p := range want {
+		if res.Paths[i] != p {
+			t.Fatalf("Paths[%d] = %q, want %q", i, res.Paths[i], p)
+		}
+		if _, err := os.Stat(filepath.Join(s.workDir, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(s.workDir, res.Sheet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := png.DecodeConfig(f)
+	_ = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 columns x 2 rows of 40x20 cells with labels and padding.
+	wantW := sweepSheetPad + 2*(40+sweepSheetPad)
+	wantH := sweepSheetPad + 2*(20+sweepLabelHeight+sweepSheetPad)
+	if cfg.Width != wantW || cfg.Height != wantH {
+		t.Fatalf("sheet is %dx%d, want %dx%d", cfg.Width, cfg.Height, wantW, wantH)
+	}
+
+	// Each cell reopens as a snapshot carrying its seed and linked PNG.
+	row, err := db.GetSnapshotByName("sw_seed11")
+	if err != nil || row == nil {
+		t.Fatalf("snapshot sw_seed11 = %v, %v", row, err)
+	}
+	if row.PNGPath != want[1] {
+		t.Fatalf("snapshot PNG = %q, want %q", row.PNGPath, want[1])
+	}
+	var b builtinSnapshotPayload
+	if err := json.Unmarshal([]byte(row.BuiltinJSON), &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.RandomSeed != 11 {
+		t.Fatalf("snapshot seed = %d, want 11", b.RandomSeed)
+	}
+}
+
+func TestSweepSeeds(t *testing.T) {
+	if got := sweepSeeds(SeedSweepOptions{Seeds: []int64{5, 3}, Count: 10}); len(got) != 2 || got[1] != 3 {
+		t.Fatalf("explicit seeds = %v", got)
+	}
+	if got := sweepSeeds(SeedSweepOptions{Start: -1, Count: 3}); len(got) != 3 || got[0] != -1 || got[2] != 1 {
+		t.Fatalf("range seeds = %v", got)
+	}
+	if got := sweepSeeds(SeedSweepOptions{Count: 4, Random: true}); len(got) != 4 {
+		t.Fatalf("random seeds = %v", got)
+	}
+	if _, err := (&Sketch{}).SeedSweep(SeedSweepOptions{}); err == nil {
+		t.Fatal("expected an error for an empty sweep")
+	}
+}
+
+func TestContactSheetLayout(t *testing.T) {
+	cell := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	cells := make([]sheetCell, 5)
+	for i := range cells {
+		cells[i] = sheetCell{img: cell, label: "a label far too long to fit inside its cell"}
+	}
+	sheet := contactSheet(cells, 0, 100)
+	// Five cells lay out 3 wide (ceil(sqrt(5))) and 2 tall.
+	b := sheet.Bounds()
+	if b.Dx() != sweepSheetPad+3*(100+sweepSheetPad) || b.Dy() != sweepSheetPad+2*(50+sweepLabelHeight+sweepSheetPad) {
+		t.Fatalf("sheet bounds = %v", b)
+	}
+	// Labels are clipped to their cell: the gap column stays background.
+	gapX := sweepSheetPad + 100 + sweepSheetPad/2
+	if c := sheet.RGBAAt(gapX, sweepSheetPad+50+8); c != (color.RGBA{0x1e, 0x1e, 0x1e, 0xff}) {
+		t.Fatalf("gap pixel = %v, want background", c)
+	}
+}
+
+func TestSeedSweepRejectsPathNames(t *testing.T) {
+	s := newTestSketch(20, 20, func(*Sketch, *render.Context) {})
+	s.workDir = t.TempDir()
+	for _, name := range []string{"../x", "a/b", ".."} {
+		if _, err := s.SeedSweep(SeedSweepOptions{Start: 1, Count: 1, Name: name}); err == nil {
+			t.Errorf("seed sweep named %q was accepted", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.workDir, "saves")); !os.IsNotExist(err) {
+		t.Fatalf("a rejected sweep wrote files: %v", err)
+	}
+}
+
+func TestParamSweepGridAndSnapshots(t *testing.T) {
+	s := newTestSketch(20, 20, func(*Sketch, *render.Context) {})
+	s.BuildUI = func(_ *Sketch, ui *UI) {
+		ui.Folder("Noise", func() {
+			ui.FloatSlider("Scale", 0, 5, 1, 0.1)
+		})
+		ui.IntSlider("Count", 1, 10, 4, 1)
+	}
+	s.rebuildControls()
+	s.workDir = t.TempDir()
+	db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s.db = db
+
+	res, err := s.ParamSweep(ParamSweepOptions{
+		X:    SweepAxis{Folder: "Noise", Name: "Scale", Min: 0.1, Max: 2, Steps: 3},
+		Y:    &SweepAxis{Name: "Count", Min: 2, Max: 3, Steps: 2},
+		Name: "ps",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Paths) != 6 || res.Paths[5] != "saves/sweep/ps/ps_r1_c2.png" {
+		t.Fatalf("Paths = %v", res.Paths)
+	}
+	if s.GetFloat("Noise", "Scale") != 1 || s.GetInt("", "Count") != 4 {
+		t.Fatal("swept controls were not restored")
+	}
+
+	// The middle column of the second row is Scale 1.05, Count 3.
+	row, err := db.GetSnapshotByName("ps_r1_c1")
+	if err != nil || row == nil {
+		t.Fatalf("snapshot ps_r1_c1 = %v, %v", row, err)
+	}
+	if _, err := s.ApplyControlState([]byte(row.ControlJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.GetFloat("Noise", "Scale"); math.Abs(got-1.05) > 1e-9 {
+		t.Fatalf("Scale = %v, want 1.05", got)
+	}
+	if got := s.GetInt("", "Count"); got != 3 {
+		t.Fatalf("Count = %d, want 3", got)
+	}
+
+	if _, err := s.ParamSweep(ParamSweepOptions{X: SweepAxis{Name: "nope", Steps: 2}}); err == nil {
+		t.Fatal("expected an error for an unknown control")
+	}
+	if _, err := s.ParamSweep(ParamSweepOptions{X: SweepAxis{Name: "Count", Min: 1, Max: 2, Steps: 2}, Name: "../x"}); err == nil {
+		t.Fatal("a sweep named ../x was accepted")
+	}
+	if _, err := os.Stat(filepath.Join(s.workDir, "saves", "x")); !os.IsNotExist(err) {
+		t.Fatalf("the rejected sweep wrote outside saves/: %v", err)
+	}
+}
+
+func TestParamSweepKeepsModulatedBase(t *testing.T) {
+	s := newTestSketch(20, 20, func(*Sketch, *render.Context) {})
+	s.BuildUI = func(_ *Sketch, ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 5, 0.1)
+	}
+	s.rebuildControls()
+	s.workDir = t.TempDir()
+	db, err := sketchdb.Open(filepath.Join(s.workDir, "sketch.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s.db = db
+	s.SetModulator("", "Scale", Modulator{Shape: ModSine, Rate: 1, Depth: 0.2})
+	s.Tick = 15 // sine peak: Scale reads 7
+
+	if _, err := s.ParamSweep(ParamSweepOptions{
+		X:    SweepAxis{Name: "Scale", Min: 1, Max: 3, Steps: 2},
+		Name: "mod",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.FloatSliders[0].Val; v != 5 {
+		t.Fatalf("base Scale = %v after the sweep, want 5", v)
+	}
+	row, err := db.GetSnapshotByName("mod_r0_c0")
+	if err != nil || row == nil {
+		t.Fatalf("snapshot mod_r0_c0 = %v, %v", row, err)
+	}
+	if want := "Parameter sweep mod: Scale=1"; row.Description != want {
+		t.Fatalf("description %q, want %q", row.Description, want)
+	}
+}
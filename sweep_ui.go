@@ -0,0 +1,169 @@
+package sketchy
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/aldernero/debugui"
+)
+
+// Seed sources for the Seed Sweep dialog.
+const (
+	sweepModeRange  = iota // Start, Start+1, …
+	sweepModeRandom        // Count random seeds
+)
+
+var sweepModeLabels = []string{"Range", "Random"}
+
+func (s *Sketch) openSeedSweepDialog() {
+	s.dlgSweepOpen = true
+	s.sweepStart = int(s.RandomSeed)
+	if s.sweepCount <= 0 {
+		s.sweepCount = 16
+	}
+}
+
+// dialogSeedSweep collects the options for [Sketch.SeedSweep] and runs it on
+// OK. The sweep renders on the ebiten thread, so the window pauses for it.
+func (s *Sketch) dialogSeedSweep(ctx *debugui.Context) {
+	if !s.dlgSweepOpen {
+		return
+	}
+	ctx.Window("Seed Sweep", image.Rect(200, 100, 520, 320), func(layout debugui.ContainerLayout) {
+		ctx.BringRootContainerToFront()
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("Seeds")
+		ctx.IDScope("sweepMode", func() {
+			ctx.Dropdown(&s.sweepModeIdx, sweepModeLabels)
+		})
+		if s.sweepModeIdx == sweepModeRange {
+			ctx.Text("Start")
+			ctx.IDScope("sweepStart", func() {
+				ctx.NumberField(&s.sweepStart, 1)
+			})
+		}
+		ctx.Text("Count")
+		ctx.IDScope("sweepCount", func() {
+			ctx.NumberField(&s.sweepCount, 1).On(func() {
+				s.sweepCount = clampInt(s.sweepCount, 1, maxSweepCount)
+			})
+		})
+		ctx.Text("Columns (0 = auto)")
+		ctx.IDScope("sweepColumns", func() {
+			ctx.NumberField(&s.sweepColumns, 1).On(func() {
+				s.sweepColumns = clampInt(s.sweepColumns, 0, maxSweepCount)
+			})
+		})
+		modalActionRow(ctx, "Render", func() { s.dlgSweepOpen = false }, func() {
+			res, err := s.SeedSweep(SeedSweepOptions{
+				Start:   int64(s.sweepStart),
+				Count:   s.sweepCount,
+				Random:  s.sweepModeIdx == sweepModeRandom,
+				Columns: s.sweepColumns,
+			})
+			if err != nil {
+				s.sweepStatus = "Sweep error: " + err.Error()
+			} else {
+				s.sweepStatus = fmt.Sprintf("Swept %d seeds: %s", len(res.Seeds), res.Sheet)
+			}
+			fmt.Println(s.sweepStatus)
+			s.dlgSweepOpen = false
+		})
+	})
+}
+
+// openParamSweepDialog lists the sliders that can be swept, preselecting the
+// first one over its full range.
+func (s *Sketch) openParamSweepDialog() {
+	s.psControls = s.psControls[:0]
+	s.psLabels = s.psLabels[:0]
+	for _, e := range s.uiPlan {
+		var a SweepAxis
+		switch e.Kind {
+		case entryFloatSlider:
+			f := s.FloatSliders[e.Index]
+			a = SweepAxis{Folder: f.Folder, Name: f.Name, Min: f.MinVal, Max: f.MaxVal, Steps: 5}
+		case entryIntSlider:
+			n := s.IntSliders[e.Index]
+			a = SweepAxis{Folder: n.Folder, Name: n.Name, Min: float64(n.MinVal), Max: float64(n.MaxVal), Steps: 5}
+		default:
+			continue
+		}
+		s.psControls = append(s.psControls, a)
+		s.psLabels = append(s.psLabels, controlMapKey(a.Folder, a.Name))
+	}
+	if len(s.psControls) == 0 {
+		return
+	}
+	s.dlgParamSweepOpen = true
+	s.psXIdx = clampInt(s.psXIdx, 0, len(s.psControls)-1)
+	s.psX = s.psControls[s.psXIdx]
+	s.psYIdx = clampInt(s.psYIdx, 0, len(s.psControls))
+	if s.psYIdx > 0 {
+		s.psY = s.psControls[s.psYIdx-1]
+	}
+}
+
+// dialogParamSweep collects the axes for [Sketch.ParamSweep] and runs it on
+// OK.
+func (s *Sketch) dialogParamSweep(ctx *debugui.Context) {
+	if !s.dlgParamSweepOpen {
+		return
+	}
+	ctx.Window("Parameter Sweep", image.Rect(200, 80, 560, 420), func(layout debugui.ContainerLayout) {
+		ctx.BringRootContainerToFront()
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("Columns (X)")
+		ctx.IDScope("psX", func() {
+			ctx.Dropdown(&s.psXIdx, s.psLabels).On(func() {
+				s.psX = s.psControls[s.psXIdx]
+			})
+		})
+		sweepAxisRows(ctx, "psXRange", &s.psX)
+
+		yLabels := append([]string{"(none)"}, s.psLabels...)
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("Rows (Y)")
+		ctx.IDScope("psY", func() {
+			ctx.Dropdown(&s.psYIdx, yLabels).On(func() {
+				if s.psYIdx > 0 {
+					s.psY = s.psControls[s.psYIdx-1]
+				}
+			})
+		})
+		if s.psYIdx > 0 {
+			sweepAxisRows(ctx, "psYRange", &s.psY)
+		}
+
+		modalActionRow(ctx, "Render", func() { s.dlgParamSweepOpen = false }, func() {
+			opts := ParamSweepOptions{X: s.psX}
+			if s.psYIdx > 0 {
+				y := s.psY
+				opts.Y = &y
+			}
+			res, err := s.ParamSweep(opts)
+			if err != nil {
+				s.sweepStatus = "Sweep error: " + err.Error()
+			} else {
+				s.sweepStatus = fmt.Sprintf("Swept %d cells: %s", len(res.Paths), res.Sheet)
+			}
+			fmt.Println(s.sweepStatus)
+			s.dlgParamSweepOpen = false
+		})
+	})
+}
+
+// sweepAxisRows edits an axis's range and step count.
+func sweepAxisRows(ctx *debugui.Context, id string, a *SweepAxis) {
+	ctx.IDScope(id, func() {
+		ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+		ctx.Text("From")
+		ctx.NumberFieldF(&a.Min, 0.01, 3)
+		ctx.Text("To")
+		ctx.NumberFieldF(&a.Max, 0.01, 3)
+		ctx.Text("Steps")
+		ctx.NumberField(&a.Steps, 1).On(func() {
+			a.Steps = clampInt(a.Steps, 1, maxSweepCount)
+		})
+	})
+}
@@ -0,0 +1,252 @@
+package sketchy
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	"github.com/aldernero/gaul/render"
+)
+
+// PNG saves larger than tiledPNGPixels are rendered in bands of at most
+// tiledBandBytes of RGBA and streamed to the file a band at a time, so
+// memory stays bounded at any export scale.
+const tiledPNGPixels = 64 << 20 // 8192×8192
+
+// tiledBandBytes is a variable so tests can render small images in several
+// bands.
+var tiledBandBytes = 64 << 20
+
+// renderTiledPNGToFile is renderPNGToFile for very large images: a w×h PNG
+// at scale, replayed band by band from a copy of the current recording, so
+// frames keep rendering while the bands are drawn. Progress is reported in
+// the Builtins panel.
+func (s *Sketch) renderTiledPNGToFile(full string, scale float64, w, h int) error {
+	rec := s.copyRecording()
+	f, err := os.Create(full)
+	if err != nil {
+		return err
+	}
+	rows := max(1, tiledBandBytes/(4*w))
+	err = writeTiledPNG(f, w, h, rows, s.pageDensity(scale), func(band *image.RGBA) {
+		replayBand(rec, band, scale)
+	}, func(done, total int) {
+		s.setTileStatus(fmt.Sprintf("Rendering %d×%d PNG: %d%%", w, h, 100*done/total))
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		s.setTileStatus("Tiled PNG failed: " + err.Error())
+		return err
+	}
+	s.setTileStatus(fmt.Sprintf("Saved %d×%d PNG", w, h))
+	return nil
+}
+
+// copyRecording copies the current frame's recording under saveMutex, so a
+// long save can replay it while frames keep rendering.
+func (s *Sketch) copyRecording() *render.Recorder {
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+	rec := render.NewRecorder(s.SketchWidth, s.SketchHeight)
+	s.recorder.Replay(rec)
+	return rec
+}
+
+// replayBand draws rec at scale into band. A raster draws in its image's
+// own coordinates, so a band whose bounds start at row y0 receives just its
+// part of the frame.
+func replayBand(rec *render.Recorder, band *image.RGBA, scale float64) {
+	ras := render.NewRasterFromImage(band)
+	ras.SetScale(scale)
+	rec.Replay(ras)
+}
+
+// writeTiledPNG writes a w×h RGBA PNG tagged with dpi (0 leaves it out),
+// drawing it rows full-width rows at a time: draw fills each band, cleared
+// to transparent, and progress is told the rows done so far.
+func writeTiledPNG(out io.Writer, w, h, rows int, dpi float64, draw func(band *image.RGBA), progress func(done, total int)) error {
+	rows = max(1, min(h, rows))
+	pix := make([]byte, 4*w*rows)
+	bw := bufio.NewWriterSize(out, 1<<20)
+	enc, err := newPNGStream(bw, w, h, dpi)
+	if err != nil {
+		return err
+	}
+	for y0 := 0; y0 < h; y0 += rows {
+		y1 := min(y0+rows, h)
+		band := &image.RGBA{Pix: pix[:4*w*(y1-y0)], Stride: 4 * w, Rect: image.Rect(0, y0, w, y1)}
+		clear(band.Pix)
+		draw(band)
+		if err := enc.writeRows(band); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(y1, h)
+		}
+	}
+	if err := enc.close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// pngStream encodes an 8-bit RGBA PNG row by row. image/png needs the whole
+// image in memory; this keeps only the previous row.
+type pngStream struct {
+	w          io.Writer
+	idat       *idatWriter
+	z          *zlib.Writer
+	prev, cur  []byte
+	candidates [5][]byte // the row under each filter, with its type byte
+}
+
+func newPNGStream(w io.Writer, width, height int, dpi float64) (*pngStream, error) {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8], ihdr[9] = 8, 6 // 8-bit RGBA
+	head := append([]byte("\x89PNG\r\n\x1a\n"), pngChunk("IHDR", ihdr)...)
+	if dpi > 0 {
+		head = append(head, pngChunk("pHYs", pngDensity(dpi))...)
+	}
+	if _, err := w.Write(head); err != nil {
+		return nil, err
+	}
+	p := &pngStream{w: w, idat: &idatWriter{w: w}, prev: make([]byte, 4*width), cur: make([]byte, 4*width)}
+	p.z = zlib.NewWriter(p.idat)
+	for i := range p.candidates {
+		p.candidates[i] = make([]byte, 1+4*width)
+		p.candidates[i][0] = byte(i)
+	}
+	return p, nil
+}
+
+// writeRows encodes the rows of band, converting from premultiplied alpha.
+func (p *pngStream) writeRows(band *image.RGBA) error {
+	b := band.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := band.Pix[(y-b.Min.Y)*band.Stride:]
+		for i := 0; i < len(p.cur); i += 4 {
+			r, g, bl, a := row[i], row[i+1], row[i+2], row[i+3]
+			if a != 0 && a != 255 {
+				r = byte(uint(r) * 255 / uint(a))
+				g = byte(uint(g) * 255 / uint(a))
+				bl = byte(uint(bl) * 255 / uint(a))
+			}
+			p.cur[i], p.cur[i+1], p.cur[i+2], p.cur[i+3] = r, g, bl, a
+		}
+		if _, err := p.z.Write(p.filter()); err != nil {
+			return err
+		}
+		p.prev, p.cur = p.cur, p.prev
+	}
+	return nil
+}
+
+// filter applies each PNG filter to the current row and picks the one with
+// the smallest sum of absolute differences, as image/png does.
+func (p *pngStream) filter() []byte {
+	cur, prev := p.cur, p.prev
+	best, bestSum := 0, -1
+	for ft, out := range p.candidates {
+		sum := 0
+		for i := range cur {
+			var left, upLeft byte
+			if i >= 4 {
+				left, upLeft = cur[i-4], prev[i-4]
+			}
+			up := prev[i]
+			var v byte
+			switch ft {
+			case 0:
+				v = cur[i]
+			case 1:
+				v = cur[i] - left
+			case 2:
+				v = cur[i] - up
+			case 3:
+				v = cur[i] - byte((int(left)+int(up))/2)
+			case 4:
+				v = cur[i] - paeth(left, up, upLeft)
+			}
+			out[1+i] = v
+			sum += abs(int(int8(v)))
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = ft, sum
+		}
+	}
+	return p.candidates[best]
+}
+
+func paeth(a, b, c byte) byte {
+	pa := abs(int(b) - int(c))
+	pb := abs(int(a) - int(c))
+	pc := abs(int(a) + int(b) - 2*int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (p *pngStream) close() error {
+	if err := p.z.Close(); err != nil {
+		return err
+	}
+	if err := p.idat.flush(); err != nil {
+		return err
+	}
+	_, err := p.w.Write(pngChunk("IEND", nil))
+	return err
+}
+
+// idatWriter splits the compressed stream into IDAT chunks.
+type idatWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func (iw *idatWriter) Write(b []byte) (int, error) {
+	iw.buf = append(iw.buf, b...)
+	if len(iw.buf) >= 1<<20 {
+		return len(b), iw.flush()
+	}
+	return len(b), nil
+}
+
+func (iw *idatWriter) flush() error {
+	if len(iw.buf) == 0 {
+		return nil
+	}
+	_, err := iw.w.Write(pngChunk("IDAT", iw.buf))
+	iw.buf = iw.buf[:0]
+	return err
+}
+
+// TileStatus is the progress of the running tiled PNG save, or how the last
+// one ended, as shown in the Builtins panel.
+func (s *Sketch) TileStatus() string {
+	v, _ := s.tileStatus.Load().(string)
+	return v
+}
+
+// setTileStatus is called from the save worker, so the status is atomic.
+func (s *Sketch) setTileStatus(msg string) {
+	s.tileStatus.Store(msg)
+}
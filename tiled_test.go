@@ -0,0 +1,118 @@
+package sketchy
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aldernero/gaul/render"
+)
+
+func TestWriteTiledPNG(t *testing.T) {
+	const w, h = 37, 50
+	// Premultiplied pixels with some translucency, drawn in absolute
+	// coordinates, so a band in the wrong place shows.
+	at := func(x, y int) color.RGBA {
+		a := uint8(255 - 3*y)
+		return color.RGBA{R: uint8(x * 6 * int(a) / 255), G: uint8(y * 5 * int(a) / 255), A: a}
+	}
+	var bands []image.Rectangle
+	var done []int
+	var b bytes.Buffer
+	err := writeTiledPNG(&b, w, h, 16, 300, func(band *image.RGBA) {
+		bands = append(bands, band.Bounds())
+		r := band.Bounds()
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				band.SetRGBA(x, y, at(x, y))
+			}
+		}
+	}, func(n, total int) { done = append(done, n) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bands) != 4 || bands[3] != image.Rect(0, 48, w, h) || done[3] != h {
+		t.Fatalf("bands = %v, progress = %v", bands, done)
+	}
+
+	img, err := png.Decode(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, w, h) {
+		t.Fatalf("bounds = %v", img.Bounds())
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			want := at(x, y)
+			r, g, bl, a := img.At(x, y).RGBA()
+			if d := int(r>>8) - int(want.R); a>>8 != uint32(want.A) || d < -1 || d > 1 || bl != 0 || abs(int(g>>8)-int(want.G)) > 1 {
+				t.Fatalf("pixel %d,%d = %v, want %v", x, y, img.At(x, y), want)
+			}
+		}
+	}
+	if !bytes.Contains(b.Bytes(), []byte("pHYs")) {
+		t.Fatal("no pHYs chunk")
+	}
+}
+
+func TestRenderTiledPNGMatchesUnbanded(t *testing.T) {
+	// Shapes crossing band edges, one of them translucent.
+	s := newTestSketch(60, 50, func(_ *Sketch, c *render.Context) {
+		c.SetFillColor(color.RGBA{255, 0, 0, 255})
+		c.SetStrokeColor(nil)
+		c.DrawCircle(30, 25, 18)
+		c.Fill()
+		c.SetStrokeColor(color.RGBA{0, 0, 128, 128})
+		c.SetStrokeWidth(3)
+		c.MoveTo(0, 3)
+		c.LineTo(60, 47)
+		c.Stroke()
+	})
+	s.renderFrame()
+	dir := t.TempDir()
+	whole := filepath.Join(dir, "whole.png")
+	if err := s.renderPNGToFile(whole, 2*DefaultDPI); err != nil {
+		t.Fatal(err)
+	}
+
+	// 16-row bands: seven of them, the last one partial.
+	const w, h = 120, 100
+	defer func(n int) { tiledBandBytes = n }(tiledBandBytes)
+	tiledBandBytes = 4 * w * 16
+	tiled := filepath.Join(dir, "tiled.png")
+	if err := s.renderTiledPNGToFile(tiled, 2, w, h); err != nil {
+		t.Fatal(err)
+	}
+
+	decode := func(path string) image.Image {
+		t.Helper()
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		img, err := png.Decode(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	want, got := decode(whole), decode(tiled)
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("tiled bounds %v, unbanded %v", got.Bounds(), want.Bounds())
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+			u := color.NRGBAModel.Convert(want.At(x, y)).(color.NRGBA)
+			if abs(int(g.R)-int(u.R)) > 1 || abs(int(g.G)-int(u.G)) > 1 || abs(int(g.B)-int(u.B)) > 1 || g.A != u.A {
+				t.Fatalf("pixel %d,%d = %v, unbanded %v", x, y, g, u)
+			}
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package sketchy
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Easing shapes how a keyframed value moves from its key to the next.
+type Easing int
+
+const (
+	EaseLinear Easing = iota
+	EaseIn            // slow start (cubic)
+	EaseOut           // slow finish (cubic)
+	EaseInOut         // slow start and finish (cubic)
+	EaseHold          // keep the key's value until the next key
+)
+
+var (
+	easingNames  = []string{"linear", "in", "out", "in-out", "hold"}
+	easingLabels = []string{"Linear", "Ease in", "Ease out", "Ease in-out", "Hold"}
+)
+
+// MarshalText stores an Easing by name in snapshot JSON.
+func (e Easing) MarshalText() ([]byte, error) {
+	if e < 0 || int(e) >= len(easingNames) {
+		return nil, fmt.Errorf("sketchy: unknown easing %d", e)
+	}
+	return []byte(easingNames[e]), nil
+}
+
+// UnmarshalText reads an Easing name; unknown names are linear.
+func (e *Easing) UnmarshalText(b []byte) error {
+	*e = EaseLinear
+	if i := slices.Index(easingNames, string(b)); i >= 0 {
+		*e = Easing(i)
+	}
+	return nil
+}
+
+// ease maps t in [0, 1] along the curve.
+func (e Easing) ease(t float64) float64 {
+	switch e {
+	case EaseIn:
+		return t * t * t
+	case EaseOut:
+		u := 1 - t
+		return 1 - u*u*u
+	case EaseInOut:
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		u := 2 - 2*t
+		return 1 - u*u*u/2
+	case EaseHold:
+		return 0
+	}
+	return t
+}
+
+// Keyframe is a control's value at a timeline tick: Value for float and int
+// sliders, Color ("#RRGGBB") for color pickers. Ease shapes the way to the
+// next key.
+type Keyframe struct {
+	Tick  int64   `json:"tick"`
+	Value float64 `json:"value,omitempty"`
+	Color string  `json:"color,omitempty"`
+	Ease  Easing  `json:"ease"`
+}
+
+// defaultTimelineSpan is the scrubber's range, in ticks, while the
+// timeline has no length and no keys past it.
+const defaultTimelineSpan = 240
+
+// timeline animates float sliders, int sliders and color pickers from
+// keyframes. Its playhead is separate from Tick: it advances once per tick
+// while playing, and the scrubber moves it.
+type timeline struct {
+	tracks map[string][]Keyframe // by control key, sorted by Tick
+	head   int64
+	// length is where playback stops, or with loop, the period it wraps
+	// at: ticks 0…length-1, so a key at length matching the one at 0 loops
+	// seamlessly. Zero plays to the last key.
+	length   int64
+	loop     bool
+	playing  bool
+	scrubbed bool // the head moved while paused; apply on the next tick
+
+	// Builtins panel state (timeline_ui.go).
+	selIdx    int
+	easeIdx   int
+	headInt   int
+	lengthInt int
+}
+
+// timelinePayload is the timeline in snapshot control_json (schema 4).
+type timelinePayload struct {
+	Length int64                 `json:"length,omitempty"`
+	Loop   bool                  `json:"loop,omitempty"`
+	Tracks map[string][]Keyframe `json:"tracks"`
+}
+
+// end is the last tick the timeline plays to: its length, else its last
+// key.
+func (tl *timeline) end() int64 {
+	if tl.length > 0 {
+		return tl.length
+	}
+	var last int64
+	for _, keys := range tl.tracks {
+		last = max(last, keys[len(keys)-1].Tick)
+	}
+	return last
+}
+
+// span is the scrubber's range.
+func (tl *timeline) span() int64 {
+	if e := tl.end(); e > 0 {
+		return max(e, tl.head)
+	}
+	return max(defaultTimelineSpan, tl.head)
+}
+
+// setKey adds k to key's track, replacing a key at the same tick.
+func (tl *timeline) setKey(key string, k Keyframe) {
+	if tl.tracks == nil {
+		tl.tracks = make(map[string][]Keyframe)
+	}
+	keys := tl.tracks[key]
+	i, found := slices.BinarySearchFunc(keys, k.Tick, compareKeyTick)
+	if found {
+		keys[i] = k
+	} else {
+		keys = slices.Insert(keys, i, k)
+	}
+	tl.tracks[key] = keys
+}
+
+// deleteKey removes key's keyframe at tick, reporting whether there was one.
+func (tl *timeline) deleteKey(key string, tick int64) bool {
+	keys := tl.tracks[key]
+	i := slices.IndexFunc(keys, func(k Keyframe) bool { return k.Tick == tick })
+	if i < 0 {
+		return false
+	}
+	keys = slices.Delete(keys, i, i+1)
+	if len(keys) == 0 {
+		delete(tl.tracks, key)
+	} else {
+		tl.tracks[key] = keys
+	}
+	return true
+}
+
+func compareKeyTick(k Keyframe, tick int64) int {
+	return cmp.Compare(k.Tick, tick)
+}
+
+// keysAround is the pair of keys around tick and how far along (eased) tick is
+// from the first to the second. Outside the keys, both are the nearest.
+func keysAround(keys []Keyframe, tick int64) (a, b Keyframe, t float64) {
+	i, found := slices.BinarySearchFunc(keys, tick, compareKeyTick)
+	switch {
+	case found:
+		return keys[i], keys[i], 0
+	case i == 0:
+		return keys[0], keys[0], 0
+	case i == len(keys):
+		return keys[i-1], keys[i-1], 0
+	}
+	a, b = keys[i-1], keys[i]
+	return a, b, a.Ease.ease(float64(tick-a.Tick) / float64(b.Tick-a.Tick))
+}
+
+// payload is the timeline for a snapshot, or nil when it has no keys.
+func (tl *timeline) payload() *timelinePayload {
+	if len(tl.tracks) == 0 {
+		return nil
+	}
+	tracks := make(map[string][]Keyframe, len(tl.tracks))
+	for k, keys := range tl.tracks {
+		tracks[k] = slices.Clone(keys)
+	}
+	return &timelinePayload{Length: tl.length, Loop: tl.loop, Tracks: tracks}
+}
+
+// restoreTimeline replaces the timeline's keys and length with a
+// snapshot's (none when p is nil), returning the tracks whose control is
+// gone; those are dropped.
+func (s *Sketch) restoreTimeline(p *timelinePayload) (missing []string) {
+	tl := &s.timeline
+	tl.tracks = nil
+	tl.length, tl.loop = 0, false
+	if p == nil {
+		return nil
+	}
+	tl.length, tl.loop = max(p.Length, 0), p.Loop
+	for k, keys := range p.Tracks {
+		if !s.isAnimatable(k) {
+			missing = append(missing, k)
+			continue
+		}
+		for _, kf := range keys {
+			tl.setKey(k, kf)
+		}
+	}
+	return missing
+}
+
+// isAnimatable reports whether the control key names a float slider, int
+// slider or color picker.
+func (s *Sketch) isAnimatable(k string) bool {
+	if _, ok := s.floatSliderControlMap[k]; ok {
+		return true
+	}
+	if _, ok := s.intSliderControlMap[k]; ok {
+		return true
+	}
+	_, ok := s.colorPickerControlMap[k]
+	return ok
+}
+
+// applyTimeline sets every keyframed control to its value at the head.
+// The controls' change detection then flags DidControlsChange, as a panel
+// edit would; the frame is marked dirty here too, for headless renders,
+// which have no change detection.
+func (s *Sketch) applyTimeline() {
+	if len(s.timeline.tracks) > 0 {
+		s.dirty = true
+	}
+	for k, keys := range s.timeline.tracks {
+		a, b, t := keysAround(keys, s.timeline.head)
+		if i, ok := s.floatSliderControlMap[k]; ok {
+			s.FloatSliders[i].Val = a.Value + (b.Value-a.Value)*t
+		} else if i, ok := s.intSliderControlMap[k]; ok {
+			s.IntSliders[i].Val = int(math.Round(a.Value + (b.Value-a.Value)*t))
+		} else if i, ok := s.colorPickerControlMap[k]; ok {
+			ca, err := colorful.Hex(a.Color)
+			if err != nil {
+				continue
+			}
+			cb, err := colorful.Hex(b.Color)
+			if err != nil {
+				cb = ca
+			}
+			r, g, bl := ca.BlendLab(cb, t).Clamped().RGB255()
+			cp := &s.ColorPickers[i]
+			cp.r, cp.g, cp.b = int(r), int(g), int(bl)
+		}
+	}
+}
+
+// updateTimeline runs once per tick from Update, before UpdateControls (and
+// per headless tick): it applies the keyframes at the head while playing
+// (or after a scrub) and advances the head.
+func (s *Sketch) updateTimeline() {
+	tl := &s.timeline
+	if !tl.playing && !tl.scrubbed {
+		return
+	}
+	tl.scrubbed = false
+	s.applyTimeline()
+	if !tl.playing {
+		return
+	}
+	tl.head++
+	end := tl.end()
+	switch {
+	case end <= 0:
+	case tl.loop && tl.head >= end:
+		tl.head = 0
+	case !tl.loop && tl.head > end:
+		tl.head = end
+		tl.playing = false
+	}
+}
+
+// timelineKey resolves a control for the keyframe API, exiting like
+// SetFloat does for an unknown one.
+func (s *Sketch) timelineKey(folder, name string, m map[string]int, kind string) string {
+	k := controlMapKey(folder, name)
+	if _, ok := m[k]; !ok {
+		log.Fatalf("%q is not a %s", k, kind)
+	}
+	return k
+}
+
+// SetFloatKey keys a float slider to v at tick of the timeline, replacing
+// any key it has there. ease shapes the move to the next key.
+func (s *Sketch) SetFloatKey(folder, name string, tick int64, v float64, ease Easing) {
+	k := s.timelineKey(folder, name, s.floatSliderControlMap, "float slider")
+	s.timeline.setKey(k, Keyframe{Tick: tick, Value: v, Ease: ease})
+}
+
+// SetIntKey keys an int slider to v at tick; values between keys round to
+// the nearest int.
+func (s *Sketch) SetIntKey(folder, name string, tick int64, v int, ease Easing) {
+	k := s.timelineKey(folder, name, s.intSliderControlMap, "int slider")
+	s.timeline.setKey(k, Keyframe{Tick: tick, Value: float64(v), Ease: ease})
+}
+
+// SetColorKey keys a color picker to hex ("#RRGGBB") at tick; colors
+// between keys blend in Lab space.
+func (s *Sketch) SetColorKey(folder, name string, tick int64, hex string, ease Easing) {
+	k := s.timelineKey(folder, name, s.colorPickerControlMap, "color picker")
+	s.timeline.setKey(k, Keyframe{Tick: tick, Color: colorToRGBHex(stringToColor(hex)), Ease: ease})
+}
+
+// ClearKeys removes every keyframe of a control.
+func (s *Sketch) ClearKeys(folder, name string) {
+	delete(s.timeline.tracks, controlMapKey(folder, name))
+}
+
+// SetTimelineLength sets where playback stops (or, with loop, wraps: ticks
+// 0 to ticks-1 repeat). Zero plays to the last key.
+func (s *Sketch) SetTimelineLength(ticks int64, loop bool) {
+	s.timeline.length, s.timeline.loop = max(ticks, 0), loop
+}
+
+// PlayTimeline starts or pauses playback from the current playhead.
+func (s *Sketch) PlayTimeline(play bool) {
+	s.timeline.playing = play
+}
+
+// SeekTimeline moves the playhead to tick and applies the keys there on
+// the next tick.
+func (s *Sketch) SeekTimeline(tick int64) {
+	s.timeline.head = max(tick, 0)
+	s.timeline.scrubbed = true
+}
+
+// TimelineTick is the playhead's tick.
+func (s *Sketch) TimelineTick() int64 {
+	return s.timeline.head
+}
@@ -0,0 +1,166 @@
+package sketchy
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// timelineControls adds the controls the timeline tests key.
+func timelineControls(ui *UI) {
+	ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+	ui.IntSlider("Count", 0, 100, 0, 1)
+	ui.ColorPicker("Tint", "#000000")
+}
+
+func TestEasingCurves(t *testing.T) {
+	for _, e := range []Easing{EaseLinear, EaseIn, EaseOut, EaseInOut} {
+		if e.ease(0) != 0 || e.ease(1) != 1 {
+			t.Errorf("%d: ends at %v, %v", e, e.ease(0), e.ease(1))
+		}
+	}
+	if v := EaseIn.ease(0.5); v >= 0.5 {
+		t.Errorf("ease in at half = %v", v)
+	}
+	if v := EaseOut.ease(0.5); v <= 0.5 {
+		t.Errorf("ease out at half = %v", v)
+	}
+	if v := EaseInOut.ease(0.5); math.Abs(v-0.5) > 1e-12 {
+		t.Errorf("ease in-out at half = %v", v)
+	}
+	if EaseHold.ease(0.99) != 0 {
+		t.Error("hold moved before the next key")
+	}
+
+	b, err := json.Marshal(Keyframe{Tick: 3, Value: 1, Ease: EaseInOut})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"tick":3,"value":1,"ease":"in-out"}` {
+		t.Fatalf("keyframe JSON %s", b)
+	}
+	var kf Keyframe
+	if err := json.Unmarshal([]byte(`{"tick":1,"ease":"bounce"}`), &kf); err != nil || kf.Ease != EaseLinear {
+		t.Fatalf("unknown easing read as %d (%v)", kf.Ease, err)
+	}
+}
+
+func TestTimelineInterpolation(t *testing.T) {
+	s := newTextBoxSketch(t, timelineControls)
+	s.SetFloatKey("", "Scale", 0, 0, EaseLinear)
+	s.SetFloatKey("", "Scale", 10, 5, EaseLinear)
+	s.SetIntKey("", "Count", 0, 0, EaseLinear)
+	s.SetIntKey("", "Count", 10, 3, EaseLinear)
+	s.SetColorKey("", "Tint", 0, "#ff0000", EaseHold)
+	s.SetColorKey("", "Tint", 10, "#0000ff", EaseLinear)
+
+	s.SeekTimeline(5)
+	s.updateTimeline()
+	if v := s.FloatSliders[0].Val; math.Abs(v-2.5) > 1e-9 {
+		t.Errorf("scale at 5 = %v, want 2.5", v)
+	}
+	if v := s.IntSliders[0].Val; v != 2 { // 1.5 rounds up
+		t.Errorf("count at 5 = %d, want 2", v)
+	}
+	if h := s.ColorPickers[0].GetHex(); h != "#FF0000" {
+		t.Errorf("held tint at 5 = %s", h)
+	}
+
+	// Past the last key, controls keep its value.
+	s.SeekTimeline(50)
+	s.updateTimeline()
+	if s.FloatSliders[0].Val != 5 || s.ColorPickers[0].GetHex() != "#0000FF" {
+		t.Errorf("after the keys: scale %v tint %s", s.FloatSliders[0].Val, s.ColorPickers[0].GetHex())
+	}
+
+	// Keying the same tick replaces the key.
+	s.SetFloatKey("", "Scale", 10, 8, EaseLinear)
+	if keys := s.timeline.tracks["Scale"]; len(keys) != 2 || keys[1].Value != 8 {
+		t.Fatalf("track %+v", keys)
+	}
+}
+
+func TestTimelinePlayback(t *testing.T) {
+	s := newTextBoxSketch(t, func(ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+	})
+	s.SetFloatKey("", "Scale", 0, 0, EaseLinear)
+	s.SetFloatKey("", "Scale", 4, 4, EaseLinear)
+
+	// Without a length, playback stops at the last key.
+	s.PlayTimeline(true)
+	for range 10 {
+		s.updateTimeline()
+	}
+	if s.timeline.playing || s.TimelineTick() != 4 || s.FloatSliders[0].Val != 4 {
+		t.Fatalf("playing %v at %d, scale %v", s.timeline.playing, s.TimelineTick(), s.FloatSliders[0].Val)
+	}
+
+	// Looping over 4 ticks plays 0, 1, 2, 3, 0, …
+	s.SetTimelineLength(4, true)
+	s.SeekTimeline(0)
+	s.PlayTimeline(true)
+	var got []float64
+	for range 6 {
+		s.updateTimeline()
+		got = append(got, s.FloatSliders[0].Val)
+	}
+	want := []float64{0, 1, 2, 3, 0, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("looped values %v, want %v", got, want)
+		}
+	}
+
+	// History waits for playback to stop.
+	s.trackHistory()
+	s.DidControlsChange = true
+	s.trackHistory()
+	if len(s.history.undo) != 0 {
+		t.Fatal("history committed during playback")
+	}
+}
+
+func TestTimelineSnapshotRoundTrip(t *testing.T) {
+	src := newTextBoxSketch(t, timelineControls)
+	src.SetFloatKey("", "Scale", 0, 1, EaseIn)
+	src.SetColorKey("", "Tint", 30, "#336699", EaseOut)
+	src.SetTimelineLength(60, true)
+	data, err := src.serializeControlState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTextBoxSketch(t, timelineControls)
+	dst.SetIntKey("", "Count", 5, 5, EaseLinear) // replaced by the snapshot's
+	missing, err := dst.applyControlStateJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) > 0 {
+		t.Fatalf("missing %v", missing)
+	}
+	tl := dst.timeline
+	if tl.length != 60 || !tl.loop || len(tl.tracks) != 2 {
+		t.Fatalf("restored length %d loop %v tracks %v", tl.length, tl.loop, tl.tracks)
+	}
+	if k := tl.tracks["Tint"][0]; k.Tick != 30 || k.Color != "#336699" || k.Ease != EaseOut {
+		t.Fatalf("tint key %+v", k)
+	}
+
+	// A track whose control is gone is reported and dropped.
+	other := newTextBoxSketch(t, func(ui *UI) {
+		ui.FloatSlider("Scale", 0, 10, 1, 0.1)
+	})
+	missing, err = other.applyControlStateJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, m := range missing {
+		found = found || m == "Tint"
+	}
+	if !found || len(other.timeline.tracks) != 1 {
+		t.Fatalf("missing %v, tracks %v", missing, other.timeline.tracks)
+	}
+}
@@ -0,0 +1,200 @@
+package sketchy
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+
+	"github.com/aldernero/debugui"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// timelineScrubberHeight is the height of the Builtins timeline scrubber.
+const timelineScrubberHeight = 28
+
+// Scrubber colors: the track, keys of other controls, keys of the selected
+// control, and the playhead.
+var (
+	scrubberBG       = color.RGBA{0x1e, 0x1e, 0x1e, 0xff}
+	scrubberKey      = color.RGBA{0x70, 0x70, 0x70, 0xff}
+	scrubberSelKey   = color.RGBA{0xf0, 0xb4, 0x3c, 0xff}
+	scrubberPlayhead = color.RGBA{0xe8, 0x4a, 0x4a, 0xff}
+)
+
+// animatableControls lists the keys of the controls the timeline can
+// animate: float and int sliders and the sketch's color pickers.
+func (s *Sketch) animatableControls() []string {
+	var keys []string
+	for i := range s.FloatSliders {
+		keys = append(keys, controlMapKey(s.FloatSliders[i].Folder, s.FloatSliders[i].Name))
+	}
+	for i := range s.IntSliders {
+		keys = append(keys, controlMapKey(s.IntSliders[i].Folder, s.IntSliders[i].Name))
+	}
+	for i := range s.ColorPickers {
+		if i == s.builtinColorBGIdx || i == s.builtinColorFGIdx {
+			continue
+		}
+		keys = append(keys, controlMapKey(s.ColorPickers[i].Folder, s.ColorPickers[i].Name))
+	}
+	return keys
+}
+
+// keyAtHead keys control k to its current value at the playhead, with the
+// panel's easing.
+func (s *Sketch) keyAtHead(k string) {
+	tl := &s.timeline
+	kf := Keyframe{Tick: tl.head, Ease: Easing(tl.easeIdx)}
+	if i, ok := s.floatSliderControlMap[k]; ok {
+		kf.Value = s.FloatSliders[i].Val
+	} else if i, ok := s.intSliderControlMap[k]; ok {
+		kf.Value = float64(s.IntSliders[i].Val)
+	} else if i, ok := s.colorPickerControlMap[k]; ok {
+		kf.Color = s.ColorPickers[i].GetHex()
+	} else {
+		return
+	}
+	tl.setKey(k, kf)
+	s.history.pending = true
+}
+
+// drawBuiltinTimelineRows is the Timeline section: a scrubber showing every
+// control's keys (the selected control's highlighted) and the playhead,
+// play and key buttons, and the selected control, easing, playhead and
+// length.
+func (s *Sketch) drawBuiltinTimelineRows(ctx *debugui.Context) {
+	ctx.IDScope("timeline", func() {
+		ctx.Header("Timeline", false, func() {
+			names := s.animatableControls()
+			ctx.SetGridLayout([]int{-1}, nil)
+			if len(names) == 0 {
+				ctx.Text("No sliders or colors to animate")
+				return
+			}
+			tl := &s.timeline
+			tl.selIdx = clampInt(tl.selIdx, 0, len(names)-1)
+			sel := names[tl.selIdx]
+
+			ctx.SetGridLayout([]int{-1}, []int{timelineScrubberHeight})
+			ctx.IDScope("scrubber", func() {
+				ctx.DragArea(
+					func(screen *ebiten.Image, bounds image.Rectangle) {
+						s.drawScrubber(screen, bounds, ctx.Scale(), sel)
+					},
+					func(bounds image.Rectangle, pos image.Point) bool {
+						dx := bounds.Dx()
+						if dx <= 1 {
+							return false
+						}
+						span := tl.span()
+						t := int64(math.Round(float64(pos.X-bounds.Min.X) / float64(dx-1) * float64(span)))
+						t = min(max(t, 0), span)
+						if t == tl.head {
+							return false
+						}
+						tl.head = t
+						return true
+					},
+				).On(func() {
+					tl.scrubbed = true
+				})
+			})
+
+			ctx.SetGridLayout([]int{-1, -1, -1}, nil)
+			label := "Play"
+			if tl.playing {
+				label = "Pause"
+			}
+			ctx.IDScope("play", func() {
+				ctx.Button(label).On(func() { tl.playing = !tl.playing })
+			})
+			ctx.IDScope("setKey", func() {
+				ctx.Button("Set key").On(func() { s.keyAtHead(sel) })
+			})
+			ctx.IDScope("deleteKey", func() {
+				ctx.Button("Delete key").On(func() {
+					if tl.deleteKey(sel, tl.head) {
+						s.history.pending = true
+					}
+				})
+			})
+
+			ctx.SetGridLayout([]int{ControlLabelColumnWidth, -1}, nil)
+			ctx.Text("Control")
+			ctx.IDScope("control", func() {
+				ctx.Dropdown(&tl.selIdx, names)
+			})
+			ctx.Text("Ease")
+			ctx.IDScope("ease", func() {
+				ctx.Dropdown(&tl.easeIdx, easingLabels).On(func() {
+					// Re-ease the selected control's key under the playhead.
+					keys := tl.tracks[sel]
+					if i := slices.IndexFunc(keys, func(k Keyframe) bool { return k.Tick == tl.head }); i >= 0 {
+						keys[i].Ease = Easing(tl.easeIdx)
+						s.history.pending = true
+					}
+				})
+			})
+			ctx.Text("Tick")
+			tl.headInt = int(tl.head)
+			ctx.IDScope("head", func() {
+				ctx.NumberField(&tl.headInt, 1).On(func() {
+					tl.head = int64(max(tl.headInt, 0))
+					tl.scrubbed = true
+				})
+			})
+			ctx.Text("Length")
+			tl.lengthInt = int(tl.length)
+			ctx.IDScope("length", func() {
+				ctx.NumberField(&tl.lengthInt, 1).On(func() {
+					tl.length = int64(max(tl.lengthInt, 0))
+					s.history.pending = true
+				})
+			})
+			ctx.SetGridLayout([]int{-1}, nil)
+			ctx.IDScope("loop", func() {
+				ctx.Checkbox(&tl.loop, "Loop").On(func() { s.history.pending = true })
+			})
+			n := 0
+			for _, keys := range tl.tracks {
+				n += len(keys)
+			}
+			ctx.Text(fmt.Sprintf("%d keys on %s; %d on %d controls", len(tl.tracks[sel]), sel, n, len(tl.tracks)))
+		})
+	})
+}
+
+// drawScrubber paints the timeline track in bounds (panel units; scale
+// converts to screen pixels).
+func (s *Sketch) drawScrubber(screen *ebiten.Image, bounds image.Rectangle, scale int, sel string) {
+	tl := &s.timeline
+	dx, dy := bounds.Dx(), bounds.Dy()
+	if dx <= 1 || dy <= 0 {
+		return
+	}
+	sc := float32(scale)
+	rect := func(x, y, w, h float32, c color.Color) {
+		vector.FillRect(screen, x*sc, y*sc, w*sc, h*sc, c, false)
+	}
+	x0, y0 := float32(bounds.Min.X), float32(bounds.Min.Y)
+	rect(x0, y0, float32(dx), float32(dy), scrubberBG)
+	span := float32(tl.span())
+	xAt := func(tick int64) float32 {
+		return x0 + float32(tick)/span*float32(dx-1)
+	}
+	for k, keys := range tl.tracks {
+		if k == sel {
+			continue
+		}
+		for _, kf := range keys {
+			rect(xAt(kf.Tick), y0+float32(dy)*2/3, 1, float32(dy)/3, scrubberKey)
+		}
+	}
+	for _, kf := range tl.tracks[sel] {
+		rect(xAt(kf.Tick)-1, y0+float32(dy)/4, 3, float32(dy)/2, scrubberSelKey)
+	}
+	rect(xAt(tl.head), y0, 1, float32(dy), scrubberPlayhead)
+}